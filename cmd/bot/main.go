@@ -1,63 +1,210 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/japersik/safe-flight-bot/internal/api"
+	"github.com/japersik/safe-flight-bot/internal/calendar"
+	"github.com/japersik/safe-flight-bot/internal/config"
 	"github.com/japersik/safe-flight-bot/internal/flyDataClient"
 	"github.com/japersik/safe-flight-bot/internal/flyDataClient/avtmClient"
 	"github.com/japersik/safe-flight-bot/internal/flyDataClient/openstreetmapClient"
 	"github.com/japersik/safe-flight-bot/internal/flyPlanner"
+	"github.com/japersik/safe-flight-bot/internal/health"
+	"github.com/japersik/safe-flight-bot/internal/logbook"
+	"github.com/japersik/safe-flight-bot/internal/mapRenderer"
+	"github.com/japersik/safe-flight-bot/internal/metrics"
+	"github.com/japersik/safe-flight-bot/internal/ratelimit"
 	"github.com/japersik/safe-flight-bot/internal/telegram"
+	"github.com/japersik/safe-flight-bot/internal/tracing"
+	"github.com/japersik/safe-flight-bot/internal/userStorage"
 	"github.com/japersik/safe-flight-bot/logger"
+	"github.com/japersik/safe-flight-bot/model"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"net/http"
 	"os"
 	"os/signal"
+	"path"
 	"syscall"
+	"time"
 )
 
 func main() {
+	configPath := flag.String("config", config.DefaultPath(), "path to YAML config file")
+	flag.Parse()
+	cfg, err := config.Load(*configPath, config.PathRequired(*configPath))
+	if err == nil {
+		err = cfg.ValidateBot()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	model.SetDefaults(cfg.ModelDefaults())
 
 	// logger setup
-	zLog := zap.New(zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.Lock(os.Stdout), zap.NewAtomicLevel()))
+	logLevel := zap.NewAtomicLevelAt(cfg.LogLevel())
+	zLog := zap.New(zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.Lock(os.Stdout), logLevel))
 	logger.NewInstance(logger.NewZapLogger(zLog))
 	logger.Info("program started")
 	//signals setup
-	shutdownSignal := make(chan os.Signal, 1)
-	signal.Notify(shutdownSignal, syscall.SIGQUIT, syscall.SIGINT, syscall.SIGTERM)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGQUIT, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	//tbBot setup
-	tgBotToken := os.Getenv("TG_BOT_TOKEN")
-	if tgBotToken == "" {
-		logger.Fatal("Empty env variable TG_BOT_TOKEN")
+	//tracing setup
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing.Exporter, cfg.Tracing.Endpoint, cfg.Tracing.SampleRatio)
+	if err != nil {
+		logger.Fatal("tracing setup error", logger.Err(err))
 	}
-	bot, err := tgbotapi.NewBotAPI(tgBotToken)
+
+	//tbBot setup
+	//спаны запросов к Bot API: имя метода из последнего элемента пути, адрес с токеном не записывается
+	telegramEndpoint := func(r *http.Request) string { return path.Base(r.URL.Path) }
+	telegramClient := &http.Client{Transport: tracing.OpaqueTransport("telegram", telegramEndpoint, nil)}
+	bot, err := tgbotapi.NewBotAPIWithClient(cfg.Telegram.Token, tgbotapi.APIEndpoint, telegramClient)
 	if err != nil {
-		return
+		logger.Fatal("bot api error", logger.Err(err))
 	}
 
 	//
-	avtm := avtmClient.NewAvtmClient()
-	maps := openstreetmapClient.NewOpenStreetClient()
+	avtm := avtmClient.NewAvtmClient(cfg.Services.Avtm.URL, cfg.Services.Avtm.Timeout)
+	maps := openstreetmapClient.NewOpenStreetClient(cfg.Services.Nominatim.URL, cfg.Services.Nominatim.Timeout)
+	//общий лимит одновременных запросов ко всем внешним сервисам
+	upstream := ratelimit.NewSemaphore(cfg.Limits.UpstreamConcurrency)
+	avtm.SetTransport(upstream.Transport(metrics.InstrumentTransport("avtm", metrics.PathEndpoint,
+		tracing.Transport("avtm", metrics.PathEndpoint, nil))))
+	maps.SetTransport(upstream.Transport(metrics.InstrumentTransport("nominatim", metrics.PathEndpoint,
+		tracing.Transport("nominatim", metrics.PathEndpoint, nil))))
 	flClient := flyDataClient.Client{WeatherInfoSource: avtm, ZoneInfoSource: avtm, LocalityInfoSource: maps}
 
 	//mission planner setup
-	planner := flyPlanner.NewPlaner("data/file.json")
+	planner := flyPlanner.NewPlaner(cfg.Storage.Plans)
+	planner.SetUpdateInterval(cfg.Planner.UpdateInterval)
+	planner.SetMaxUserPlans(cfg.Limits.MaxActivePlans)
+	//файл планов перезаписывается при остановке, поэтому его не должен изменять другой процесс
+	if err := planner.LockStore(); err != nil {
+		logger.Fatal("plans file lock error", logger.String("file", cfg.Storage.Plans), logger.Err(err))
+	}
+
+	//users data setup
+	users := userStorage.NewFileStorage(cfg.Storage.Users)
+	if err := users.Init(); err != nil {
+		logger.Fatal("users data file loading error", logger.Err(err))
+	}
+
+	//flight logbook setup
+	flights := logbook.NewFileLogbook(cfg.Storage.Logbook)
+	if err := flights.Init(); err != nil {
+		logger.Fatal("logbook file loading error", logger.Err(err))
+	}
 
-	myBot := telegram.NewBot(bot, flClient, planner)
+	myBot := telegram.NewBot(bot, flClient, planner, users, flights)
+	myBot.SetAdmins(cfg.Admins)
+	myBot.SetLogLevel(logLevel)
+	//лимит проверок общий для бота и REST API
+	checks := ratelimit.NewUserLimiter(cfg.Limits.Checks.PerMinute, cfg.Limits.Checks.Burst)
+	myBot.SetRateLimits(ratelimit.NewUserLimiter(cfg.Limits.Updates.PerMinute, cfg.Limits.Updates.Burst), checks)
+	renderer := mapRenderer.NewRenderer(cfg.Services.Tiles.URL, cfg.Services.Tiles.Timeout)
+	tileEndpoint := func(*http.Request) string { return "tile" }
+	renderer.SetTransport(upstream.Transport(metrics.InstrumentTransport("tiles", tileEndpoint,
+		tracing.Transport("tiles", tileEndpoint, nil))))
+	myBot.SetMapRenderer(renderer)
+
+	//hot reload of non-critical settings
+	watcher := config.NewWatcher(*configPath, cfg)
+	watcher.OnReload(func(cfg *config.Config) {
+		logLevel.SetLevel(cfg.LogLevel())
+		model.SetDefaults(cfg.ModelDefaults())
+		myBot.SetAdmins(cfg.Admins)
+	})
+	go watcher.Run(ctx)
+	//планы загружаются до запуска серверов, которые их читают и изменяют
 	planner.SetNotifier(myBot)
 	planner.Init()
 	planner.Start()
-	go func() {
-		<-shutdownSignal
-		err := planner.SavePlans()
-		if err != nil {
-			logger.Error("exit the program, data file save error ", err)
-		} else {
-			logger.Error("exit the program, the data file is saved")
-		}
-		os.Exit(0)
-	}()
-	if err := myBot.Start(); err != nil {
-		logger.Fatal("error bot starting: ", err)
+	//calendar feed setup
+	if addr := cfg.CalendarFeed.Addr; addr != "" {
+		myBot.SetCalendarFeedURL(cfg.CalendarFeed.URL)
+		go func() {
+			if err := calendar.ListenAndServe(addr, calendar.NewFeedHandler(planner, users)); err != nil {
+				logger.Error("calendar feed server error", logger.Err(err))
+			}
+		}()
+	}
+	//REST API setup
+	if addr := cfg.API.Addr; addr != "" {
+		myBot.SetAPIURL(cfg.API.URL)
+		apiServer := api.NewServer(flClient, planner, users)
+		apiServer.SetCheckLimiter(checks)
+		go func() {
+			if err := api.ListenAndServe(addr, apiServer); err != nil {
+				logger.Error("api server error", logger.Err(err))
+			}
+		}()
+	}
+	//metrics and probes setup
+	if addr := cfg.Metrics.Addr; addr != "" {
+		metrics.RegisterGauge("active_plans", "Active flight plans.", func() float64 {
+			return float64(len(planner.AllPlans()))
+		})
+		metrics.RegisterGauge("armed_timers", "Notification timers scheduled by the planner.", func() float64 {
+			return float64(planner.PendingNotifications())
+		})
+		metrics.RegisterGauge("planning_sessions", "Users planning a flight right now.", func() float64 {
+			return float64(myBot.PlanningSessions())
+		})
+		planStore := health.Check{Name: "plan_store", Probe: func(context.Context) error { return planner.CheckStore() }}
+		liveness := []health.Check{planStore}
+		readiness := []health.Check{planStore, {Name: "telegram", Probe: func(context.Context) error { return myBot.CheckTelegram() }}}
+		go func() {
+			if err := metrics.ListenAndServe(addr, liveness, readiness); err != nil {
+				logger.Error("metrics server error", logger.Err(err))
+			}
+		}()
+	}
+	//updates receiving: long polling or webhook (telegram.mode)
+	if cfg.Telegram.Mode == "webhook" {
+		webhook := cfg.Telegram.Webhook
+		err = myBot.StartWebhook(ctx, telegram.WebhookConfig{
+			ListenAddr:  webhook.Addr,
+			URL:         webhook.URL,
+			PathSecret:  webhook.PathSecret,
+			SecretToken: webhook.SecretToken,
+			CertFile:    webhook.TLSCert,
+			KeyFile:     webhook.TLSKey,
+			UploadCert:  webhook.UploadCert,
+		})
+	} else {
+		err = myBot.Start(ctx)
+	}
+	if err != nil {
+		//планы и данные пользователей уже загружены и могли измениться, поэтому сохраняются до выхода
+		saveData(planner, users, flights)
+		logger.Fatal("error bot starting", logger.Err(err))
+	}
+	saveData(planner, users, flights)
+	//ctx уже отменен сигналом, спаны отправляются с отдельным ограничением времени
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.Error("tracing shutdown error", logger.Err(err))
+	}
+	_ = logger.Sync()
+}
+
+func saveData(planner *flyPlanner.Planer, users *userStorage.FileStorage, flights *logbook.FileLogbook) {
+	if err := planner.SavePlans(); err != nil {
+		logger.Error("exit the program, data file save error", logger.Err(err))
+	} else {
+		logger.Info("exit the program, the data file is saved")
+	}
+	if err := users.Save(); err != nil {
+		logger.Error("users data file save error", logger.Err(err))
+	}
+	if err := flights.Save(); err != nil {
+		logger.Error("logbook file save error", logger.Err(err))
 	}
 }
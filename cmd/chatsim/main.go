@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"github.com/japersik/safe-flight-bot/internal/config"
+	"github.com/japersik/safe-flight-bot/internal/conversation"
+	"github.com/japersik/safe-flight-bot/internal/flyDataClient"
+	"github.com/japersik/safe-flight-bot/internal/flyDataClient/avtmClient"
+	"github.com/japersik/safe-flight-bot/internal/flyDataClient/openstreetmapClient"
+	"github.com/japersik/safe-flight-bot/internal/flyPlanner"
+	"github.com/japersik/safe-flight-bot/internal/simulator"
+	"github.com/japersik/safe-flight-bot/internal/userStorage"
+	"github.com/japersik/safe-flight-bot/logger"
+	"github.com/japersik/safe-flight-bot/model"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"os"
+)
+
+//chatsim консольный чат с ботом без Telegram. Данные хранятся отдельно от данных бота
+func main() {
+	cfg, err := config.Load(config.DefaultPath(), config.PathRequired(config.DefaultPath()))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	model.SetDefaults(cfg.ModelDefaults())
+	zLog := zap.New(zapcore.NewCore(zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig()), zapcore.Lock(os.Stderr), zap.NewAtomicLevelAt(zap.WarnLevel)))
+	logger.NewInstance(logger.NewZapLogger(zLog))
+
+	avtm := avtmClient.NewAvtmClient(cfg.Services.Avtm.URL, cfg.Services.Avtm.Timeout)
+	maps := openstreetmapClient.NewOpenStreetClient(cfg.Services.Nominatim.URL, cfg.Services.Nominatim.Timeout)
+	flClient := flyDataClient.Client{WeatherInfoSource: avtm, ZoneInfoSource: avtm, LocalityInfoSource: maps}
+
+	planner := flyPlanner.NewPlaner("data/chatsim.json")
+	planner.SetMaxUserPlans(cfg.Limits.MaxActivePlans)
+	users := userStorage.NewFileStorage("data/chatsim_users.json")
+	if err := users.Init(); err != nil {
+		logger.Fatal("users data file loading error", logger.Err(err))
+	}
+
+	core := conversation.NewCore(flClient, planner, users)
+	console := simulator.NewConsole(core, os.Stdin, os.Stdout, 1)
+	planner.SetNotifier(core)
+	planner.SetChangeListener(core)
+	planner.Init()
+	planner.Start()
+	if err := console.Run(); err != nil {
+		logger.Error("input reading error", logger.Err(err))
+	}
+	if err := planner.SavePlans(); err != nil {
+		logger.Error("data file save error", logger.Err(err))
+	}
+	if err := users.Save(); err != nil {
+		logger.Error("users data file save error", logger.Err(err))
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/japersik/safe-flight-bot/internal/flyDataClient"
+	"github.com/japersik/safe-flight-bot/model"
+	"io"
+	"strings"
+)
+
+//checkResult отчет с итоговой оценкой, формат совпадает с ответом /v1/check REST API
+type checkResult struct {
+	flyDataClient.Report
+	Status  string                `json:"status"`
+	Verdict flyDataClient.Verdict `json:"verdict"`
+}
+
+func runCheck(args []string, out io.Writer) (int, error) {
+	flags := newFlagSet("check")
+	lat := flags.Float64("lat", 0, "широта точки полёта")
+	lng := flags.Float64("lng", 0, "долгота точки полёта")
+	radius := flags.Int("radius", model.DefaultRadius(), "радиус района полёта, м")
+	altitude := flags.Int("alt", model.DefaultAltitude(), "высота полёта, м")
+	asJSON := flags.Bool("json", false, "вывод в формате JSON")
+	if err := parseFlags(flags, args); err != nil {
+		return 0, err
+	}
+	coordinate := model.Coordinate{Lat: *lat, Lng: *lng}
+	if err := validateCoordinate(flags, coordinate, isSet(flags, "lat", "lng")); err != nil {
+		return 0, err
+	}
+	if *radius <= 0 || *radius > model.MaxRadius {
+		return 0, usageError(flags, "--radius должен быть от 1 до %d", model.MaxRadius)
+	}
+	if *altitude <= 0 || *altitude > model.MaxAltitude {
+		return 0, usageError(flags, "--alt должна быть от 1 до %d", model.MaxAltitude)
+	}
+	report := newClient().GetReport(context.Background(), coordinate, *radius, *altitude)
+	verdict := report.Verdict()
+	result := checkResult{Report: report, Status: verdict.Level.Status(), Verdict: verdict}
+	if *asJSON {
+		return verdictCode(verdict.Level), writeJSON(out, result)
+	}
+	fmt.Fprint(out, checkText(result))
+	return verdictCode(verdict.Level), nil
+}
+
+func checkText(result checkResult) string {
+	var sb strings.Builder
+	report := result.Report
+	fmt.Fprintf(&sb, "Точка: %.6f, %.6f, радиус %d м, высота %d м\n", report.Coordinate.Lat, report.Coordinate.Lng,
+		report.Radius, report.Altitude)
+	fmt.Fprintf(&sb, "Итог: %s (%s)\n", result.Status, result.Verdict.Level)
+	for _, reason := range result.Verdict.Reasons {
+		fmt.Fprintf(&sb, "  - %s\n", reason)
+	}
+	if report.Locality != nil {
+		fmt.Fprintf(&sb, "Населенный пункт: %s\n", report.Locality.Name)
+	} else {
+		sb.WriteString("Населенный пункт: нет данных\n")
+	}
+	if report.Condition != nil {
+		writeZones(&sb, "Действующие зоны", report.Condition.ActiveZones)
+		writeZones(&sb, "Недействующие зоны", report.Condition.InactiveZones)
+	}
+	if report.Weather != nil {
+		sb.WriteString("Погода: " + weatherText(report.Weather.Current) + "\n")
+	} else {
+		sb.WriteString("Погода: нет данных\n")
+	}
+	return sb.String()
+}
+
+func writeZones(sb *strings.Builder, title string, zones []model.Zone) {
+	if len(zones) == 0 {
+		return
+	}
+	sb.WriteString(title + ":\n")
+	for _, zone := range zones {
+		if zone.Name != "" {
+			fmt.Fprintf(sb, "  %s «%s»\n", zone.Code, zone.Name)
+		} else {
+			fmt.Fprintf(sb, "  %s\n", zone.Code)
+		}
+	}
+}
+
+func weatherText(weather model.WeatherData) string {
+	return fmt.Sprintf("%.0f °C, ветер %.1f м/с, %.0f°, осадки %.0f%%, видимость %d м", weather.Temperature,
+		weather.WindSpeed, weather.WindDeg, weather.PrecipProb*100, weather.Visibility)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/japersik/safe-flight-bot/internal/flyDataClient"
+	"github.com/japersik/safe-flight-bot/model"
+	"io"
+	"math"
+	"time"
+)
+
+//forecastHour прогноз на час с оценкой пригодности погоды для заданных ограничений дрона
+type forecastHour struct {
+	model.WeatherData
+	Status  string                `json:"status"`
+	Verdict flyDataClient.Verdict `json:"verdict"`
+}
+
+func runForecast(args []string, out io.Writer) (int, error) {
+	flags := newFlagSet("forecast")
+	lat := flags.Float64("lat", 0, "широта точки полёта")
+	lng := flags.Float64("lng", 0, "долгота точки полёта")
+	hours := flags.Int("hours", 12, "количество часов прогноза")
+	maxWind := flags.Float64("max-wind", 0, "допустимый ветер, м/с. 0 - не проверять")
+	minTemp := flags.Float64("min-temp", math.NaN(), "минимальная рабочая температура, °C")
+	maxTemp := flags.Float64("max-temp", math.NaN(), "максимальная рабочая температура, °C")
+	asJSON := flags.Bool("json", false, "вывод в формате JSON")
+	if err := parseFlags(flags, args); err != nil {
+		return 0, err
+	}
+	coordinate := model.Coordinate{Lat: *lat, Lng: *lng}
+	if err := validateCoordinate(flags, coordinate, isSet(flags, "lat", "lng")); err != nil {
+		return 0, err
+	}
+	if *hours <= 0 {
+		return 0, usageError(flags, "--hours должно быть положительным")
+	}
+	if math.IsNaN(*minTemp) != math.IsNaN(*maxTemp) || *minTemp > *maxTemp {
+		return 0, usageError(flags, "--min-temp и --max-temp задаются вместе, min не больше max")
+	}
+	//ограничения передаются как дрон, чтобы оценка совпадала с уведомлениями бота
+	drone := model.Drone{MaxWind: *maxWind}
+	if !math.IsNaN(*minTemp) {
+		drone.MinTemp, drone.MaxTemp = *minTemp, *maxTemp
+	}
+
+	weather, err := newClient().GetForecastWeather(context.Background(), coordinate)
+	if err != nil {
+		return 0, fmt.Errorf("weather forecast error: %w", err)
+	}
+	report := flyDataClient.Report{Coordinate: coordinate, Weather: weather}
+	until := time.Now().Add(time.Duration(*hours) * time.Hour)
+	forecast := make([]forecastHour, 0, *hours)
+	worst := flyDataClient.VerdictGo
+	for _, hourly := range weather.Hourly {
+		if hourly.Timestamp.After(until) || hourly.Timestamp.Before(time.Now().Add(-time.Hour)) {
+			continue
+		}
+		verdict := report.DroneVerdict(drone, hourly.Timestamp)
+		if verdict.Level > worst {
+			worst = verdict.Level
+		}
+		forecast = append(forecast, forecastHour{WeatherData: hourly, Status: verdict.Level.Status(), Verdict: verdict})
+	}
+	if len(forecast) == 0 {
+		worst = flyDataClient.VerdictUnknown
+	}
+	if *asJSON {
+		return verdictCode(worst), writeJSON(out, forecast)
+	}
+	if len(forecast) == 0 {
+		fmt.Fprintln(out, "Нет данных прогноза на выбранный период")
+	}
+	for _, hour := range forecast {
+		fmt.Fprintf(out, "%s  %-7s  %s\n", hour.Timestamp.Local().Format("02.01 15:04"), hour.Status, weatherText(hour.WeatherData))
+		for _, reason := range hour.Verdict.Reasons {
+			fmt.Fprintf(out, "  - %s\n", reason)
+		}
+	}
+	return verdictCode(worst), nil
+}
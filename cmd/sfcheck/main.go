@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/japersik/safe-flight-bot/internal/config"
+	"github.com/japersik/safe-flight-bot/internal/flyDataClient"
+	"github.com/japersik/safe-flight-bot/internal/flyDataClient/avtmClient"
+	"github.com/japersik/safe-flight-bot/internal/flyDataClient/openstreetmapClient"
+	"github.com/japersik/safe-flight-bot/logger"
+	"github.com/japersik/safe-flight-bot/model"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"io"
+	"os"
+)
+
+//коды завершения. Для check и forecast код отражает оценку возможности полёта
+const (
+	exitGo      = 0
+	exitError   = 1
+	exitUsage   = 2
+	exitCaution = 3
+	exitNoGo    = 4
+	exitUnknown = 5
+)
+
+const usage = `sfcheck - проверка возможности полётов и управление планами
+
+Использование:
+  sfcheck check --lat 55.75 --lng 37.61 [--radius 300] [--alt 150] [--json]
+  sfcheck forecast --lat 55.75 --lng 37.61 [--hours 12] [--max-wind 10] [--min-temp -10 --max-temp 40] [--json]
+  sfcheck plans list --user ID [--json]
+  sfcheck plans add --user ID --lat 55.75 --lng 37.61 --time "01.12.2030 10:00" [--daily] [--notify -24h,-3h,0s]
+  sfcheck plans cancel [--notify=false] ID
+
+Коды завершения: 0 - полёт возможен, 3 - возможен с ограничениями, 4 - запрещен, 5 - недостаточно данных,
+1 - ошибка, 2 - неверные аргументы
+`
+
+//errUsage неверные аргументы командной строки
+var errUsage = errors.New("usage error")
+
+var cfg = config.Default()
+
+func main() {
+	zLog := zap.New(zapcore.NewCore(zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig()), zapcore.Lock(os.Stderr), zap.NewAtomicLevelAt(zap.WarnLevel)))
+	logger.NewInstance(logger.NewZapLogger(zLog))
+	os.Exit(run(os.Args[1:], os.Stdout))
+}
+
+func run(args []string, out io.Writer) int {
+	//адреса сервисов, файлы данных и значения по умолчанию берутся из конфигурации бота
+	var err error
+	if cfg, err = config.Load(config.DefaultPath(), config.PathRequired(config.DefaultPath())); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	model.SetDefaults(cfg.ModelDefaults())
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return exitUsage
+	}
+	var code int
+	switch args[0] {
+	case "check":
+		code, err = runCheck(args[1:], out)
+	case "forecast":
+		code, err = runForecast(args[1:], out)
+	case "plans":
+		code, err = runPlans(args[1:], out)
+	case "help", "-h", "--help":
+		fmt.Fprint(out, usage)
+		return exitGo
+	default:
+		fmt.Fprintf(os.Stderr, "неизвестная команда %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		return exitGo
+	case errors.Is(err, errUsage):
+		return exitUsage
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "ошибка:", err)
+		return exitError
+	}
+	return code
+}
+
+func newClient() flyDataClient.Client {
+	avtm := avtmClient.NewAvtmClient(cfg.Services.Avtm.URL, cfg.Services.Avtm.Timeout)
+	maps := openstreetmapClient.NewOpenStreetClient(cfg.Services.Nominatim.URL, cfg.Services.Nominatim.Timeout)
+	return flyDataClient.Client{WeatherInfoSource: avtm, ZoneInfoSource: avtm, LocalityInfoSource: maps}
+}
+
+//newFlagSet набор флагов подкоманды: ошибки разбора выводятся в stderr и возвращаются как errUsage
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	return flags
+}
+
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	return nil
+}
+
+//usageError сообщение о неверных аргументах
+func usageError(flags *flag.FlagSet, format string, a ...interface{}) error {
+	fmt.Fprintf(os.Stderr, "%s: %s\n", flags.Name(), fmt.Sprintf(format, a...))
+	flags.Usage()
+	return errUsage
+}
+
+func validateCoordinate(flags *flag.FlagSet, coordinate model.Coordinate, set bool) error {
+	if !set {
+		return usageError(flags, "--lat и --lng обязательны")
+	}
+	if coordinate.Lat < -90 || coordinate.Lat > 90 || coordinate.Lng < -180 || coordinate.Lng > 180 {
+		return usageError(flags, "координаты вне допустимого диапазона")
+	}
+	return nil
+}
+
+//isSet true, если все флаги заданы явно
+func isSet(flags *flag.FlagSet, names ...string) bool {
+	set := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, name := range names {
+		if !set[name] {
+			return false
+		}
+	}
+	return true
+}
+
+func verdictCode(level flyDataClient.VerdictLevel) int {
+	switch level {
+	case flyDataClient.VerdictGo:
+		return exitGo
+	case flyDataClient.VerdictCaution:
+		return exitCaution
+	case flyDataClient.VerdictNoGo:
+		return exitNoGo
+	default:
+		return exitUnknown
+	}
+}
+
+func writeJSON(out io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
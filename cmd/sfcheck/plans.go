@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/japersik/safe-flight-bot/internal/conversation"
+	"github.com/japersik/safe-flight-bot/internal/flyPlanner"
+	"github.com/japersik/safe-flight-bot/internal/telegram"
+	"github.com/japersik/safe-flight-bot/internal/userStorage"
+	"github.com/japersik/safe-flight-bot/model"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func runPlans(args []string, out io.Writer) (int, error) {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 0, errUsage
+	}
+	switch args[0] {
+	case "list":
+		return exitGo, runPlansList(args[1:], out)
+	case "add":
+		return exitGo, runPlansAdd(args[1:], out)
+	case "cancel":
+		return exitGo, runPlansCancel(args[1:], out)
+	}
+	fmt.Fprintf(os.Stderr, "неизвестная команда plans %q\n\n%s", args[0], usage)
+	return 0, errUsage
+}
+
+//loadPlanner загрузка файла планов без запуска уведомлений: их отправляет бот. Для изменения планов (lock)
+//файл блокируется, чтобы не потерять изменения, которые запущенный бот перезапишет при остановке
+func loadPlanner(file string, lock bool) (*flyPlanner.Planer, error) {
+	planner := flyPlanner.NewPlaner(file)
+	planner.SetMaxUserPlans(cfg.Limits.MaxActivePlans)
+	if lock {
+		if err := planner.LockStore(); errors.Is(err, flyPlanner.StoreLockedErr) {
+			return nil, fmt.Errorf("%w: stop the bot or change plans via the REST API", err)
+		} else if err != nil {
+			return nil, fmt.Errorf("plans file lock error: %w", err)
+		}
+	}
+	if err := planner.Load(); err != nil {
+		return nil, fmt.Errorf("plans file loading error: %w", err)
+	}
+	return planner, nil
+}
+
+func runPlansList(args []string, out io.Writer) error {
+	flags := newFlagSet("plans list")
+	file := flags.String("file", cfg.Storage.Plans, "файл планов")
+	userId := flags.Int64("user", 0, "id пользователя Telegram")
+	asJSON := flags.Bool("json", false, "вывод в формате JSON")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if !isSet(flags, "user") {
+		return usageError(flags, "--user обязателен")
+	}
+	planner, err := loadPlanner(*file, false)
+	if err != nil {
+		return err
+	}
+	plans := planner.UserPlans(*userId)
+	if *asJSON {
+		return writeJSON(out, plans)
+	}
+	if len(plans) == 0 {
+		fmt.Fprintln(out, "Запланированных полётов нет")
+	}
+	for _, plan := range plans {
+		when := plan.FlyDateTime.Format("02.01.2006 15:04 -0700")
+		if plan.IsEveryDayPlan {
+			when = "ежедневно в " + plan.FlyDateTime.Format("15:04 -0700")
+		}
+		fmt.Fprintf(out, "№%d  %s  %.6f, %.6f", plan.FlyId, when, plan.Data.Coordinate.Lat, plan.Data.Coordinate.Lng)
+		if plan.Data.UserId != *userId {
+			fmt.Fprint(out, "  (подписка)")
+		}
+		fmt.Fprintln(out)
+	}
+	return nil
+}
+
+func runPlansAdd(args []string, out io.Writer) error {
+	flags := newFlagSet("plans add")
+	file := flags.String("file", cfg.Storage.Plans, "файл планов")
+	usersFile := flags.String("users", cfg.Storage.Users, "файл данных пользователей (уведомления и чек-лист)")
+	userId := flags.Int64("user", 0, "id пользователя Telegram, которому придут уведомления")
+	lat := flags.Float64("lat", 0, "широта точки полёта")
+	lng := flags.Float64("lng", 0, "долгота точки полёта")
+	radius := flags.Int("radius", 0, "радиус района полёта, м. 0 - по умолчанию")
+	altitude := flags.Int("alt", 0, "высота полёта, м. 0 - по умолчанию")
+	at := flags.String("time", "", `местное время полёта "01.12.2030 10:00", для --daily "10:00", или RFC 3339`)
+	daily := flags.Bool("daily", false, "ежедневное уведомление")
+	notify := flags.String("notify", "", "смещения уведомлений через запятую, например -24h,-3h,0s. "+
+		"По умолчанию - настройки пользователя")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	coordinate := model.Coordinate{Lat: *lat, Lng: *lng}
+	if err := validateCoordinate(flags, coordinate, isSet(flags, "lat", "lng")); err != nil {
+		return err
+	}
+	if !isSet(flags, "user", "time") {
+		return usageError(flags, "--user и --time обязательны")
+	}
+	if *radius < 0 || *radius > model.MaxRadius || *altitude < 0 || *altitude > model.MaxAltitude {
+		return usageError(flags, "недопустимый радиус или высота")
+	}
+	coordinate = coordinate.Rounded()
+	flyTime, err := parseFlyTime(*at, coordinate, *daily)
+	if err != nil {
+		return usageError(flags, "неверное время %q", *at)
+	}
+	if !*daily && flyTime.Before(time.Now()) {
+		return usageError(flags, "время полёта уже прошло")
+	}
+
+	users := userStorage.NewFileStorage(*usersFile)
+	if err := users.Init(); err != nil {
+		return fmt.Errorf("users data file loading error: %w", err)
+	}
+	planner, err := loadPlanner(*file, true)
+	if err != nil {
+		return err
+	}
+	plan := model.FlyPlan{
+		Data: model.FlyData{
+			UserId:     *userId,
+			Coordinate: coordinate,
+			Radius:     *radius,
+			Altitude:   *altitude,
+		},
+		FlyDateTime:    flyTime,
+		IsEveryDayPlan: *daily,
+		Notifications:  []time.Duration{0},
+	}
+	if !*daily {
+		plan.Notifications = users.GetNotifications(*userId)
+		if *notify != "" {
+			if plan.Notifications, err = model.ParseNotifications(strings.Split(*notify, ",")); err != nil {
+				return usageError(flags, "%s", conversation.NotificationsErrorText(err))
+			}
+		}
+	}
+	//сохранение как при планировании в чате: новому плану копируется чек-лист пользователя
+	core := conversation.NewCore(newClient(), planner, users)
+	if _, err := core.SavePlan(context.Background(), &plan); errors.Is(err, flyPlanner.PlansLimitErr) {
+		return fmt.Errorf("user %d: %w (limits.maxActivePlans = %d)", *userId, err, cfg.Limits.MaxActivePlans)
+	} else if err != nil {
+		return err
+	}
+	if err := planner.SavePlans(); err != nil {
+		return fmt.Errorf("plans file save error: %w", err)
+	}
+	fmt.Fprintf(out, "План №%d создан\n", plan.FlyId)
+	return nil
+}
+
+func runPlansCancel(args []string, out io.Writer) error {
+	flags := newFlagSet("plans cancel")
+	file := flags.String("file", cfg.Storage.Plans, "файл планов")
+	notify := flags.Bool("notify", true, "оповестить подписчиков плана через Telegram (нужен telegram.token)")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return usageError(flags, "укажите номер плана")
+	}
+	flyId, err := strconv.ParseUint(flags.Arg(0), 10, 64)
+	if err != nil {
+		return usageError(flags, "неверный номер плана %q", flags.Arg(0))
+	}
+	planner, err := loadPlanner(*file, true)
+	if err != nil {
+		return err
+	}
+	if *notify {
+		if err := setSubscribersNotifier(planner); err != nil {
+			return err
+		}
+	}
+	if err := planner.CancelFly(context.Background(), flyId); err != nil {
+		return fmt.Errorf("plan №%d: %w", flyId, err)
+	}
+	if err := planner.SavePlans(); err != nil {
+		return fmt.Errorf("plans file save error: %w", err)
+	}
+	fmt.Fprintf(out, "План №%d отменён\n", flyId)
+	return nil
+}
+
+//setSubscribersNotifier подписчиков оповещает бот, как при отмене плана в чате. Сам бот не запускается:
+//он только отправляет сообщения из ChangeListener планировщика
+func setSubscribersNotifier(planner *flyPlanner.Planer) error {
+	if cfg.Telegram.Token == "" {
+		fmt.Fprintln(os.Stderr, "telegram.token не задан: подписчики плана не будут оповещены")
+		return nil
+	}
+	bot, err := tgbotapi.NewBotAPI(cfg.Telegram.Token)
+	if err != nil {
+		return fmt.Errorf("bot api error: %w", err)
+	}
+	telegram.NewBot(bot, newClient(), planner, nil, nil)
+	return nil
+}
+
+//parseFlyTime RFC 3339 или местное время в часовом поясе точки полёта, как при планировании в чате
+func parseFlyTime(value string, coordinate model.Coordinate, daily bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if daily {
+		return conversation.ParseTime(value, coordinate)
+	}
+	return conversation.ParseDateTime(value, coordinate)
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"github.com/japersik/safe-flight-bot/internal/flyDataClient"
+	"github.com/japersik/safe-flight-bot/internal/flyPlanner"
+	"github.com/japersik/safe-flight-bot/internal/metrics"
+	"github.com/japersik/safe-flight-bot/internal/ratelimit"
+	"github.com/japersik/safe-flight-bot/logger"
+	"github.com/japersik/safe-flight-bot/model"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxBodySize = 64 << 10
+
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+type Checker interface {
+	GetReport(ctx context.Context, coordinate model.Coordinate, radius int, altitude int) flyDataClient.Report
+}
+
+type Users interface {
+	UserByAPIToken(token string) (userId int64, ok bool)
+	GetNotifications(userId int64) []time.Duration
+	GetChecklist(userId int64) []string
+}
+
+//Server REST API проверок условий полёта и управления планами. Доступ по токену пользователя из команды /api бота
+type Server struct {
+	checker Checker
+	planner flyPlanner.Planner
+	users   Users
+	mux     *http.ServeMux
+	checks  *ratelimit.UserLimiter
+}
+
+//NewServer ...
+func NewServer(checker Checker, planner flyPlanner.Planner, users Users) *Server {
+	s := &Server{checker: checker, planner: planner, users: users, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/v1/openapi.yaml", s.handleOpenAPI)
+	s.mux.HandleFunc("/v1/check", s.authorized(s.handleCheck))
+	s.mux.HandleFunc("/v1/plans", s.authorized(s.handlePlans))
+	s.mux.HandleFunc("/v1/plans/", s.authorized(s.handlePlan))
+	return s
+}
+
+//SetCheckLimiter лимит проверок на пользователя. Передается тот же лимит, что и боту, чтобы API не позволял его обойти
+func (s *Server) SetCheckLimiter(checks *ratelimit.UserLimiter) {
+	s.checks = checks
+}
+
+//requestIdHeader идентификатор запроса: принимается от клиента или создается и возвращается в ответе
+const requestIdHeader = "X-Request-Id"
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	requestId := r.Header.Get(requestIdHeader)
+	if requestId == "" || len(requestId) > 64 {
+		requestId = logger.NewCorrelationId()
+	}
+	w.Header().Set(requestIdHeader, requestId)
+	log := logger.With(logger.CorrelationId(requestId), logger.String("method", r.Method), logger.Endpoint(r.URL.Path))
+	start := time.Now()
+	s.mux.ServeHTTP(w, r.WithContext(logger.NewContext(r.Context(), log)))
+	log.Debug("api request handled", logger.Duration(time.Since(start)))
+}
+
+//ListenAndServe запуск http сервера API
+func ListenAndServe(addr string, server *Server) error {
+	httpServer := &http.Server{
+		Addr:         addr,
+		Handler:      server,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+	logger.Info("api server listening", logger.String("addr", addr))
+	return httpServer.ListenAndServe()
+}
+
+type authorizedHandler func(w http.ResponseWriter, r *http.Request, userId int64)
+
+//authorized проверка заголовка Authorization: Bearer <token>
+func (s *Server) authorized(handler authorizedHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "missing bearer token")
+			return
+		}
+		userId, ok := s.users.UserByAPIToken(strings.TrimSpace(strings.TrimPrefix(auth, "Bearer ")))
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+		log := logger.FromContext(r.Context()).With(logger.UserId(userId))
+		handler(w, r.WithContext(logger.NewContext(r.Context(), log)), userId)
+	}
+}
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(openAPISpec)
+}
+
+//checkResponse отчет о зонах, погоде и населенном пункте с итоговой оценкой
+type checkResponse struct {
+	flyDataClient.Report
+	Status  string                `json:"status"`
+	Verdict flyDataClient.Verdict `json:"verdict"`
+}
+
+func (s *Server) handleCheck(w http.ResponseWriter, r *http.Request, userId int64) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	query := r.URL.Query()
+	lat, errLat := strconv.ParseFloat(query.Get("lat"), 64)
+	lng, errLng := strconv.ParseFloat(query.Get("lng"), 64)
+	if errLat != nil || errLng != nil {
+		writeError(w, http.StatusBadRequest, "lat and lng are required")
+		return
+	}
+	radius, err := intParam(query.Get("radius"), model.DefaultRadius())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "radius must be an integer")
+		return
+	}
+	altitude, err := intParam(query.Get("alt"), model.DefaultAltitude())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "alt must be an integer")
+		return
+	}
+	coordinate := model.Coordinate{Lat: lat, Lng: lng}
+	if msg := validateArea(coordinate, radius, altitude); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if decision := s.checks.Allow(userId); !decision.Allowed {
+		metrics.Throttled("api_checks")
+		w.Header().Set("Retry-After", strconv.Itoa(decision.RetrySeconds()))
+		writeError(w, http.StatusTooManyRequests, "too many checks, retry later")
+		return
+	}
+	report := s.checker.GetReport(r.Context(), coordinate, radius, altitude)
+	verdict := report.Verdict()
+	writeJSON(w, http.StatusOK, checkResponse{Report: report, Status: verdict.Level.Status(), Verdict: verdict})
+}
+
+func intParam(value string, def int) (int, error) {
+	if value == "" {
+		return def, nil
+	}
+	return strconv.Atoi(value)
+}
+
+//validateArea returns error message or empty string
+func validateArea(coordinate model.Coordinate, radius int, altitude int) string {
+	if coordinate.Lat < -90 || coordinate.Lat > 90 || coordinate.Lng < -180 || coordinate.Lng > 180 {
+		return "coordinates out of range"
+	}
+	if radius <= 0 || radius > model.MaxRadius {
+		return "radius must be between 1 and " + strconv.Itoa(model.MaxRadius)
+	}
+	if altitude < 0 || altitude > model.MaxAltitude {
+		return "altitude must be between 0 and " + strconv.Itoa(model.MaxAltitude)
+	}
+	return ""
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		logger.Warn("api response error", logger.Err(err))
+	}
+}
@@ -0,0 +1,332 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/japersik/safe-flight-bot/internal/flyDataClient"
+	"github.com/japersik/safe-flight-bot/internal/flyPlanner"
+	"github.com/japersik/safe-flight-bot/internal/ratelimit"
+	"github.com/japersik/safe-flight-bot/logger"
+	"github.com/japersik/safe-flight-bot/model"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	ownerId      = 1
+	subscriberId = 2
+	strangerId   = 3
+)
+
+func TestMain(m *testing.M) {
+	logger.NewInstance(logger.NewZapLogger(zap.NewNop()))
+	os.Exit(m.Run())
+}
+
+type fakeChecker struct {
+	calls int
+}
+
+func (c *fakeChecker) GetReport(ctx context.Context, coordinate model.Coordinate, radius int, altitude int) flyDataClient.Report {
+	c.calls++
+	return flyDataClient.Report{Coordinate: coordinate, Radius: radius, Altitude: altitude,
+		Condition: &model.Condition{DaylightHours: true}}
+}
+
+type fakeUsers struct{}
+
+func (fakeUsers) UserByAPIToken(token string) (int64, bool) {
+	switch token {
+	case "owner":
+		return ownerId, true
+	case "subscriber":
+		return subscriberId, true
+	case "stranger":
+		return strangerId, true
+	}
+	return 0, false
+}
+
+func (fakeUsers) GetNotifications(userId int64) []time.Duration {
+	return []time.Duration{-time.Hour, 0}
+}
+
+func (fakeUsers) GetChecklist(userId int64) []string {
+	return []string{"Заряд батарей"}
+}
+
+//newTestServer сервер с планом ownerId №1, на который подписан subscriberId
+func newTestServer(t *testing.T) (*Server, *fakeChecker, *flyPlanner.Planer) {
+	t.Helper()
+	planner := flyPlanner.NewPlaner(filepath.Join(t.TempDir(), "plans.json"))
+	flyId, err := planner.PlanFly(model.FlyPlan{FlyDateTime: time.Now().Add(48 * time.Hour),
+		Notifications: []time.Duration{0},
+		Data:          model.FlyData{UserId: ownerId, Coordinate: model.Coordinate{Lat: 55.75, Lng: 37.61}, Radius: 500}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := planner.ShareFly(flyId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := planner.Subscribe(token, model.Subscriber{UserId: subscriberId}); err != nil {
+		t.Fatal(err)
+	}
+	checker := &fakeChecker{}
+	return NewServer(checker, planner, fakeUsers{}), checker, planner
+}
+
+//changeRecorder сохраняет изменения планов, о которых планировщик сообщил бы подписчикам
+type changeRecorder struct {
+	changes []string
+	plans   []model.FlyPlan
+}
+
+func (r *changeRecorder) PlanCanceled(ctx context.Context, plan model.FlyPlan) {
+	r.changes = append(r.changes, "canceled")
+	r.plans = append(r.plans, plan)
+}
+
+func (r *changeRecorder) PlanUpdated(ctx context.Context, plan model.FlyPlan) {
+	r.changes = append(r.changes, "updated")
+	r.plans = append(r.plans, plan)
+}
+
+func do(server http.Handler, method, path, token, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, r)
+	return w
+}
+
+func TestAuthorization(t *testing.T) {
+	server, _, _ := newTestServer(t)
+	tests := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{"no header", "", http.StatusUnauthorized},
+		{"basic auth", "Basic b3duZXI6", http.StatusUnauthorized},
+		{"unknown token", "Bearer nobody", http.StatusUnauthorized},
+		{"valid token", "Bearer owner", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/plans", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, r)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.status == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") != "Bearer" {
+				t.Errorf("WWW-Authenticate = %q", w.Header().Get("WWW-Authenticate"))
+			}
+			if w.Header().Get(requestIdHeader) == "" {
+				t.Errorf("response has no %s", requestIdHeader)
+			}
+		})
+	}
+}
+
+func TestHandleCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		query  string
+		status int
+	}{
+		{"defaults", http.MethodGet, "lat=55.75&lng=37.61", http.StatusOK},
+		{"radius and altitude", http.MethodGet, "lat=55.75&lng=37.61&radius=1000&alt=120", http.StatusOK},
+		{"missing lng", http.MethodGet, "lat=55.75", http.StatusBadRequest},
+		{"latitude out of range", http.MethodGet, "lat=91&lng=37.61", http.StatusBadRequest},
+		{"radius not a number", http.MethodGet, "lat=55.75&lng=37.61&radius=big", http.StatusBadRequest},
+		{"radius too large", http.MethodGet, "lat=55.75&lng=37.61&radius=50001", http.StatusBadRequest},
+		{"negative altitude", http.MethodGet, "lat=55.75&lng=37.61&alt=-1", http.StatusBadRequest},
+		{"post", http.MethodPost, "lat=55.75&lng=37.61", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, checker, _ := newTestServer(t)
+			w := do(server, tt.method, "/v1/check?"+tt.query, "owner", "")
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusOK {
+				if checker.calls != 0 {
+					t.Errorf("checker called for invalid request")
+				}
+				return
+			}
+			response := checkResponse{}
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if response.Status != "go" || response.Radius <= 0 {
+				t.Errorf("unexpected response %+v", response)
+			}
+		})
+	}
+}
+
+func TestHandleCheckLimit(t *testing.T) {
+	server, checker, _ := newTestServer(t)
+	server.SetCheckLimiter(ratelimit.NewUserLimiter(1, 1))
+	if w := do(server, http.MethodGet, "/v1/check?lat=55.75&lng=37.61", "owner", ""); w.Code != http.StatusOK {
+		t.Fatalf("first check status = %d", w.Code)
+	}
+	w := do(server, http.MethodGet, "/v1/check?lat=55.75&lng=37.61", "owner", "")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("second check status = %d, Retry-After = %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := do(server, http.MethodGet, "/v1/check?lat=55.75&lng=37.61", "stranger", ""); w.Code != http.StatusOK {
+		t.Errorf("other user check status = %d", w.Code)
+	}
+	if checker.calls != 2 {
+		t.Errorf("checker calls = %d, want 2", checker.calls)
+	}
+}
+
+func TestHandlePlan(t *testing.T) {
+	future := time.Now().Add(72 * time.Hour).UTC().Format(time.RFC3339)
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	tests := []struct {
+		name   string
+		method string
+		token  string
+		body   string
+		status int
+		// оповещение подписчиков через flyPlanner.ChangeListener
+		change string
+	}{
+		{"owner get", http.MethodGet, "owner", "", http.StatusOK, ""},
+		{"subscriber get", http.MethodGet, "subscriber", "", http.StatusOK, ""},
+		{"stranger get", http.MethodGet, "stranger", "", http.StatusNotFound, ""},
+		{"subscriber delete", http.MethodDelete, "subscriber", "", http.StatusForbidden, ""},
+		{"stranger delete", http.MethodDelete, "stranger", "", http.StatusNotFound, ""},
+		{"owner delete", http.MethodDelete, "owner", "", http.StatusNoContent, "canceled"},
+		{"owner update", http.MethodPut, "owner", `{"lat":55.8,"lng":37.7,"dateTime":"` + future + `"}`, http.StatusOK,
+			"updated"},
+		{"update in the past", http.MethodPut, "owner", `{"lat":55.8,"lng":37.7,"dateTime":"` + past + `"}`,
+			http.StatusBadRequest, ""},
+		{"update with unknown field", http.MethodPut, "owner", `{"lat":55.8,"lng":37.7,"userId":3}`,
+			http.StatusBadRequest, ""},
+		{"update with bad notifications", http.MethodPut, "owner",
+			`{"lat":55.8,"lng":37.7,"dateTime":"` + future + `","notifications":["-1h"]}`, http.StatusBadRequest, ""},
+		{"patch", http.MethodPatch, "owner", "", http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _, planner := newTestServer(t)
+			changes := &changeRecorder{}
+			planner.SetChangeListener(changes)
+			w := do(server, tt.method, "/v1/plans/1", tt.token, tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			_, err := planner.GetFly(1)
+			if deleted := err != nil; deleted != (tt.status == http.StatusNoContent) {
+				t.Errorf("plan deleted = %v after %s", deleted, tt.name)
+			}
+			if strings.Join(changes.changes, ",") != tt.change {
+				t.Errorf("listener got %v, want %q", changes.changes, tt.change)
+			}
+			//подписчики берутся из сохраненного плана, запрос их не передает
+			if len(changes.plans) > 0 && !changes.plans[0].IsSubscribed(subscriberId) {
+				t.Errorf("listener got plan without subscribers: %+v", changes.plans[0])
+			}
+		})
+	}
+	t.Run("not found", func(t *testing.T) {
+		server, _, _ := newTestServer(t)
+		for _, path := range []string{"/v1/plans/2", "/v1/plans/abc"} {
+			if w := do(server, http.MethodGet, path, "owner", ""); w.Code != http.StatusNotFound {
+				t.Errorf("GET %s status = %d, want %d", path, w.Code, http.StatusNotFound)
+			}
+		}
+	})
+}
+
+func TestHandlePlansCreate(t *testing.T) {
+	future := time.Now().Add(72 * time.Hour).UTC().Format(time.RFC3339)
+	tests := []struct {
+		name          string
+		body          string
+		maxPlans      int
+		status        int
+		notifications []string
+	}{
+		{"user notifications", `{"lat":55.75,"lng":37.61,"dateTime":"` + future + `"}`, 0, http.StatusCreated,
+			[]string{"-1h0m0s", "0s"}},
+		{"own notifications", `{"lat":55.75,"lng":37.61,"dateTime":"` + future + `","notifications":["0","-30m"]}`, 0,
+			http.StatusCreated, []string{"-30m0s", "0s"}},
+		{"every day", `{"lat":55.75,"lng":37.61,"dateTime":"` + future + `","everyDay":true}`, 0, http.StatusCreated,
+			[]string{"0s"}},
+		{"missing date", `{"lat":55.75,"lng":37.61}`, 0, http.StatusBadRequest, nil},
+		{"invalid json", `{"lat":`, 0, http.StatusBadRequest, nil},
+		{"limit reached", `{"lat":55.75,"lng":37.61,"dateTime":"` + future + `"}`, 1, http.StatusConflict, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _, planner := newTestServer(t)
+			planner.SetMaxUserPlans(tt.maxPlans)
+			w := do(server, http.MethodPost, "/v1/plans", "owner", tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusCreated {
+				return
+			}
+			plan := Plan{}
+			if err := json.NewDecoder(w.Body).Decode(&plan); err != nil {
+				t.Fatal(err)
+			}
+			if w.Header().Get("Location") != "/v1/plans/2" || plan.Id != 2 || !plan.Owner {
+				t.Errorf("Location = %q, plan %+v", w.Header().Get("Location"), plan)
+			}
+			if strings.Join(plan.Notifications, ",") != strings.Join(tt.notifications, ",") {
+				t.Errorf("notifications = %q, want %q", plan.Notifications, tt.notifications)
+			}
+		})
+	}
+}
+
+func TestHandlePlansList(t *testing.T) {
+	server, _, _ := newTestServer(t)
+	tests := []struct {
+		token string
+		count int
+		owner bool
+	}{
+		{"owner", 1, true},
+		{"subscriber", 1, false},
+		{"stranger", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			w := do(server, http.MethodGet, "/v1/plans", tt.token, "")
+			plans := make([]Plan, 0)
+			if err := json.NewDecoder(w.Body).Decode(&plans); err != nil {
+				t.Fatal(err)
+			}
+			if len(plans) != tt.count {
+				t.Fatalf("plans = %+v, want %d", plans, tt.count)
+			}
+			if tt.count > 0 && plans[0].Owner != tt.owner {
+				t.Errorf("owner = %v, want %v", plans[0].Owner, tt.owner)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/japersik/safe-flight-bot/internal/flyPlanner"
+	"github.com/japersik/safe-flight-bot/model"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Plan представление плана полёта в API
+type Plan struct {
+	Id       uint64    `json:"id"`
+	Lat      float64   `json:"lat"`
+	Lng      float64   `json:"lng"`
+	Radius   int       `json:"radius"`
+	Altitude int       `json:"altitude,omitempty"`
+	DateTime time.Time `json:"dateTime"`
+	EveryDay bool      `json:"everyDay"`
+	// смещения уведомлений в формате Go duration: "-3h0m0s"
+	Notifications []string `json:"notifications"`
+	// false для планов, на которые пользователь подписан по приглашению
+	Owner     bool                    `json:"owner"`
+	Drone     string                  `json:"drone,omitempty"`
+	Checklist []model.ChecklistItem   `json:"checklist,omitempty"`
+	LastCheck *model.FlightConditions `json:"lastCheck,omitempty"`
+}
+
+//PlanInput тело запросов создания и изменения плана
+type PlanInput struct {
+	Lat      float64   `json:"lat"`
+	Lng      float64   `json:"lng"`
+	Radius   int       `json:"radius"`
+	Altitude int       `json:"altitude"`
+	DateTime time.Time `json:"dateTime"`
+	EveryDay bool      `json:"everyDay"`
+	// не указаны - при создании используются уведомления пользователя, при изменении остаются прежними
+	Notifications []string `json:"notifications"`
+}
+
+func newPlan(plan model.FlyPlan, userId int64) Plan {
+	notifications := make([]string, 0, len(plan.Notifications))
+	for _, offset := range plan.Notifications {
+		notifications = append(notifications, offset.String())
+	}
+	result := Plan{
+		Id:            plan.FlyId,
+		Lat:           plan.Data.Coordinate.Lat,
+		Lng:           plan.Data.Coordinate.Lng,
+		Radius:        plan.Data.Radius,
+		Altitude:      plan.Data.Altitude,
+		DateTime:      plan.FlyDateTime,
+		EveryDay:      plan.IsEveryDayPlan,
+		Notifications: notifications,
+		Owner:         plan.Data.UserId == userId,
+		Checklist:     plan.Checklist,
+		LastCheck:     plan.LastCheck,
+	}
+	if plan.Drone != nil {
+		result.Drone = plan.Drone.Name
+	}
+	return result
+}
+
+//handlePlans GET - планы пользователя, POST - создание плана
+func (s *Server) handlePlans(w http.ResponseWriter, r *http.Request, userId int64) {
+	switch r.Method {
+	case http.MethodGet:
+		plans := s.planner.UserPlans(userId)
+		result := make([]Plan, 0, len(plans))
+		for _, plan := range plans {
+			result = append(result, newPlan(plan, userId))
+		}
+		writeJSON(w, http.StatusOK, result)
+	case http.MethodPost:
+		input, err := readPlanInput(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		plan := model.FlyPlan{Data: model.FlyData{UserId: userId}}
+		if err := s.applyInput(&plan, input); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if !plan.IsEveryDayPlan {
+			plan.Checklist = model.NewChecklist(s.users.GetChecklist(userId))
+		}
+		flyId, err := s.planner.PlanFly(plan)
+		if errors.Is(err, flyPlanner.PlansLimitErr) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "plan creation error")
+			return
+		}
+		created, err := s.planner.GetFly(flyId)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "plan creation error")
+			return
+		}
+		w.Header().Set("Location", "/v1/plans/"+strconv.FormatUint(flyId, 10))
+		writeJSON(w, http.StatusCreated, newPlan(created, userId))
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+//handlePlan GET, PUT, DELETE /v1/plans/{id}
+func (s *Server) handlePlan(w http.ResponseWriter, r *http.Request, userId int64) {
+	flyId, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, "/v1/plans/"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "plan not found")
+		return
+	}
+	plan, err := s.planner.GetFly(flyId)
+	if err != nil || (plan.Data.UserId != userId && !plan.IsSubscribed(userId)) {
+		writeError(w, http.StatusNotFound, "plan not found")
+		return
+	}
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, newPlan(plan, userId))
+		return
+	}
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if plan.Data.UserId != userId {
+		writeError(w, http.StatusForbidden, "only the plan owner can modify it")
+		return
+	}
+	if r.Method == http.MethodDelete {
+		if err := s.planner.CancelFly(r.Context(), flyId); err != nil && !errors.Is(err, flyPlanner.PlanNotExistErr) {
+			writeError(w, http.StatusInternalServerError, "plan cancel error")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	input, err := readPlanInput(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := s.applyInput(&plan, input); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := s.planner.UpdateFly(r.Context(), plan); err != nil {
+		writeError(w, http.StatusNotFound, "plan not found")
+		return
+	}
+	if updated, err := s.planner.GetFly(flyId); err == nil {
+		plan = updated
+	}
+	writeJSON(w, http.StatusOK, newPlan(plan, userId))
+}
+
+func readPlanInput(r *http.Request) (PlanInput, error) {
+	input := PlanInput{}
+	decoder := json.NewDecoder(io.LimitReader(r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&input); err != nil {
+		return input, errors.New("invalid request body: " + err.Error())
+	}
+	return input, nil
+}
+
+//applyInput проверка и перенос данных запроса в план. Владелец, чат, дрон и чек-лист не меняются
+func (s *Server) applyInput(plan *model.FlyPlan, input PlanInput) error {
+	if input.Radius == 0 {
+		input.Radius = model.DefaultRadius()
+	}
+	coordinate := model.Coordinate{Lat: input.Lat, Lng: input.Lng}
+	if msg := validateArea(coordinate, input.Radius, input.Altitude); msg != "" {
+		return errors.New(msg)
+	}
+	if input.DateTime.IsZero() {
+		return errors.New("dateTime is required")
+	}
+	if !input.EveryDay && input.DateTime.Before(time.Now()) {
+		return errors.New("dateTime must be in the future")
+	}
+	notifications := []time.Duration{0}
+	if !input.EveryDay {
+		switch {
+		case input.Notifications == nil && plan.FlyId != 0 && !plan.IsEveryDayPlan:
+			notifications = plan.Notifications
+		case input.Notifications == nil:
+			notifications = s.users.GetNotifications(plan.Data.UserId)
+		default:
+			offsets, err := model.ParseNotifications(input.Notifications)
+			if err != nil {
+				return err
+			}
+			notifications = offsets
+		}
+	}
+	plan.Data.Coordinate = coordinate.Rounded()
+	plan.Data.Radius = input.Radius
+	plan.Data.Altitude = input.Altitude
+	plan.FlyDateTime = input.DateTime
+	plan.IsEveryDayPlan = input.EveryDay
+	plan.Notifications = notifications
+	return nil
+}
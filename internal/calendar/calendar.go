@@ -0,0 +1,141 @@
+package calendar
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/japersik/safe-flight-bot/model"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	prodId     = "-//safe-flight-bot//RU"
+	uidDomain  = "safe-flight-bot"
+	utcLayout  = "20060102T150405Z"
+	maxLineLen = 75
+)
+
+//Write выгрузка планов полётов в формате iCalendar (RFC 5545)
+func Write(w io.Writer, name string, plans []model.FlyPlan, now time.Time) error {
+	writer := &icsWriter{w: bufio.NewWriter(w)}
+	writer.line("BEGIN:VCALENDAR")
+	writer.line("VERSION:2.0")
+	writer.line("PRODID:" + prodId)
+	writer.line("CALSCALE:GREGORIAN")
+	writer.line("METHOD:PUBLISH")
+	writer.line("X-WR-CALNAME:" + escapeText(name))
+	for _, plan := range plans {
+		writeEvent(writer, plan, now)
+	}
+	writer.line("END:VCALENDAR")
+	if writer.err != nil {
+		return writer.err
+	}
+	return writer.w.Flush()
+}
+
+func writeEvent(writer *icsWriter, plan model.FlyPlan, now time.Time) {
+	start := plan.FlyDateTime
+	coord := plan.Data.Coordinate
+	writer.line("BEGIN:VEVENT")
+	writer.line(fmt.Sprintf("UID:plan-%d@%s", plan.FlyId, uidDomain))
+	writer.line("DTSTAMP:" + now.UTC().Format(utcLayout))
+	writer.line("DTSTART:" + start.UTC().Format(utcLayout))
+	writer.line("DTEND:" + start.Add(model.DefaultFlyDuration).UTC().Format(utcLayout))
+	if plan.IsEveryDayPlan {
+		writer.line("RRULE:FREQ=DAILY")
+		writer.line(escapeProperty("SUMMARY", fmt.Sprintf("Ежедневная проверка условий полёта (план №%d)", plan.FlyId)))
+	} else {
+		writer.line(escapeProperty("SUMMARY", fmt.Sprintf("Полёт БВС (план №%d)", plan.FlyId)))
+	}
+	writer.line(fmt.Sprintf("GEO:%.6f;%.6f", coord.Lat, coord.Lng))
+	writer.line(escapeProperty("LOCATION", fmt.Sprintf("%.6f, %.6f", coord.Lat, coord.Lng)))
+	writer.line(escapeProperty("DESCRIPTION", Description(plan)))
+	writer.line("URL:" + fmt.Sprintf("https://www.openstreetmap.org/?mlat=%f&mlon=%f#map=15/%f/%f",
+		coord.Lat, coord.Lng, coord.Lat, coord.Lng))
+	writer.line("END:VEVENT")
+}
+
+//Description описание события: параметры полёта и условия последней проверки
+func Description(plan model.FlyPlan) string {
+	lines := make([]string, 0)
+	if plan.Data.Radius > 0 {
+		lines = append(lines, fmt.Sprintf("Радиус: %d м", plan.Data.Radius))
+	}
+	if plan.Data.Altitude > 0 {
+		lines = append(lines, fmt.Sprintf("Высота: %d м", plan.Data.Altitude))
+	}
+	if plan.Drone != nil {
+		lines = append(lines, "Дрон: "+plan.Drone.Name)
+	}
+	check := plan.LastCheck
+	if check == nil {
+		lines = append(lines, "Проверка условий еще не выполнялась")
+		return strings.Join(lines, "\n")
+	}
+	lines = append(lines, "Последняя проверка: "+check.CheckedAt.Format("02.01.2006 15:04 MST"))
+	if check.Weather != nil {
+		lines = append(lines, fmt.Sprintf("Погода: %.0f °C, ветер %.1f м/с, вероятность осадков %.0f%%",
+			check.Weather.Temperature, check.Weather.WindSpeed, check.Weather.PrecipProb*100))
+	}
+	if check.NearBoundaryZone {
+		lines = append(lines, "20-км приграничная зона: полёты запрещены")
+	}
+	if len(check.ActiveZones) > 0 {
+		lines = append(lines, "Действующие зоны ограничений: "+strings.Join(check.ActiveZones, ", "))
+	} else {
+		lines = append(lines, "Действующих зон ограничений нет")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func escapeProperty(name, value string) string {
+	return name + ":" + escapeText(value)
+}
+
+//escapeText экранирование значения типа TEXT
+func escapeText(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return replacer.Replace(value)
+}
+
+//icsWriter записывает строки с переносом длинных строк по 75 октетов и окончанием CRLF
+type icsWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (w *icsWriter) line(line string) {
+	if w.err != nil {
+		return
+	}
+	first := true
+	for len(line) > 0 {
+		limit := maxLineLen
+		if !first {
+			//место под пробел в начале строки продолжения
+			limit--
+		}
+		cut := len(line)
+		if cut > limit {
+			cut = limit
+			//не разрезаем многобайтовый символ UTF-8
+			for cut > 0 && line[cut]&0xC0 == 0x80 {
+				cut--
+			}
+		}
+		if !first {
+			w.write(" ")
+		}
+		w.write(line[:cut] + "\r\n")
+		line = line[cut:]
+		first = false
+	}
+}
+
+func (w *icsWriter) write(s string) {
+	if w.err == nil {
+		_, w.err = w.w.WriteString(s)
+	}
+}
@@ -0,0 +1,106 @@
+package calendar
+
+import (
+	"bytes"
+	"github.com/japersik/safe-flight-bot/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWrite(t *testing.T) {
+	start := time.Date(2030, 6, 1, 10, 0, 0, 0, time.FixedZone("MSK", 3*60*60))
+	now := time.Date(2030, 5, 20, 12, 0, 0, 0, time.UTC)
+	coordinate := model.Coordinate{Lat: 55.7558, Lng: 37.6173}
+	tests := []struct {
+		name     string
+		plan     model.FlyPlan
+		contains []string
+		excludes []string
+	}{
+		{
+			name: "one-off plan",
+			plan: model.FlyPlan{FlyId: 7, FlyDateTime: start, Data: model.FlyData{Coordinate: coordinate, Radius: 300}},
+			contains: []string{"UID:plan-7@safe-flight-bot\r\n", "DTSTAMP:20300520T120000Z\r\n",
+				"DTSTART:20300601T070000Z\r\n", "DTEND:20300601T080000Z\r\n", "SUMMARY:Полёт БВС (план №7)\r\n",
+				"GEO:55.755800;37.617300\r\n", `LOCATION:55.755800\, 37.617300`},
+			excludes: []string{"RRULE"},
+		},
+		{
+			name: "every day plan",
+			plan: model.FlyPlan{FlyId: 8, FlyDateTime: start, IsEveryDayPlan: true, Data: model.FlyData{Coordinate: coordinate}},
+			contains: []string{"RRULE:FREQ=DAILY\r\n",
+				"SUMMARY:Ежедневная проверка условий полёта (план №8)\r\n"},
+		},
+		{
+			name: "last check in description",
+			plan: model.FlyPlan{FlyId: 9, FlyDateTime: start, Data: model.FlyData{Coordinate: coordinate},
+				LastCheck: &model.FlightConditions{CheckedAt: now,
+					Weather:     &model.WeatherData{Temperature: 18, WindSpeed: 3.5, PrecipProb: 0.4},
+					ActiveZones: []string{"UUR123"}}},
+			contains: []string{`вероятность осадков 40%`, `Действующие зоны ограничений: UUR123`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			if err := Write(buf, "Полёты", []model.FlyPlan{tt.plan}, now); err != nil {
+				t.Fatal(err)
+			}
+			data := buf.String()
+			if !strings.HasPrefix(data, "BEGIN:VCALENDAR\r\n") || !strings.HasSuffix(data, "END:VCALENDAR\r\n") {
+				t.Errorf("calendar is not wrapped in VCALENDAR:\n%s", data)
+			}
+			for _, line := range strings.Split(strings.TrimSuffix(data, "\r\n"), "\r\n") {
+				if len(line) > maxLineLen {
+					t.Errorf("line is longer than %d octets: %q", maxLineLen, line)
+				}
+			}
+			//проверяем содержимое после объединения перенесенных строк
+			unfolded := strings.ReplaceAll(data, "\r\n ", "")
+			for _, s := range tt.contains {
+				if !strings.Contains(unfolded, s) {
+					t.Errorf("calendar does not contain %q:\n%s", s, unfolded)
+				}
+			}
+			for _, s := range tt.excludes {
+				if strings.Contains(unfolded, s) {
+					t.Errorf("calendar contains %q:\n%s", s, unfolded)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteParseRoundTrip(t *testing.T) {
+	start := time.Date(2030, 6, 1, 7, 0, 0, 0, time.UTC)
+	plans := []model.FlyPlan{
+		{FlyId: 1, FlyDateTime: start, Data: model.FlyData{Coordinate: model.Coordinate{Lat: 55.7558, Lng: 37.6173},
+			Radius: 300, Altitude: 120}, Drone: &model.Drone{Name: "Mavic 3"}},
+		{FlyId: 2, FlyDateTime: start.Add(48 * time.Hour), IsEveryDayPlan: true,
+			Data: model.FlyData{Coordinate: model.Coordinate{Lat: -33.8688, Lng: 151.2093}}},
+	}
+	buf := &bytes.Buffer{}
+	if err := Write(buf, "Полёты", plans, start); err != nil {
+		t.Fatal(err)
+	}
+	events, err := Parse(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != len(plans) {
+		t.Fatalf("Parse() returned %d events, want %d", len(events), len(plans))
+	}
+	for i, plan := range plans {
+		event := events[i]
+		if !event.Start.Equal(plan.FlyDateTime) || !event.End.Equal(plan.FlyDateTime.Add(model.DefaultFlyDuration)) {
+			t.Errorf("event %d time = %v - %v, want start %v", i, event.Start, event.End, plan.FlyDateTime)
+		}
+		if event.Floating || event.Daily != plan.IsEveryDayPlan {
+			t.Errorf("event %d floating = %v, daily = %v", i, event.Floating, event.Daily)
+		}
+		if event.Geo == nil || *event.Geo != plan.Data.Coordinate {
+			t.Errorf("event %d geo = %v, want %v", i, event.Geo, plan.Data.Coordinate)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package calendar
+
+import (
+	"bytes"
+	"github.com/japersik/safe-flight-bot/logger"
+	"github.com/japersik/safe-flight-bot/model"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//FeedPath путь ссылки на календарь: FeedPath + <token> + ".ics"
+const FeedPath = "/calendar/"
+
+type PlanSource interface {
+	UserPlans(userId int64) []model.FlyPlan
+}
+
+type TokenResolver interface {
+	UserByCalendarToken(token string) (userId int64, ok bool)
+}
+
+//FeedHandler http обработчик календаря по секретному токену пользователя. Планы читаются при каждом запросе,
+//поэтому календарь всегда совпадает с планировщиком
+type FeedHandler struct {
+	plans  PlanSource
+	tokens TokenResolver
+}
+
+//NewFeedHandler ...
+func NewFeedHandler(plans PlanSource, tokens TokenResolver) *FeedHandler {
+	return &FeedHandler{plans: plans, tokens: tokens}
+}
+
+func (h *FeedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, FeedPath), ".ics")
+	userId, ok := h.tokens.UserByCalendarToken(token)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	buf := &bytes.Buffer{}
+	if err := Write(buf, "Полёты БВС", h.plans.UserPlans(userId), time.Now()); err != nil {
+		logger.FromContext(r.Context()).Error("calendar feed error", logger.UserId(userId), logger.Err(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(buf.Bytes())
+}
+
+//ListenAndServe запуск http сервера календарей
+func ListenAndServe(addr string, handler *FeedHandler) error {
+	mux := http.NewServeMux()
+	mux.Handle(FeedPath, handler)
+	server := &http.Server{
+		Addr:         addr,
+		Handler:      mux,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+	logger.Info("calendar feed listening", logger.String("addr", addr))
+	return server.ListenAndServe()
+}
@@ -0,0 +1,224 @@
+package calendar
+
+import (
+	"bufio"
+	"errors"
+	"github.com/japersik/safe-flight-bot/model"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var NoEventsErr = errors.New("calendar has no events")
+
+//Event событие календаря, из которого можно создать план полёта
+type Event struct {
+	UID      string
+	Summary  string
+	Location string
+	// координаты из свойства GEO, nil если не указаны
+	Geo   *model.Coordinate
+	Start time.Time
+	End   time.Time
+	// время указано без часового пояса и должно быть интерпретировано в местном времени места полёта
+	Floating bool
+	Daily    bool
+}
+
+//InLocation переводит "плавающее" время события в часовой пояс места полёта
+func (e *Event) InLocation(loc *time.Location) {
+	if !e.Floating || loc == nil {
+		return
+	}
+	e.Start = time.Date(e.Start.Year(), e.Start.Month(), e.Start.Day(), e.Start.Hour(), e.Start.Minute(), e.Start.Second(), 0, loc)
+	e.End = time.Date(e.End.Year(), e.End.Month(), e.End.Day(), e.End.Hour(), e.End.Minute(), e.End.Second(), 0, loc)
+	e.Floating = false
+}
+
+//property строка содержимого iCalendar: NAME;PARAM=VALUE:value
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+//Parse разбор VEVENT из документа iCalendar (RFC 5545)
+func Parse(r io.Reader) ([]Event, error) {
+	lines, err := unfoldLines(r)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]Event, 0)
+	var event *Event
+	depth := 0
+	for _, line := range lines {
+		prop, ok := parseProperty(line)
+		if !ok {
+			continue
+		}
+		switch {
+		case prop.name == "BEGIN" && strings.EqualFold(prop.value, "VEVENT"):
+			event = &Event{}
+			depth = 0
+		case prop.name == "BEGIN" && event != nil:
+			//вложенные компоненты, например VALARM
+			depth++
+		case prop.name == "END" && event != nil && depth > 0:
+			depth--
+		case prop.name == "END" && strings.EqualFold(prop.value, "VEVENT") && event != nil:
+			if !event.Start.IsZero() {
+				if event.End.IsZero() || !event.End.After(event.Start) {
+					event.End = event.Start.Add(model.DefaultFlyDuration)
+				}
+				events = append(events, *event)
+			}
+			event = nil
+		case event != nil && depth == 0:
+			applyProperty(event, prop)
+		}
+	}
+	if len(events) == 0 {
+		return nil, NoEventsErr
+	}
+	return events, nil
+}
+
+func applyProperty(event *Event, prop property) {
+	switch prop.name {
+	case "UID":
+		event.UID = prop.value
+	case "SUMMARY":
+		event.Summary = unescapeText(prop.value)
+	case "LOCATION":
+		event.Location = unescapeText(prop.value)
+	case "GEO":
+		parts := strings.Split(prop.value, ";")
+		if len(parts) != 2 {
+			return
+		}
+		lat, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+		lng, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err1 == nil && err2 == nil && lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180 {
+			event.Geo = &model.Coordinate{Lat: lat, Lng: lng}
+		}
+	case "DTSTART":
+		if t, floating, ok := parseDateTime(prop); ok {
+			event.Start, event.Floating = t, floating
+		}
+	case "DTEND":
+		if t, _, ok := parseDateTime(prop); ok {
+			event.End = t
+		}
+	case "DURATION":
+		if d, ok := parseDuration(prop.value); ok && !event.Start.IsZero() {
+			event.End = event.Start.Add(d)
+		}
+	case "RRULE":
+		event.Daily = strings.Contains(strings.ToUpper(prop.value), "FREQ=DAILY")
+	}
+}
+
+//parseDateTime разбор DATE-TIME в UTC, с TZID или без часового пояса, а также DATE
+func parseDateTime(prop property) (t time.Time, floating bool, ok bool) {
+	value := prop.value
+	if prop.params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err := time.Parse("20060102", value)
+		return t, true, err == nil
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(utcLayout, value)
+		return t, false, err == nil
+	}
+	if tzid := prop.params["TZID"]; tzid != "" {
+		if loc, err := time.LoadLocation(strings.Trim(tzid, `"`)); err == nil {
+			t, err := time.ParseInLocation("20060102T150405", value, loc)
+			return t, false, err == nil
+		}
+	}
+	t, err := time.Parse("20060102T150405", value)
+	return t, true, err == nil
+}
+
+//parseDuration разбор DURATION вида PT1H30M или P1D
+func parseDuration(value string) (time.Duration, bool) {
+	value = strings.TrimPrefix(strings.ToUpper(value), "+")
+	if !strings.HasPrefix(value, "P") {
+		return 0, false
+	}
+	var d time.Duration
+	number := 0
+	inTime := false
+	for _, c := range value[1:] {
+		switch {
+		case c >= '0' && c <= '9':
+			number = number*10 + int(c-'0')
+		case c == 'T':
+			inTime = true
+		case c == 'W':
+			d += time.Duration(number) * 7 * 24 * time.Hour
+			number = 0
+		case c == 'D':
+			d += time.Duration(number) * 24 * time.Hour
+			number = 0
+		case c == 'H' && inTime:
+			d += time.Duration(number) * time.Hour
+			number = 0
+		case c == 'M' && inTime:
+			d += time.Duration(number) * time.Minute
+			number = 0
+		case c == 'S' && inTime:
+			d += time.Duration(number) * time.Second
+			number = 0
+		default:
+			return 0, false
+		}
+	}
+	return d, d > 0
+}
+
+//unfoldLines объединение строк, перенесенных по 75 октетов
+func unfoldLines(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lines := make([]string, 0)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+func parseProperty(line string) (property, bool) {
+	//двоеточие может встречаться в значениях параметров в кавычках
+	inQuotes := false
+	colon := -1
+	for i, c := range line {
+		if c == '"' {
+			inQuotes = !inQuotes
+		} else if c == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+	if colon <= 0 {
+		return property{}, false
+	}
+	parts := strings.Split(line[:colon], ";")
+	prop := property{name: strings.ToUpper(parts[0]), params: map[string]string{}, value: line[colon+1:]}
+	for _, param := range parts[1:] {
+		if kv := strings.SplitN(param, "=", 2); len(kv) == 2 {
+			prop.params[strings.ToUpper(kv[0])] = kv[1]
+		}
+	}
+	return prop, true
+}
+
+func unescapeText(value string) string {
+	replacer := strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+	return replacer.Replace(value)
+}
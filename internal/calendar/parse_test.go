@@ -0,0 +1,168 @@
+package calendar
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Skip("no tzdata:", err)
+	}
+	event := func(lines ...string) string {
+		return "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\n" + strings.Join(lines, "\r\n") + "\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	}
+	tests := []struct {
+		name     string
+		data     string
+		start    time.Time
+		end      time.Time
+		floating bool
+		daily    bool
+		summary  string
+		geo      bool
+	}{
+		{
+			name:  "utc",
+			data:  event("UID:1", "DTSTART:20300601T070000Z", "DTEND:20300601T093000Z"),
+			start: time.Date(2030, 6, 1, 7, 0, 0, 0, time.UTC),
+			end:   time.Date(2030, 6, 1, 9, 30, 0, 0, time.UTC),
+		},
+		{
+			name:  "tzid",
+			data:  event(`DTSTART;TZID="Europe/Moscow":20300601T100000`, "DTEND;TZID=Europe/Moscow:20300601T110000"),
+			start: time.Date(2030, 6, 1, 10, 0, 0, 0, moscow),
+			end:   time.Date(2030, 6, 1, 11, 0, 0, 0, moscow),
+		},
+		{
+			name:     "floating time",
+			data:     event("DTSTART:20300601T100000"),
+			start:    time.Date(2030, 6, 1, 10, 0, 0, 0, time.UTC),
+			end:      time.Date(2030, 6, 1, 11, 0, 0, 0, time.UTC),
+			floating: true,
+		},
+		{
+			name:     "date",
+			data:     event("DTSTART;VALUE=DATE:20300601"),
+			start:    time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC),
+			end:      time.Date(2030, 6, 1, 1, 0, 0, 0, time.UTC),
+			floating: true,
+		},
+		{
+			name:  "duration",
+			data:  event("DTSTART:20300601T070000Z", "DURATION:PT1H30M"),
+			start: time.Date(2030, 6, 1, 7, 0, 0, 0, time.UTC),
+			end:   time.Date(2030, 6, 1, 8, 30, 0, 0, time.UTC),
+		},
+		{
+			name:  "end before start",
+			data:  event("DTSTART:20300601T070000Z", "DTEND:20300601T060000Z"),
+			start: time.Date(2030, 6, 1, 7, 0, 0, 0, time.UTC),
+			end:   time.Date(2030, 6, 1, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "alarm properties are ignored",
+			data: event("DTSTART:20300601T070000Z", "SUMMARY:Полёт", "BEGIN:VALARM", "TRIGGER:-PT15M",
+				"SUMMARY:Напоминание", "END:VALARM", "RRULE:FREQ=DAILY;COUNT=5"),
+			start:   time.Date(2030, 6, 1, 7, 0, 0, 0, time.UTC),
+			end:     time.Date(2030, 6, 1, 8, 0, 0, 0, time.UTC),
+			daily:   true,
+			summary: "Полёт",
+		},
+		{
+			name:    "folded and escaped text",
+			data:    event("DTSTART:20300601T070000Z", "SUMMARY:Съемка\\, парк", "  Горького\\; южная часть", "GEO:55.7291;37.6011"),
+			start:   time.Date(2030, 6, 1, 7, 0, 0, 0, time.UTC),
+			end:     time.Date(2030, 6, 1, 8, 0, 0, 0, time.UTC),
+			summary: "Съемка, парк Горького; южная часть",
+			geo:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := Parse(strings.NewReader(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != 1 {
+				t.Fatalf("Parse() returned %d events, want 1", len(events))
+			}
+			e := events[0]
+			if !e.Start.Equal(tt.start) || !e.End.Equal(tt.end) {
+				t.Errorf("time = %v - %v, want %v - %v", e.Start, e.End, tt.start, tt.end)
+			}
+			if e.Floating != tt.floating || e.Daily != tt.daily {
+				t.Errorf("floating = %v, daily = %v, want %v, %v", e.Floating, e.Daily, tt.floating, tt.daily)
+			}
+			if e.Summary != tt.summary {
+				t.Errorf("summary = %q, want %q", e.Summary, tt.summary)
+			}
+			if (e.Geo != nil) != tt.geo {
+				t.Errorf("geo = %v, want present %v", e.Geo, tt.geo)
+			}
+		})
+	}
+}
+
+func TestParseNoEvents(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"empty", ""},
+		{"no events", "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nEND:VCALENDAR\r\n"},
+		{"event without start", "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:1\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"},
+		{"not a calendar", "hello world"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(strings.NewReader(tt.data)); !errors.Is(err, NoEventsErr) {
+				t.Errorf("Parse() error = %v, want %v", err, NoEventsErr)
+			}
+		})
+	}
+}
+
+func TestEventInLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+5", 5*60*60)
+	e := Event{Start: time.Date(2030, 6, 1, 10, 0, 0, 0, time.UTC), End: time.Date(2030, 6, 1, 11, 0, 0, 0, time.UTC),
+		Floating: true}
+	e.InLocation(loc)
+	if want := time.Date(2030, 6, 1, 5, 0, 0, 0, time.UTC); !e.Start.Equal(want) || e.Floating {
+		t.Errorf("InLocation() start = %v, floating = %v, want %v", e.Start, e.Floating, want)
+	}
+	//время с часовым поясом не меняется
+	e.InLocation(time.UTC)
+	if want := time.Date(2030, 6, 1, 5, 0, 0, 0, time.UTC); !e.Start.Equal(want) {
+		t.Errorf("InLocation() changed fixed start to %v", e.Start)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"PT1H30M", 90 * time.Minute, true},
+		{"PT45M", 45 * time.Minute, true},
+		{"P1D", 24 * time.Hour, true},
+		{"P1W", 7 * 24 * time.Hour, true},
+		{"P1DT2H", 26 * time.Hour, true},
+		{"+PT90S", 90 * time.Second, true},
+		{"pt2h", 2 * time.Hour, true},
+		{"PT0S", 0, false},
+		{"P1H", 0, false},
+		{"1H", 0, false},
+		{"PT1.5H", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseDuration(tt.value)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseDuration(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
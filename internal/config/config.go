@@ -0,0 +1,332 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/japersik/safe-flight-bot/internal/flyDataClient/avtmClient"
+	"github.com/japersik/safe-flight-bot/internal/flyDataClient/openstreetmapClient"
+	"github.com/japersik/safe-flight-bot/internal/flyPlanner"
+	"github.com/japersik/safe-flight-bot/internal/mapRenderer"
+	"github.com/japersik/safe-flight-bot/internal/tracing"
+	"github.com/japersik/safe-flight-bot/model"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v3"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+//Config настройки бота и утилит. Загружается из YAML файла, переменные окружения имеют приоритет над файлом
+type Config struct {
+	Telegram     Telegram `yaml:"telegram"`
+	Storage      Storage  `yaml:"storage"`
+	Services     Services `yaml:"services"`
+	CalendarFeed Server   `yaml:"calendarFeed"`
+	API          Server   `yaml:"api"`
+	Metrics      Metrics  `yaml:"metrics"`
+	Tracing      Tracing  `yaml:"tracing"`
+	Planner      Planner  `yaml:"planner"`
+	Limits       Limits   `yaml:"limits"`
+	Defaults     Defaults `yaml:"defaults"`
+	Log          Log      `yaml:"log"`
+	// id пользователей Telegram с доступом к командам администрирования
+	Admins []int64 `yaml:"admins"`
+	// период проверки изменений файла конфигурации. 0 - только по сигналу SIGHUP
+	ReloadInterval time.Duration `yaml:"reloadInterval"`
+}
+
+//Telegram ...
+type Telegram struct {
+	Token string `yaml:"token"`
+	// polling или webhook
+	Mode    string  `yaml:"mode"`
+	Webhook Webhook `yaml:"webhook"`
+}
+
+//Webhook ...
+type Webhook struct {
+	Addr        string `yaml:"addr"`
+	URL         string `yaml:"url"`
+	PathSecret  string `yaml:"pathSecret"`
+	SecretToken string `yaml:"secretToken"`
+	TLSCert     string `yaml:"tlsCert"`
+	TLSKey      string `yaml:"tlsKey"`
+	UploadCert  bool   `yaml:"uploadCert"`
+}
+
+//Storage пути к файлам данных
+type Storage struct {
+	Plans   string `yaml:"plans"`
+	Users   string `yaml:"users"`
+	Logbook string `yaml:"logbook"`
+}
+
+//Services адреса внешних сервисов. Позволяют использовать локальные заглушки
+type Services struct {
+	Avtm      Service `yaml:"avtm"`
+	Nominatim Service `yaml:"nominatim"`
+	// URL - шаблон адреса тайлов вида https://tile.openstreetmap.org/{z}/{x}/{y}.png
+	Tiles Service `yaml:"tiles"`
+}
+
+//Service ...
+type Service struct {
+	URL     string        `yaml:"url"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+//Server http сервер: адрес для прослушивания и внешний адрес для ссылок. Пустой Addr - сервер не запускается
+type Server struct {
+	Addr string `yaml:"addr"`
+	URL  string `yaml:"url"`
+}
+
+//Metrics сервер /metrics, /healthz и /readyz. Пустой Addr - сервер не запускается
+type Metrics struct {
+	Addr string `yaml:"addr"`
+}
+
+//Tracing экспорт трассировок OpenTelemetry
+type Tracing struct {
+	// none, otlp или stdout
+	Exporter string `yaml:"exporter"`
+	// адрес OTLP/HTTP коллектора, например http://localhost:4318. Пустой - из OTEL_EXPORTER_OTLP_ENDPOINT
+	Endpoint string `yaml:"endpoint"`
+	// доля записываемых трасс от 0 до 1
+	SampleRatio float64 `yaml:"sampleRatio"`
+}
+
+//Planner ...
+type Planner struct {
+	UpdateInterval time.Duration `yaml:"updateInterval"`
+}
+
+//Limits защита от перегрузки внешних сервисов. Нулевые значения отключают ограничение
+type Limits struct {
+	// сообщения, нажатия кнопок и inline запросы одного пользователя
+	Updates Rate `yaml:"updates"`
+	// проверки места одного пользователя в боте и REST API, каждая запрашивает внешние сервисы
+	Checks Rate `yaml:"checks"`
+	// одновременные запросы ко всем внешним сервисам
+	UpstreamConcurrency int `yaml:"upstreamConcurrency"`
+	// активные планы и подписки одного пользователя
+	MaxActivePlans int `yaml:"maxActivePlans"`
+}
+
+//Rate token bucket: PerMinute - скорость пополнения, Burst - число запросов подряд
+type Rate struct {
+	PerMinute int `yaml:"perMinute"`
+	Burst     int `yaml:"burst"`
+}
+
+//Defaults значения для пользователей, не указавших свои
+type Defaults struct {
+	Radius        int             `yaml:"radius"`
+	Altitude      int             `yaml:"altitude"`
+	Notifications []time.Duration `yaml:"notifications"`
+}
+
+//Log ...
+type Log struct {
+	Level string `yaml:"level"`
+}
+
+//Default настройки по умолчанию, совпадающие с поведением без файла конфигурации
+func Default() *Config {
+	defaults := model.GetDefaults()
+	return &Config{
+		Telegram: Telegram{Mode: "polling"},
+		Storage: Storage{
+			Plans:   "data/file.json",
+			Users:   "data/users.json",
+			Logbook: "data/logbook.json",
+		},
+		Services: Services{
+			Avtm:      Service{URL: avtmClient.DefaultBaseURL, Timeout: 3 * time.Second},
+			Nominatim: Service{URL: openstreetmapClient.DefaultBaseURL, Timeout: 3 * time.Second},
+			Tiles:     Service{URL: mapRenderer.DefaultTileServer, Timeout: 5 * time.Second},
+		},
+		Tracing: Tracing{Exporter: tracing.ExporterNone, SampleRatio: 1},
+		Planner: Planner{UpdateInterval: flyPlanner.DefaultUpdateInterval},
+		Limits: Limits{
+			Updates:             Rate{PerMinute: 30, Burst: 10},
+			Checks:              Rate{PerMinute: 6, Burst: 3},
+			UpstreamConcurrency: 16,
+			MaxActivePlans:      20,
+		},
+		Defaults: Defaults{
+			Radius:        defaults.Radius,
+			Altitude:      defaults.Altitude,
+			Notifications: defaults.Notifications,
+		},
+		Log:            Log{Level: "info"},
+		ReloadInterval: 30 * time.Second,
+	}
+}
+
+//Load чтение файла, применение переменных окружения и проверка. Если path пустой или файла нет и required=false,
+//используются значения по умолчанию и окружение
+func Load(path string, required bool) (*Config, error) {
+	cfg := Default()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case err == nil:
+			if err := yaml.Unmarshal(data, cfg); err != nil {
+				return nil, fmt.Errorf("config file %s: %w", path, err)
+			}
+		case errors.Is(err, os.ErrNotExist) && !required:
+		default:
+			return nil, fmt.Errorf("config file: %w", err)
+		}
+	}
+	if err := applyEnv(cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+//ValidationError список ошибок конфигурации
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return "invalid config:\n  " + strings.Join(e, "\n  ")
+}
+
+//Validate проверка значений, общих для бота и утилит
+func (c *Config) Validate() error {
+	var errs ValidationError
+	check := func(ok bool, format string, a ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, a...))
+		}
+	}
+	check(c.Storage.Plans != "", "storage.plans: path is required")
+	check(c.Storage.Users != "", "storage.users: path is required")
+	check(c.Storage.Logbook != "", "storage.logbook: path is required")
+	services := []struct {
+		name    string
+		service Service
+	}{
+		{"services.avtm", c.Services.Avtm},
+		{"services.nominatim", c.Services.Nominatim},
+		{"services.tiles", c.Services.Tiles},
+	}
+	for _, s := range services {
+		check(isHTTPURL(s.service.URL), "%s.url: http(s) url expected, got %q", s.name, s.service.URL)
+		check(s.service.Timeout > 0, "%s.timeout: must be positive", s.name)
+	}
+	if c.CalendarFeed.Addr != "" {
+		check(isHTTPURL(c.CalendarFeed.URL), "calendarFeed.url: http(s) url is required when calendarFeed.addr is set")
+	}
+	if c.API.Addr != "" {
+		check(c.API.URL == "" || isHTTPURL(c.API.URL), "api.url: http(s) url expected, got %q", c.API.URL)
+	}
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout:
+	case tracing.ExporterOTLP:
+		check(c.Tracing.Endpoint == "" || isHTTPURL(c.Tracing.Endpoint), "tracing.endpoint: http(s) url expected, got %q", c.Tracing.Endpoint)
+	default:
+		check(false, "tracing.exporter: none, otlp or stdout expected, got %q", c.Tracing.Exporter)
+	}
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sampleRatio: must be between 0 and 1")
+	check(c.Planner.UpdateInterval >= 10*time.Second, "planner.updateInterval: must be at least 10s")
+	rates := []struct {
+		name string
+		rate Rate
+	}{
+		{"limits.updates", c.Limits.Updates},
+		{"limits.checks", c.Limits.Checks},
+	}
+	for _, r := range rates {
+		check(r.rate.PerMinute >= 0, "%s.perMinute: must not be negative", r.name)
+		check(r.rate.PerMinute == 0 || r.rate.Burst > 0, "%s.burst: must be positive when perMinute is set", r.name)
+	}
+	check(c.Limits.UpstreamConcurrency >= 0, "limits.upstreamConcurrency: must not be negative")
+	check(c.Limits.MaxActivePlans >= 0, "limits.maxActivePlans: must not be negative")
+	check(c.Defaults.Radius > 0 && c.Defaults.Radius <= model.MaxRadius, "defaults.radius: must be between 1 and %d", model.MaxRadius)
+	check(c.Defaults.Altitude > 0 && c.Defaults.Altitude <= model.MaxAltitude, "defaults.altitude: must be between 1 and %d", model.MaxAltitude)
+	_, err := model.NormalizeNotifications(c.Defaults.Notifications)
+	check(err == nil, "defaults.notifications: %v", err)
+	_, err = zapcore.ParseLevel(c.Log.Level)
+	check(err == nil, "log.level: unknown level %q", c.Log.Level)
+	for _, id := range c.Admins {
+		check(id > 0, "admins: invalid user id %d", id)
+	}
+	check(c.ReloadInterval >= 0, "reloadInterval: must not be negative")
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+//ValidateBot дополнительные проверки для запуска бота
+func (c *Config) ValidateBot() error {
+	var errs ValidationError
+	if c.Telegram.Token == "" {
+		errs = append(errs, "telegram.token: bot token is required (TG_BOT_TOKEN)")
+	}
+	switch c.Telegram.Mode {
+	case "polling":
+	case "webhook":
+		if c.Telegram.Webhook.Addr == "" {
+			errs = append(errs, "telegram.webhook.addr: listen address is required in webhook mode")
+		}
+		if !strings.HasPrefix(c.Telegram.Webhook.URL, "https://") {
+			errs = append(errs, "telegram.webhook.url: https url is required in webhook mode")
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("telegram.mode: polling or webhook expected, got %q", c.Telegram.Mode))
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+//LogLevel ...
+func (c *Config) LogLevel() zapcore.Level {
+	level, _ := zapcore.ParseLevel(c.Log.Level)
+	return level
+}
+
+//ModelDefaults значения по умолчанию для model.SetDefaults
+func (c *Config) ModelDefaults() model.Defaults {
+	return model.Defaults{
+		Radius:        c.Defaults.Radius,
+		Altitude:      c.Defaults.Altitude,
+		Notifications: c.Defaults.Notifications,
+	}
+}
+
+//IsAdmin ...
+func (c *Config) IsAdmin(userId int64) bool {
+	for _, id := range c.Admins {
+		if id == userId {
+			return true
+		}
+	}
+	return false
+}
+
+func isHTTPURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+//DefaultPath путь к файлу конфигурации: переменная CONFIG_FILE или config.yaml в рабочем каталоге
+func DefaultPath() string {
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		return path
+	}
+	return "config.yaml"
+}
+
+//PathRequired файл, указанный явно, должен существовать. config.yaml по умолчанию необязателен
+func PathRequired(path string) bool {
+	return path != "config.yaml"
+}
@@ -0,0 +1,189 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+//clearEnv пустые значения переменных не применяются, поэтому окружение процесса не влияет на тесты
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, env := range envOverrides {
+		t.Setenv(env.name, "")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		env     map[string]string
+		check   func(c *Config) bool
+		invalid []string
+	}{
+		{
+			name:  "defaults without file",
+			check: func(c *Config) bool { return reflect.DeepEqual(c, Default()) },
+		},
+		{
+			name: "file values",
+			file: "telegram:\n  mode: webhook\nlimits:\n  maxActivePlans: 5\ndefaults:\n  notifications: [-1h, 0s]\n",
+			check: func(c *Config) bool {
+				return c.Telegram.Mode == "webhook" && c.Limits.MaxActivePlans == 5 &&
+					reflect.DeepEqual(c.Defaults.Notifications, []time.Duration{-time.Hour, 0}) &&
+					c.Storage.Plans == "data/file.json"
+			},
+		},
+		{
+			name: "env overrides file",
+			file: "storage:\n  plans: from-file.json\nlog:\n  level: debug\n",
+			env: map[string]string{"PLANS_FILE": "from-env.json", "ADMIN_IDS": "1, 2", "WEBHOOK_UPLOAD_CERT": "true",
+				"TRACING_SAMPLE_RATIO": "0.5", "DEFAULT_NOTIFICATIONS": "-2h,0"},
+			check: func(c *Config) bool {
+				return c.Storage.Plans == "from-env.json" && c.Log.Level == "debug" &&
+					reflect.DeepEqual(c.Admins, []int64{1, 2}) && c.Telegram.Webhook.UploadCert &&
+					c.Tracing.SampleRatio == 0.5 &&
+					reflect.DeepEqual(c.Defaults.Notifications, []time.Duration{-2 * time.Hour, 0})
+			},
+		},
+		{
+			name:    "invalid env values",
+			env:     map[string]string{"LIMIT_MAX_ACTIVE_PLANS": "many", "AVTM_TIMEOUT": "3"},
+			invalid: []string{"LIMIT_MAX_ACTIVE_PLANS", "AVTM_TIMEOUT"},
+		},
+		{
+			name: "validation errors",
+			file: "services:\n  avtm:\n    url: ftp://example.com\nplanner:\n  updateInterval: 1s\n" +
+				"defaults:\n  notifications: [-1h]\nlog:\n  level: loud\n",
+			invalid: []string{"services.avtm.url", "planner.updateInterval", "defaults.notifications", "log.level"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if tt.file != "" {
+				if err := os.WriteFile(path, []byte(tt.file), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cfg, err := Load(path, false)
+			if tt.invalid != nil {
+				var validation ValidationError
+				if !errors.As(err, &validation) {
+					t.Fatalf("Load() error = %v, want ValidationError", err)
+				}
+				for _, field := range tt.invalid {
+					if !strings.Contains(err.Error(), field) {
+						t.Errorf("error does not mention %s:\n%v", field, err)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.check(cfg) {
+				t.Errorf("unexpected config %+v", cfg)
+			}
+		})
+	}
+}
+
+func TestLoadRequired(t *testing.T) {
+	clearEnv(t)
+	path := filepath.Join(t.TempDir(), "missing.yaml")
+	if _, err := Load(path, true); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Load() of required missing file error = %v", err)
+	}
+	bad := filepath.Join(t.TempDir(), "bad.yaml")
+	if err := os.WriteFile(bad, []byte("limits: [1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(bad, false); err == nil {
+		t.Error("Load() accepted malformed YAML")
+	}
+}
+
+func TestValidateBot(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		invalid []string
+	}{
+		{"polling", func(c *Config) { c.Telegram.Token = "token" }, nil},
+		{"no token", func(c *Config) {}, []string{"telegram.token"}},
+		{"webhook", func(c *Config) {
+			c.Telegram = Telegram{Token: "token", Mode: "webhook", Webhook: Webhook{Addr: ":8443", URL: "https://example.com"}}
+		}, nil},
+		{"webhook without https", func(c *Config) {
+			c.Telegram = Telegram{Token: "token", Mode: "webhook", Webhook: Webhook{URL: "http://example.com"}}
+		}, []string{"telegram.webhook.addr", "telegram.webhook.url"}},
+		{"unknown mode", func(c *Config) { c.Telegram = Telegram{Token: "token", Mode: "push"} }, []string{"telegram.mode"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.modify(cfg)
+			err := cfg.ValidateBot()
+			if (err != nil) != (tt.invalid != nil) {
+				t.Fatalf("ValidateBot() error = %v", err)
+			}
+			for _, field := range tt.invalid {
+				if !strings.Contains(err.Error(), field) {
+					t.Errorf("error does not mention %s:\n%v", field, err)
+				}
+			}
+		})
+	}
+}
+
+func TestSetField(t *testing.T) {
+	var (
+		s         string
+		b         bool
+		i         int
+		f         float64
+		d         time.Duration
+		durations []time.Duration
+		ids       []int64
+	)
+	tests := []struct {
+		field interface{}
+		value string
+		want  interface{}
+		ok    bool
+	}{
+		{&s, "text", "text", true},
+		{&b, "true", true, true},
+		{&b, "yes", nil, false},
+		{&i, "42", 42, true},
+		{&i, "4.2", nil, false},
+		{&f, "0.25", 0.25, true},
+		{&d, "90s", 90 * time.Second, true},
+		{&d, "90", nil, false},
+		{&durations, "-1h, 0s,,2h", []time.Duration{-time.Hour, 0, 2 * time.Hour}, true},
+		{&durations, "-1h,soon", nil, false},
+		{&ids, "10,20", []int64{10, 20}, true},
+		{&ids, "ten", nil, false},
+		{&[]string{}, "a,b", nil, false},
+	}
+	for _, tt := range tests {
+		err := setField(tt.field, tt.value)
+		if (err == nil) != tt.ok {
+			t.Errorf("setField(%T, %q) error = %v, want ok %v", tt.field, tt.value, err, tt.ok)
+			continue
+		}
+		if got := reflect.ValueOf(tt.field).Elem().Interface(); tt.ok && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("setField(%T, %q) = %v, want %v", tt.field, tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//envOverrides переменные окружения и соответствующие им поля конфигурации.
+//Имена переменных, существовавших до появления файла конфигурации, сохранены
+var envOverrides = []struct {
+	name  string
+	field func(c *Config) interface{}
+}{
+	{"TG_BOT_TOKEN", func(c *Config) interface{} { return &c.Telegram.Token }},
+	{"BOT_MODE", func(c *Config) interface{} { return &c.Telegram.Mode }},
+	{"WEBHOOK_ADDR", func(c *Config) interface{} { return &c.Telegram.Webhook.Addr }},
+	{"WEBHOOK_URL", func(c *Config) interface{} { return &c.Telegram.Webhook.URL }},
+	{"WEBHOOK_PATH_SECRET", func(c *Config) interface{} { return &c.Telegram.Webhook.PathSecret }},
+	{"WEBHOOK_SECRET_TOKEN", func(c *Config) interface{} { return &c.Telegram.Webhook.SecretToken }},
+	{"WEBHOOK_TLS_CERT", func(c *Config) interface{} { return &c.Telegram.Webhook.TLSCert }},
+	{"WEBHOOK_TLS_KEY", func(c *Config) interface{} { return &c.Telegram.Webhook.TLSKey }},
+	{"WEBHOOK_UPLOAD_CERT", func(c *Config) interface{} { return &c.Telegram.Webhook.UploadCert }},
+	{"PLANS_FILE", func(c *Config) interface{} { return &c.Storage.Plans }},
+	{"USERS_FILE", func(c *Config) interface{} { return &c.Storage.Users }},
+	{"LOGBOOK_FILE", func(c *Config) interface{} { return &c.Storage.Logbook }},
+	{"AVTM_URL", func(c *Config) interface{} { return &c.Services.Avtm.URL }},
+	{"AVTM_TIMEOUT", func(c *Config) interface{} { return &c.Services.Avtm.Timeout }},
+	{"NOMINATIM_URL", func(c *Config) interface{} { return &c.Services.Nominatim.URL }},
+	{"NOMINATIM_TIMEOUT", func(c *Config) interface{} { return &c.Services.Nominatim.Timeout }},
+	{"MAP_TILE_SERVER", func(c *Config) interface{} { return &c.Services.Tiles.URL }},
+	{"MAP_TILE_TIMEOUT", func(c *Config) interface{} { return &c.Services.Tiles.Timeout }},
+	{"CALENDAR_FEED_ADDR", func(c *Config) interface{} { return &c.CalendarFeed.Addr }},
+	{"CALENDAR_FEED_URL", func(c *Config) interface{} { return &c.CalendarFeed.URL }},
+	{"API_ADDR", func(c *Config) interface{} { return &c.API.Addr }},
+	{"API_URL", func(c *Config) interface{} { return &c.API.URL }},
+	{"METRICS_ADDR", func(c *Config) interface{} { return &c.Metrics.Addr }},
+	{"TRACING_EXPORTER", func(c *Config) interface{} { return &c.Tracing.Exporter }},
+	{"TRACING_ENDPOINT", func(c *Config) interface{} { return &c.Tracing.Endpoint }},
+	{"TRACING_SAMPLE_RATIO", func(c *Config) interface{} { return &c.Tracing.SampleRatio }},
+	{"PLANNER_UPDATE_INTERVAL", func(c *Config) interface{} { return &c.Planner.UpdateInterval }},
+	{"LIMIT_UPDATES_PER_MINUTE", func(c *Config) interface{} { return &c.Limits.Updates.PerMinute }},
+	{"LIMIT_UPDATES_BURST", func(c *Config) interface{} { return &c.Limits.Updates.Burst }},
+	{"LIMIT_CHECKS_PER_MINUTE", func(c *Config) interface{} { return &c.Limits.Checks.PerMinute }},
+	{"LIMIT_CHECKS_BURST", func(c *Config) interface{} { return &c.Limits.Checks.Burst }},
+	{"LIMIT_UPSTREAM_CONCURRENCY", func(c *Config) interface{} { return &c.Limits.UpstreamConcurrency }},
+	{"LIMIT_MAX_ACTIVE_PLANS", func(c *Config) interface{} { return &c.Limits.MaxActivePlans }},
+	{"DEFAULT_RADIUS", func(c *Config) interface{} { return &c.Defaults.Radius }},
+	{"DEFAULT_ALTITUDE", func(c *Config) interface{} { return &c.Defaults.Altitude }},
+	{"DEFAULT_NOTIFICATIONS", func(c *Config) interface{} { return &c.Defaults.Notifications }},
+	{"LOG_LEVEL", func(c *Config) interface{} { return &c.Log.Level }},
+	{"ADMIN_IDS", func(c *Config) interface{} { return &c.Admins }},
+	{"CONFIG_RELOAD_INTERVAL", func(c *Config) interface{} { return &c.ReloadInterval }},
+}
+
+//applyEnv заданные непустые переменные окружения заменяют значения из файла
+func applyEnv(cfg *Config) error {
+	var errs ValidationError
+	for _, env := range envOverrides {
+		value, ok := os.LookupEnv(env.name)
+		if !ok || value == "" {
+			continue
+		}
+		if err := setField(env.field(cfg), value); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", env.name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func setField(field interface{}, value string) error {
+	var err error
+	switch f := field.(type) {
+	case *string:
+		*f = value
+	case *bool:
+		*f, err = strconv.ParseBool(value)
+	case *int:
+		*f, err = strconv.Atoi(value)
+	case *float64:
+		*f, err = strconv.ParseFloat(value, 64)
+	case *time.Duration:
+		*f, err = time.ParseDuration(value)
+	case *[]time.Duration:
+		list := make([]time.Duration, 0)
+		for _, part := range splitList(value) {
+			d, err := time.ParseDuration(part)
+			if err != nil {
+				return err
+			}
+			list = append(list, d)
+		}
+		*f = list
+	case *[]int64:
+		list := make([]int64, 0)
+		for _, part := range splitList(value) {
+			id, err := strconv.ParseInt(part, 10, 64)
+			if err != nil {
+				return err
+			}
+			list = append(list, id)
+		}
+		*f = list
+	default:
+		return fmt.Errorf("unsupported field type %T", field)
+	}
+	return err
+}
+
+//splitList значения через запятую
+func splitList(value string) []string {
+	parts := strings.Split(value, ",")
+	list := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			list = append(list, part)
+		}
+	}
+	return list
+}
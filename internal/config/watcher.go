@@ -0,0 +1,115 @@
+package config
+
+import (
+	"context"
+	"github.com/japersik/safe-flight-bot/logger"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+)
+
+//Watcher перезагрузка конфигурации по сигналу SIGHUP и при изменении файла. На лету применяются только
+//некритичные значения: уровень логирования, администраторы и значения по умолчанию. Изменение остальных
+//требует перезапуска
+type Watcher struct {
+	path     string
+	current  *Config
+	modTime  time.Time
+	handlers []func(cfg *Config)
+	mutex    *sync.Mutex
+}
+
+//NewWatcher cfg - конфигурация, загруженная из path при запуске
+func NewWatcher(path string, cfg *Config) *Watcher {
+	w := &Watcher{path: path, current: cfg, mutex: &sync.Mutex{}}
+	if info, err := os.Stat(path); err == nil {
+		w.modTime = info.ModTime()
+	}
+	return w
+}
+
+//OnReload handler вызывается с новой конфигурацией после каждой успешной перезагрузки
+func (w *Watcher) OnReload(handler func(cfg *Config)) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.handlers = append(w.handlers, handler)
+}
+
+//Current ...
+func (w *Watcher) Current() *Config {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.current
+}
+
+//Run отслеживание изменений до отмены ctx
+func (w *Watcher) Run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	var tick <-chan time.Time
+	if interval := w.Current().ReloadInterval; interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			logger.Info("SIGHUP received, reloading config")
+			w.reload()
+		case <-tick:
+			if w.fileChanged() {
+				logger.Info("config file changed, reloading")
+				w.reload()
+			}
+		}
+	}
+}
+
+func (w *Watcher) fileChanged() bool {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if info.ModTime().Equal(w.modTime) {
+		return false
+	}
+	w.modTime = info.ModTime()
+	return true
+}
+
+//reload при ошибке продолжает работать предыдущая конфигурация
+func (w *Watcher) reload() {
+	loaded, err := Load(w.path, false)
+	if err != nil {
+		logger.Error("config reload error, previous config is kept", logger.String("file", w.path), logger.Err(err))
+		return
+	}
+	w.mutex.Lock()
+	next := *w.current
+	next.Log = loaded.Log
+	next.Admins = loaded.Admins
+	next.Defaults = loaded.Defaults
+	//остальные поля должны совпасть с текущими, иначе изменения применятся только после перезапуска
+	loaded.Log, loaded.Admins, loaded.Defaults = next.Log, next.Admins, next.Defaults
+	restartRequired := !reflect.DeepEqual(*loaded, next)
+	w.current = &next
+	handlers := append([]func(cfg *Config){}, w.handlers...)
+	w.mutex.Unlock()
+	if restartRequired {
+		logger.Warn("config reloaded, changes of tokens, storage, services and servers require restart")
+	} else {
+		logger.Info("config reloaded", logger.String("file", w.path))
+	}
+	for _, handler := range handlers {
+		handler(&next)
+	}
+}
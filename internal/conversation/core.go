@@ -0,0 +1,268 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"github.com/japersik/safe-flight-bot/internal/coordParser"
+	"github.com/japersik/safe-flight-bot/internal/flyDataClient"
+	"github.com/japersik/safe-flight-bot/internal/flyPlanner"
+	"github.com/japersik/safe-flight-bot/internal/userStorage"
+	"github.com/japersik/safe-flight-bot/logger"
+	"github.com/japersik/safe-flight-bot/model"
+	"html"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//sessionKey ключ сессии диалога: в группах каждый участник планирует независимо
+type sessionKey struct {
+	chatId int64
+	userId int64
+}
+
+//PlanListener получает планы, созданные или измененные в диалоге. Если не задан, ядро само сообщает о создании плана
+type PlanListener interface {
+	PlanSaved(ctx context.Context, in Incoming, plan model.FlyPlan, created bool) error
+}
+
+//Reporter адаптер с собственным оформлением отчетов о месте и ограничением числа проверок.
+//Если не задан, ядро само отправляет отчеты без ограничений
+type Reporter interface {
+	//AllowCheck вызывается перед проверкой места, запрашивающей внешние сервисы. При отказе адаптер сам отвечает
+	//пользователю
+	AllowCheck(ctx context.Context, in Incoming) (bool, error)
+	SendReport(ctx context.Context, in Incoming, coord model.Coordinate) error
+}
+
+//Core ядро диалога, не зависящее от мессенджера: отчеты о возможности полётов, планирование и уведомления.
+//Мессенджеры подключаются через Transport
+type Core struct {
+	client        flyDataClient.Client
+	planner       flyPlanner.Planner
+	users         userStorage.Storage
+	transport     Transport
+	listener      PlanListener
+	reporter      Reporter
+	sessions      map[sessionKey]*planningSession
+	sessionsMutex *sync.Mutex
+	zones         map[string]model.Zone
+	zonesMutex    *sync.Mutex
+}
+
+//NewCore ...
+func NewCore(client flyDataClient.Client, planner flyPlanner.Planner, users userStorage.Storage) *Core {
+	return &Core{
+		client:        client,
+		planner:       planner,
+		users:         users,
+		sessions:      map[sessionKey]*planningSession{},
+		sessionsMutex: &sync.Mutex{},
+		zones:         map[string]model.Zone{},
+		zonesMutex:    &sync.Mutex{},
+	}
+}
+
+//SetTransport ...
+func (c *Core) SetTransport(transport Transport) {
+	c.transport = transport
+}
+
+//SetPlanListener ...
+func (c *Core) SetPlanListener(listener PlanListener) {
+	c.listener = listener
+}
+
+//SetReporter ...
+func (c *Core) SetReporter(reporter Reporter) {
+	c.reporter = reporter
+}
+
+func (c *Core) allowCheck(ctx context.Context, in Incoming) (bool, error) {
+	if c.reporter == nil {
+		return true, nil
+	}
+	return c.reporter.AllowCheck(ctx, in)
+}
+
+func (c *Core) sendReport(ctx context.Context, in Incoming, coord model.Coordinate) error {
+	if c.reporter == nil {
+		return c.SendReport(ctx, in, coord)
+	}
+	return c.reporter.SendReport(ctx, in, coord)
+}
+
+func (c *Core) send(out Outgoing) error {
+	if c.transport == nil {
+		return nil
+	}
+	return c.transport.Send(out)
+}
+
+func (c *Core) sendText(chatId int64, text string) error {
+	return c.send(Outgoing{ChatId: chatId, Text: text})
+}
+
+//Handle полная обработка входящего сообщения для адаптеров без собственных сценариев
+func (c *Core) Handle(ctx context.Context, in Incoming) error {
+	if ok, err := c.HandlePlanning(ctx, in); ok {
+		return err
+	}
+	if in.Action != "" {
+		return c.handleAction(ctx, in)
+	}
+	switch {
+	case in.Location != nil:
+		if ok, err := c.allowCheck(ctx, in); !ok {
+			return err
+		}
+		return c.sendReport(ctx, in, *in.Location)
+	case in.Command == "start" || in.Command == "help":
+		return c.sendText(in.ChatId, "Отправьте геолокацию, координаты, ссылку на карту или название места для "+
+			"получения информации о возможности полётов\n\n/plans - запланированные полёты")
+	case in.Command == "plans":
+		return c.sendPlans(in)
+	case in.Command == "cancel":
+		return c.sendText(in.ChatId, notInPlanningText)
+	case in.Command != "":
+		return c.sendText(in.ChatId, "Сейчас эта команда не поддерживается")
+	}
+	return c.HandleText(ctx, in)
+}
+
+//HandleText поиск координат, ссылки на карту или названия места в тексте сообщения
+func (c *Core) HandleText(ctx context.Context, in Incoming) error {
+	if coord, ok := coordParser.Parse(in.Text); ok {
+		if ok, err := c.allowCheck(ctx, in); !ok {
+			return err
+		}
+		return c.sendReport(ctx, in, coord)
+	}
+	//поиск по названию и подсказка только в личных сообщениях, чтобы не реагировать на переписку в группах
+	if !in.Private {
+		return nil
+	}
+	if strings.TrimSpace(in.Text) != "" {
+		//поиск и отчет по найденному месту считаются одной проверкой
+		if ok, err := c.allowCheck(ctx, in); !ok {
+			return err
+		}
+		places, err := c.client.SearchLocality(ctx, in.Text, 1)
+		if err != nil {
+			logger.FromContext(ctx).Warn("place search error", logger.Err(err))
+		}
+		if len(places) > 0 {
+			if err := c.sendText(in.ChatId, "Найдено место: "+html.EscapeString(places[0].Name)); err != nil {
+				return err
+			}
+			return c.sendReport(ctx, in, places[0].Coordinate)
+		}
+	}
+	return c.sendText(in.ChatId, "Не удалось распознать место. Отправьте геолокацию, координаты "+
+		"(<b>55.7558, 37.6173</b> или <b>55°45'21\"N 37°37'04\"E</b>), ссылку на Google, Яндекс или OpenStreetMap карту "+
+		"или название места")
+}
+
+func (c *Core) handleAction(ctx context.Context, in Incoming) error {
+	kind, arg := parseAction(in.Action)
+	switch kind {
+	case ActionPlan, ActionDaily, ActionRepeat:
+		coord, ok := parseCoordinateArg(arg)
+		if !ok {
+			break
+		}
+		if kind == ActionRepeat {
+			if ok, err := c.allowCheck(ctx, in); !ok {
+				return err
+			}
+			return c.sendReport(ctx, in, coord)
+		}
+		return c.StartPlanning(in, model.FlyPlan{
+			Data:           model.FlyData{Coordinate: coord},
+			IsEveryDayPlan: kind == ActionDaily,
+		})
+	case ActionZone:
+		text := "Информация о зоне устарела, повторите запрос"
+		if zone, ok := c.Zone(arg); ok {
+			text = ZoneText(zone)
+		}
+		return c.sendText(in.ChatId, text)
+	case ActionCancelPlan:
+		flyId, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			break
+		}
+		return c.cancelPlan(ctx, in, flyId)
+	case ActionDrone, ActionCancelPlanning:
+		return c.sendText(in.ChatId, notInPlanningText)
+	}
+	return c.sendText(in.ChatId, "Еще не реализовано:(")
+}
+
+//SendReport отчет о возможности полётов в выбранной точке с кнопками планирования
+func (c *Core) SendReport(ctx context.Context, in Incoming, coord model.Coordinate) error {
+	coord = coord.Rounded()
+	text, report, err := c.Report(ctx, coord, model.DefaultRadius(), model.DefaultAltitude())
+	if err != nil {
+		return err
+	}
+	arg := coordinateArg(coord)
+	buttons := append(ZoneButtons(report.Zones()),
+		[]Button{{Text: "Запланировать полёт тут", Action: action(ActionPlan, arg)}},
+		[]Button{{Text: "Создать ежедневное уведомление", Action: action(ActionDaily, arg)}},
+		[]Button{{Text: "Повторить запрос", Action: action(ActionRepeat, arg)}},
+	)
+	return c.send(Outgoing{ChatId: in.ChatId, Text: text, Buttons: buttons})
+}
+
+//ZoneButtons кнопки для просмотра подробной информации о зонах, по две в ряд
+func ZoneButtons(zones []model.Zone) [][]Button {
+	rows := make([][]Button, 0, len(zones)/2+1)
+	row := make([]Button, 0, 2)
+	for _, zone := range zones {
+		row = append(row, Button{Text: "ℹ " + zone.Code, Action: action(ActionZone, zone.Code)})
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = make([]Button, 0, 2)
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func (c *Core) sendPlans(in Incoming) error {
+	plans := c.planner.UserPlans(in.UserId)
+	if len(plans) == 0 {
+		return c.sendText(in.ChatId, "У вас нет запланированных полётов")
+	}
+	text := "<b>Запланированные полёты</b>\n"
+	buttons := make([][]Button, 0)
+	for _, plan := range plans {
+		when := plan.FlyDateTime.Format("02.01.2006 15:04")
+		if plan.IsEveryDayPlan {
+			when = "ежедневно в " + plan.FlyDateTime.Format("15:04")
+		}
+		text += fmt.Sprintf("№%d: %s, %f, %f\n", plan.FlyId, when, plan.Data.Coordinate.Lat, plan.Data.Coordinate.Lng)
+		if plan.Data.UserId == in.UserId {
+			buttons = append(buttons, []Button{{Text: fmt.Sprintf("Отменить №%d", plan.FlyId),
+				Action: action(ActionCancelPlan, strconv.FormatUint(plan.FlyId, 10))}})
+		}
+	}
+	return c.send(Outgoing{ChatId: in.ChatId, Text: text, Buttons: buttons})
+}
+
+func (c *Core) cancelPlan(ctx context.Context, in Incoming, flyId uint64) error {
+	plan, err := c.planner.GetFly(flyId)
+	if err != nil {
+		return c.sendText(in.ChatId, "Это уведомление уже было отключено")
+	}
+	if plan.Data.UserId != in.UserId {
+		return c.sendText(in.ChatId, "Отключить уведомление может только автор плана")
+	}
+	if err := c.planner.CancelFly(ctx, flyId); err != nil {
+		return c.sendText(in.ChatId, "Это уведомление уже было отключено")
+	}
+	return c.sendText(in.ChatId, "Уведомление успешно отключено")
+}
@@ -0,0 +1,89 @@
+package conversation
+
+import (
+	"fmt"
+	"github.com/japersik/safe-flight-bot/model"
+	"strconv"
+	"strings"
+)
+
+//Incoming входящее сообщение или нажатие кнопки, не зависящее от мессенджера
+type Incoming struct {
+	ChatId int64
+	UserId int64
+	// имя для обращения к пользователю в групповом чате
+	UserName string
+	Private  bool
+	Text     string
+	// команда без "/", например "cancel"
+	Command  string
+	Location *model.Coordinate
+	// Action данные нажатой кнопки (Button.Action)
+	Action string
+}
+
+func (in Incoming) key() sessionKey {
+	return sessionKey{chatId: in.ChatId, userId: in.UserId}
+}
+
+//Button кнопка под сообщением. При нажатии Action возвращается в Incoming.Action
+type Button struct {
+	Text   string
+	Action string
+}
+
+//Outgoing сообщение пользователю. Text размечен подмножеством HTML: <b>, <i>, <a>, <code>
+type Outgoing struct {
+	ChatId  int64
+	Text    string
+	Buttons [][]Button
+	// пользователь, к которому обращено сообщение в групповом чате. Пустой - сообщение всему чату
+	MentionUserId   int64
+	MentionUserName string
+	// сообщение ждет текстового ответа пользователя
+	AwaitReply bool
+}
+
+//Transport адаптер мессенджера: доставка сообщений, сформированных ядром
+type Transport interface {
+	Send(out Outgoing) error
+}
+
+//действия кнопок ядра
+const (
+	ActionPlan           = "plan"
+	ActionDaily          = "daily"
+	ActionRepeat         = "repeat"
+	ActionZone           = "zone"
+	ActionDrone          = "drone"
+	ActionCancelPlanning = "cancel_planning"
+	ActionCancelPlan     = "cancel_plan"
+)
+
+func action(kind string, arg string) string {
+	if arg == "" {
+		return kind
+	}
+	return kind + ":" + arg
+}
+
+func parseAction(data string) (kind string, arg string) {
+	if i := strings.Index(data, ":"); i >= 0 {
+		return data[:i], data[i+1:]
+	}
+	return data, ""
+}
+
+func coordinateArg(coordinate model.Coordinate) string {
+	return fmt.Sprintf("%.6f,%.6f", coordinate.Lat, coordinate.Lng)
+}
+
+func parseCoordinateArg(arg string) (model.Coordinate, bool) {
+	parts := strings.Split(arg, ",")
+	if len(parts) != 2 {
+		return model.Coordinate{}, false
+	}
+	lat, errLat := strconv.ParseFloat(parts[0], 64)
+	lng, errLng := strconv.ParseFloat(parts[1], 64)
+	return model.Coordinate{Lat: lat, Lng: lng}, errLat == nil && errLng == nil
+}
@@ -0,0 +1,75 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"github.com/japersik/safe-flight-bot/internal/flyDataClient"
+	"github.com/japersik/safe-flight-bot/logger"
+	"github.com/japersik/safe-flight-bot/model"
+	"strconv"
+	"time"
+)
+
+//Notification текст автоматического уведомления по плану и зоны для кнопок. Условия проверки сохраняются в плане
+func (c *Core) Notification(ctx context.Context, plan model.FlyPlan, notification time.Duration) (string, flyDataClient.Report, []model.Zone, error) {
+	text, report, err := c.Report(ctx, plan.Data.Coordinate, PlanRadius(plan), plan.Data.Altitude)
+	if err != nil {
+		return "", report, nil, err
+	}
+	from, _ := plan.Window(time.Now())
+	c.planner.SetLastCheck(plan.FlyId, *FlightConditions(report, from))
+	windowText, windowZones := c.FlightWindowText(ctx, plan)
+	text += windowText + DroneText(plan, report)
+	if !plan.IsEveryDayPlan && len(plan.Checklist) > 0 && notification == 0 {
+		text += UncheckedText(plan)
+	}
+	zones := UniqueZones(append(report.Zones(), windowZones...))
+	text = "Автоматическое уведомление №" + strconv.FormatUint(plan.FlyId, 10) + "\n" + text
+	return text, report, zones, nil
+}
+
+//Notify реализация интерфейса flyPlanner.Notifier для адаптеров без собственных уведомлений
+func (c *Core) Notify(ctx context.Context, plan model.FlyPlan, notification time.Duration) error {
+	text, _, zones, err := c.Notification(ctx, plan, notification)
+	if err != nil {
+		return err
+	}
+	buttons := append(ZoneButtons(zones), []Button{{Text: "Отключить уведомление",
+		Action: action(ActionCancelPlan, strconv.FormatUint(plan.FlyId, 10))}})
+	return c.send(Outgoing{ChatId: plan.Data.NotifyChatId(), Text: text, Buttons: buttons})
+}
+
+//PlanCanceledText сообщение подписчикам об отмене плана
+func PlanCanceledText(plan model.FlyPlan) string {
+	return fmt.Sprintf("План №%d отменён автором", plan.FlyId)
+}
+
+//PlanUpdatedText сообщение подписчикам об изменении плана и зоны на новое время полёта для кнопок
+func (c *Core) PlanUpdatedText(ctx context.Context, plan model.FlyPlan) (string, []model.Zone) {
+	windowText, zones := c.FlightWindowText(ctx, plan)
+	return fmt.Sprintf("Автор изменил время плана №%d\n", plan.FlyId) + windowText, zones
+}
+
+//PlanCanceled реализация интерфейса flyPlanner.ChangeListener для адаптеров без собственных уведомлений
+func (c *Core) PlanCanceled(ctx context.Context, plan model.FlyPlan) {
+	c.sendToSubscribers(ctx, plan, Outgoing{Text: PlanCanceledText(plan)})
+}
+
+//PlanUpdated реализация интерфейса flyPlanner.ChangeListener для адаптеров без собственных уведомлений
+func (c *Core) PlanUpdated(ctx context.Context, plan model.FlyPlan) {
+	text, zones := c.PlanUpdatedText(ctx, plan)
+	c.sendToSubscribers(ctx, plan, Outgoing{Text: text, Buttons: ZoneButtons(zones)})
+}
+
+//sendToSubscribers рассылка сообщения участникам команды, кроме чата, в который приходят уведомления плана
+func (c *Core) sendToSubscribers(ctx context.Context, plan model.FlyPlan, out Outgoing) {
+	for _, sub := range plan.Subscribers {
+		if sub.UserId == plan.Data.NotifyChatId() {
+			continue
+		}
+		out.ChatId = sub.UserId
+		if err := c.send(out); err != nil {
+			logger.FromContext(ctx).Warn("subscriber notification error", logger.FlyId(plan.FlyId), logger.UserId(sub.UserId), logger.Err(err))
+		}
+	}
+}
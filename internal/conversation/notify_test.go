@@ -0,0 +1,44 @@
+package conversation
+
+import (
+	"context"
+	"github.com/japersik/safe-flight-bot/internal/flyDataClient"
+	"github.com/japersik/safe-flight-bot/model"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordingTransport []Outgoing
+
+func (r *recordingTransport) Send(out Outgoing) error {
+	*r = append(*r, out)
+	return nil
+}
+
+func TestPlanChangedSubscribers(t *testing.T) {
+	plan := model.FlyPlan{FlyId: 7, FlyDateTime: time.Now().Add(24 * time.Hour),
+		Data:        model.FlyData{UserId: 1, ChatId: -100, Coordinate: moscow},
+		Subscribers: []model.Subscriber{{UserId: 2}, {UserId: -100}, {UserId: 3}}}
+	core := NewCore(flyDataClient.Client{ZoneInfoSource: fakeZones{}}, nil, nil)
+	sent := &recordingTransport{}
+	core.SetTransport(sent)
+
+	core.PlanCanceled(context.Background(), plan)
+	core.PlanUpdated(context.Background(), plan)
+	chats := make([]int64, 0, len(*sent))
+	for _, out := range *sent {
+		chats = append(chats, out.ChatId)
+	}
+	//групповой чат плана пропускается: уведомления плана и так приходят туда
+	if !reflect.DeepEqual(chats, []int64{2, 3, 2, 3}) {
+		t.Fatalf("messages sent to %v", chats)
+	}
+	if text := (*sent)[0].Text; text != PlanCanceledText(plan) {
+		t.Errorf("cancel text = %q", text)
+	}
+	if text := (*sent)[2].Text; !strings.Contains(text, "изменил время плана №7") || !strings.Contains(text, "Зоны на время полёта") {
+		t.Errorf("update text = %q", text)
+	}
+}
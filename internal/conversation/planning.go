@@ -0,0 +1,243 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/japersik/safe-flight-bot/internal/flyPlanner"
+	"github.com/japersik/safe-flight-bot/model"
+	"github.com/zsefvlol/timezonemapper"
+	"strconv"
+	"time"
+)
+
+//planningStage стадия создания плана полёта или ежедневного уведомления
+type planningStage int
+
+const (
+	dateTimeSelect planningStage = iota
+	droneSelect
+)
+
+const notInPlanningText = "Вы не находитесь в режиме планирования"
+
+//PlansLimitText ответ при достижении лимита активных планов пользователя
+const PlansLimitText = "Достигнуто максимальное число активных планов и подписок. " +
+	"Отмените ненужные планы в /plans и попробуйте снова"
+
+//NotificationsErrorText ответ пользователю на ошибку model.ParseNotifications
+func NotificationsErrorText(err error) string {
+	switch {
+	case errors.Is(err, model.TooManyNotificationsErr):
+		return fmt.Sprintf("Можно указать не более %d уведомлений", model.MaxNotifications)
+	case errors.Is(err, model.NotificationOffsetRangeErr):
+		return "Смещение уведомления не может быть больше 7 дней"
+	case errors.Is(err, model.NoNotificationAfterStartErr):
+		return "Нужно хотя бы одно уведомление в момент начала полёта или после него, например 0 или 2h"
+	}
+	return "Не удалось разобрать смещение уведомления. Используйте формат -24h, -90m, 0 или 2h"
+}
+
+//planningSession используется для хранения информации о процессе создания автоматических уведомлений
+type planningSession struct {
+	plan  model.FlyPlan
+	stage planningStage
+}
+
+//StartPlanning начало планирования полёта. План принадлежит чату, в котором создается.
+//Для существующего плана (FlyId != 0) меняется только время
+func (c *Core) StartPlanning(in Incoming, plan model.FlyPlan) error {
+	if plan.FlyId == 0 {
+		plan.Data.UserId = in.ChatId
+		if in.UserId != 0 {
+			plan.Data.UserId = in.UserId
+		}
+		if !in.Private {
+			plan.Data.ChatId = in.ChatId
+		}
+	}
+	session := planningSession{plan: plan, stage: dateTimeSelect}
+	if plan.FlyId == 0 && in.UserId != 0 && len(c.users.GetFleet(in.UserId)) > 0 {
+		session.stage = droneSelect
+	}
+	c.sessionsMutex.Lock()
+	c.sessions[in.key()] = &session
+	c.sessionsMutex.Unlock()
+	return c.sendPlanningStatus(in, session)
+}
+
+//IsPlanning true, если пользователь находится в режиме планирования в этом чате
+func (c *Core) IsPlanning(in Incoming) bool {
+	c.sessionsMutex.Lock()
+	defer c.sessionsMutex.Unlock()
+	_, ok := c.sessions[in.key()]
+	return ok
+}
+
+//PlanningSessions число пользователей, планирующих полёт
+func (c *Core) PlanningSessions() int {
+	c.sessionsMutex.Lock()
+	defer c.sessionsMutex.Unlock()
+	return len(c.sessions)
+}
+
+//HandlePlanning обработка сообщения в режиме планирования. false - пользователь не планирует полёт
+func (c *Core) HandlePlanning(ctx context.Context, in Incoming) (bool, error) {
+	key := in.key()
+	c.sessionsMutex.Lock()
+	session, ok := c.sessions[key]
+	c.sessionsMutex.Unlock()
+	if !ok {
+		return false, nil
+	}
+	if in.Action != "" {
+		return true, c.handlePlanningAction(in)
+	}
+	if in.Command == "cancel" {
+		c.sessionsMutex.Lock()
+		delete(c.sessions, key)
+		c.sessionsMutex.Unlock()
+		return true, c.sendText(in.ChatId, planningCanceledText)
+	}
+	if session.stage == droneSelect {
+		return true, c.sendPlanningStatus(in, *session)
+	}
+	plan := session.plan
+	if plan.IsEveryDayPlan {
+		t, err := ParseTime(in.Text, plan.Data.Coordinate)
+		if err != nil {
+			return true, c.sendPlanningStatus(in, *session)
+		}
+		plan.FlyDateTime = t
+		plan.Notifications = []time.Duration{0}
+	} else {
+		t, err := ParseDateTime(in.Text, plan.Data.Coordinate)
+		if err != nil {
+			return true, c.sendPlanningStatus(in, *session)
+		}
+		plan.FlyDateTime = t
+		plan.Notifications = c.users.GetNotifications(plan.Data.UserId)
+	}
+	c.sessionsMutex.Lock()
+	delete(c.sessions, key)
+	c.sessionsMutex.Unlock()
+	created, err := c.SavePlan(ctx, &plan)
+	if errors.Is(err, flyPlanner.PlansLimitErr) {
+		return true, c.sendText(in.ChatId, PlansLimitText)
+	}
+	if err != nil {
+		return true, err
+	}
+	if c.listener != nil {
+		return true, c.listener.PlanSaved(ctx, in, plan, created)
+	}
+	return true, c.sendPlanCreated(ctx, in, plan)
+}
+
+func (c *Core) handlePlanningAction(in Incoming) error {
+	key := in.key()
+	kind, arg := parseAction(in.Action)
+	c.sessionsMutex.Lock()
+	session, ok := c.sessions[key]
+	switch {
+	case !ok:
+	case kind == ActionCancelPlanning:
+		delete(c.sessions, key)
+	case kind == ActionDrone && session.stage == droneSelect:
+		droneId, _ := strconv.ParseUint(arg, 10, 64)
+		if drone, err := c.users.GetDrone(in.UserId, droneId); err == nil {
+			session.plan.Drone = &drone
+		}
+		session.stage = dateTimeSelect
+	}
+	c.sessionsMutex.Unlock()
+	if !ok {
+		return c.sendText(in.ChatId, notInPlanningText)
+	}
+	if kind == ActionCancelPlanning {
+		return c.sendText(in.ChatId, planningCanceledText)
+	}
+	return c.sendPlanningStatus(in, *session)
+}
+
+//SavePlan создание нового или изменение существующего плана. Новому плану копируется чек-лист автора
+func (c *Core) SavePlan(ctx context.Context, plan *model.FlyPlan) (created bool, err error) {
+	if plan.FlyId == 0 {
+		if !plan.IsEveryDayPlan {
+			plan.Checklist = model.NewChecklist(c.users.GetChecklist(plan.Data.UserId))
+		}
+		plan.FlyId, err = c.planner.PlanFly(*plan)
+		return true, err
+	}
+	if err := c.planner.UpdateFly(ctx, *plan); err != nil {
+		return false, err
+	}
+	if updated, err := c.planner.GetFly(plan.FlyId); err == nil {
+		*plan = updated
+	}
+	return false, nil
+}
+
+//ParseTime местное время ежедневного плана в часовом поясе точки полёта
+func ParseTime(str string, coordinate model.Coordinate) (time.Time, error) {
+	layout := "15:04"
+	timezone := timezonemapper.LatLngToTimezoneString(coordinate.Lat, coordinate.Lng)
+	loc, _ := time.LoadLocation(timezone)
+	return time.ParseInLocation(layout, str, loc)
+}
+
+//ParseDateTime местные дата и время полёта в часовом поясе точки
+func ParseDateTime(str string, coordinate model.Coordinate) (time.Time, error) {
+	layout := "02.01.2006 15:04"
+	timezone := timezonemapper.LatLngToTimezoneString(coordinate.Lat, coordinate.Lng)
+	loc, _ := time.LoadLocation(timezone)
+	return time.ParseInLocation(layout, str, loc)
+}
+
+const planningCanceledText = "Планирование полета/ежедневного уведомления отменено"
+
+func (c *Core) sendPlanningStatus(in Incoming, session planningSession) error {
+	out := Outgoing{
+		ChatId:          in.ChatId,
+		MentionUserId:   in.UserId,
+		MentionUserName: in.UserName,
+		AwaitReply:      true,
+	}
+	cancel := Button{Text: "Отмена", Action: ActionCancelPlanning}
+	switch {
+	case session.stage == droneSelect:
+		out.Text = "Вы находитесь в режиме планирования. Выберите дрон для полёта"
+		out.AwaitReply = false
+		for _, drone := range c.users.GetFleet(in.UserId) {
+			out.Buttons = append(out.Buttons, []Button{{Text: "🚁 " + drone.Name,
+				Action: action(ActionDrone, strconv.FormatUint(drone.DroneId, 10))}})
+		}
+		out.Buttons = append(out.Buttons, []Button{{Text: "Без выбора дрона", Action: action(ActionDrone, "0")}, cancel})
+	case session.plan.IsEveryDayPlan:
+		out.Text = "Вы находитесь в режиме планирования.\n" +
+			` Напишите местное время в формате <b>23:59</b> или нажмите кнопку "Отмена" для отмены планирования полета`
+		out.Buttons = [][]Button{{cancel}}
+	default:
+		out.Text = "Вы находитесь в режиме планирования.\n" +
+			` Напишите дату и местное время в формате <b>01.12.2022 23:59</b> или нажмите кнопку "Отмена" для отмены планирования полета`
+		out.Buttons = [][]Button{{cancel}}
+	}
+	return c.send(out)
+}
+
+//PlanCreatedText сообщение о сохраненном плане и зоны на время полёта для кнопок
+func (c *Core) PlanCreatedText(ctx context.Context, plan model.FlyPlan) (string, []model.Zone) {
+	windowText, zones := c.FlightWindowText(ctx, plan)
+	text := "Уведомление успешно запланировано.\nНажмите кнопку ниже для отмены\n\n" + windowText
+	if plan.Drone != nil {
+		text += "\nДрон: " + DroneDescription(*plan.Drone) + "\n"
+	}
+	return text, zones
+}
+
+func (c *Core) sendPlanCreated(ctx context.Context, in Incoming, plan model.FlyPlan) error {
+	text, zones := c.PlanCreatedText(ctx, plan)
+	buttons := append(ZoneButtons(zones), []Button{{Text: "Отменить уведомление",
+		Action: action(ActionCancelPlan, strconv.FormatUint(plan.FlyId, 10))}})
+	return c.send(Outgoing{ChatId: in.ChatId, Text: text, Buttons: buttons})
+}
@@ -0,0 +1,94 @@
+package conversation
+
+import (
+	"fmt"
+	"github.com/japersik/safe-flight-bot/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	moscow      = model.Coordinate{Lat: 55.7558, Lng: 37.6173}
+	novosibirsk = model.Coordinate{Lat: 55.0084, Lng: 82.9357}
+)
+
+func TestParseDateTime(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		coordinate model.Coordinate
+		want       time.Time
+		ok         bool
+	}{
+		{"moscow", "01.06.2030 10:00", moscow, time.Date(2030, 6, 1, 7, 0, 0, 0, time.UTC), true},
+		{"novosibirsk", "01.06.2030 10:00", novosibirsk, time.Date(2030, 6, 1, 3, 0, 0, 0, time.UTC), true},
+		{"leap day", "29.02.2032 23:59", moscow, time.Date(2032, 2, 29, 20, 59, 0, 0, time.UTC), true},
+		{"no leap day", "29.02.2031 12:00", moscow, time.Time{}, false},
+		{"american order", "06/01/2030 10:00", moscow, time.Time{}, false},
+		{"time only", "10:00", moscow, time.Time{}, false},
+		{"short year", "01.06.30 10:00", moscow, time.Time{}, false},
+		{"hour out of range", "01.06.2030 24:00", moscow, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDateTime(tt.text, tt.coordinate)
+			if (err == nil) != tt.ok {
+				t.Fatalf("ParseDateTime(%q) error = %v, want ok %v", tt.text, err, tt.ok)
+			}
+			if tt.ok && !got.Equal(tt.want) {
+				t.Errorf("ParseDateTime(%q) = %v, want %v", tt.text, got.UTC(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		coordinate model.Coordinate
+		hour       int
+		minute     int
+		location   string
+		ok         bool
+	}{
+		{"moscow", "07:30", moscow, 7, 30, "Europe/Moscow", true},
+		{"novosibirsk", "23:59", novosibirsk, 23, 59, "Asia/Novosibirsk", true},
+		{"single digit hour", "7:05", moscow, 7, 5, "Europe/Moscow", true},
+		{"minutes out of range", "10:60", moscow, 0, 0, "", false},
+		{"with date", "01.06.2030 10:00", moscow, 0, 0, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTime(tt.text, tt.coordinate)
+			if (err == nil) != tt.ok {
+				t.Fatalf("ParseTime(%q) error = %v, want ok %v", tt.text, err, tt.ok)
+			}
+			if !tt.ok {
+				return
+			}
+			if got.Hour() != tt.hour || got.Minute() != tt.minute || got.Location().String() != tt.location {
+				t.Errorf("ParseTime(%q) = %v %s, want %02d:%02d %s", tt.text, got, got.Location(), tt.hour, tt.minute,
+					tt.location)
+			}
+		})
+	}
+}
+
+func TestNotificationsErrorText(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{model.TooManyNotificationsErr, fmt.Sprintf("не более %d уведомлений", model.MaxNotifications)},
+		{fmt.Errorf("%w: 200h", model.NotificationOffsetRangeErr), "больше 7 дней"},
+		{model.NoNotificationAfterStartErr, "в момент начала полёта или после него"},
+		{fmt.Errorf("%w %q", model.NotificationOffsetErr, "час"), "Не удалось разобрать"},
+	}
+	for _, tt := range tests {
+		if got := NotificationsErrorText(tt.err); !strings.Contains(got, tt.want) {
+			t.Errorf("NotificationsErrorText(%v) = %q, want it to contain %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/japersik/safe-flight-bot/internal/flyDataClient"
+	"github.com/japersik/safe-flight-bot/model"
+	"html"
+	"strings"
+	"time"
+)
+
+//Report текст отчета о возможности полётов и отчет для вывода подробной информации о зонах
+func (c *Core) Report(ctx context.Context, coord model.Coordinate, radius int, altitude int) (string, flyDataClient.Report, error) {
+	report := c.client.GetReport(ctx, coord, radius, altitude)
+	if report.Condition != nil {
+		c.CacheZones(report.Condition.ActiveZones, report.Condition.InactiveZones)
+	}
+	text := ReportText(report)
+	if len(report.Zones()) > 0 {
+		text += "Нажмите на код зоны ниже для получения подробной информации\n"
+	}
+	return text, report, nil
+}
+
+func ReportText(report flyDataClient.Report) string {
+	coord := report.Coordinate
+	text := fmt.Sprintf("Полученые географические координаты:<b> %f ,%f </b>\n\n", coord.Lng, coord.Lat)
+	if locationInfo := report.Locality; locationInfo == nil {
+		text += "К сожалению, не удалось получить информацию о населенных пунктах вблизи. \n\n"
+	} else {
+		text += "Точка находится в: " + locationInfo.Name + "\n"
+		if locationInfo.FlyRestriction {
+			text += "Полёты над населёнными пунктами <b>требуют согласования</b> с администрацией\n\n"
+		}
+	}
+
+	if zoneInfo := report.Condition; zoneInfo != nil {
+		if zoneInfo.NearBoundaryZone {
+			text += "Выбранные координаты находятся в 20-км приграничной зоне. Полёты здесь запрещены\n\n"
+		} else {
+			if len(zoneInfo.ActiveZones) == 0 {
+				text += "В этом месте нет действующих зон ограничений полётов\n\n"
+			} else {
+				text += "В этом месте имеются зоны, <b>ограничивающие полеты</b>: " +
+					ZonesTitles(zoneInfo.ActiveZones) + "\n\n"
+			}
+			if len(zoneInfo.InactiveZones) > 0 {
+				text += "Также в данный момент <b>не действуют</b>, но могут стать активными следующие зоны:" +
+					ZonesTitles(zoneInfo.InactiveZones) + "\n\n"
+			}
+		}
+	} else {
+		text += "К сожалению, не удалось получить информацию о зонах ограничения полетов от сервера. \n\n"
+	}
+
+	if weatherInfo := report.Weather; weatherInfo != nil {
+		text += fmt.Sprintf("<b>Информация о погоде:</b> \n")
+		text += fmt.Sprintf("Температура: %v *C\n", weatherInfo.Current.Temperature)
+		text += fmt.Sprintf("Ветер: %v м/c, %v \n", weatherInfo.Current.WindSpeed, weatherInfo.Current.WindDeg)
+		text += fmt.Sprintf("Влажность: %v%% \n", weatherInfo.Current.Humidity)
+		text += fmt.Sprintf("Вероятность выпадения осадков: %v%% \n", weatherInfo.Current.PrecipProb*100)
+		text += fmt.Sprintf("Видимость: %v м\n", weatherInfo.Current.Visibility)
+		text += fmt.Sprintf("Давление: %v мм рт.ст. \n\n", weatherInfo.Current.Pressure)
+	} else {
+		text += "К сожалению, не удалось получить информацию о погоде от сервера. \n\n"
+	}
+	return text
+}
+
+var zoneTypeNames = map[model.ZoneType]string{
+	model.ZoneProhibited: "Запретная зона",
+	model.ZoneRestricted: "Зона ограничения полётов",
+	model.ZoneDangerous:  "Опасная зона",
+	model.ZoneCTR:        "Диспетчерская зона аэродрома (CTR)",
+	model.ZoneTemporary:  "Временный режим",
+}
+
+//CacheZones сохраняет подробную информацию о зонах для последующего вывода по кнопке
+func (c *Core) CacheZones(zones ...[]model.Zone) {
+	c.zonesMutex.Lock()
+	defer c.zonesMutex.Unlock()
+	for _, list := range zones {
+		for _, zone := range list {
+			c.zones[zone.Code] = zone
+		}
+	}
+}
+
+//Zone зона из кэша по коду
+func (c *Core) Zone(code string) (model.Zone, bool) {
+	c.zonesMutex.Lock()
+	defer c.zonesMutex.Unlock()
+	zone, ok := c.zones[code]
+	return zone, ok
+}
+
+//ZoneTitle код зоны с названием, если оно известно
+func ZoneTitle(zone model.Zone) string {
+	if zone.Name == "" {
+		return html.EscapeString(zone.Code)
+	}
+	return fmt.Sprintf("%s «%s»", html.EscapeString(zone.Code), html.EscapeString(zone.Name))
+}
+
+func ZonesTitles(zones []model.Zone) string {
+	titles := make([]string, 0, len(zones))
+	for _, zone := range zones {
+		titles = append(titles, ZoneTitle(zone))
+	}
+	return strings.Join(titles, ", ")
+}
+
+func ZoneText(zone model.Zone) string {
+	text := fmt.Sprintf("Зона <b>%s</b>\n", html.EscapeString(zone.Code))
+	if zone.Name != "" {
+		text += "Название: " + html.EscapeString(zone.Name) + "\n"
+	}
+	if name, ok := zoneTypeNames[zone.Type]; ok {
+		text += "Тип: " + name + "\n"
+	}
+	switch {
+	case zone.LowerLimit != nil && zone.UpperLimit != nil:
+		text += fmt.Sprintf("Высоты: от %d до %d м\n", *zone.LowerLimit, *zone.UpperLimit)
+	case zone.LowerLimit != nil:
+		text += fmt.Sprintf("Высоты: от %d м\n", *zone.LowerLimit)
+	case zone.UpperLimit != nil:
+		text += fmt.Sprintf("Высоты: до %d м\n", *zone.UpperLimit)
+	}
+	if zone.Schedule != "" {
+		text += "Режим работы: " + html.EscapeString(zone.Schedule) + "\n"
+	}
+	if len(zone.ActivePeriods) > 0 {
+		text += "Периоды активности:\n"
+		for _, period := range zone.ActivePeriods {
+			text += fmt.Sprintf(" • %s — %s UTC\n", period.From.UTC().Format("02.01.2006 15:04"),
+				period.To.UTC().Format("02.01.2006 15:04"))
+		}
+	}
+	if zone.Contact != "" {
+		text += "Для получения разрешения: " + html.EscapeString(zone.Contact) + "\n"
+	}
+	return text
+}
+
+//FlightWindowTooFarText активность зон на время полёта еще нельзя узнать
+const FlightWindowTooFarText = "Активность зон пока неизвестна: сервис показывает ее не более чем на 72 часа вперёд. " +
+	"Состояние зон придет в уведомлениях ближе к полёту"
+
+//FlightWindowText информация об активности зон в запланированное время полёта
+func (c *Core) FlightWindowText(ctx context.Context, plan model.FlyPlan) (string, []model.Zone) {
+	now := time.Now()
+	from, to := plan.Window(now)
+	zoneInfo, err := c.client.CheckConditionsForPeriod(ctx, plan.Data.Coordinate, PlanRadius(plan), plan.Data.Altitude, from, to.Sub(from))
+	text := fmt.Sprintf("<b>Зоны на время полёта %s–%s:</b>\n", from.Format("02.01.2006 15:04"), to.Format("15:04"))
+	if errors.Is(err, flyDataClient.PeriodTooFarErr) {
+		return text + FlightWindowTooFarText + "\n\n", nil
+	}
+	if err != nil {
+		return "К сожалению, не удалось получить информацию о зонах на время полёта. \n\n", nil
+	}
+	c.CacheZones(zoneInfo.ActiveZones, zoneInfo.InactiveZones)
+	if len(zoneInfo.ActiveZones) == 0 && len(zoneInfo.InactiveZones) == 0 {
+		return text + "Зон ограничений полётов нет\n\n", nil
+	}
+	startsNow := !from.After(now.Add(time.Hour))
+	for _, zone := range zoneInfo.ActiveZones {
+		active, known := zone.ActiveDuring(from, to)
+		switch {
+		case known && active, !known && startsNow:
+			text += " • " + ZoneTitle(zone) + " — <b>активна</b>\n"
+		case known:
+			text += " • " + ZoneTitle(zone) + " — не активна\n"
+		default:
+			text += " • " + ZoneTitle(zone) + " — <b>может быть активна</b>, расписание неизвестно\n"
+		}
+	}
+	for _, zone := range zoneInfo.InactiveZones {
+		active, known := zone.ActiveDuring(from, to)
+		switch {
+		case known && active:
+			text += " • " + ZoneTitle(zone) + " — <b>активна</b>\n"
+		case known, startsNow:
+			text += " • " + ZoneTitle(zone) + " — не активна\n"
+		default:
+			text += " • " + ZoneTitle(zone) + " — <b>может быть активна</b>, расписание неизвестно\n"
+		}
+	}
+	return text + "\n", append(zoneInfo.ActiveZones, zoneInfo.InactiveZones...)
+}
+
+func UniqueZones(zones []model.Zone) []model.Zone {
+	seen := map[string]bool{}
+	ans := make([]model.Zone, 0, len(zones))
+	for _, zone := range zones {
+		if !seen[zone.Code] {
+			seen[zone.Code] = true
+			ans = append(ans, zone)
+		}
+	}
+	return ans
+}
+
+func DroneDescription(drone model.Drone) string {
+	text := fmt.Sprintf("<b>%s</b>, %d г (%s)", html.EscapeString(drone.Name), drone.Weight, drone.WeightClass())
+	if drone.MaxWind > 0 {
+		text += fmt.Sprintf("\nВетер до %.1f м/с", drone.MaxWind)
+	}
+	if drone.HasTempLimits() {
+		text += fmt.Sprintf("\nТемпература от %.0f до %.0f °C", drone.MinTemp, drone.MaxTemp)
+	}
+	return text + "\n" + RegistrationText(drone)
+}
+
+func RegistrationText(drone model.Drone) string {
+	switch {
+	case !drone.NeedsRegistration():
+		return "Учет в Росавиации не требуется"
+	case drone.RegistrationNumber == "":
+		return "Масса более 150 г: дрон подлежит <b>учету</b> в Росавиации, учетный номер не указан"
+	default:
+		return "Учетный номер: " + html.EscapeString(drone.RegistrationNumber)
+	}
+}
+
+//DroneText пригодность погоды для выбранного в плане дрона
+func DroneText(plan model.FlyPlan, report flyDataClient.Report) string {
+	if plan.Drone == nil {
+		return ""
+	}
+	from, _ := plan.Window(time.Now())
+	verdict := report.DroneVerdict(*plan.Drone, from)
+	text := fmt.Sprintf("\n<b>Дрон %s:</b> %s %s\n", html.EscapeString(plan.Drone.Name), VerdictIcons[verdict.Level],
+		droneVerdictTitles[verdict.Level])
+	for _, reason := range verdict.Reasons {
+		text += "• " + reason + "\n"
+	}
+	return text + RegistrationText(*plan.Drone) + "\n"
+}
+
+var droneVerdictTitles = map[flyDataClient.VerdictLevel]string{
+	flyDataClient.VerdictUnknown: "недостаточно данных о погоде",
+	flyDataClient.VerdictGo:      "погода подходит для полёта",
+	flyDataClient.VerdictCaution: "полёт возможен с осторожностью",
+	flyDataClient.VerdictNoGo:    "погода не подходит для полёта",
+}
+
+//VerdictIcons значки итоговой оценки возможности полёта
+var VerdictIcons = map[flyDataClient.VerdictLevel]string{
+	flyDataClient.VerdictUnknown: "❔",
+	flyDataClient.VerdictGo:      "✅",
+	flyDataClient.VerdictCaution: "⚠️",
+	flyDataClient.VerdictNoGo:    "⛔",
+}
+
+//UncheckedText список невыполненных пунктов для уведомления во время взлета
+func UncheckedText(plan model.FlyPlan) string {
+	unchecked := plan.UncheckedItems()
+	if len(unchecked) == 0 {
+		return "\n✅ Все пункты чек-листа выполнены\n"
+	}
+	text := "\n<b>Не отмечены пункты чек-листа:</b>\n"
+	for _, item := range unchecked {
+		text += "⬜ " + html.EscapeString(item) + "\n"
+	}
+	return text
+}
+
+//FlightConditions снимок условий из отчета последней проверки. Погода берется на время начала полёта, если есть прогноз
+func FlightConditions(report flyDataClient.Report, start time.Time) *model.FlightConditions {
+	conditions := &model.FlightConditions{CheckedAt: time.Now(), Weather: report.WeatherAt(start)}
+	if report.Condition != nil {
+		for _, zone := range report.Condition.ActiveZones {
+			conditions.ActiveZones = append(conditions.ActiveZones, zone.Code)
+		}
+		conditions.NearBoundaryZone = report.Condition.NearBoundaryZone
+	}
+	return conditions
+}
+
+//NotifyRadius радиус проверки для уведомлений по планам, созданным без указания радиуса
+const NotifyRadius = 100
+
+//PlanRadius радиус проверки для запланированного полёта
+func PlanRadius(plan model.FlyPlan) int {
+	if plan.Data.Radius > 0 {
+		return plan.Data.Radius
+	}
+	return NotifyRadius
+}
@@ -0,0 +1,59 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/japersik/safe-flight-bot/internal/flyDataClient"
+	"github.com/japersik/safe-flight-bot/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+//fakeZones источник зон с заранее заданным ответом
+type fakeZones struct {
+	condition model.Condition
+	err       error
+}
+
+func (f fakeZones) CheckConditions(ctx context.Context, coordinate model.Coordinate, radius int, altitude int) (model.Condition, error) {
+	return f.condition, f.err
+}
+
+func (f fakeZones) CheckConditionsForPeriod(ctx context.Context, coordinate model.Coordinate, radius int, altitude int, from time.Time, duration time.Duration) (model.Condition, error) {
+	return f.condition, f.err
+}
+
+func TestFlightWindowText(t *testing.T) {
+	plan := model.FlyPlan{FlyDateTime: time.Now().Add(24 * time.Hour), Data: model.FlyData{Coordinate: moscow, Radius: 500}}
+	header := "Зоны на время полёта " + plan.FlyDateTime.Format("02.01.2006 15:04")
+	tests := []struct {
+		name     string
+		source   fakeZones
+		contains []string
+		zones    int
+	}{
+		{"too far ahead", fakeZones{err: flyDataClient.PeriodTooFarErr}, []string{header, FlightWindowTooFarText}, 0},
+		{"wrapped too far ahead", fakeZones{err: fmt.Errorf("check: %w", flyDataClient.PeriodTooFarErr)},
+			[]string{FlightWindowTooFarText}, 0},
+		{"service error", fakeZones{err: errors.New("timeout")}, []string{"не удалось получить информацию"}, 0},
+		{"no zones", fakeZones{}, []string{header, "Зон ограничений полётов нет"}, 0},
+		{"active zone", fakeZones{condition: model.Condition{ActiveZones: []model.Zone{{Code: "UUR1"}}}},
+			[]string{header, "UUR1"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core := NewCore(flyDataClient.Client{ZoneInfoSource: tt.source}, nil, nil)
+			text, zones := core.FlightWindowText(context.Background(), plan)
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("text %q does not contain %q", text, want)
+				}
+			}
+			if len(zones) != tt.zones {
+				t.Errorf("zones = %v, want %d", zones, tt.zones)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package coordParser
+
+import (
+	"github.com/japersik/safe-flight-bot/model"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	//decimalPattern "55.7558, 37.6173" or "55.7558 37.6173"
+	decimalPattern = regexp.MustCompile(`^\s*([-+]?\d{1,2}(?:[.,]\d+)?)\s*[,;\s]\s*([-+]?\d{1,3}(?:[.,]\d+)?)\s*$`)
+	//dmsPattern one component like 55°45'21.5"N, N55°45.35', 55°45′21″ с.ш. or 37 37 04 E
+	dmsPattern = regexp.MustCompile(`(?i)([NSEWСЮВЗ])?\s*(\d{1,3}(?:[.,]\d+)?)\s*[°º]?\s*(?:(\d{1,2}(?:[.,]\d+)?)\s*['′’]?\s*)?(?:(\d{1,2}(?:[.,]\d+)?)\s*(?:["″”]|'')?\s*)?(с\.\s?ш\.?|ю\.\s?ш\.?|в\.\s?д\.?|з\.\s?д\.?|[NSEWСЮВЗ])?`)
+	//pairPattern "55.7558,37.6173" inside url parts
+	pairPattern = regexp.MustCompile(`([-+]?\d{1,3}\.\d+)\s*,\s*([-+]?\d{1,3}\.\d+)`)
+	//googleAtPattern google maps ".../@55.7558,37.6173,15z"
+	googleAtPattern = regexp.MustCompile(`@([-+]?\d{1,2}\.\d+),([-+]?\d{1,3}\.\d+)`)
+	//googleDataPattern google maps place data "!3d55.7558!4d37.6173"
+	googleDataPattern = regexp.MustCompile(`!3d([-+]?\d{1,2}\.\d+)!4d([-+]?\d{1,3}\.\d+)`)
+	//osmHashPattern openstreetmap "#map=15/55.7558/37.6173"
+	osmHashPattern = regexp.MustCompile(`map=\d+/([-+]?\d{1,2}\.\d+)/([-+]?\d{1,3}\.\d+)`)
+)
+
+//Parse extracts coordinate from text: decimal degrees, degrees-minutes-seconds or Google, Yandex and OpenStreetMap links.
+//Latitude goes first
+func Parse(text string) (model.Coordinate, bool) {
+	text = strings.TrimSpace(text)
+	if match := decimalPattern.FindStringSubmatch(text); match != nil {
+		return parsePair(match[1], match[2])
+	}
+	if u, err := url.Parse(text); err == nil && u.Host != "" {
+		return parseURL(u)
+	}
+	return parseDMS(text)
+}
+
+func parseURL(u *url.URL) (model.Coordinate, bool) {
+	host := strings.ToLower(u.Host)
+	query := u.Query()
+	switch {
+	case strings.Contains(host, "yandex"):
+		//yandex keeps longitude first
+		for _, key := range []string{"pt", "whatshere[point]", "ll"} {
+			if match := pairPattern.FindStringSubmatch(query.Get(key)); match != nil {
+				return parsePair(match[2], match[1])
+			}
+		}
+	case strings.Contains(host, "openstreetmap") || strings.Contains(host, "osm.org"):
+		if query.Get("mlat") != "" && query.Get("mlon") != "" {
+			return parsePair(query.Get("mlat"), query.Get("mlon"))
+		}
+		if match := osmHashPattern.FindStringSubmatch(u.Fragment); match != nil {
+			return parsePair(match[1], match[2])
+		}
+	case strings.Contains(host, "google") || strings.Contains(host, "goo.gl"):
+		full := u.String()
+		if match := googleDataPattern.FindStringSubmatch(full); match != nil {
+			return parsePair(match[1], match[2])
+		}
+		for _, key := range []string{"q", "query", "ll", "destination", "center"} {
+			if match := pairPattern.FindStringSubmatch(query.Get(key)); match != nil {
+				return parsePair(match[1], match[2])
+			}
+		}
+		if match := googleAtPattern.FindStringSubmatch(full); match != nil {
+			return parsePair(match[1], match[2])
+		}
+	}
+	return model.Coordinate{}, false
+}
+
+type dmsComponent struct {
+	value      float64
+	hemisphere string
+}
+
+//parseDMS parses two degrees-minutes-seconds components. Hemisphere letters define the order if present.
+//The text must not contain anything except the components and separators
+func parseDMS(text string) (model.Coordinate, bool) {
+	components := make([]dmsComponent, 0, 2)
+	rest := dmsPattern.ReplaceAllString(text, "")
+	if strings.Trim(rest, " ,;\t\n") != "" {
+		return model.Coordinate{}, false
+	}
+	for _, match := range dmsPattern.FindAllStringSubmatch(text, -1) {
+		if strings.TrimSpace(match[0]) == "" || match[2] == "" {
+			continue
+		}
+		degrees, err := parseFloat(match[2])
+		if err != nil {
+			return model.Coordinate{}, false
+		}
+		value := degrees
+		if match[3] != "" {
+			minutes, err := parseFloat(match[3])
+			if err != nil || minutes >= 60 {
+				return model.Coordinate{}, false
+			}
+			value += minutes / 60
+		}
+		if match[4] != "" {
+			seconds, err := parseFloat(match[4])
+			if err != nil || seconds >= 60 {
+				return model.Coordinate{}, false
+			}
+			value += seconds / 3600
+		}
+		hemisphere := hemisphereLetter(match[1] + match[5])
+		if hemisphere == "S" || hemisphere == "W" {
+			value = -value
+		}
+		components = append(components, dmsComponent{value: value, hemisphere: hemisphere})
+	}
+	if len(components) != 2 {
+		return model.Coordinate{}, false
+	}
+	lat, lng := components[0], components[1]
+	if lat.hemisphere == "E" || lat.hemisphere == "W" || lng.hemisphere == "N" || lng.hemisphere == "S" {
+		lat, lng = lng, lat
+	}
+	return validate(model.Coordinate{Lat: lat.value, Lng: lng.value})
+}
+
+//hemisphereLetter normalizes latin and cyrillic hemisphere designations to N, S, E or W
+func hemisphereLetter(str string) string {
+	str = strings.ToUpper(strings.ReplaceAll(str, " ", ""))
+	switch {
+	case str == "":
+		return ""
+	case strings.HasPrefix(str, "N"), strings.HasPrefix(str, "С"):
+		return "N"
+	case strings.HasPrefix(str, "S"), strings.HasPrefix(str, "Ю"):
+		return "S"
+	case strings.HasPrefix(str, "E"), strings.HasPrefix(str, "В"):
+		return "E"
+	case strings.HasPrefix(str, "W"), strings.HasPrefix(str, "З"):
+		return "W"
+	}
+	return ""
+}
+
+func parsePair(latStr, lngStr string) (model.Coordinate, bool) {
+	lat, err := parseFloat(latStr)
+	if err != nil {
+		return model.Coordinate{}, false
+	}
+	lng, err := parseFloat(lngStr)
+	if err != nil {
+		return model.Coordinate{}, false
+	}
+	return validate(model.Coordinate{Lat: lat, Lng: lng})
+}
+
+func parseFloat(str string) (float64, error) {
+	return strconv.ParseFloat(strings.Replace(strings.TrimSpace(str), ",", ".", 1), 64)
+}
+
+func validate(coordinate model.Coordinate) (model.Coordinate, bool) {
+	if coordinate.Lat < -90 || coordinate.Lat > 90 || coordinate.Lng < -180 || coordinate.Lng > 180 {
+		return model.Coordinate{}, false
+	}
+	return coordinate, true
+}
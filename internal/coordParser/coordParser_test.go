@@ -0,0 +1,81 @@
+package coordParser
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		lat  float64
+		lng  float64
+		ok   bool
+	}{
+		{"decimal comma", "55.7558, 37.6173", 55.7558, 37.6173, true},
+		{"decimal space", " 55.7558 37.6173 ", 55.7558, 37.6173, true},
+		{"decimal semicolon and comma fraction", "55,7558; 37,6173", 55.7558, 37.6173, true},
+		{"decimal negative", "-33.8688, -151.2093", -33.8688, -151.2093, true},
+		{"decimal latitude out of range", "95.1, 37.6", 0, 0, false},
+		{"decimal longitude out of range", "55.1, 181.5", 0, 0, false},
+		{"dms latin", `55°45'21"N 37°37'04"E`, 55.755833, 37.617778, true},
+		{"dms hemisphere first", "N55°45.35' E37°37.07'", 55.755833, 37.617833, true},
+		{"dms longitude first", `37°37'04"E 55°45'21"N`, 55.755833, 37.617778, true},
+		{"dms southern western", `33°52'08"S 151°12'33"W`, -33.868889, -151.209167, true},
+		{"dms cyrillic", "55°45′21″ с.ш. 37°37′04″ в.д.", 55.755833, 37.617778, true},
+		{"dms spaces", "55 45 21 N 37 37 04 E", 55.755833, 37.617778, true},
+		{"dms minutes out of range", `55°61'00"N 37°37'04"E`, 0, 0, false},
+		{"dms extra text", `около 55°45'21"N 37°37'04"E`, 0, 0, false},
+		{"single number", "55.7558", 0, 0, false},
+		{"place name", "Москва, Парк Горького", 0, 0, false},
+		{"empty", "", 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coord, ok := Parse(tt.text)
+			if ok != tt.ok {
+				t.Fatalf("Parse(%q) ok = %v, want %v", tt.text, ok, tt.ok)
+			}
+			if ok && (math.Abs(coord.Lat-tt.lat) > 1e-5 || math.Abs(coord.Lng-tt.lng) > 1e-5) {
+				t.Errorf("Parse(%q) = %v, %v, want %v, %v", tt.text, coord.Lat, coord.Lng, tt.lat, tt.lng)
+			}
+		})
+	}
+}
+
+func TestParseMapLink(t *testing.T) {
+	tests := []struct {
+		name string
+		link string
+		lat  float64
+		lng  float64
+		ok   bool
+	}{
+		{"google at", "https://www.google.com/maps/@55.7558,37.6173,15z", 55.7558, 37.6173, true},
+		{"google place data", "https://www.google.com/maps/place/Moscow/@55.7,37.5,10z/data=!3d55.7558!4d37.6173",
+			55.7558, 37.6173, true},
+		{"google query", "https://maps.google.com/?q=55.7558,37.6173", 55.7558, 37.6173, true},
+		{"google search api", "https://www.google.com/maps/search/?api=1&query=55.7558%2C37.6173", 55.7558, 37.6173, true},
+		{"yandex point longitude first", "https://yandex.ru/maps/?pt=37.6173,55.7558&z=15", 55.7558, 37.6173, true},
+		{"yandex whatshere", "https://yandex.ru/maps/213/moscow/?whatshere%5Bpoint%5D=37.6173%2C55.7558&z=17",
+			55.7558, 37.6173, true},
+		{"yandex center", "https://yandex.ru/maps/?ll=37.6173%2C55.7558&z=12", 55.7558, 37.6173, true},
+		{"osm marker", "https://www.openstreetmap.org/?mlat=55.7558&mlon=37.6173#map=15/55.7/37.6", 55.7558, 37.6173, true},
+		{"osm hash", "https://www.openstreetmap.org/#map=15/55.7558/37.6173", 55.7558, 37.6173, true},
+		{"osm short host", "https://osm.org/#map=15/55.7558/37.6173", 55.7558, 37.6173, true},
+		{"google without coordinates", "https://www.google.com/maps/place/Moscow", 0, 0, false},
+		{"unknown host", "https://example.com/?q=55.7558,37.6173", 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coord, ok := Parse(tt.link)
+			if ok != tt.ok {
+				t.Fatalf("Parse(%q) ok = %v, want %v", tt.link, ok, tt.ok)
+			}
+			if ok && (math.Abs(coord.Lat-tt.lat) > 1e-6 || math.Abs(coord.Lng-tt.lng) > 1e-6) {
+				t.Errorf("Parse(%q) = %v, %v, want %v, %v", tt.link, coord.Lat, coord.Lng, tt.lat, tt.lng)
+			}
+		})
+	}
+}
@@ -2,37 +2,63 @@ package avtmClient
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
+	"github.com/japersik/safe-flight-bot/internal/flyDataClient"
 	"github.com/japersik/safe-flight-bot/logger"
 	"github.com/japersik/safe-flight-bot/model"
 	"math"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 )
 
 const (
-	currentWeatherEndPoint  = "https://map.avtm.center/app/public/services/weather-current"
-	forecastWeatherEndPoint = "https://map.avtm.center/app/public/services/weather-forecast"
-	checkConditionsEndPoint = "https://map.avtm.center/app/flight-check/check-conditions"
+	//DefaultBaseURL адрес сервиса map.avtm.center
+	DefaultBaseURL = "https://map.avtm.center"
+	defaultTimeout = time.Second * 3
+
+	currentWeatherEndPoint  = "/app/public/services/weather-current"
+	forecastWeatherEndPoint = "/app/public/services/weather-forecast"
+	checkConditionsEndPoint = "/app/flight-check/check-conditions"
 )
 
 type AvtmClient struct {
 	webClient http.Client
+	baseURL   string
 }
 
-func NewAvtmClient() *AvtmClient {
+//NewAvtmClient baseURL позволяет использовать локальную заглушку сервиса. Пустой адрес и нулевой таймаут - значения по умолчанию
+func NewAvtmClient(baseURL string, timeout time.Duration) *AvtmClient {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
 	return &AvtmClient{
-		http.Client{
-			Timeout: time.Second * 3,
+		webClient: http.Client{
+			Timeout: timeout,
 		},
+		baseURL: strings.TrimSuffix(baseURL, "/"),
 	}
 }
 
+//SetTransport транспорт http запросов, например с учетом метрик
+func (c *AvtmClient) SetTransport(transport http.RoundTripper) {
+	c.webClient.Transport = transport
+}
+
 //GetCurrentWeather receives current  weather as flyDataClient.CurrentWeatherData form Avmt api.
-func (c AvtmClient) GetCurrentWeather(coordinate model.Coordinate) (*model.CurrentWeatherData, error) {
-	url, err := url.Parse(currentWeatherEndPoint)
+func (c AvtmClient) GetCurrentWeather(ctx context.Context, coordinate model.Coordinate) (*model.CurrentWeatherData, error) {
+	logger.FromContext(ctx).Debug("getting current weather", logger.Any("coordinate", coordinate))
+	url, err := url.Parse(c.baseURL + currentWeatherEndPoint)
+	if err != nil {
+		return nil, err
+	}
 	var req = &http.Request{
 		Method: http.MethodGet,
 		URL:    url,
@@ -44,10 +70,11 @@ func (c AvtmClient) GetCurrentWeather(coordinate model.Coordinate) (*model.Curre
 	q.Add("lang", "ru")
 
 	req.URL.RawQuery = q.Encode()
-	response, err := c.webClient.Do(req)
+	response, err := c.webClient.Do(req.WithContext(ctx))
 	if err != nil {
 		return nil, err
 	}
+	defer response.Body.Close()
 	decoder := json.NewDecoder(response.Body)
 	ans := &model.CurrentWeatherData{}
 	if err = decoder.Decode(ans); err != nil {
@@ -101,9 +128,12 @@ func (jwd JSONWeatherData) castToWeatherData(loc *time.Location) model.WeatherDa
 }
 
 //GetForecastWeather receives forecast weather as flyDataClient.WeatherData form Avmt api.
-func (c AvtmClient) GetForecastWeather(coordinate model.Coordinate) (*model.WeatherForecast, error) {
-	logger.InfoF("getting weather forecast at point (%f, %f)\n", coordinate.Lat, coordinate.Lng)
-	url, err := url.Parse(forecastWeatherEndPoint)
+func (c AvtmClient) GetForecastWeather(ctx context.Context, coordinate model.Coordinate) (*model.WeatherForecast, error) {
+	logger.FromContext(ctx).Debug("getting weather forecast", logger.Any("coordinate", coordinate))
+	url, err := url.Parse(c.baseURL + forecastWeatherEndPoint)
+	if err != nil {
+		return nil, err
+	}
 	var req = &http.Request{
 		Method: http.MethodGet,
 		URL:    url,
@@ -115,7 +145,7 @@ func (c AvtmClient) GetForecastWeather(coordinate model.Coordinate) (*model.Weat
 	q.Add("lng", strconv.FormatFloat(coordinate.Lng, 'f', 10, 64))
 	req.URL.RawQuery = q.Encode()
 
-	response, err := c.webClient.Do(req)
+	response, err := c.webClient.Do(req.WithContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -185,27 +215,63 @@ func (loc *JSONCheckConditions) castJSONtoCondition() model.Condition {
 		LocalTimeInLocation: "",
 		Sunrise:             loc.Sunrise,
 		Sunset:              loc.Sunset,
-		ActiveZones:         []string{},
-		InactiveZones:       []string{},
+		ActiveZones:         []model.Zone{},
+		InactiveZones:       []model.Zone{},
 	}
 	for _, info := range loc.Zones {
-		ans.ActiveZones = append(ans.ActiveZones, info.IntersectionCodes...)
+		details := map[string]model.Zone{}
+		for _, m := range append(info.Active, info.Inactive...) {
+			zone := castMapToZone(m)
+			if zone.Code == "" {
+				logger.Debug("zone without code skipped", logger.String("zone", zoneDebugString(m)))
+				continue
+			}
+			details[zone.Code] = zone
+		}
+		for _, code := range info.IntersectionCodes {
+			zone, ok := details[code]
+			if !ok {
+				zone = model.Zone{Code: code, Type: zoneType("", code, "")}
+			}
+			ans.ActiveZones = append(ans.ActiveZones, zone)
+		}
 		for _, m := range info.Inactive {
-			if i, ok := m["code"]; ok {
-
-				if st, ok := i.(string); ok {
-					ans.InactiveZones = append(ans.InactiveZones, st)
-				}
+			if zone := castMapToZone(m); zone.Code != "" {
+				ans.InactiveZones = append(ans.InactiveZones, zone)
 			}
 		}
-
 	}
 	return ans
 }
 
+//maxDurationHours the longest period for check-conditions request
+const maxDurationHours = 72
+
 //CheckConditions  receives fly zone Conditions form Avmt api.
-func (c AvtmClient) CheckConditions(coordinate model.Coordinate, radius int) (model.Condition, error) {
-	logger.Info("getting information about fly zones at point (%f, %f)\n", coordinate.Lat, coordinate.Lng)
+func (c AvtmClient) CheckConditions(ctx context.Context, coordinate model.Coordinate, radius int, altitude int) (model.Condition, error) {
+	return c.checkConditions(ctx, coordinate, radius, altitude, 1)
+}
+
+//CheckConditionsForPeriod receives fly zone Conditions for the period from now to the end of [from, from+duration) window.
+//Zones that can become active before the end of the window are returned as active. The service checks at most
+//maxDurationHours from now, so for a window ending later flyDataClient.PeriodTooFarErr is returned
+func (c AvtmClient) CheckConditionsForPeriod(ctx context.Context, coordinate model.Coordinate, radius int, altitude int, from time.Time, duration time.Duration) (model.Condition, error) {
+	hours := int(math.Ceil(time.Until(from.Add(duration)).Hours()))
+	if hours < 1 {
+		hours = 1
+	}
+	if hours > maxDurationHours {
+		return model.Condition{}, flyDataClient.PeriodTooFarErr
+	}
+	return c.checkConditions(ctx, coordinate, radius, altitude, hours)
+}
+
+func (c AvtmClient) checkConditions(ctx context.Context, coordinate model.Coordinate, radius int, altitude int, durationHours int) (model.Condition, error) {
+	if altitude <= 0 {
+		altitude = model.DefaultAltitude()
+	}
+	logger.FromContext(ctx).Debug("checking fly zones", logger.Any("coordinate", coordinate),
+		logger.Int("radius", radius), logger.Int("altitude", altitude), logger.Int("hours", durationHours))
 	type Geometry struct {
 		Type        string         `json:"type"`
 		Coordinates [][][2]float64 `json:"coordinates"`
@@ -224,8 +290,7 @@ func (c AvtmClient) CheckConditions(coordinate model.Coordinate, radius int) (mo
 
 	n := 8
 	coordinates := make([][2]float64, 0, n)
-	for i := 0; i < n; i++ {
-		newCoord := circleCoordinate(coordinate, radius, 360/n*i)
+	for _, newCoord := range model.AreaPolygon(coordinate, radius, n) {
 		coordinates = append(coordinates, [2]float64{newCoord.Lng, newCoord.Lat})
 	}
 	geometry := Geometry{
@@ -239,18 +304,30 @@ func (c AvtmClient) CheckConditions(coordinate model.Coordinate, radius int) (mo
 	}
 	reqArg := ccReq{
 		Area:          area,
-		Altitude:      150,
-		DurationHours: 1,
+		Altitude:      altitude,
+		DurationHours: durationHours,
 	}
 	data, _ := json.Marshal(reqArg)
 	r := bytes.NewReader(data)
-	resp, err := c.webClient.Post(checkConditionsEndPoint, "application/json", r)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+checkConditionsEndPoint, r)
 	if err != nil {
 		return model.Condition{}, err
 	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.webClient.Do(req)
+	if err != nil {
+		return model.Condition{}, err
+	}
+	defer resp.Body.Close()
+	//ответ с ошибкой не должен выглядеть как отсутствие зон
+	if resp.StatusCode != http.StatusOK {
+		return model.Condition{}, fmt.Errorf("check conditions: unexpected status %s", resp.Status)
+	}
 	decoder := json.NewDecoder(resp.Body)
 	ans := &JSONCheckConditions{}
-	decoder.Decode(&ans)
+	if err := decoder.Decode(&ans); err != nil {
+		return model.Condition{}, err
+	}
 	//for s, i := range ans.Zones {
 	//	fmt.Println(s)
 	//	fmt.Println(i.Active)
@@ -259,13 +336,3 @@ func (c AvtmClient) CheckConditions(coordinate model.Coordinate, radius int) (mo
 	//fmt.Println(ans)
 	return ans.castJSONtoCondition(), nil
 }
-func circleCoordinate(coordinate model.Coordinate, radius int, andreDeg int) model.Coordinate {
-	angle := float64(andreDeg) * math.Pi * 2 / 360
-	dx := float64(radius) * math.Cos(angle)
-	dy := float64(radius) * math.Sin(angle)
-	ans := model.Coordinate{
-		Lat: coordinate.Lat + (180/math.Pi)*(dy/6378137),
-		Lng: coordinate.Lng + (180/math.Pi)*(dx/6378137)/math.Cos(coordinate.Lat*math.Pi/180),
-	}
-	return ans
-}
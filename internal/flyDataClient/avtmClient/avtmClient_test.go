@@ -0,0 +1,220 @@
+package avtmClient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/japersik/safe-flight-bot/internal/flyDataClient"
+	"github.com/japersik/safe-flight-bot/logger"
+	"github.com/japersik/safe-flight-bot/model"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	logger.NewInstance(logger.NewZapLogger(zap.NewNop()))
+	os.Exit(m.Run())
+}
+
+//checkConditionsServer отвечает на check-conditions содержимым body и сохраняет тело запроса
+func checkConditionsServer(t *testing.T, status int, body []byte, request *map[string]interface{}) *AvtmClient {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != checkConditionsEndPoint {
+			http.NotFound(w, r)
+			return
+		}
+		if request != nil {
+			json.NewDecoder(r.Body).Decode(request)
+		}
+		w.WriteHeader(status)
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return NewAvtmClient(server.URL, 0)
+}
+
+//knownZoneKeys ключи зоны, которые использует разбор
+func knownZoneKeys() map[string]bool {
+	known := map[string]bool{}
+	for _, keys := range [][]string{codeKeys, nameKeys, typeKeys, lowerLimitKeys, upperLimitKeys, scheduleKeys,
+		periodsKeys, contactKeys, temporaryKeys, geometryKeys} {
+		for _, key := range keys {
+			known[key] = true
+		}
+	}
+	return known
+}
+
+//TestCheckConditionsFixtures разбор сохраненных ответов сервиса из testdata
+func TestCheckConditionsFixtures(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "check-conditions-*.json"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no fixtures: %v", err)
+	}
+	known := knownZoneKeys()
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw := JSONCheckConditions{}
+			if err := json.Unmarshal(data, &raw); err != nil {
+				t.Fatalf("fixture does not match JSONCheckConditions: %v", err)
+			}
+			condition, err := checkConditionsServer(t, http.StatusOK, data, nil).
+				CheckConditions(context.Background(), model.Coordinate{Lat: 55.75, Lng: 37.61}, 500, 150)
+			if err != nil {
+				t.Fatal(err)
+			}
+			active := map[string]bool{}
+			for _, zone := range condition.ActiveZones {
+				active[zone.Code] = true
+			}
+			unused := map[string]bool{}
+			for _, info := range raw.Zones {
+				for _, code := range info.IntersectionCodes {
+					if !active[code] {
+						t.Errorf("intersection %s is not reported as active", code)
+					}
+				}
+				for _, m := range append(info.Active, info.Inactive...) {
+					for key := range m {
+						if !known[key] {
+							unused[key] = true
+						}
+					}
+				}
+			}
+			for _, zone := range append(condition.ActiveZones, condition.InactiveZones...) {
+				if zone.Code == "" {
+					t.Errorf("zone without code: %+v", zone)
+				}
+			}
+			if len(unused) > 0 {
+				keys := make([]string, 0, len(unused))
+				for key := range unused {
+					keys = append(keys, key)
+				}
+				sort.Strings(keys)
+				t.Logf("zone keys not used by the parser: %s", strings.Join(keys, ", "))
+			}
+		})
+	}
+}
+
+func TestCheckConditionsSyntheticFixture(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "check-conditions-synthetic.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := map[string]interface{}{}
+	condition, err := checkConditionsServer(t, http.StatusOK, data, &request).
+		CheckConditions(context.Background(), model.Coordinate{Lat: 55.75, Lng: 37.61}, 500, 120)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request["altitude"] != float64(120) || request["durationHours"] != float64(1) {
+		t.Errorf("request altitude = %v, durationHours = %v", request["altitude"], request["durationHours"])
+	}
+	tests := []struct {
+		code   string
+		zones  []model.Zone
+		typ    model.ZoneType
+		upper  int
+		detail func(zone model.Zone) bool
+	}{
+		{"UUR123", condition.ActiveZones, model.ZoneRestricted, 1500,
+			func(zone model.Zone) bool {
+				return zone.Schedule != "" && zone.Contact != "" && len(zone.Geometry) == 1
+			}},
+		{"UUP45", condition.ActiveZones, model.ZoneProhibited, 3000,
+			func(zone model.Zone) bool { return zone.Name == "" }},
+		{"UUD7", condition.InactiveZones, model.ZoneDangerous, 0,
+			func(zone model.Zone) bool { return len(zone.ActivePeriods) == 2 && zone.UpperLimit == nil }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			for _, zone := range tt.zones {
+				if zone.Code != tt.code {
+					continue
+				}
+				if zone.Type != tt.typ || (zone.UpperLimit != nil && *zone.UpperLimit != tt.upper) || !tt.detail(zone) {
+					t.Errorf("unexpected zone %+v", zone)
+				}
+				return
+			}
+			t.Errorf("zone %s not found", tt.code)
+		})
+	}
+	if len(condition.InactiveZones) != 1 {
+		t.Errorf("inactive zones = %v, zone without code must be skipped", condition.InactiveZones)
+	}
+}
+
+func TestCheckConditionsErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"server error", http.StatusInternalServerError, `{"map": {}}`},
+		{"bad gateway html", http.StatusBadGateway, `<html>502</html>`},
+		{"malformed json", http.StatusOK, `{"map": `},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := checkConditionsServer(t, tt.status, []byte(tt.body), nil).
+				CheckConditions(context.Background(), model.Coordinate{Lat: 55.75, Lng: 37.61}, 500, 150)
+			if err == nil {
+				t.Error("failed check is reported as a location without zones")
+			}
+		})
+	}
+}
+
+func TestCheckConditionsForPeriod(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     time.Time
+		duration time.Duration
+		hours    float64
+		tooFar   bool
+	}{
+		{"started window", time.Now().Add(-30 * time.Minute), time.Hour, 1, false},
+		{"tomorrow", time.Now().Add(24 * time.Hour), time.Hour, 25, false},
+		{"ends at the horizon", time.Now().Add(70*time.Hour + 30*time.Minute), time.Hour, maxDurationHours, false},
+		{"ends past the horizon", time.Now().Add(72 * time.Hour), time.Hour, 0, true},
+		{"next week", time.Now().Add(7 * 24 * time.Hour), time.Hour, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var request map[string]interface{}
+			_, err := checkConditionsServer(t, http.StatusOK, []byte(`{"map": {}}`), &request).
+				CheckConditionsForPeriod(context.Background(), model.Coordinate{Lat: 55.75, Lng: 37.61}, 500, 150, tt.from, tt.duration)
+			if tt.tooFar {
+				if !errors.Is(err, flyDataClient.PeriodTooFarErr) {
+					t.Errorf("error = %v, want %v", err, flyDataClient.PeriodTooFarErr)
+				}
+				if request != nil {
+					t.Errorf("service was queried for a window it can't check: %v", request)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if request["durationHours"] != tt.hours {
+				t.Errorf("durationHours = %v, want %v", request["durationHours"], tt.hours)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+#!/bin/sh
+# Сохраняет ответ check-conditions сервиса map.avtm.center для тестов разбора зон:
+#   ./capture.sh moscow-center 55.7558 37.6173 [altitude] [hours]
+# Ответ записывается в check-conditions-<name>.json рядом со скриптом. Тесты пакета разбирают все такие файлы
+set -eu
+name=$1
+lat=$2
+lng=$3
+altitude=${4:-150}
+hours=${5:-1}
+base=${AVTM_URL:-https://map.avtm.center}
+dir=$(dirname "$0")
+# квадрат примерно 1x1 км вокруг точки
+d=0.005
+body=$(printf '{"area":{"type":"Feature","properties":{},"geometry":{"type":"Polygon","coordinates":[[[%s,%s],[%s,%s],[%s,%s],[%s,%s],[%s,%s]]]}},"altitude":%s,"durationHours":%s}' \
+	"$(echo "$lng - $d" | bc)" "$(echo "$lat - $d" | bc)" \
+	"$(echo "$lng + $d" | bc)" "$(echo "$lat - $d" | bc)" \
+	"$(echo "$lng + $d" | bc)" "$(echo "$lat + $d" | bc)" \
+	"$(echo "$lng - $d" | bc)" "$(echo "$lat + $d" | bc)" \
+	"$(echo "$lng - $d" | bc)" "$(echo "$lat - $d" | bc)" \
+	"$altitude" "$hours")
+curl -sSf -X POST -H 'Content-Type: application/json' -d "$body" \
+	"$base/app/flight-check/check-conditions" -o "$dir/check-conditions-$name.json"
+echo "saved $dir/check-conditions-$name.json"
@@ -0,0 +1,255 @@
+package avtmClient
+
+import (
+	"fmt"
+	"github.com/japersik/safe-flight-bot/model"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//zone payload keys. Avtm api is not documented, so several variants are checked. Responses captured with
+//testdata/capture.sh are parsed by the package tests, which list zone keys missing here
+var (
+	codeKeys       = []string{"code", "zoneCode", "ident"}
+	nameKeys       = []string{"name", "title", "zoneName", "description"}
+	typeKeys       = []string{"type", "zoneType", "kind", "category"}
+	lowerLimitKeys = []string{"lowerLimit", "altitudeFrom", "minAltitude", "bottom", "lower"}
+	upperLimitKeys = []string{"upperLimit", "altitudeTo", "maxAltitude", "top", "upper"}
+	scheduleKeys   = []string{"schedule", "activity", "activityTime", "workTime", "timetable"}
+	periodsKeys    = []string{"activePeriods", "periods", "activations", "intervals"}
+	contactKeys    = []string{"contact", "contacts", "authority", "organization", "owner", "phone"}
+	temporaryKeys  = []string{"temporary", "isTemporary", "notam"}
+	geometryKeys   = []string{"geometry", "geoJson", "geojson", "area"}
+)
+
+//castMapToZone converts a zone description from check-conditions response to model.Zone
+func castMapToZone(m map[string]interface{}) model.Zone {
+	zone := model.Zone{
+		Code:     stringValue(m, codeKeys...),
+		Name:     stringValue(m, nameKeys...),
+		Schedule: stringValue(m, scheduleKeys...),
+		Contact:  stringValue(m, contactKeys...),
+	}
+	if zone.Name == zone.Code {
+		zone.Name = ""
+	}
+	if v, ok := numberValue(m, lowerLimitKeys...); ok {
+		lower := int(math.Round(v))
+		zone.LowerLimit = &lower
+	}
+	if v, ok := numberValue(m, upperLimitKeys...); ok {
+		upper := int(math.Round(v))
+		zone.UpperLimit = &upper
+	}
+	zone.ActivePeriods = periodsValue(m, append(periodsKeys, scheduleKeys...)...)
+	zone.Geometry = geometryValue(m, geometryKeys...)
+	zone.Type = zoneType(stringValue(m, typeKeys...), zone.Code, zone.Name)
+	if zone.Type == model.ZoneUnknown || zone.Type == model.ZoneRestricted {
+		if temporary, ok := boolValue(m, temporaryKeys...); ok && temporary {
+			zone.Type = model.ZoneTemporary
+		}
+	}
+	return zone
+}
+
+//zoneType detects zone type by explicit type field or by ICAO zone code (UUP - prohibited, UUR - restricted, UUD - danger)
+func zoneType(kind, code, name string) model.ZoneType {
+	kind = strings.ToLower(kind)
+	switch {
+	case strings.Contains(kind, "prohib") || strings.Contains(kind, "запрет"):
+		return model.ZoneProhibited
+	case strings.Contains(kind, "ctr") || strings.Contains(kind, "диспетч"):
+		return model.ZoneCTR
+	case strings.Contains(kind, "temp") || strings.Contains(kind, "времен") || strings.Contains(kind, "notam"):
+		return model.ZoneTemporary
+	case strings.Contains(kind, "danger") || strings.Contains(kind, "опасн"):
+		return model.ZoneDangerous
+	case strings.Contains(kind, "restrict") || strings.Contains(kind, "огранич"):
+		return model.ZoneRestricted
+	}
+
+	upperName := strings.ToUpper(name)
+	if strings.Contains(upperName, "CTR") || strings.Contains(upperName, "ДИСПЕТЧЕРСК") {
+		return model.ZoneCTR
+	}
+	if strings.Contains(upperName, "ВРЕМЕНН") {
+		return model.ZoneTemporary
+	}
+	code = strings.ToUpper(code)
+	if len(code) >= 3 {
+		switch code[2] {
+		case 'P':
+			return model.ZoneProhibited
+		case 'R':
+			return model.ZoneRestricted
+		case 'D':
+			return model.ZoneDangerous
+		}
+	}
+	if strings.Contains(code, "CTR") {
+		return model.ZoneCTR
+	}
+	return model.ZoneUnknown
+}
+
+func stringValue(m map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		switch v := m[key].(type) {
+		case string:
+			if v = strings.TrimSpace(v); v != "" {
+				return v
+			}
+		case []interface{}:
+			parts := make([]string, 0, len(v))
+			for _, item := range v {
+				if s, ok := item.(string); ok && strings.TrimSpace(s) != "" {
+					parts = append(parts, strings.TrimSpace(s))
+				}
+			}
+			if len(parts) > 0 {
+				return strings.Join(parts, ", ")
+			}
+		}
+	}
+	return ""
+}
+
+func numberValue(m map[string]interface{}, keys ...string) (float64, bool) {
+	for _, key := range keys {
+		switch v := m[key].(type) {
+		case float64:
+			return v, true
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err == nil {
+				return f, true
+			}
+		case map[string]interface{}:
+			if f, ok := numberValue(v, "value", "altitude", "meters"); ok {
+				return f, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func boolValue(m map[string]interface{}, keys ...string) (bool, bool) {
+	for _, key := range keys {
+		if v, ok := m[key].(bool); ok {
+			return v, true
+		}
+	}
+	return false, false
+}
+
+func periodsValue(m map[string]interface{}, keys ...string) []model.TimePeriod {
+	for _, key := range keys {
+		list, ok := m[key].([]interface{})
+		if !ok {
+			continue
+		}
+		periods := make([]model.TimePeriod, 0, len(list))
+		for _, item := range list {
+			period, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			from, okFrom := timeValue(period, "from", "start", "startTime", "begin", "dateFrom")
+			to, okTo := timeValue(period, "to", "end", "endTime", "finish", "dateTo")
+			if okFrom && okTo {
+				periods = append(periods, model.TimePeriod{From: from, To: to})
+			}
+		}
+		if len(periods) > 0 {
+			return periods
+		}
+	}
+	return nil
+}
+
+var timeLayouts = []string{time.RFC3339, ctLayout, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02T15:04"}
+
+func timeValue(m map[string]interface{}, keys ...string) (time.Time, bool) {
+	for _, key := range keys {
+		switch v := m[key].(type) {
+		case float64:
+			//unix time in milliseconds
+			return time.UnixMilli(int64(v)), true
+		case string:
+			for _, layout := range timeLayouts {
+				if t, err := time.Parse(layout, v); err == nil {
+					return t, true
+				}
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+//geometryValue parses GeoJSON Polygon or MultiPolygon (or Feature with such geometry) and returns outer rings
+func geometryValue(m map[string]interface{}, keys ...string) [][]model.Coordinate {
+	for _, key := range keys {
+		geometry, ok := m[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if inner, ok := geometry["geometry"].(map[string]interface{}); ok {
+			geometry = inner
+		}
+		coordinates, _ := geometry["coordinates"].([]interface{})
+		var polygons []interface{}
+		switch geometry["type"] {
+		case "Polygon":
+			polygons = []interface{}{coordinates}
+		case "MultiPolygon":
+			polygons = coordinates
+		default:
+			continue
+		}
+		ans := make([][]model.Coordinate, 0, len(polygons))
+		for _, polygon := range polygons {
+			rings, ok := polygon.([]interface{})
+			if !ok || len(rings) == 0 {
+				continue
+			}
+			if ring := ringValue(rings[0]); len(ring) > 2 {
+				ans = append(ans, ring)
+			}
+		}
+		if len(ans) > 0 {
+			return ans
+		}
+	}
+	return nil
+}
+
+func ringValue(v interface{}) []model.Coordinate {
+	points, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	ring := make([]model.Coordinate, 0, len(points))
+	for _, p := range points {
+		point, ok := p.([]interface{})
+		if !ok || len(point) < 2 {
+			continue
+		}
+		lng, okLng := point[0].(float64)
+		lat, okLat := point[1].(float64)
+		if okLng && okLat {
+			ring = append(ring, model.Coordinate{Lng: lng, Lat: lat})
+		}
+	}
+	return ring
+}
+
+//zoneDebugString is used for logging of unexpected zone payloads
+func zoneDebugString(m map[string]interface{}) string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return fmt.Sprintf("zone payload keys: %s", strings.Join(keys, ","))
+}
@@ -0,0 +1,153 @@
+package avtmClient
+
+import (
+	"encoding/json"
+	"github.com/japersik/safe-flight-bot/model"
+	"testing"
+	"time"
+)
+
+func TestZoneType(t *testing.T) {
+	tests := []struct {
+		kind string
+		code string
+		name string
+		want model.ZoneType
+	}{
+		{"prohibited", "UUR1", "", model.ZoneProhibited},
+		{"Запретная зона", "", "", model.ZoneProhibited},
+		{"CTR", "", "", model.ZoneCTR},
+		{"диспетчерская зона", "", "", model.ZoneCTR},
+		{"temporary", "UUR1", "", model.ZoneTemporary},
+		{"NOTAM", "", "", model.ZoneTemporary},
+		{"danger", "", "", model.ZoneDangerous},
+		{"restricted", "UUP1", "", model.ZoneRestricted},
+		{"зона ограничения", "", "", model.ZoneRestricted},
+		{"", "", "Внуково CTR", model.ZoneCTR},
+		{"", "", "Временный режим", model.ZoneTemporary},
+		{"", "UUP45", "", model.ZoneProhibited},
+		{"", "uur123", "", model.ZoneRestricted},
+		{"", "UUD7", "", model.ZoneDangerous},
+		{"", "UUWWCTR", "", model.ZoneCTR},
+		{"", "UU", "", model.ZoneUnknown},
+		{"airfield", "XX1", "", model.ZoneUnknown},
+	}
+	for _, tt := range tests {
+		if got := zoneType(tt.kind, tt.code, tt.name); got != tt.want {
+			t.Errorf("zoneType(%q, %q, %q) = %q, want %q", tt.kind, tt.code, tt.name, got, tt.want)
+		}
+	}
+}
+
+//zonePayload разбор JSON зоны так же, как в ответе сервиса
+func zonePayload(t *testing.T, payload string) map[string]interface{} {
+	t.Helper()
+	m := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(payload), &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestZoneAltitudeLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		lower   *int
+		upper   *int
+	}{
+		{"numbers", `{"lowerLimit": 0, "upperLimit": 1500}`, intPtr(0), intPtr(1500)},
+		{"strings", `{"lowerLimit": " 150 ", "upperLimit": "300.6"}`, intPtr(150), intPtr(301)},
+		{"nested values", `{"altitudeFrom": {"value": 100}, "altitudeTo": {"meters": 900}}`, intPtr(100), intPtr(900)},
+		{"upper only", `{"maxAltitude": 120}`, nil, intPtr(120)},
+		{"not numbers", `{"lowerLimit": "GND", "upperLimit": "UNL"}`, nil, nil},
+		{"missing", `{}`, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zone := castMapToZone(zonePayload(t, tt.payload))
+			if !equalIntPtr(zone.LowerLimit, tt.lower) || !equalIntPtr(zone.UpperLimit, tt.upper) {
+				t.Errorf("limits = %s..%s, want %s..%s", formatIntPtr(zone.LowerLimit), formatIntPtr(zone.UpperLimit),
+					formatIntPtr(tt.lower), formatIntPtr(tt.upper))
+			}
+		})
+	}
+}
+
+func TestZoneSchedule(t *testing.T) {
+	from := time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)
+	to := from.Add(2 * time.Hour)
+	tests := []struct {
+		name     string
+		payload  string
+		schedule string
+		periods  []model.TimePeriod
+	}{
+		{"avtm timestamps", `{"activePeriods": [{"from": "2030-06-01T12:00:00.000", "to": "2030-06-01T14:00:00.000"}]}`,
+			"", []model.TimePeriod{{From: from, To: to}}},
+		{"rfc3339", `{"periods": [{"start": "2030-06-01T15:00:00+03:00", "end": "2030-06-01T17:00:00+03:00"}]}`,
+			"", []model.TimePeriod{{From: from, To: to}}},
+		{"unix milliseconds", `{"activations": [{"dateFrom": 1906632000000, "dateTo": 1906639200000}]}`,
+			"", []model.TimePeriod{{From: from.AddDate(0, 0, 1), To: to.AddDate(0, 0, 1)}}},
+		{"text schedule", `{"schedule": "ежедневно 08:00-20:00"}`, "ежедневно 08:00-20:00", nil},
+		{"schedule list of periods", `{"schedule": [{"from": "2030-06-01T12:00:00", "to": "2030-06-01T14:00:00"}]}`,
+			"", []model.TimePeriod{{From: from, To: to}}},
+		{"schedule list of strings", `{"workTime": ["пн-пт 09:00-18:00", " ", "сб 10:00-14:00"]}`,
+			"пн-пт 09:00-18:00, сб 10:00-14:00", nil},
+		{"incomplete periods are skipped", `{"activePeriods": [{"from": "2030-06-01T12:00:00"}, {"to": "soon"}]}`, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zone := castMapToZone(zonePayload(t, tt.payload))
+			if zone.Schedule != tt.schedule {
+				t.Errorf("schedule = %q, want %q", zone.Schedule, tt.schedule)
+			}
+			if len(zone.ActivePeriods) != len(tt.periods) {
+				t.Fatalf("periods = %v, want %v", zone.ActivePeriods, tt.periods)
+			}
+			for i, period := range zone.ActivePeriods {
+				if !period.From.Equal(tt.periods[i].From) || !period.To.Equal(tt.periods[i].To) {
+					t.Errorf("period %d = %v, want %v", i, period, tt.periods[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCastMapToZone(t *testing.T) {
+	zone := castMapToZone(zonePayload(t, `{"code": "UUR123", "name": "UUR123", "isTemporary": true,
+		"contacts": ["+7 495 000-00-00", "atc@example.com"],
+		"geometry": {"type": "Feature", "geometry": {"type": "MultiPolygon", "coordinates": [
+			[[[37.6, 55.7], [37.7, 55.7], [37.7, 55.8], [37.6, 55.7]]],
+			[[[37.6, 55.7], [37.7, 55.7]]]
+		]}}}`))
+	if zone.Code != "UUR123" || zone.Name != "" {
+		t.Errorf("code = %q, name = %q: name equal to code must be dropped", zone.Code, zone.Name)
+	}
+	if zone.Type != model.ZoneTemporary {
+		t.Errorf("type = %q, want %q", zone.Type, model.ZoneTemporary)
+	}
+	if zone.Contact != "+7 495 000-00-00, atc@example.com" {
+		t.Errorf("contact = %q", zone.Contact)
+	}
+	//полигон из двух точек отбрасывается
+	if len(zone.Geometry) != 1 || len(zone.Geometry[0]) != 4 || zone.Geometry[0][1] != (model.Coordinate{Lat: 55.7, Lng: 37.7}) {
+		t.Errorf("geometry = %v", zone.Geometry)
+	}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func equalIntPtr(a, b *int) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+func formatIntPtr(v *int) string {
+	if v == nil {
+		return "nil"
+	}
+	b, _ := json.Marshal(*v)
+	return string(b)
+}
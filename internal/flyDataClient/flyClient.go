@@ -1,23 +1,40 @@
 package flyDataClient
 
-import "github.com/japersik/safe-flight-bot/model"
+import (
+	"context"
+	"errors"
+	"github.com/japersik/safe-flight-bot/model"
+	"time"
+)
 
 type WeatherInfoSource interface {
-	GetForecastWeather(model.Coordinate) (*model.WeatherForecast, error)
-	GetCurrentWeather(model.Coordinate) (*model.CurrentWeatherData, error)
+	GetForecastWeather(context.Context, model.Coordinate) (*model.WeatherForecast, error)
+	GetCurrentWeather(context.Context, model.Coordinate) (*model.CurrentWeatherData, error)
 }
 
+//PeriodTooFarErr окно полёта заканчивается позже, чем сервис зон может показать их активность
+var PeriodTooFarErr = errors.New("flight window is too far ahead for zone activity check")
+
 type ZoneInfoSource interface {
-	CheckConditions(model.Coordinate, int) (model.Condition, error)
+	CheckConditions(ctx context.Context, coordinate model.Coordinate, radius int, altitude int) (model.Condition, error)
+	//CheckConditionsForPeriod returns PeriodTooFarErr if the window can't be checked yet
+	CheckConditionsForPeriod(ctx context.Context, coordinate model.Coordinate, radius int, altitude int, from time.Time, duration time.Duration) (model.Condition, error)
 }
 
 type LocalityInfo struct {
-	Name           string
-	FlyRestriction bool
+	Name           string `json:"name"`
+	FlyRestriction bool   `json:"flyRestriction"`
+}
+
+//Place result of search by place name
+type Place struct {
+	Name       string           `json:"name"`
+	Coordinate model.Coordinate `json:"coordinate"`
 }
 
 type LocalityInfoSource interface {
-	GetLocalityFlyInfo(model.Coordinate) (*LocalityInfo, error)
+	GetLocalityFlyInfo(context.Context, model.Coordinate) (*LocalityInfo, error)
+	SearchLocality(ctx context.Context, query string, limit int) ([]Place, error)
 }
 
 type Client struct {
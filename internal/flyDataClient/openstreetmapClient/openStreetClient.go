@@ -1,28 +1,44 @@
 package openstreetmapClient
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/japersik/safe-flight-bot/internal/flyDataClient"
+	"github.com/japersik/safe-flight-bot/logger"
 	"github.com/japersik/safe-flight-bot/model"
-	"log"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 )
 
 const (
-	getDataEndPoint = "https://nominatim.openstreetmap.org/reverse/"
+	//DefaultBaseURL адрес сервиса Nominatim OpenStreetMap
+	DefaultBaseURL = "https://nominatim.openstreetmap.org"
+	defaultTimeout = time.Second * 3
+
+	getDataEndPoint = "/reverse/"
+	searchEndPoint  = "/search"
 )
 
 type OpenStreetClient struct {
 	webClient http.Client
+	baseURL   string
+}
+
+//SetTransport транспорт http запросов, например с учетом метрик
+func (c *OpenStreetClient) SetTransport(transport http.RoundTripper) {
+	c.webClient.Transport = transport
 }
 
-func (c OpenStreetClient) GetLocalityFlyInfo(coordinate model.Coordinate) (*flyDataClient.LocalityInfo, error) {
-	log.Printf("getting information about locality at point (%f, %f)\n", coordinate.Lat, coordinate.Lng)
-	url, err := url.Parse(getDataEndPoint)
+func (c OpenStreetClient) GetLocalityFlyInfo(ctx context.Context, coordinate model.Coordinate) (*flyDataClient.LocalityInfo, error) {
+	logger.FromContext(ctx).Debug("getting locality info", logger.Any("coordinate", coordinate))
+	url, err := url.Parse(c.baseURL + getDataEndPoint)
+	if err != nil {
+		return nil, err
+	}
 	var req = &http.Request{
 		Method: http.MethodGet,
 		URL:    url,
@@ -37,7 +53,7 @@ func (c OpenStreetClient) GetLocalityFlyInfo(coordinate model.Coordinate) (*flyD
 	q.Add("format", "jsonv2")
 	req.URL.RawQuery = q.Encode()
 
-	response, err := c.webClient.Do(req)
+	response, err := c.webClient.Do(req.WithContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -74,10 +90,69 @@ func (c OpenStreetClient) GetLocalityFlyInfo(coordinate model.Coordinate) (*flyD
 	return &ans, nil
 }
 
-func NewOpenStreetClient() *OpenStreetClient {
+//SearchLocality forward geocoding of place name with Nominatim search
+func (c OpenStreetClient) SearchLocality(ctx context.Context, query string, limit int) ([]flyDataClient.Place, error) {
+	logger.FromContext(ctx).Debug("searching for place", logger.String("query", query))
+	url, err := url.Parse(c.baseURL + searchEndPoint)
+	if err != nil {
+		return nil, err
+	}
+	var req = &http.Request{
+		Method: http.MethodGet,
+		URL:    url,
+		Header: http.Header{"Content-Type": []string{"application/json"}},
+	}
+
+	q := req.URL.Query()
+	q.Add("q", query)
+	q.Add("limit", strconv.Itoa(limit))
+	q.Add("accept-language", "ru")
+	q.Add("format", "jsonv2")
+	req.URL.RawQuery = q.Encode()
+
+	response, err := c.webClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	decoder := json.NewDecoder(response.Body)
+	resp := []struct {
+		Lat         string `json:"lat"`
+		Lon         string `json:"lon"`
+		DisplayName string `json:"display_name"`
+	}{}
+	if err = decoder.Decode(&resp); err != nil {
+		return nil, err
+	}
+
+	ans := make([]flyDataClient.Place, 0, len(resp))
+	for _, place := range resp {
+		lat, errLat := strconv.ParseFloat(place.Lat, 64)
+		lng, errLng := strconv.ParseFloat(place.Lon, 64)
+		if errLat != nil || errLng != nil {
+			continue
+		}
+		ans = append(ans, flyDataClient.Place{
+			Name:       place.DisplayName,
+			Coordinate: model.Coordinate{Lat: lat, Lng: lng},
+		})
+	}
+	return ans, nil
+}
+
+//NewOpenStreetClient baseURL позволяет использовать локальную заглушку или свой сервер Nominatim.
+//Пустой адрес и нулевой таймаут - значения по умолчанию
+func NewOpenStreetClient(baseURL string, timeout time.Duration) *OpenStreetClient {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
 	return &OpenStreetClient{
 		webClient: http.Client{
-			Timeout: time.Second * 3,
+			Timeout: timeout,
 		},
+		baseURL: strings.TrimSuffix(baseURL, "/"),
 	}
 }
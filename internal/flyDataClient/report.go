@@ -0,0 +1,194 @@
+package flyDataClient
+
+import (
+	"context"
+	"fmt"
+	"github.com/japersik/safe-flight-bot/internal/tracing"
+	"github.com/japersik/safe-flight-bot/logger"
+	"github.com/japersik/safe-flight-bot/model"
+	"go.opentelemetry.io/otel/attribute"
+	"sync"
+	"time"
+)
+
+//Report collected information about the flight area. Nil fields mean that the source is unavailable
+type Report struct {
+	Coordinate model.Coordinate       `json:"coordinate"`
+	Radius     int                    `json:"radius"`
+	Altitude   int                    `json:"altitude"`
+	Locality   *LocalityInfo          `json:"locality,omitempty"`
+	Condition  *model.Condition       `json:"condition,omitempty"`
+	Weather    *model.WeatherForecast `json:"weather,omitempty"`
+}
+
+//Zones returns active and inactive zones of the report
+func (r Report) Zones() []model.Zone {
+	if r.Condition == nil {
+		return nil
+	}
+	zones := make([]model.Zone, 0, len(r.Condition.ActiveZones)+len(r.Condition.InactiveZones))
+	zones = append(zones, r.Condition.ActiveZones...)
+	return append(zones, r.Condition.InactiveZones...)
+}
+
+//GetReport requests all sources concurrently. Each section is traced in its own span
+func (c Client) GetReport(ctx context.Context, coordinate model.Coordinate, radius int, altitude int) Report {
+	if altitude <= 0 {
+		altitude = model.DefaultAltitude()
+	}
+	ctx, span := tracing.Start(ctx, "report", attribute.Float64("coordinate.lat", coordinate.Lat),
+		attribute.Float64("coordinate.lng", coordinate.Lng), attribute.Int("radius", radius), attribute.Int("altitude", altitude))
+	defer span.End()
+	report := Report{Coordinate: coordinate, Radius: radius, Altitude: altitude}
+	wg := sync.WaitGroup{}
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		ctx, span := tracing.Start(ctx, "report.locality")
+		locality, err := c.GetLocalityFlyInfo(ctx, coordinate)
+		tracing.End(span, err)
+		if err != nil {
+			logger.FromContext(ctx).Warn("locality info error", logger.Err(err))
+			return
+		}
+		report.Locality = locality
+	}()
+	go func() {
+		defer wg.Done()
+		ctx, span := tracing.Start(ctx, "report.zones")
+		condition, err := c.CheckConditions(ctx, coordinate, radius, altitude)
+		tracing.End(span, err)
+		if err != nil {
+			logger.FromContext(ctx).Warn("zone info error", logger.Err(err))
+			return
+		}
+		report.Condition = &condition
+	}()
+	go func() {
+		defer wg.Done()
+		ctx, span := tracing.Start(ctx, "report.weather")
+		weather, err := c.GetForecastWeather(ctx, coordinate)
+		tracing.End(span, err)
+		if err != nil {
+			logger.FromContext(ctx).Warn("weather info error", logger.Err(err))
+			return
+		}
+		report.Weather = weather
+	}()
+	wg.Wait()
+	return report
+}
+
+//VerdictLevel итоговая оценка возможности полёта
+type VerdictLevel int
+
+const (
+	VerdictUnknown VerdictLevel = iota
+	VerdictGo
+	VerdictCaution
+	VerdictNoGo
+)
+
+//Verdict оценка возможности полёта с причинами
+type Verdict struct {
+	Level   VerdictLevel `json:"level"`
+	Reasons []string     `json:"reasons"`
+}
+
+func (v *Verdict) raise(level VerdictLevel, reason string) {
+	if level > v.Level {
+		v.Level = level
+	}
+	v.Reasons = append(v.Reasons, reason)
+}
+
+//Verdict returns go/no-go estimation of the report
+func (r Report) Verdict() Verdict {
+	verdict := Verdict{Reasons: []string{}}
+	if r.Condition == nil {
+		verdict.Reasons = append(verdict.Reasons, "нет данных о зонах ограничений")
+		return verdict
+	}
+	verdict.Level = VerdictGo
+	if r.Condition.NearBoundaryZone {
+		verdict.raise(VerdictNoGo, "20-км приграничная зона")
+	}
+	if len(r.Condition.ActiveZones) > 0 {
+		verdict.raise(VerdictNoGo, "действуют зоны ограничений полётов")
+	}
+	if len(r.Condition.InactiveZones) > 0 {
+		verdict.raise(VerdictCaution, "рядом есть зоны, которые могут стать активными")
+	}
+	if !r.Condition.DaylightHours {
+		verdict.raise(VerdictCaution, "тёмное время суток")
+	}
+	if r.Locality != nil && r.Locality.FlyRestriction {
+		verdict.raise(VerdictCaution, "полёт над населённым пунктом требует согласования")
+	}
+	return verdict
+}
+
+//WeatherAt returns forecast for the time or current weather if there is no forecast
+func (r Report) WeatherAt(at time.Time) *model.WeatherData {
+	if r.Weather == nil {
+		return nil
+	}
+	weather := r.Weather.Current
+	for _, hourly := range r.Weather.Hourly {
+		if !hourly.Timestamp.After(at) {
+			weather = hourly
+		}
+	}
+	return &weather
+}
+
+//DroneVerdict проверка пригодности погоды для дрона на указанное время
+func (r Report) DroneVerdict(drone model.Drone, at time.Time) Verdict {
+	verdict := Verdict{Reasons: []string{}}
+	weather := r.WeatherAt(at)
+	if weather == nil {
+		verdict.Reasons = append(verdict.Reasons, "нет данных о погоде")
+		return verdict
+	}
+	verdict.Level = VerdictGo
+	if drone.MaxWind > 0 && weather.WindSpeed > drone.MaxWind {
+		verdict.raise(VerdictNoGo, fmt.Sprintf("ветер %.1f м/с превышает допустимый %.1f м/с", weather.WindSpeed, drone.MaxWind))
+	} else if drone.MaxWind > 0 && weather.WindSpeed > drone.MaxWind*0.8 {
+		verdict.raise(VerdictCaution, fmt.Sprintf("ветер %.1f м/с близок к допустимому %.1f м/с", weather.WindSpeed, drone.MaxWind))
+	}
+	if drone.HasTempLimits() && (weather.Temperature < drone.MinTemp || weather.Temperature > drone.MaxTemp) {
+		verdict.raise(VerdictNoGo, fmt.Sprintf("температура %.0f °C вне рабочего диапазона %.0f..%.0f °C",
+			weather.Temperature, drone.MinTemp, drone.MaxTemp))
+	}
+	if drone.NeedsRegistration() && drone.RegistrationNumber == "" {
+		verdict.raise(VerdictCaution, "не указан учетный номер дрона")
+	}
+	return verdict
+}
+
+func (l VerdictLevel) String() string {
+	switch l {
+	case VerdictGo:
+		return "Ограничений полётов не найдено"
+	case VerdictCaution:
+		return "Полёты возможны с ограничениями"
+	case VerdictNoGo:
+		return "Полёты запрещены"
+	default:
+		return "Недостаточно данных"
+	}
+}
+
+//Status короткое обозначение оценки для скриптов и API: go, caution, no-go или unknown
+func (l VerdictLevel) Status() string {
+	switch l {
+	case VerdictGo:
+		return "go"
+	case VerdictCaution:
+		return "caution"
+	case VerdictNoGo:
+		return "no-go"
+	default:
+		return "unknown"
+	}
+}
@@ -1,26 +1,66 @@
 package flyPlanner
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"github.com/japersik/safe-flight-bot/internal/metrics"
+	"github.com/japersik/safe-flight-bot/internal/tracing"
 	"github.com/japersik/safe-flight-bot/logger"
 	"github.com/japersik/safe-flight-bot/model"
+	"go.opentelemetry.io/otel/attribute"
 	"io"
 	"os"
+	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 )
 
-const updateNotifyTime = 120 * time.Second
+//DefaultUpdateInterval период, с которым таймеры создаются для уведомлений ближайшего времени
+const DefaultUpdateInterval = 120 * time.Second
+
+var (
+	PlanNotExistErr = errors.New("id not exist")
+	OwnPlanErr      = errors.New("user is plan owner")
+	PlansLimitErr   = errors.New("active plans limit reached")
+	StoreLockedErr  = errors.New("plans file is used by another process")
+)
 
 type Planner interface {
 	SetNotifier(notifier Notifier)
+	SetChangeListener(listener ChangeListener)
 	PlanFly(info model.FlyPlan) (flyId uint64, err error)
-	CancelFly(flyId uint64) error
+	//CancelFly и UpdateFly после изменения плана вызывают ChangeListener с ctx вызова
+	CancelFly(ctx context.Context, flyId uint64) error
+	GetFly(flyId uint64) (model.FlyPlan, error)
+	UpdateFly(ctx context.Context, info model.FlyPlan) error
+	ShareFly(flyId uint64) (token string, err error)
+	Subscribe(token string, subscriber model.Subscriber) (model.FlyPlan, error)
+	Unsubscribe(flyId uint64, userId int64) error
+	ToggleChecklistItem(flyId uint64, item int) (model.FlyPlan, error)
+	SetLastCheck(flyId uint64, conditions model.FlightConditions) error
+	//UserPlans returns plans created by the user or the user is subscribed to
+	UserPlans(userId int64) []model.FlyPlan
+	//AllPlans returns all active plans ordered by id
+	AllPlans() []model.FlyPlan
+	//PendingNotifications returns number of notification timers already scheduled
+	PendingNotifications() int
 }
 
 type Notifier interface {
-	Notify(data model.FlyPlan) error
+	//Notify notification - смещение уведомления относительно FlyDateTime. ctx содержит логгер уведомления
+	Notify(ctx context.Context, data model.FlyPlan, notification time.Duration) error
+}
+
+//ChangeListener оповещает подписчиков об отмене и изменении плана, откуда бы они ни были сделаны: из чата,
+//через REST API или sfcheck. Вызывается после изменения, без блокировки планировщика
+type ChangeListener interface {
+	PlanCanceled(ctx context.Context, plan model.FlyPlan)
+	PlanUpdated(ctx context.Context, plan model.FlyPlan)
 }
 
 type plansData struct {
@@ -31,10 +71,15 @@ type plansData struct {
 
 type Planer struct {
 	notifier       Notifier
+	listener       ChangeListener
 	plansData      *plansData
 	notifyMap      map[runningPlan]*time.Timer
 	notifyMapMutex *sync.Mutex
 	filepath       string
+	updateInterval time.Duration
+	maxUserPlans   int
+	// открыт, пока процесс держит блокировку файла планов (LockStore)
+	lockFile *os.File
 }
 type runningPlan struct {
 	flyId        uint64
@@ -50,7 +95,8 @@ func NewPlaner(filepath string) *Planer {
 	},
 		notifyMap:      map[runningPlan]*time.Timer{},
 		notifyMapMutex: &sync.Mutex{},
-		filepath:       filepath}
+		filepath:       filepath,
+		updateInterval: DefaultUpdateInterval}
 
 }
 
@@ -59,13 +105,25 @@ func (p *Planer) SetNotifier(notifier Notifier) {
 	p.notifier = notifier
 }
 
+//SetChangeListener ...
+func (p *Planer) SetChangeListener(listener ChangeListener) {
+	p.listener = listener
+}
+
+//SetUpdateInterval задается до Init и Start
+func (p *Planer) SetUpdateInterval(interval time.Duration) {
+	if interval > 0 {
+		p.updateInterval = interval
+	}
+}
+
 //Start ...
 func (p *Planer) Start() error {
-	logger.Info("notification starting")
+	logger.Info("notification starting", logger.Duration(p.updateInterval))
 	if p.notifier == nil {
 		return errors.New("notifier not defined")
 	}
-	ticker := time.NewTicker(updateNotifyTime)
+	ticker := time.NewTicker(p.updateInterval)
 	quit := make(chan struct{})
 	go func() {
 		for {
@@ -81,10 +139,17 @@ func (p *Planer) Start() error {
 	return nil
 }
 
-func (p *Planer) sendNotify(notificationInfo runningPlan) error {
+func (p *Planer) sendNotify(ctx context.Context, notificationInfo runningPlan) error {
+	//без получателя план не изменяется: уведомление отправит процесс, работающий с уведомлениями
+	if p.notifier == nil {
+		return errors.New("notifier not defined")
+	}
 	flyId := notificationInfo.flyId
 	p.plansData.plansInfoMutex.Lock()
-	defer p.plansData.plansInfoMutex.Unlock()
+	if _, ok := p.plansData.PlansInfo[flyId]; !ok {
+		p.plansData.plansInfoMutex.Unlock()
+		return PlanNotExistErr
+	}
 	if !p.plansData.PlansInfo[flyId].IsEveryDayPlan {
 		for i, timeDir := range p.plansData.PlansInfo[flyId].Notifications {
 			if i >= len(p.plansData.PlansInfo[flyId].Notifications) {
@@ -97,12 +162,14 @@ func (p *Planer) sendNotify(notificationInfo runningPlan) error {
 			}
 		}
 	}
-	toSend := *p.plansData.PlansInfo[flyId]
+	toSend := copyPlan(p.plansData.PlansInfo[flyId])
 	if !p.plansData.PlansInfo[flyId].IsEveryDayPlan && len(p.plansData.PlansInfo[flyId].Notifications) == 0 &&
 		p.plansData.PlansInfo[flyId].FlyDateTime.Sub(time.Now()) < 0 {
 		delete(p.plansData.PlansInfo, flyId)
 	}
-	return p.notifier.Notify(toSend)
+	//получатель может обращаться к планировщику (SetLastCheck), поэтому вызывается без блокировки
+	p.plansData.plansInfoMutex.Unlock()
+	return p.notifier.Notify(ctx, toSend, notificationInfo.notification)
 }
 
 func (p *Planer) updateNotificationList() {
@@ -128,12 +195,22 @@ func (p *Planer) addAllNotifications(plan model.FlyPlan) {
 				deltaT += time.Hour * 24
 			}
 		}
-		if deltaT <= updateNotifyTime && deltaT > -updateNotifyTime/2 {
+		if deltaT <= p.updateInterval && deltaT > -p.updateInterval/2 {
 			//fmt.Println("Adding ", deltaT, notification)
 			notificationInfo := runningPlan{plan.FlyId, notification}
 			if _, ok := p.notifyMap[notificationInfo]; !ok {
 				p.notifyMap[notificationInfo] = time.AfterFunc(deltaT, func() {
-					p.sendNotify(notificationInfo)
+					ctx, span := tracing.Start(logger.WithCorrelation(context.Background(),
+						logger.FlyId(notificationInfo.flyId), logger.Any("offset", notificationInfo.notification)),
+						"planner.notify", attribute.Int64("fly.id", int64(notificationInfo.flyId)),
+						attribute.String("notification.offset", notificationInfo.notification.String()))
+					ctx = tracing.WithTraceId(ctx)
+					err := p.sendNotify(ctx, notificationInfo)
+					tracing.End(span, err)
+					if err != nil {
+						logger.FromContext(ctx).Error("notification error", logger.Err(err))
+					}
+					metrics.NotificationSent(err)
 					p.notifyMapMutex.Lock()
 					defer p.notifyMapMutex.Unlock()
 					delete(p.notifyMap, notificationInfo)
@@ -145,29 +222,242 @@ func (p *Planer) addAllNotifications(plan model.FlyPlan) {
 func (p *Planer) Init() {
 	err := p.loadPlans()
 	if err != nil {
-		logger.Fatal(err)
+		logger.Fatal("flight plan file loading error", logger.String("file", p.filepath), logger.Err(err))
 	}
+	p.updateNotificationList()
+}
+
+//CheckStore проверка возможности записи файла планов: планы сохраняются при остановке
+func (p *Planer) CheckStore() error {
+	dir := filepath.Dir(p.filepath)
+	file, err := os.CreateTemp(dir, ".check-*")
+	if err != nil {
+		return fmt.Errorf("plans directory %s is not writable: %w", dir, err)
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+//Load загрузка планов без запуска уведомлений, для утилит, работающих с файлом планов
+func (p *Planer) Load() error {
+	return p.loadPlans()
+}
+
+//SetMaxUserPlans ограничение числа активных планов пользователя, включая подписки. 0 - без ограничения
+func (p *Planer) SetMaxUserPlans(max int) {
+	p.plansData.plansInfoMutex.Lock()
+	p.maxUserPlans = max
+	p.plansData.plansInfoMutex.Unlock()
+}
+
+//limitReached вызывается под plansInfoMutex. Прошедшие разовые планы не учитываются: они хранятся до последнего
+//уведомления после полёта
+func (p *Planer) limitReached(userId int64) bool {
+	if p.maxUserPlans <= 0 {
+		return false
+	}
+	now := time.Now()
+	count := 0
+	for _, plan := range p.plansData.PlansInfo {
+		if !plan.IsEveryDayPlan && !plan.FlyDateTime.After(now) {
+			continue
+		}
+		if plan.Data.UserId == userId || plan.IsSubscribed(userId) {
+			count++
+		}
+	}
+	return count >= p.maxUserPlans
 }
 
 //PlanFly ...
 func (p *Planer) PlanFly(info model.FlyPlan) (uint64, error) {
-	p.plansData.MaxPlanId++
-	info.FlyId = p.plansData.MaxPlanId
 	if info.IsEveryDayPlan {
 		info.FlyDateTime = info.FlyDateTime.AddDate(time.Now().Year()-info.FlyDateTime.Year(), 0, 0)
 	}
 	p.plansData.plansInfoMutex.Lock()
 	defer p.plansData.plansInfoMutex.Unlock()
+	if p.limitReached(info.Data.UserId) {
+		return 0, PlansLimitErr
+	}
+	p.plansData.MaxPlanId++
+	info.FlyId = p.plansData.MaxPlanId
 	p.plansData.PlansInfo[info.FlyId] = &info
 	p.addAllNotifications(info)
-	logger.InfoF("flight No.%d from user %d created at (%f, %f)\n", info.FlyId, info.Data.UserId,
-		info.Data.Coordinate.Lat, info.Data.Coordinate.Lng)
+	logger.Info("flight created", logger.FlyId(info.FlyId), logger.UserId(info.Data.UserId),
+		logger.Any("coordinate", info.Data.Coordinate))
 	return info.FlyId, nil
 }
 
 //CancelFly ...
-func (p *Planer) CancelFly(flyId uint64) error {
-	logger.InfoF("flight No.%d canceled\n", flyId)
+func (p *Planer) CancelFly(ctx context.Context, flyId uint64) error {
+	p.stopNotifications(flyId)
+
+	p.plansData.plansInfoMutex.Lock()
+	plan, ok := p.plansData.PlansInfo[flyId]
+	if !ok {
+		p.plansData.plansInfoMutex.Unlock()
+		return PlanNotExistErr
+	}
+	delete(p.plansData.PlansInfo, flyId)
+	canceled := copyPlan(plan)
+	p.plansData.plansInfoMutex.Unlock()
+	logger.FromContext(ctx).Info("flight canceled", logger.FlyId(flyId))
+	if p.listener != nil {
+		p.listener.PlanCanceled(ctx, canceled)
+	}
+	return nil
+}
+
+//UpdateFly изменение времени и параметров плана с перезапуском уведомлений. Подписчики и токен сохраняются
+func (p *Planer) UpdateFly(ctx context.Context, info model.FlyPlan) error {
+	p.stopNotifications(info.FlyId)
+	if info.IsEveryDayPlan {
+		info.FlyDateTime = info.FlyDateTime.AddDate(time.Now().Year()-info.FlyDateTime.Year(), 0, 0)
+	}
+	p.plansData.plansInfoMutex.Lock()
+	old, ok := p.plansData.PlansInfo[info.FlyId]
+	if !ok {
+		p.plansData.plansInfoMutex.Unlock()
+		return PlanNotExistErr
+	}
+	info.ShareToken = old.ShareToken
+	info.Subscribers = old.Subscribers
+	info.Checklist = old.Checklist
+	p.plansData.PlansInfo[info.FlyId] = &info
+	p.addAllNotifications(info)
+	updated := copyPlan(&info)
+	p.plansData.plansInfoMutex.Unlock()
+	logger.FromContext(ctx).Info("flight updated", logger.FlyId(info.FlyId))
+	if p.listener != nil {
+		p.listener.PlanUpdated(ctx, updated)
+	}
+	return nil
+}
+
+//ShareFly возвращает токен приглашения, создавая его при первом вызове
+func (p *Planer) ShareFly(flyId uint64) (string, error) {
+	p.plansData.plansInfoMutex.Lock()
+	defer p.plansData.plansInfoMutex.Unlock()
+	plan, ok := p.plansData.PlansInfo[flyId]
+	if !ok {
+		return "", PlanNotExistErr
+	}
+	if plan.ShareToken == "" {
+		buf := make([]byte, 8)
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		plan.ShareToken = hex.EncodeToString(buf)
+	}
+	return plan.ShareToken, nil
+}
+
+//Subscribe подписка пользователя на уведомления плана по токену приглашения
+func (p *Planer) Subscribe(token string, subscriber model.Subscriber) (model.FlyPlan, error) {
+	p.plansData.plansInfoMutex.Lock()
+	defer p.plansData.plansInfoMutex.Unlock()
+	for _, plan := range p.plansData.PlansInfo {
+		if token == "" || plan.ShareToken != token {
+			continue
+		}
+		if plan.Data.UserId == subscriber.UserId {
+			return copyPlan(plan), OwnPlanErr
+		}
+		if !plan.IsSubscribed(subscriber.UserId) {
+			if p.limitReached(subscriber.UserId) {
+				return copyPlan(plan), PlansLimitErr
+			}
+			plan.Subscribers = append(plan.Subscribers, subscriber)
+			logger.Info("user subscribed to flight", logger.UserId(subscriber.UserId), logger.FlyId(plan.FlyId))
+		}
+		return copyPlan(plan), nil
+	}
+	return model.FlyPlan{}, PlanNotExistErr
+}
+
+//Unsubscribe ...
+func (p *Planer) Unsubscribe(flyId uint64, userId int64) error {
+	p.plansData.plansInfoMutex.Lock()
+	defer p.plansData.plansInfoMutex.Unlock()
+	plan, ok := p.plansData.PlansInfo[flyId]
+	if !ok {
+		return PlanNotExistErr
+	}
+	if !plan.IsSubscribed(userId) {
+		return PlanNotExistErr
+	}
+	//новый срез: выданные копии плана могут использоваться без блокировки
+	subscribers := make([]model.Subscriber, 0, len(plan.Subscribers)-1)
+	for _, sub := range plan.Subscribers {
+		if sub.UserId != userId {
+			subscribers = append(subscribers, sub)
+		}
+	}
+	plan.Subscribers = subscribers
+	return nil
+}
+
+//ToggleChecklistItem отметка пункта чек-листа выполненным или снятие отметки
+func (p *Planer) ToggleChecklistItem(flyId uint64, item int) (model.FlyPlan, error) {
+	p.plansData.plansInfoMutex.Lock()
+	defer p.plansData.plansInfoMutex.Unlock()
+	plan, ok := p.plansData.PlansInfo[flyId]
+	if !ok {
+		return model.FlyPlan{}, PlanNotExistErr
+	}
+	if item < 0 || item >= len(plan.Checklist) {
+		return copyPlan(plan), errors.New("checklist item not exist")
+	}
+	plan.Checklist[item].Done = !plan.Checklist[item].Done
+	return copyPlan(plan), nil
+}
+
+//SetLastCheck ...
+func (p *Planer) SetLastCheck(flyId uint64, conditions model.FlightConditions) error {
+	p.plansData.plansInfoMutex.Lock()
+	defer p.plansData.plansInfoMutex.Unlock()
+	plan, ok := p.plansData.PlansInfo[flyId]
+	if !ok {
+		return PlanNotExistErr
+	}
+	plan.LastCheck = &conditions
+	return nil
+}
+
+//UserPlans ...
+func (p *Planer) UserPlans(userId int64) []model.FlyPlan {
+	p.plansData.plansInfoMutex.Lock()
+	defer p.plansData.plansInfoMutex.Unlock()
+	plans := make([]model.FlyPlan, 0)
+	for _, plan := range p.plansData.PlansInfo {
+		if plan.Data.UserId == userId || plan.IsSubscribed(userId) {
+			plans = append(plans, copyPlan(plan))
+		}
+	}
+	sort.Slice(plans, func(i, j int) bool { return plans[i].FlyId < plans[j].FlyId })
+	return plans
+}
+
+//AllPlans ...
+func (p *Planer) AllPlans() []model.FlyPlan {
+	p.plansData.plansInfoMutex.Lock()
+	defer p.plansData.plansInfoMutex.Unlock()
+	plans := make([]model.FlyPlan, 0, len(p.plansData.PlansInfo))
+	for _, plan := range p.plansData.PlansInfo {
+		plans = append(plans, copyPlan(plan))
+	}
+	sort.Slice(plans, func(i, j int) bool { return plans[i].FlyId < plans[j].FlyId })
+	return plans
+}
+
+//PendingNotifications ...
+func (p *Planer) PendingNotifications() int {
+	p.notifyMapMutex.Lock()
+	defer p.notifyMapMutex.Unlock()
+	return len(p.notifyMap)
+}
+
+func (p *Planer) stopNotifications(flyId uint64) {
 	p.notifyMapMutex.Lock()
 	defer p.notifyMapMutex.Unlock()
 	for plan, timer := range p.notifyMap {
@@ -176,14 +466,26 @@ func (p *Planer) CancelFly(flyId uint64) error {
 			delete(p.notifyMap, plan)
 		}
 	}
+}
 
+//GetFly ...
+func (p *Planer) GetFly(flyId uint64) (model.FlyPlan, error) {
 	p.plansData.plansInfoMutex.Lock()
 	defer p.plansData.plansInfoMutex.Unlock()
-	if _, ok := p.plansData.PlansInfo[flyId]; ok {
-		delete(p.plansData.PlansInfo, flyId)
-		return nil
+	if plan, ok := p.plansData.PlansInfo[flyId]; ok {
+		return copyPlan(plan), nil
 	}
-	return errors.New("id not exist")
+	return model.FlyPlan{}, PlanNotExistErr
+}
+
+//copyPlan копия хранимого плана для выдачи наружу. Срезы копируются, так как планировщик изменяет их под
+//блокировкой, а копии используются без нее. Вызывается под plansInfoMutex
+func copyPlan(plan *model.FlyPlan) model.FlyPlan {
+	result := *plan
+	result.Notifications = append(plan.Notifications[:0:0], plan.Notifications...)
+	result.Subscribers = append(plan.Subscribers[:0:0], plan.Subscribers...)
+	result.Checklist = append(plan.Checklist[:0:0], plan.Checklist...)
+	return result
 }
 
 //loadPlans ...
@@ -202,20 +504,20 @@ func (p *Planer) loadPlans() error {
 	err = decoder.Decode(plansData)
 	if err != nil {
 		if err == io.EOF {
-			logger.Info("Creating new data file")
+			logger.Info("creating new flight plan file", logger.String("file", p.filepath))
 			return nil
 		}
 		return err
 	}
 	p.plansData = plansData
-	logger.InfoF("flight plan file loaded. There are %d plans in total. Max id %d", len(plansData.PlansInfo), plansData.MaxPlanId)
-	p.updateNotificationList()
+	logger.Info("flight plan file loaded", logger.String("file", p.filepath), logger.Int("plans", len(plansData.PlansInfo)),
+		logger.Any("maxPlanId", plansData.MaxPlanId))
 	return nil
 }
 
 //SavePlans ...
 func (p Planer) SavePlans() error {
-	file, err := os.OpenFile(p.filepath, os.O_CREATE|os.O_WRONLY, 0644)
+	file, err := os.OpenFile(p.filepath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
 		return err
 	}
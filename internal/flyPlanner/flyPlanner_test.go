@@ -0,0 +1,345 @@
+package flyPlanner
+
+import (
+	"context"
+	"errors"
+	"github.com/japersik/safe-flight-bot/logger"
+	"github.com/japersik/safe-flight-bot/model"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	logger.NewInstance(logger.NewZapLogger(zap.NewNop()))
+	os.Exit(m.Run())
+}
+
+//newTestPlaner планировщик, создающий таймеры только для уведомлений ближайших 10 секунд. Время планов
+//в тестах выбрано вне этого окна, чтобы таймеры не срабатывали
+func newTestPlaner(t *testing.T) *Planer {
+	t.Helper()
+	p := NewPlaner(filepath.Join(t.TempDir(), "plans.json"))
+	p.SetUpdateInterval(10 * time.Second)
+	return p
+}
+
+//recordingNotifier сохраняет уведомления и, как бот, записывает результат проверки в план
+type recordingNotifier struct {
+	planer *Planer
+	plans  []model.FlyPlan
+	err    error
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, data model.FlyPlan, notification time.Duration) error {
+	n.plans = append(n.plans, data)
+	n.planer.SetLastCheck(data.FlyId, model.FlightConditions{CheckedAt: time.Now()})
+	return n.err
+}
+
+func TestSendNotify(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name          string
+		plan          model.FlyPlan
+		notification  time.Duration
+		notifierErr   error
+		remaining     []time.Duration
+		deleted       bool
+		sentOffsets   []time.Duration
+		wantErr       error
+		withoutNotify bool
+	}{
+		{
+			name:         "one-off plan before start",
+			plan:         model.FlyPlan{FlyDateTime: now.Add(2 * time.Hour), Notifications: []time.Duration{-time.Hour, 0, time.Hour}},
+			notification: -time.Hour,
+			remaining:    []time.Duration{time.Hour, 0},
+			sentOffsets:  []time.Duration{time.Hour, 0},
+		},
+		{
+			name:         "last notification after flight deletes plan",
+			plan:         model.FlyPlan{FlyDateTime: now.Add(-3 * time.Hour), Notifications: []time.Duration{time.Hour}},
+			notification: time.Hour,
+			deleted:      true,
+			sentOffsets:  []time.Duration{},
+		},
+		{
+			name:         "last notification before flight keeps plan",
+			plan:         model.FlyPlan{FlyDateTime: now.Add(2 * time.Hour), Notifications: []time.Duration{-time.Hour}},
+			notification: -time.Hour,
+			remaining:    []time.Duration{},
+			sentOffsets:  []time.Duration{},
+		},
+		{
+			name:         "every day plan keeps notifications",
+			plan:         model.FlyPlan{FlyDateTime: now.Add(-time.Hour), IsEveryDayPlan: true, Notifications: []time.Duration{0}},
+			notification: 0,
+			remaining:    []time.Duration{0},
+			sentOffsets:  []time.Duration{0},
+		},
+		{
+			name:         "notifier error is returned",
+			plan:         model.FlyPlan{FlyDateTime: now.Add(2 * time.Hour), Notifications: []time.Duration{0}},
+			notification: 0,
+			notifierErr:  errors.New("telegram is down"),
+			remaining:    []time.Duration{},
+			sentOffsets:  []time.Duration{},
+			wantErr:      errors.New("telegram is down"),
+		},
+		{
+			name:          "without notifier plan is unchanged",
+			plan:          model.FlyPlan{FlyDateTime: now.Add(2 * time.Hour), Notifications: []time.Duration{0}},
+			notification:  0,
+			remaining:     []time.Duration{0},
+			wantErr:       errors.New("notifier not defined"),
+			withoutNotify: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPlaner(t)
+			notifier := &recordingNotifier{planer: p, err: tt.notifierErr}
+			if !tt.withoutNotify {
+				p.SetNotifier(notifier)
+			}
+			flyId, err := p.PlanFly(tt.plan)
+			if err != nil {
+				t.Fatal(err)
+			}
+			//получатель вызывает планировщик, поэтому вызов под блокировкой завис бы
+			done := make(chan error)
+			go func() { done <- p.sendNotify(context.Background(), runningPlan{flyId, tt.notification}) }()
+			select {
+			case err = <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("sendNotify deadlocked")
+			}
+			if (err == nil) != (tt.wantErr == nil) || (err != nil && err.Error() != tt.wantErr.Error()) {
+				t.Fatalf("sendNotify() error = %v, want %v", err, tt.wantErr)
+			}
+			if !tt.withoutNotify {
+				if len(notifier.plans) != 1 {
+					t.Fatalf("notifier called %d times, want 1", len(notifier.plans))
+				}
+				if sent := notifier.plans[0].Notifications; !reflect.DeepEqual(sent, tt.sentOffsets) {
+					t.Errorf("sent plan notifications = %v, want %v", sent, tt.sentOffsets)
+				}
+			}
+			plan, err := p.GetFly(flyId)
+			if deleted := errors.Is(err, PlanNotExistErr); deleted != tt.deleted {
+				t.Fatalf("plan deleted = %v, want %v", deleted, tt.deleted)
+			}
+			if tt.deleted {
+				return
+			}
+			if !reflect.DeepEqual(plan.Notifications, tt.remaining) {
+				t.Errorf("remaining notifications = %v, want %v", plan.Notifications, tt.remaining)
+			}
+			if checked := plan.LastCheck != nil; checked == tt.withoutNotify {
+				t.Errorf("last check saved = %v", checked)
+			}
+		})
+	}
+	t.Run("missing plan", func(t *testing.T) {
+		p := newTestPlaner(t)
+		p.SetNotifier(&recordingNotifier{planer: p})
+		if err := p.sendNotify(context.Background(), runningPlan{42, 0}); !errors.Is(err, PlanNotExistErr) {
+			t.Errorf("sendNotify() error = %v, want %v", err, PlanNotExistErr)
+		}
+	})
+}
+
+func TestReturnedPlansAreCopies(t *testing.T) {
+	p := newTestPlaner(t)
+	flyId, err := p.PlanFly(model.FlyPlan{FlyDateTime: time.Now().Add(48 * time.Hour),
+		Notifications: []time.Duration{-time.Hour, 0}, Checklist: model.NewChecklist([]string{"Заряд батарей"}),
+		Data: model.FlyData{UserId: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _ := p.ShareFly(flyId)
+	if _, err := p.Subscribe(token, model.Subscriber{UserId: 2}); err != nil {
+		t.Fatal(err)
+	}
+	getters := []struct {
+		name string
+		get  func() model.FlyPlan
+	}{
+		{"GetFly", func() model.FlyPlan { plan, _ := p.GetFly(flyId); return plan }},
+		{"UserPlans", func() model.FlyPlan { return p.UserPlans(1)[0] }},
+		{"AllPlans", func() model.FlyPlan { return p.AllPlans()[0] }},
+		{"Subscribe", func() model.FlyPlan { plan, _ := p.Subscribe(token, model.Subscriber{UserId: 2}); return plan }},
+	}
+	for _, g := range getters {
+		t.Run(g.name, func(t *testing.T) {
+			plan := g.get()
+			plan.Notifications[0] = time.Minute
+			plan.Checklist[0].Done = true
+			plan.Subscribers[0].UserId = 3
+			stored, _ := p.GetFly(flyId)
+			if stored.Notifications[0] != -time.Hour || stored.Checklist[0].Done || stored.Subscribers[0].UserId != 2 {
+				t.Errorf("changing the returned plan changed the stored one: %+v", stored)
+			}
+		})
+	}
+}
+
+func TestPlansLimit(t *testing.T) {
+	now := time.Now()
+	oneOff := func(userId int64, at time.Time) model.FlyPlan {
+		return model.FlyPlan{FlyDateTime: at, Notifications: []time.Duration{24 * time.Hour}, Data: model.FlyData{UserId: userId}}
+	}
+	everyDay := func(userId int64, at time.Time) model.FlyPlan {
+		plan := oneOff(userId, at)
+		plan.IsEveryDayPlan = true
+		return plan
+	}
+	tests := []struct {
+		name     string
+		max      int
+		existing []model.FlyPlan
+		err      error
+	}{
+		{"unlimited", 0, []model.FlyPlan{oneOff(1, now.Add(time.Hour)), oneOff(1, now.Add(2*time.Hour))}, nil},
+		{"below limit", 2, []model.FlyPlan{oneOff(1, now.Add(time.Hour))}, nil},
+		{"limit reached", 2, []model.FlyPlan{oneOff(1, now.Add(time.Hour)), everyDay(1, now.Add(-time.Hour))},
+			PlansLimitErr},
+		{"past one-off plans are not counted", 2,
+			[]model.FlyPlan{oneOff(1, now.Add(-time.Hour)), oneOff(1, now.Add(-2*time.Hour)), oneOff(1, now.Add(time.Hour))}, nil},
+		{"past every day plans are counted", 1, []model.FlyPlan{everyDay(1, now.Add(-47*time.Hour))}, PlansLimitErr},
+		{"other users are not counted", 1, []model.FlyPlan{oneOff(2, now.Add(time.Hour))}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPlaner(t)
+			for _, plan := range tt.existing {
+				if _, err := p.PlanFly(plan); err != nil {
+					t.Fatal(err)
+				}
+			}
+			p.SetMaxUserPlans(tt.max)
+			if _, err := p.PlanFly(oneOff(1, now.Add(3*time.Hour))); !errors.Is(err, tt.err) {
+				t.Errorf("PlanFly() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	p := newTestPlaner(t)
+	p.SetMaxUserPlans(1)
+	future := time.Now().Add(48 * time.Hour)
+	shared, _ := p.PlanFly(model.FlyPlan{FlyDateTime: future, Data: model.FlyData{UserId: 1}})
+	token, err := p.ShareFly(shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := p.ShareFly(shared); again != token {
+		t.Errorf("ShareFly() created a new token %q, want %q", again, token)
+	}
+	//у пользователя 3 уже есть собственный план
+	if _, err := p.PlanFly(model.FlyPlan{FlyDateTime: future, Data: model.FlyData{UserId: 3}}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		token  string
+		userId int64
+		err    error
+	}{
+		{"owner", token, 1, OwnPlanErr},
+		{"unknown token", "0000", 2, PlanNotExistErr},
+		{"empty token", "", 2, PlanNotExistErr},
+		{"subscriber", token, 2, nil},
+		{"already subscribed", token, 2, nil},
+		{"limit reached", token, 3, PlansLimitErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := p.Subscribe(tt.token, model.Subscriber{UserId: tt.userId}); !errors.Is(err, tt.err) {
+				t.Errorf("Subscribe() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+	plan, _ := p.GetFly(shared)
+	if len(plan.Subscribers) != 1 || !plan.IsSubscribed(2) {
+		t.Fatalf("subscribers = %v, want user 2 once", plan.Subscribers)
+	}
+	//подписка учитывается в лимите подписчика
+	if _, err := p.PlanFly(model.FlyPlan{FlyDateTime: future, Data: model.FlyData{UserId: 2}}); !errors.Is(err, PlansLimitErr) {
+		t.Errorf("PlanFly() for subscriber error = %v, want %v", err, PlansLimitErr)
+	}
+	if err := p.Unsubscribe(shared, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Unsubscribe(shared, 2); !errors.Is(err, PlanNotExistErr) {
+		t.Errorf("second Unsubscribe() error = %v, want %v", err, PlanNotExistErr)
+	}
+	if _, err := p.PlanFly(model.FlyPlan{FlyDateTime: future, Data: model.FlyData{UserId: 2}}); err != nil {
+		t.Errorf("PlanFly() after Unsubscribe() error = %v", err)
+	}
+}
+
+//changeListener как бот читает план из планировщика во время оповещения
+type changeListener struct {
+	planer   *Planer
+	canceled []uint64
+	updated  []model.FlyPlan
+}
+
+func (l *changeListener) PlanCanceled(ctx context.Context, plan model.FlyPlan) {
+	if _, err := l.planer.GetFly(plan.FlyId); !errors.Is(err, PlanNotExistErr) {
+		panic("canceled plan is still stored")
+	}
+	l.canceled = append(l.canceled, plan.FlyId)
+}
+
+func (l *changeListener) PlanUpdated(ctx context.Context, plan model.FlyPlan) {
+	stored, _ := l.planer.GetFly(plan.FlyId)
+	l.updated = append(l.updated, stored)
+}
+
+func TestChangeListener(t *testing.T) {
+	p := newTestPlaner(t)
+	listener := &changeListener{planer: p}
+	p.SetChangeListener(listener)
+	future := time.Now().Add(48 * time.Hour)
+	flyId, _ := p.PlanFly(model.FlyPlan{FlyDateTime: future, Data: model.FlyData{UserId: 1}})
+	token, _ := p.ShareFly(flyId)
+	if _, err := p.Subscribe(token, model.Subscriber{UserId: 2}); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := p.UpdateFly(context.Background(), model.FlyPlan{FlyId: flyId, FlyDateTime: future.Add(time.Hour),
+			Data: model.FlyData{UserId: 1}}); err != nil {
+			t.Error(err)
+		}
+		if err := p.UpdateFly(context.Background(), model.FlyPlan{FlyId: 42}); !errors.Is(err, PlanNotExistErr) {
+			t.Errorf("UpdateFly() of missing plan error = %v", err)
+		}
+		if err := p.CancelFly(context.Background(), flyId); err != nil {
+			t.Error(err)
+		}
+		if err := p.CancelFly(context.Background(), flyId); !errors.Is(err, PlanNotExistErr) {
+			t.Errorf("second CancelFly() error = %v", err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("listener called under the planner lock")
+	}
+	if len(listener.updated) != 1 || !listener.updated[0].FlyDateTime.Equal(future.Add(time.Hour)) ||
+		!listener.updated[0].IsSubscribed(2) {
+		t.Errorf("updated = %+v, want the stored plan with its subscriber", listener.updated)
+	}
+	if !reflect.DeepEqual(listener.canceled, []uint64{flyId}) {
+		t.Errorf("canceled = %v, want [%d]", listener.canceled, flyId)
+	}
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+
+package flyPlanner
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+//LockStore монопольная блокировка файла планов на время работы процесса. Бот держит планы в памяти и
+//перезаписывает файл при остановке, поэтому изменять файл, пока бот запущен, нельзя. Блокировка снимается
+//при завершении процесса, в том числе аварийном
+func (p *Planer) LockStore() error {
+	file, err := os.OpenFile(p.filepath+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return StoreLockedErr
+		}
+		return err
+	}
+	p.lockFile = file
+	return nil
+}
@@ -0,0 +1,37 @@
+//go:build !windows
+
+package flyPlanner
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestLockStore(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "plans.json")
+	bot := NewPlaner(file)
+	if err := bot.LockStore(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		file string
+		err  error
+	}{
+		{"same file", file, StoreLockedErr},
+		{"other file", filepath.Join(t.TempDir(), "plans.json"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := NewPlaner(tt.file).LockStore(); !errors.Is(err, tt.err) {
+				t.Errorf("LockStore() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+	//блокировка снимается при закрытии файла, как при завершении процесса
+	bot.lockFile.Close()
+	if err := NewPlaner(file).LockStore(); err != nil {
+		t.Errorf("LockStore() after release error = %v", err)
+	}
+}
@@ -0,0 +1,6 @@
+package flyPlanner
+
+//LockStore на Windows блокировка файла планов не выполняется
+func (p *Planer) LockStore() error {
+	return nil
+}
@@ -0,0 +1,40 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+type checkResponse struct {
+	Name      string `json:"name"`
+	OK        bool   `json:"ok"`
+	LatencyMs int64  `json:"latencyMs"`
+	Error     string `json:"error,omitempty"`
+}
+
+type response struct {
+	Status string          `json:"status"`
+	Checks []checkResponse `json:"checks"`
+}
+
+//Handler http обработчик проверок: 200 и status "ok", если все проверки успешны, иначе 503 и "fail"
+func Handler(checks []Check, timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := response{Status: "ok", Checks: make([]checkResponse, 0, len(checks))}
+		code := http.StatusOK
+		for _, result := range Run(r.Context(), checks, timeout) {
+			check := checkResponse{Name: result.Name, OK: result.OK(), LatencyMs: result.Latency.Milliseconds()}
+			if !result.OK() {
+				check.Error = result.Err.Error()
+				resp.Status = "fail"
+				code = http.StatusServiceUnavailable
+			}
+			resp.Checks = append(resp.Checks, check)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(resp)
+	})
+}
@@ -0,0 +1,48 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandler(t *testing.T) {
+	ok := Check{Name: "telegram", Probe: func(ctx context.Context) error { return nil }}
+	failed := Check{Name: "zones", Probe: func(ctx context.Context) error { return errors.New("connection refused") }}
+	tests := []struct {
+		name   string
+		checks []Check
+		code   int
+		status string
+	}{
+		{"no checks", nil, http.StatusOK, "ok"},
+		{"all ok", []Check{ok}, http.StatusOK, "ok"},
+		{"one failed", []Check{ok, failed}, http.StatusServiceUnavailable, "fail"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			Handler(tt.checks, time.Second).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if w.Code != tt.code || w.Header().Get("Cache-Control") != "no-store" {
+				t.Errorf("code %d, headers %v", w.Code, w.Header())
+			}
+			resp := response{}
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Status != tt.status || len(resp.Checks) != len(tt.checks) {
+				t.Fatalf("response %+v", resp)
+			}
+			for i, check := range resp.Checks {
+				failed := check.Name == "zones"
+				if check.Name != tt.checks[i].Name || check.OK == failed || (check.Error != "") != failed {
+					t.Errorf("check %d = %+v", i, check)
+				}
+			}
+		})
+	}
+}
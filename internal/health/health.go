@@ -0,0 +1,99 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"github.com/japersik/safe-flight-bot/internal/flyDataClient"
+	"github.com/japersik/safe-flight-bot/internal/mapRenderer"
+	"github.com/japersik/safe-flight-bot/model"
+	"sync"
+	"time"
+)
+
+//probeCoordinate точка для пробных запросов к внешним сервисам (Москва)
+var probeCoordinate = model.Coordinate{Lat: 55.7558, Lng: 37.6173}
+
+//Check проверка доступности внешнего сервиса
+type Check struct {
+	Name  string
+	Probe func(ctx context.Context) error
+}
+
+//Result ...
+type Result struct {
+	Name    string
+	Latency time.Duration
+	Err     error
+}
+
+//OK ...
+func (r Result) OK() bool {
+	return r.Err == nil
+}
+
+//ErrTimeout проверка не завершилась за отведенное время
+var ErrTimeout = errors.New("timeout")
+
+//Run выполняет проверки параллельно. Результаты возвращаются в порядке checks. Незавершенные за timeout
+//проверки считаются неуспешными, их ctx отменяется
+func Run(ctx context.Context, checks []Check, timeout time.Duration) []Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	results := make([]Result, len(checks))
+	for i, check := range checks {
+		results[i] = Result{Name: check.Name, Latency: timeout, Err: ErrTimeout}
+	}
+	mutex := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	wg.Add(len(checks))
+	for i, check := range checks {
+		go func(i int, check Check) {
+			defer wg.Done()
+			start := time.Now()
+			err := check.Probe(ctx)
+			mutex.Lock()
+			defer mutex.Unlock()
+			//проверка, завершившаяся из-за отмены ctx, не успела за timeout
+			if results != nil && ctx.Err() == nil {
+				results[i] = Result{Name: check.Name, Latency: time.Since(start), Err: err}
+			}
+		}(i, check)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	final := results
+	//поздние результаты больше не записываются
+	results = nil
+	return final
+}
+
+//Upstreams проверки сервисов, от которых зависят ответы бота. renderer может быть nil
+func Upstreams(client flyDataClient.Client, renderer *mapRenderer.Renderer) []Check {
+	checks := []Check{
+		{Name: "zones", Probe: func(ctx context.Context) error {
+			_, err := client.CheckConditions(ctx, probeCoordinate, model.DefaultRadius(), model.DefaultAltitude())
+			return err
+		}},
+		{Name: "weather", Probe: func(ctx context.Context) error {
+			_, err := client.GetCurrentWeather(ctx, probeCoordinate)
+			return err
+		}},
+		{Name: "geocoder", Probe: func(ctx context.Context) error {
+			_, err := client.GetLocalityFlyInfo(ctx, probeCoordinate)
+			return err
+		}},
+	}
+	if renderer != nil {
+		checks = append(checks, Check{Name: "tiles", Probe: renderer.Check})
+	}
+	return checks
+}
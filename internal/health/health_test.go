@@ -0,0 +1,54 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	unavailable := errors.New("503 Service Unavailable")
+	canceled := make(chan error, 1)
+	checks := []Check{
+		{Name: "slow", Probe: func(ctx context.Context) error {
+			<-ctx.Done()
+			canceled <- ctx.Err()
+			return nil
+		}},
+		{Name: "zones", Probe: func(ctx context.Context) error { return nil }},
+		{Name: "weather", Probe: func(ctx context.Context) error { return unavailable }},
+	}
+	start := time.Now()
+	results := Run(context.Background(), checks, 50*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Run took %v", elapsed)
+	}
+	if len(results) != len(checks) {
+		t.Fatalf("%d results", len(results))
+	}
+	for i, want := range []error{ErrTimeout, nil, unavailable} {
+		if results[i].Name != checks[i].Name || results[i].Err != want || results[i].OK() != (want == nil) {
+			t.Errorf("result %d = %+v, want %s with error %v", i, results[i], checks[i].Name, want)
+		}
+	}
+	//запоздавшая проверка получает отмену и не меняет возвращенный результат
+	select {
+	case err := <-canceled:
+		if err == nil {
+			t.Error("slow probe context not canceled")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("slow probe not canceled")
+	}
+	time.Sleep(10 * time.Millisecond)
+	if results[0].Err != ErrTimeout {
+		t.Errorf("late result written: %+v", results[0])
+	}
+}
+
+func TestRunWithoutChecks(t *testing.T) {
+	if results := Run(context.Background(), nil, time.Second); len(results) != 0 {
+		t.Errorf("results = %v", results)
+	}
+}
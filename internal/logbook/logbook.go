@@ -0,0 +1,227 @@
+package logbook
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/japersik/safe-flight-bot/logger"
+	"github.com/japersik/safe-flight-bot/model"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	EntryNotExistErr = errors.New("logbook entry not exist")
+)
+
+type Logbook interface {
+	AddEntry(entry model.LogEntry) (entryId uint64, err error)
+	GetEntry(entryId uint64) (model.LogEntry, error)
+	UpdateEntry(entry model.LogEntry) error
+	DeleteEntry(entryId uint64) error
+	//UserEntries returns confirmed user flights sorted by start time
+	UserEntries(userId int64) []model.LogEntry
+}
+
+//MonthTotal итог по полётам за месяц
+type MonthTotal struct {
+	Month    time.Time
+	Flights  int
+	Duration time.Duration
+}
+
+//MonthTotals группировка записей журнала по месяцам, от новых к старым
+func MonthTotals(entries []model.LogEntry) []MonthTotal {
+	totals := map[time.Time]*MonthTotal{}
+	for _, entry := range entries {
+		month := time.Date(entry.Start.Year(), entry.Start.Month(), 1, 0, 0, 0, 0, entry.Start.Location())
+		total, ok := totals[month]
+		if !ok {
+			total = &MonthTotal{Month: month}
+			totals[month] = total
+		}
+		total.Flights++
+		total.Duration += entry.Duration()
+	}
+	result := make([]MonthTotal, 0, len(totals))
+	for _, total := range totals {
+		result = append(result, *total)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Month.After(result[j].Month) })
+	return result
+}
+
+//WriteCSV экспорт журнала полётов
+func WriteCSV(w io.Writer, entries []model.LogEntry) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write([]string{"id", "plan", "start", "end", "duration_min", "lat", "lng", "drone", "notes",
+		"temperature", "wind_speed", "precip_prob", "active_zones", "boundary_zone"})
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		record := []string{
+			fmt.Sprint(entry.EntryId),
+			fmt.Sprint(entry.FlyId),
+			entry.Start.Format(time.RFC3339),
+			entry.End.Format(time.RFC3339),
+			fmt.Sprintf("%.0f", entry.Duration().Minutes()),
+			fmt.Sprintf("%f", entry.Coordinate.Lat),
+			fmt.Sprintf("%f", entry.Coordinate.Lng),
+			csvText(entry.Drone),
+			csvText(entry.Notes),
+			"", "", "", "", "",
+		}
+		if c := entry.Conditions; c != nil {
+			if c.Weather != nil {
+				record[9] = fmt.Sprintf("%.1f", c.Weather.Temperature)
+				record[10] = fmt.Sprintf("%.1f", c.Weather.WindSpeed)
+				record[11] = fmt.Sprintf("%.0f", c.Weather.PrecipProb*100)
+			}
+			record[12] = csvText(strings.Join(c.ActiveZones, " "))
+			record[13] = fmt.Sprint(c.NearBoundaryZone)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+//csvText текст пользователя в ячейке CSV. Ячейки, начинающиеся с символов формул, экранируются апострофом,
+//чтобы табличный редактор не выполнил их при открытии файла
+func csvText(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+type logbookData struct {
+	MaxEntryId   uint64                     `json:"maxEntryId"`
+	Entries      map[uint64]*model.LogEntry `json:"entries,omitempty"`
+	entriesMutex *sync.Mutex
+}
+
+//FileLogbook хранение журнала полётов в json файле
+type FileLogbook struct {
+	data     *logbookData
+	filepath string
+}
+
+//NewFileLogbook ...
+func NewFileLogbook(filepath string) *FileLogbook {
+	return &FileLogbook{
+		data: &logbookData{
+			MaxEntryId:   0,
+			Entries:      map[uint64]*model.LogEntry{},
+			entriesMutex: &sync.Mutex{},
+		},
+		filepath: filepath,
+	}
+}
+
+//AddEntry ...
+func (l *FileLogbook) AddEntry(entry model.LogEntry) (uint64, error) {
+	l.data.entriesMutex.Lock()
+	defer l.data.entriesMutex.Unlock()
+	l.data.MaxEntryId++
+	entry.EntryId = l.data.MaxEntryId
+	l.data.Entries[entry.EntryId] = &entry
+	logger.Info("logbook entry created", logger.Any("entryId", entry.EntryId), logger.FlyId(entry.FlyId))
+	return entry.EntryId, nil
+}
+
+//GetEntry ...
+func (l *FileLogbook) GetEntry(entryId uint64) (model.LogEntry, error) {
+	l.data.entriesMutex.Lock()
+	defer l.data.entriesMutex.Unlock()
+	if entry, ok := l.data.Entries[entryId]; ok {
+		return *entry, nil
+	}
+	return model.LogEntry{}, EntryNotExistErr
+}
+
+//UpdateEntry ...
+func (l *FileLogbook) UpdateEntry(entry model.LogEntry) error {
+	l.data.entriesMutex.Lock()
+	defer l.data.entriesMutex.Unlock()
+	if _, ok := l.data.Entries[entry.EntryId]; !ok {
+		return EntryNotExistErr
+	}
+	l.data.Entries[entry.EntryId] = &entry
+	return nil
+}
+
+//DeleteEntry ...
+func (l *FileLogbook) DeleteEntry(entryId uint64) error {
+	l.data.entriesMutex.Lock()
+	defer l.data.entriesMutex.Unlock()
+	if _, ok := l.data.Entries[entryId]; !ok {
+		return EntryNotExistErr
+	}
+	delete(l.data.Entries, entryId)
+	return nil
+}
+
+//UserEntries ...
+func (l *FileLogbook) UserEntries(userId int64) []model.LogEntry {
+	l.data.entriesMutex.Lock()
+	defer l.data.entriesMutex.Unlock()
+	entries := make([]model.LogEntry, 0)
+	for _, entry := range l.data.Entries {
+		if entry.UserId == userId && entry.Confirmed {
+			entries = append(entries, *entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Start.Before(entries[j].Start) })
+	return entries
+}
+
+//Init ...
+func (l *FileLogbook) Init() error {
+	data := &logbookData{
+		MaxEntryId:   0,
+		Entries:      map[uint64]*model.LogEntry{},
+		entriesMutex: &sync.Mutex{},
+	}
+	file, err := os.OpenFile(l.filepath, os.O_RDONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	err = decoder.Decode(data)
+	if err != nil {
+		if err == io.EOF {
+			logger.Info("creating new logbook file", logger.String("file", l.filepath))
+			return nil
+		}
+		return err
+	}
+	if data.Entries == nil {
+		data.Entries = map[uint64]*model.LogEntry{}
+	}
+	l.data = data
+	logger.Info("logbook file loaded", logger.String("file", l.filepath), logger.Int("entries", len(data.Entries)))
+	return nil
+}
+
+//Save ...
+func (l *FileLogbook) Save() error {
+	l.data.entriesMutex.Lock()
+	defer l.data.entriesMutex.Unlock()
+	file, err := os.OpenFile(l.filepath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	encoder := json.NewEncoder(file)
+	return encoder.Encode(l.data)
+}
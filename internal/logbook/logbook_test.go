@@ -0,0 +1,114 @@
+package logbook
+
+import (
+	"bytes"
+	"encoding/csv"
+	"github.com/japersik/safe-flight-bot/model"
+	"testing"
+	"time"
+)
+
+func TestCSVText(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"", ""},
+		{"Mavic 3", "Mavic 3"},
+		{"=HYPERLINK(\"http://example.com\")", "'=HYPERLINK(\"http://example.com\")"},
+		{"+7 900 000-00-00", "'+7 900 000-00-00"},
+		{"-2+3", "'-2+3"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\tcmd", "'\tcmd"},
+		{"a=b", "a=b"},
+	}
+	for _, tt := range tests {
+		if got := csvText(tt.text); got != tt.want {
+			t.Errorf("csvText(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	start := time.Date(2030, 6, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		entry model.LogEntry
+		want  map[int]string
+	}{
+		{
+			name: "without conditions",
+			entry: model.LogEntry{EntryId: 1, FlyId: 7, Start: start, End: start.Add(45 * time.Minute),
+				Coordinate: model.Coordinate{Lat: 55.7558, Lng: 37.6173}, Drone: "Mavic 3", Notes: "съемка"},
+			want: map[int]string{0: "1", 1: "7", 4: "45", 5: "55.755800", 7: "Mavic 3", 8: "съемка", 11: "", 13: ""},
+		},
+		{
+			name: "conditions with precipitation in percent",
+			entry: model.LogEntry{EntryId: 2, Start: start, End: start.Add(time.Hour), Conditions: &model.FlightConditions{
+				Weather:          &model.WeatherData{Temperature: -3.25, WindSpeed: 4, PrecipProb: 0.35},
+				ActiveZones:      []string{"UUR123", "UUP45"},
+				NearBoundaryZone: true,
+			}},
+			want: map[int]string{9: "-3.2", 10: "4.0", 11: "35", 12: "UUR123 UUP45", 13: "true"},
+		},
+		{
+			name:  "formula in user text",
+			entry: model.LogEntry{EntryId: 3, Start: start, End: start, Drone: "@drone", Notes: "=1+1"},
+			want:  map[int]string{4: "0", 7: "'@drone", 8: "'=1+1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			if err := WriteCSV(buf, []model.LogEntry{tt.entry}); err != nil {
+				t.Fatal(err)
+			}
+			records, err := csv.NewReader(buf).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != 2 || len(records[1]) != len(records[0]) {
+				t.Fatalf("unexpected records %q", records)
+			}
+			for column, want := range tt.want {
+				if got := records[1][column]; got != want {
+					t.Errorf("column %s = %q, want %q", records[0][column], got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMonthTotals(t *testing.T) {
+	entry := func(day time.Time, minutes int) model.LogEntry {
+		return model.LogEntry{Start: day, End: day.Add(time.Duration(minutes) * time.Minute)}
+	}
+	may := time.Date(2030, 5, 31, 23, 0, 0, 0, time.UTC)
+	june := time.Date(2030, 6, 1, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		entries []model.LogEntry
+		want    []MonthTotal
+	}{
+		{"empty", nil, []MonthTotal{}},
+		{"newest month first", []model.LogEntry{entry(may, 30), entry(june, 20), entry(june.AddDate(0, 0, 3), 10)},
+			[]MonthTotal{
+				{Month: time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC), Flights: 2, Duration: 30 * time.Minute},
+				{Month: time.Date(2030, 5, 1, 0, 0, 0, 0, time.UTC), Flights: 1, Duration: 30 * time.Minute},
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MonthTotals(tt.entries)
+			if len(got) != len(tt.want) {
+				t.Fatalf("MonthTotals() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Month.Equal(tt.want[i].Month) || got[i].Flights != tt.want[i].Flights ||
+					got[i].Duration != tt.want[i].Duration {
+					t.Errorf("MonthTotals()[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
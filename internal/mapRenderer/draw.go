@@ -0,0 +1,109 @@
+package mapRenderer
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+const (
+	fillAlpha    = 0.25
+	outlineWidth = 3
+	markerRadius = 7
+)
+
+type point struct {
+	x, y float64
+}
+
+//blend draws semi-transparent color over the pixel
+func blend(img *image.RGBA, x, y int, c color.RGBA, alpha float64) {
+	if !(image.Point{X: x, Y: y}.In(img.Bounds())) {
+		return
+	}
+	old := img.RGBAAt(x, y)
+	mix := func(a, b uint8) uint8 {
+		return uint8(float64(a)*(1-alpha) + float64(b)*alpha)
+	}
+	img.SetRGBA(x, y, color.RGBA{R: mix(old.R, c.R), G: mix(old.G, c.G), B: mix(old.B, c.B), A: 0xff})
+}
+
+//drawPolygon fills the polygon with translucent color (even-odd rule) and draws its outline
+func drawPolygon(img *image.RGBA, polygon []point, c color.RGBA) {
+	if len(polygon) < 3 {
+		return
+	}
+	bounds := img.Bounds()
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for _, p := range polygon {
+		minY, maxY = math.Min(minY, p.y), math.Max(maxY, p.y)
+	}
+	fromY := int(math.Max(math.Floor(minY), float64(bounds.Min.Y)))
+	toY := int(math.Min(math.Ceil(maxY), float64(bounds.Max.Y-1)))
+	for y := fromY; y <= toY; y++ {
+		scanY := float64(y) + 0.5
+		xs := make([]float64, 0, 4)
+		for i := range polygon {
+			a, b := polygon[i], polygon[(i+1)%len(polygon)]
+			if (a.y <= scanY) != (b.y <= scanY) {
+				xs = append(xs, a.x+(scanY-a.y)*(b.x-a.x)/(b.y-a.y))
+			}
+		}
+		sort.Float64s(xs)
+		for i := 0; i+1 < len(xs); i += 2 {
+			fromX := int(math.Max(math.Round(xs[i]), float64(bounds.Min.X)))
+			toX := int(math.Min(math.Round(xs[i+1]), float64(bounds.Max.X)))
+			for x := fromX; x < toX; x++ {
+				blend(img, x, y, c, fillAlpha)
+			}
+		}
+	}
+	for i := range polygon {
+		drawLine(img, polygon[i], polygon[(i+1)%len(polygon)], c)
+	}
+}
+
+//drawLine draws the thick segment
+func drawLine(img *image.RGBA, a, b point, c color.RGBA) {
+	bounds := img.Bounds()
+	if (a.x < 0 && b.x < 0) || (a.y < 0 && b.y < 0) ||
+		(a.x >= float64(bounds.Max.X) && b.x >= float64(bounds.Max.X)) ||
+		(a.y >= float64(bounds.Max.Y) && b.y >= float64(bounds.Max.Y)) {
+		return
+	}
+	length := math.Hypot(b.x-a.x, b.y-a.y)
+	steps := int(math.Ceil(length*2)) + 1
+	half := outlineWidth / 2
+	for i := 0; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		x := int(math.Round(a.x + (b.x-a.x)*t))
+		y := int(math.Round(a.y + (b.y-a.y)*t))
+		for dx := -half; dx <= half; dx++ {
+			for dy := -half; dy <= half; dy++ {
+				if image.Pt(x+dx, y+dy).In(bounds) {
+					img.SetRGBA(x+dx, y+dy, c)
+				}
+			}
+		}
+	}
+}
+
+//drawMarker draws the pilot position as a filled circle with white border
+func drawMarker(img *image.RGBA, center point, c color.RGBA) {
+	for dx := -markerRadius - 2; dx <= markerRadius+2; dx++ {
+		for dy := -markerRadius - 2; dy <= markerRadius+2; dy++ {
+			distance := math.Hypot(float64(dx), float64(dy))
+			x, y := int(center.x)+dx, int(center.y)+dy
+			if !image.Pt(x, y).In(img.Bounds()) {
+				continue
+			}
+			switch {
+			case distance <= markerRadius:
+				img.SetRGBA(x, y, c)
+			case distance <= markerRadius+2:
+				img.SetRGBA(x, y, color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff})
+			}
+		}
+	}
+}
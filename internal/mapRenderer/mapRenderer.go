@@ -0,0 +1,201 @@
+package mapRenderer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/japersik/safe-flight-bot/logger"
+	"github.com/japersik/safe-flight-bot/model"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
+	"image/png"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	//DefaultTileServer OpenStreetMap tile server url template
+	DefaultTileServer = "https://tile.openstreetmap.org/{z}/{x}/{y}.png"
+	defaultTimeout    = time.Second * 5
+	//Attribution must be shown next to the image rendered with OSM tiles
+	Attribution = "© OpenStreetMap contributors"
+
+	tileSize  = 256
+	maxZoom   = 17
+	minZoom   = 5
+	userAgent = "safe-flight-bot (https://github.com/japersik/safe-flight-bot)"
+)
+
+var (
+	areaColor         = color.RGBA{R: 0x1e, G: 0x6f, B: 0xd9, A: 0xff}
+	activeZoneColor   = color.RGBA{R: 0xd9, G: 0x1e, B: 0x1e, A: 0xff}
+	inactiveZoneColor = color.RGBA{R: 0xf0, G: 0xa0, B: 0x00, A: 0xff}
+	pilotColor        = color.RGBA{R: 0x10, G: 0x10, B: 0x10, A: 0xff}
+	noTileColor       = color.RGBA{R: 0xe0, G: 0xe0, B: 0xe0, A: 0xff}
+)
+
+//Renderer draws the flight area and restriction zones over map tiles
+type Renderer struct {
+	tileServer string
+	webClient  http.Client
+	width      int
+	height     int
+}
+
+//NewRenderer creates renderer with tile server url template like "https://tile.openstreetmap.org/{z}/{x}/{y}.png".
+//Empty template and zero timeout mean defaults
+func NewRenderer(tileServer string, timeout time.Duration) *Renderer {
+	if tileServer == "" {
+		tileServer = DefaultTileServer
+	}
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &Renderer{
+		tileServer: tileServer,
+		webClient: http.Client{
+			Timeout: timeout,
+		},
+		width:  800,
+		height: 600,
+	}
+}
+
+//SetTransport sets transport of tile requests, e.g. instrumented with metrics
+func (r *Renderer) SetTransport(transport http.RoundTripper) {
+	r.webClient.Transport = transport
+}
+
+//Render draws the circle flight area, the active and inactive zones and the pilot position. Returns PNG image
+func (r Renderer) Render(ctx context.Context, pilot model.Coordinate, radius int, active, inactive []model.Zone) ([]byte, error) {
+	area := model.AreaPolygon(pilot, radius, 36)
+	//at least 1.5 km around the pilot is shown
+	zoom := r.fitZoom(model.AreaPolygon(pilot, int(math.Max(float64(radius)*2, 1500)), 8))
+	cx, cy := project(pilot, zoom)
+	originX, originY := cx-float64(r.width)/2, cy-float64(r.height)/2
+
+	img := image.NewRGBA(image.Rect(0, 0, r.width, r.height))
+	if err := r.drawTiles(ctx, img, zoom, originX, originY); err != nil {
+		return nil, err
+	}
+	toPixels := func(polygon []model.Coordinate) []point {
+		points := make([]point, 0, len(polygon))
+		for _, coordinate := range polygon {
+			x, y := project(coordinate, zoom)
+			points = append(points, point{x - originX, y - originY})
+		}
+		return points
+	}
+	for _, zone := range inactive {
+		for _, polygon := range zone.Geometry {
+			drawPolygon(img, toPixels(polygon), inactiveZoneColor)
+		}
+	}
+	for _, zone := range active {
+		for _, polygon := range zone.Geometry {
+			drawPolygon(img, toPixels(polygon), activeZoneColor)
+		}
+	}
+	drawPolygon(img, toPixels(area), areaColor)
+	drawMarker(img, point{cx - originX, cy - originY}, pilotColor)
+
+	buf := bytes.Buffer{}
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+//fitZoom the largest zoom at which all the points fit into the image
+func (r Renderer) fitZoom(points []model.Coordinate) int {
+	for zoom := maxZoom; zoom > minZoom; zoom-- {
+		minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+		for _, p := range points {
+			x, y := project(p, zoom)
+			minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+			minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+		}
+		if maxX-minX <= float64(r.width) && maxY-minY <= float64(r.height) {
+			return zoom
+		}
+	}
+	return minZoom
+}
+
+func (r Renderer) drawTiles(ctx context.Context, img *image.RGBA, zoom int, originX, originY float64) error {
+	draw.Draw(img, img.Bounds(), image.NewUniform(noTileColor), image.Point{}, draw.Src)
+	firstX, firstY := int(math.Floor(originX/tileSize)), int(math.Floor(originY/tileSize))
+	lastX, lastY := int(math.Floor((originX+float64(r.width))/tileSize)), int(math.Floor((originY+float64(r.height))/tileSize))
+	tilesCount := 1 << zoom
+
+	wg := sync.WaitGroup{}
+	mutex := sync.Mutex{}
+	loaded := 0
+	for x := firstX; x <= lastX; x++ {
+		for y := firstY; y <= lastY; y++ {
+			if y < 0 || y >= tilesCount {
+				continue
+			}
+			wg.Add(1)
+			go func(x, y int) {
+				defer wg.Done()
+				tile, err := r.getTile(ctx, zoom, (x%tilesCount+tilesCount)%tilesCount, y)
+				if err != nil {
+					logger.FromContext(ctx).Warn("map tile loading error", logger.Err(err))
+					return
+				}
+				dst := image.Rect(x*tileSize-int(originX), y*tileSize-int(originY), (x+1)*tileSize-int(originX), (y+1)*tileSize-int(originY))
+				mutex.Lock()
+				defer mutex.Unlock()
+				draw.Draw(img, dst, tile, tile.Bounds().Min, draw.Src)
+				loaded++
+			}(x, y)
+		}
+	}
+	wg.Wait()
+	if loaded == 0 {
+		return errors.New("no map tiles loaded")
+	}
+	return nil
+}
+
+//Check requests one tile to verify the tile server is available
+func (r Renderer) Check(ctx context.Context) error {
+	_, err := r.getTile(ctx, 0, 0, 0)
+	return err
+}
+
+func (r Renderer) getTile(ctx context.Context, zoom, x, y int) (image.Image, error) {
+	url := strings.NewReplacer("{z}", strconv.Itoa(zoom), "{x}", strconv.Itoa(x), "{y}", strconv.Itoa(y)).Replace(r.tileServer)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	response, err := r.webClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tile %d/%d/%d: unexpected status %s", zoom, x, y, response.Status)
+	}
+	tile, _, err := image.Decode(response.Body)
+	return tile, err
+}
+
+//project converts coordinate to global pixel coordinates of Web Mercator projection
+func project(coordinate model.Coordinate, zoom int) (float64, float64) {
+	scale := float64(tileSize) * math.Exp2(float64(zoom))
+	lat := coordinate.Lat * math.Pi / 180
+	x := (coordinate.Lng + 180) / 360 * scale
+	y := (1 - math.Log(math.Tan(lat)+1/math.Cos(lat))/math.Pi) / 2 * scale
+	return x, y
+}
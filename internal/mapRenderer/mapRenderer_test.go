@@ -0,0 +1,173 @@
+package mapRenderer
+
+import (
+	"bytes"
+	"context"
+	"github.com/japersik/safe-flight-bot/logger"
+	"github.com/japersik/safe-flight-bot/model"
+	"go.uber.org/zap"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	logger.NewInstance(logger.NewZapLogger(zap.NewNop()))
+	os.Exit(m.Run())
+}
+
+func TestProject(t *testing.T) {
+	tests := []struct {
+		coordinate model.Coordinate
+		zoom       int
+		x, y       float64
+	}{
+		{model.Coordinate{Lat: 0, Lng: 0}, 0, 128, 128},
+		{model.Coordinate{Lat: 0, Lng: -180}, 0, 0, 128},
+		{model.Coordinate{Lat: 0, Lng: 90}, 1, 384, 256},
+		//tile 0/0 of zoom 0 ends at the Web Mercator latitude limit
+		{model.Coordinate{Lat: 85.0511287798, Lng: 0}, 0, 128, 0},
+	}
+	for _, tt := range tests {
+		x, y := project(tt.coordinate, tt.zoom)
+		if math.Abs(x-tt.x) > 1e-6 || math.Abs(y-tt.y) > 1e-6 {
+			t.Errorf("project(%v, %d) = %.6f, %.6f, want %v, %v", tt.coordinate, tt.zoom, x, y, tt.x, tt.y)
+		}
+	}
+}
+
+func TestFitZoom(t *testing.T) {
+	r := NewRenderer("", 0)
+	center := model.Coordinate{Lat: 55.75, Lng: 37.61}
+	previous := maxZoom + 1
+	for _, radius := range []int{10, 750, 5000, 50000, 2000000} {
+		zoom := r.fitZoom(model.AreaPolygon(center, radius, 8))
+		if zoom > previous || zoom < minZoom || zoom > maxZoom {
+			t.Errorf("radius %d: zoom %d after %d", radius, zoom, previous)
+		}
+		previous = zoom
+	}
+	if zoom := r.fitZoom(model.AreaPolygon(center, 10, 8)); zoom != maxZoom {
+		t.Errorf("small area zoom = %d, want %d", zoom, maxZoom)
+	}
+	if zoom := r.fitZoom(model.AreaPolygon(center, 2000000, 8)); zoom != minZoom {
+		t.Errorf("huge area zoom = %d, want %d", zoom, minZoom)
+	}
+}
+
+func TestDrawPolygon(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 40))
+	white := color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 40; y++ {
+			img.SetRGBA(x, y, white)
+		}
+	}
+	drawPolygon(img, []point{{10, 10}, {30, 10}, {30, 30}, {10, 30}}, activeZoneColor)
+	if got := img.RGBAAt(10, 20); got != activeZoneColor {
+		t.Errorf("outline pixel = %v, want %v", got, activeZoneColor)
+	}
+	inside := img.RGBAAt(20, 20)
+	if inside == white || inside == activeZoneColor || inside.R <= inside.G || inside.G != inside.B {
+		t.Errorf("fill pixel = %v, want translucent red over white", inside)
+	}
+	if got := img.RGBAAt(35, 35); got != white {
+		t.Errorf("outside pixel = %v, want untouched", got)
+	}
+	//polygons partly outside the image are clipped, degenerate ones are skipped
+	drawPolygon(img, []point{{-100, -100}, {100, -100}, {100, 100}}, areaColor)
+	drawPolygon(img, []point{{0, 0}, {39, 39}}, pilotColor)
+}
+
+//tileServer serves white tiles and records request paths
+func tileServer(t *testing.T, status int) (*httptest.Server, *[]string) {
+	t.Helper()
+	tile := image.NewRGBA(image.Rect(0, 0, tileSize, tileSize))
+	for i := range tile.Pix {
+		tile.Pix[i] = 0xff
+	}
+	buf := bytes.Buffer{}
+	png.Encode(&buf, tile)
+	mutex := sync.Mutex{}
+	paths := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		paths = append(paths, r.URL.Path)
+		mutex.Unlock()
+		if r.Header.Get("User-Agent") != userAgent {
+			t.Errorf("User-Agent = %q", r.Header.Get("User-Agent"))
+		}
+		if status != http.StatusOK {
+			http.Error(w, "tile server is down", status)
+			return
+		}
+		w.Write(buf.Bytes())
+	}))
+	t.Cleanup(server.Close)
+	return server, &paths
+}
+
+func TestRender(t *testing.T) {
+	server, paths := tileServer(t, http.StatusOK)
+	r := NewRenderer(server.URL+"/{z}/{x}/{y}.png", 0)
+	pilot := model.Coordinate{Lat: 55.75, Lng: 37.61}
+	zone := model.Zone{Code: "UUR1", Geometry: [][]model.Coordinate{{
+		{Lat: 55.7485, Lng: 37.614}, {Lat: 55.7485, Lng: 37.617}, {Lat: 55.7515, Lng: 37.617}, {Lat: 55.7515, Lng: 37.614},
+	}}}
+	data, err := r.Render(context.Background(), pilot, 100, []model.Zone{zone}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 800 || img.Bounds().Dy() != 600 {
+		t.Errorf("image size = %v", img.Bounds())
+	}
+	//800x600 image covers 4-5 tiles in each direction
+	if len(*paths) < 12 || len(*paths) > 20 {
+		t.Errorf("tiles requested = %d", len(*paths))
+	}
+	//a small radius is still shown with 1.5km of surroundings
+	zoom := r.fitZoom(model.AreaPolygon(pilot, 1500, 8))
+	if zoom >= maxZoom {
+		t.Errorf("zoom %d is not limited by the minimal view", zoom)
+	}
+	for _, path := range *paths {
+		if !strings.HasPrefix(path, "/"+strconv.Itoa(zoom)+"/") {
+			t.Errorf("tile %s is not of the fitted zoom %d", path, zoom)
+		}
+	}
+	if got := color.RGBAModel.Convert(img.At(400, 300)).(color.RGBA); got != pilotColor {
+		t.Errorf("pilot marker pixel = %v, want %v", got, pilotColor)
+	}
+	x, y := project(model.Coordinate{Lat: 55.75, Lng: 37.6155}, zoom)
+	cx, cy := project(pilot, zoom)
+	zonePixel := color.RGBAModel.Convert(img.At(int(x-cx)+400, int(y-cy)+300)).(color.RGBA)
+	if zonePixel.R <= zonePixel.G || zonePixel.G != zonePixel.B {
+		t.Errorf("active zone pixel = %v, want translucent red over the tile", zonePixel)
+	}
+}
+
+func TestRenderWithoutTiles(t *testing.T) {
+	server, paths := tileServer(t, http.StatusTooManyRequests)
+	r := NewRenderer(server.URL+"/{z}/{x}/{y}.png", 0)
+	if _, err := r.Render(context.Background(), model.Coordinate{Lat: 55.75, Lng: 37.61}, 500, nil, nil); err == nil {
+		t.Error("image without any map tile rendered")
+	}
+	if len(*paths) == 0 {
+		t.Error("no tiles requested")
+	}
+	if err := r.Check(context.Background()); err == nil || !strings.Contains(err.Error(), "429") {
+		t.Errorf("Check() error = %v, want the tile server status", err)
+	}
+}
@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"net/http"
+	"time"
+)
+
+const namespace = "safeflight"
+
+var (
+	updateDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "update_duration_seconds",
+		Help:      "Time spent handling a Telegram update, by update type.",
+		Buckets:   []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"type"})
+	upstreamRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_requests_total",
+		Help:      "Requests to external services, by service and endpoint.",
+	}, []string{"service", "endpoint"})
+	upstreamErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_errors_total",
+		Help:      "Failed requests to external services: transport errors and HTTP status >= 400.",
+	}, []string{"service", "endpoint"})
+	upstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "Latency of requests to external services, by service and endpoint.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2, 3, 5, 10},
+	}, []string{"service", "endpoint"})
+	notifications = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notifications_total",
+		Help:      "Plan notifications, by result: sent or failed.",
+	}, []string{"result"})
+	throttled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "throttled_total",
+		Help:      "Requests rejected by per-user rate limits, by limit: updates, checks or api_checks.",
+	}, []string{"limit"})
+)
+
+func init() {
+	notifications.WithLabelValues("sent")
+	notifications.WithLabelValues("failed")
+}
+
+//ObserveUpdate start - время получения обновления
+func ObserveUpdate(updateType string, start time.Time) {
+	updateDuration.WithLabelValues(updateType).Observe(time.Since(start).Seconds())
+}
+
+//NotificationSent учет результата отправки уведомления по плану
+func NotificationSent(err error) {
+	if err != nil {
+		notifications.WithLabelValues("failed").Inc()
+	} else {
+		notifications.WithLabelValues("sent").Inc()
+	}
+}
+
+//Throttled учет запроса, отклоненного лимитом limit
+func Throttled(limit string) {
+	throttled.WithLabelValues(limit).Inc()
+}
+
+//RegisterGauge значение вычисляется при каждом чтении метрик
+func RegisterGauge(name, help string, value func() float64) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{Namespace: namespace, Name: name, Help: help}, value)
+}
+
+//EndpointFunc метка endpoint для запроса. Должна иметь ограниченное число значений
+type EndpointFunc func(r *http.Request) string
+
+//PathEndpoint путь запроса в качестве метки, для сервисов с фиксированным набором адресов
+func PathEndpoint(r *http.Request) string {
+	return r.URL.Path
+}
+
+//InstrumentTransport учет запросов к внешнему сервису. next == nil - http.DefaultTransport
+func InstrumentTransport(service string, endpoint EndpointFunc, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{service: service, endpoint: endpoint, next: next}
+}
+
+type transport struct {
+	service  string
+	endpoint EndpointFunc
+	next     http.RoundTripper
+}
+
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	labels := prometheus.Labels{"service": t.service, "endpoint": t.endpoint(r)}
+	start := time.Now()
+	response, err := t.next.RoundTrip(r)
+	upstreamDuration.With(labels).Observe(time.Since(start).Seconds())
+	upstreamRequests.With(labels).Inc()
+	if err != nil || response.StatusCode >= http.StatusBadRequest {
+		upstreamErrors.With(labels).Inc()
+	}
+	return response, err
+}
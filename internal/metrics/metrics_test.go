@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"github.com/japersik/safe-flight-bot/internal/health"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestInstrumentTransport(t *testing.T) {
+	statuses := map[string]int{"/ok": http.StatusOK, "/missing": http.StatusNotFound, "/down": http.StatusBadGateway}
+	next := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		status, ok := statuses[r.URL.Path]
+		if !ok {
+			return nil, errors.New("connection refused")
+		}
+		return &http.Response{StatusCode: status, Body: http.NoBody, Request: r}, nil
+	})
+	client := http.Client{Transport: InstrumentTransport("test", PathEndpoint, next)}
+	requests := map[string]int{"/ok": 3, "/missing": 1, "/down": 2, "/refused": 1}
+	//счетчики глобальные, поэтому проверяется их прирост
+	countedRequests, countedErrors := map[string]float64{}, map[string]float64{}
+	for path := range requests {
+		countedRequests[path] = testutil.ToFloat64(upstreamRequests.WithLabelValues("test", path))
+		countedErrors[path] = testutil.ToFloat64(upstreamErrors.WithLabelValues("test", path))
+	}
+	for path, count := range requests {
+		for i := 0; i < count; i++ {
+			if response, err := client.Get("http://upstream.test" + path); err == nil {
+				response.Body.Close()
+			}
+		}
+	}
+	for path, count := range requests {
+		if got := testutil.ToFloat64(upstreamRequests.WithLabelValues("test", path)) - countedRequests[path]; got != float64(count) {
+			t.Errorf("%s: %v requests, want %d", path, got, count)
+		}
+		wantErrors := count
+		if path == "/ok" {
+			wantErrors = 0
+		}
+		if got := testutil.ToFloat64(upstreamErrors.WithLabelValues("test", path)) - countedErrors[path]; got != float64(wantErrors) {
+			t.Errorf("%s: %v errors, want %d", path, got, wantErrors)
+		}
+	}
+}
+
+func TestNotificationSent(t *testing.T) {
+	sent := testutil.ToFloat64(notifications.WithLabelValues("sent"))
+	failed := testutil.ToFloat64(notifications.WithLabelValues("failed"))
+	NotificationSent(nil)
+	NotificationSent(nil)
+	NotificationSent(errors.New("Forbidden: bot was blocked by the user"))
+	if got := testutil.ToFloat64(notifications.WithLabelValues("sent")) - sent; got != 2 {
+		t.Errorf("sent +%v, want +2", got)
+	}
+	if got := testutil.ToFloat64(notifications.WithLabelValues("failed")) - failed; got != 1 {
+		t.Errorf("failed +%v, want +1", got)
+	}
+}
+
+func TestServerHandler(t *testing.T) {
+	notReady := []health.Check{{Name: "telegram", Probe: func(ctx context.Context) error { return errors.New("polling failed") }}}
+	server := handler(nil, notReady)
+	codes := map[string]int{"/metrics": http.StatusOK, "/healthz": http.StatusOK, "/readyz": http.StatusServiceUnavailable,
+		"/": http.StatusNotFound}
+	for path, code := range codes {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != code {
+			t.Errorf("%s: %d, want %d", path, w.Code, code)
+		}
+		//счетчики уведомлений видны с нулевыми значениями до первой отправки
+		if path == "/metrics" && !strings.Contains(w.Body.String(), `safeflight_notifications_total{result="failed"}`) {
+			t.Errorf("/metrics without notifications counter:\n%s", w.Body.String())
+		}
+	}
+}
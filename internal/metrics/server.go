@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"github.com/japersik/safe-flight-bot/internal/health"
+	"github.com/japersik/safe-flight-bot/logger"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"time"
+)
+
+//probeTimeout время ответа проверок /healthz и /readyz
+const probeTimeout = 5 * time.Second
+
+//ListenAndServe сервер метрик: /metrics в формате Prometheus, /healthz - проверки работоспособности,
+///readyz - готовности принимать обновления
+func ListenAndServe(addr string, liveness, readiness []health.Check) error {
+	server := &http.Server{
+		Addr:         addr,
+		Handler:      handler(liveness, readiness),
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+	logger.Info("metrics server listening", logger.String("addr", addr))
+	return server.ListenAndServe()
+}
+
+func handler(liveness, readiness []health.Check) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/healthz", health.Handler(liveness, probeTimeout))
+	mux.Handle("/readyz", health.Handler(readiness, probeTimeout))
+	return mux
+}
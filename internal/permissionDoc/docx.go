@@ -0,0 +1,100 @@
+package permissionDoc
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"strings"
+)
+
+const contentTypesXml = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>
+</Types>`
+
+const relsXml = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>
+</Relationships>`
+
+//paragraphAlign выравнивание абзаца в терминах WordprocessingML
+type paragraphAlign string
+
+const (
+	alignLeft   paragraphAlign = "left"
+	alignCenter paragraphAlign = "center"
+	alignRight  paragraphAlign = "right"
+	alignBoth   paragraphAlign = "both"
+)
+
+//paragraph абзац документа
+type paragraph struct {
+	text  string
+	align paragraphAlign
+	bold  bool
+}
+
+//docxDocument минимальный docx документ из абзацев текста
+type docxDocument struct {
+	paragraphs []paragraph
+}
+
+func (d *docxDocument) add(align paragraphAlign, bold bool, text string) {
+	d.paragraphs = append(d.paragraphs, paragraph{text: text, align: align, bold: bold})
+}
+
+func (d *docxDocument) documentXml() []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>`)
+	for _, p := range d.paragraphs {
+		buf.WriteString(`<w:p><w:pPr><w:jc w:val="` + string(p.align) + `"/></w:pPr>`)
+		for i, line := range strings.Split(p.text, "\n") {
+			buf.WriteString(`<w:r><w:rPr><w:rFonts w:ascii="Times New Roman" w:hAnsi="Times New Roman" w:cs="Times New Roman"/>`)
+			if p.bold {
+				buf.WriteString(`<w:b/>`)
+			}
+			buf.WriteString(`<w:sz w:val="28"/></w:rPr>`)
+			if i > 0 {
+				buf.WriteString(`<w:br/>`)
+			}
+			buf.WriteString(`<w:t xml:space="preserve">`)
+			xml.EscapeText(buf, []byte(line))
+			buf.WriteString(`</w:t></w:r>`)
+		}
+		buf.WriteString(`</w:p>`)
+	}
+	buf.WriteString(`<w:sectPr><w:pgSz w:w="11906" w:h="16838"/>` +
+		`<w:pgMar w:top="1134" w:right="850" w:bottom="1134" w:left="1701" w:header="708" w:footer="708" w:gutter="0"/>` +
+		`</w:sectPr></w:body></w:document>`)
+	return buf.Bytes()
+}
+
+//bytes упаковка документа в zip контейнер docx
+func (d *docxDocument) bytes() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	writer := zip.NewWriter(buf)
+	files := []struct {
+		name    string
+		content []byte
+	}{
+		{"[Content_Types].xml", []byte(contentTypesXml)},
+		{"_rels/.rels", []byte(relsXml)},
+		{"word/document.xml", d.documentXml()},
+	}
+	for _, file := range files {
+		w, err := writer.Create(file.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(file.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
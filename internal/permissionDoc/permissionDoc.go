@@ -0,0 +1,101 @@
+package permissionDoc
+
+import (
+	"fmt"
+	"github.com/japersik/safe-flight-bot/model"
+	"time"
+)
+
+//Request данные для заявления на полёт над населенным пунктом
+type Request struct {
+	Operator   model.Operator
+	Locality   string
+	Coordinate model.Coordinate
+	Radius     int
+	Altitude   int
+	From       time.Time
+	To         time.Time
+	Drone      *model.Drone
+	Created    time.Time
+}
+
+//NewRequest заполнение заявления по плану полёта
+func NewRequest(plan model.FlyPlan, operator model.Operator, locality string) Request {
+	from, to := plan.Window(time.Now())
+	altitude := plan.Data.Altitude
+	if altitude == 0 {
+		altitude = model.DefaultAltitude()
+	}
+	return Request{
+		Operator:   operator,
+		Locality:   locality,
+		Coordinate: plan.Data.Coordinate,
+		Radius:     plan.Data.Radius,
+		Altitude:   altitude,
+		From:       from,
+		To:         to,
+		Drone:      plan.Drone,
+		Created:    time.Now().In(from.Location()),
+	}
+}
+
+//FileName ...
+func (r Request) FileName() string {
+	return fmt.Sprintf("zayavlenie_%s.docx", r.From.Format("2006-01-02"))
+}
+
+//Generate формирует docx документ с заявлением о выдаче разрешения на полёт над населенным пунктом
+func Generate(r Request) ([]byte, error) {
+	doc := &docxDocument{}
+	locality := r.Locality
+	if locality == "" {
+		locality = "________________"
+	}
+	doc.add(alignRight, false, "В администрацию\n"+locality)
+	operator := fmt.Sprintf("от %s", orBlank(r.Operator.FullName))
+	if r.Operator.Address != "" {
+		operator += "\nадрес: " + r.Operator.Address
+	}
+	operator += "\nтел.: " + orBlank(r.Operator.Phone)
+	if r.Operator.Email != "" {
+		operator += "\nэл. почта: " + r.Operator.Email
+	}
+	doc.add(alignRight, false, operator)
+	doc.add(alignCenter, true, "ЗАЯВЛЕНИЕ\nо выдаче разрешения на выполнение полёта беспилотного воздушного судна "+
+		"над населенным пунктом")
+	doc.add(alignBoth, false, fmt.Sprintf("В соответствии с пунктом 49 Федеральных правил использования воздушного "+
+		"пространства Российской Федерации прошу выдать разрешение на выполнение полёта беспилотного воздушного судна "+
+		"над населенным пунктом %s.", locality))
+
+	radius := "не указан"
+	if r.Radius > 0 {
+		radius = fmt.Sprintf("%d м", r.Radius)
+	}
+	details := fmt.Sprintf("Район полётов: окружность с центром в точке %.6f с.ш., %.6f в.д. радиусом %s.\n"+
+		"Максимальная высота полёта: %d м от уровня земли.\n"+
+		"Дата и время полёта: %s с %s до %s (местное время).\n"+
+		"Цель полёта: ________________.",
+		r.Coordinate.Lat, r.Coordinate.Lng, radius, r.Altitude,
+		r.From.Format("02.01.2006"), r.From.Format("15:04"), r.To.Format("15:04"))
+	doc.add(alignBoth, false, details)
+
+	drone := "Беспилотное воздушное судно: ________________."
+	if r.Drone != nil {
+		drone = fmt.Sprintf("Беспилотное воздушное судно: %s, максимальная взлетная масса %d г.", r.Drone.Name, r.Drone.Weight)
+		if r.Drone.NeedsRegistration() {
+			drone += "\nУчетный номер: " + orBlank(r.Drone.RegistrationNumber) + "."
+		}
+	}
+	doc.add(alignBoth, false, drone)
+	doc.add(alignBoth, false, "Внешний пилот: "+orBlank(r.Operator.FullName)+".")
+	doc.add(alignLeft, false, fmt.Sprintf("%s\t\t\t\t_____________ / %s", r.Created.Format("02.01.2006"),
+		orBlank(r.Operator.FullName)))
+	return doc.bytes()
+}
+
+func orBlank(s string) string {
+	if s == "" {
+		return "________________"
+	}
+	return s
+}
@@ -0,0 +1,147 @@
+package permissionDoc
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"github.com/japersik/safe-flight-bot/model"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+//documentText текст абзацев word/document.xml, строки внутри абзаца разделены переводом строки
+func documentText(t *testing.T, data []byte) string {
+	t.Helper()
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("docx is not a zip archive: %v", err)
+	}
+	names := map[string]*zip.File{}
+	for _, file := range archive.File {
+		names[file.Name] = file
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "word/document.xml"} {
+		if names[name] == nil {
+			t.Fatalf("docx has no %s", name)
+		}
+	}
+	reader, err := names["word/document.xml"].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	text := &strings.Builder{}
+	decoder := xml.NewDecoder(reader)
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("document.xml is not well-formed: %v", err)
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			inText = token.Name.Local == "t"
+			if token.Name.Local == "br" {
+				text.WriteString("\n")
+			}
+		case xml.EndElement:
+			inText = false
+			if token.Name.Local == "p" {
+				text.WriteString("\n\n")
+			}
+		case xml.CharData:
+			if inText {
+				text.Write(token)
+			}
+		}
+	}
+	return text.String()
+}
+
+func TestGenerate(t *testing.T) {
+	from := time.Date(2030, 6, 1, 10, 0, 0, 0, time.UTC)
+	request := Request{
+		Coordinate: model.Coordinate{Lat: 55.7558, Lng: 37.6173},
+		Altitude:   120,
+		From:       from,
+		To:         from.Add(time.Hour),
+		Created:    from.AddDate(0, 0, -7),
+	}
+	tests := []struct {
+		name     string
+		modify   func(r *Request)
+		contains []string
+		excludes []string
+	}{
+		{
+			name: "full data",
+			modify: func(r *Request) {
+				r.Operator = model.Operator{FullName: "Иванов Иван Иванович", Phone: "+7 900 000-00-00",
+					Email: "ivanov@example.com", Address: "г. Москва, ул. Ленина, д. 1"}
+				r.Locality = "Москва"
+				r.Radius = 300
+				r.Drone = &model.Drone{Name: "Mavic 3", Weight: 895, RegistrationNumber: "0123456789"}
+			},
+			contains: []string{"В администрацию\nМосква", "от Иванов Иван Иванович\nадрес: г. Москва, ул. Ленина, д. 1\n" +
+				"тел.: +7 900 000-00-00\nэл. почта: ivanov@example.com", "над населенным пунктом Москва.",
+				"55.755800 с.ш., 37.617300 в.д. радиусом 300 м", "Максимальная высота полёта: 120 м",
+				"01.06.2030 с 10:00 до 11:00", "Mavic 3, максимальная взлетная масса 895 г.", "Учетный номер: 0123456789.",
+				"25.05.2030"},
+		},
+		{
+			name:   "missing data is left blank",
+			modify: func(r *Request) {},
+			contains: []string{"В администрацию\n________________", "от ________________\nтел.: ________________",
+				"радиусом не указан", "Беспилотное воздушное судно: ________________."},
+			excludes: []string{"адрес:", "эл. почта:", "Учетный номер"},
+		},
+		{
+			name: "light drone without registration",
+			modify: func(r *Request) {
+				r.Drone = &model.Drone{Name: "Mini 2", Weight: 149}
+			},
+			contains: []string{"Mini 2, максимальная взлетная масса 149 г."},
+			excludes: []string{"Учетный номер"},
+		},
+		{
+			name: "markup characters are escaped",
+			modify: func(r *Request) {
+				r.Operator = model.Operator{FullName: `ООО "Небо & <Ко>"`, Phone: "1"}
+			},
+			contains: []string{`от ООО "Небо & <Ко>"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := request
+			tt.modify(&r)
+			data, err := Generate(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			text := documentText(t, data)
+			for _, s := range tt.contains {
+				if !strings.Contains(text, s) {
+					t.Errorf("document does not contain %q:\n%s", s, text)
+				}
+			}
+			for _, s := range tt.excludes {
+				if strings.Contains(text, s) {
+					t.Errorf("document contains %q:\n%s", s, text)
+				}
+			}
+		})
+	}
+}
+
+func TestRequestFileName(t *testing.T) {
+	r := Request{From: time.Date(2030, 6, 1, 23, 30, 0, 0, time.UTC)}
+	if got, want := r.FileName(), "zayavlenie_2030-06-01.docx"; got != want {
+		t.Errorf("FileName() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,143 @@
+package ratelimit
+
+import (
+	"golang.org/x/time/rate"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//cleanupInterval период удаления корзин неактивных пользователей
+const cleanupInterval = time.Minute
+
+//UserLimiter token bucket для каждого пользователя. nil UserLimiter ничего не ограничивает
+type UserLimiter struct {
+	limit       rate.Limit
+	burst       int
+	mutex       sync.Mutex
+	buckets     map[int64]*bucket
+	lastCleanup time.Time
+}
+
+type bucket struct {
+	limiter   *rate.Limiter
+	lastSeen  time.Time
+	throttled bool
+}
+
+//NewUserLimiter perMinute - скорость пополнения корзины, burst - ее емкость, т.е. число запросов подряд.
+//perMinute <= 0 - без ограничения
+func NewUserLimiter(perMinute, burst int) *UserLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &UserLimiter{
+		limit:       rate.Limit(float64(perMinute) / 60),
+		burst:       burst,
+		buckets:     map[int64]*bucket{},
+		lastCleanup: time.Now(),
+	}
+}
+
+//Decision результат проверки лимита
+type Decision struct {
+	Allowed bool
+	//Warn первый отказ после разрешенного запроса. Предупреждать пользователя нужно один раз, а не отвечать
+	//на каждое лишнее сообщение
+	Warn bool
+	//RetryAfter время до появления следующего токена
+	RetryAfter time.Duration
+}
+
+//RetrySeconds RetryAfter в целых секундах для ответа пользователю и заголовка Retry-After
+func (d Decision) RetrySeconds() int {
+	seconds := int(math.Ceil(d.RetryAfter.Seconds()))
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
+//Allow расходует токен пользователя, если он есть
+func (l *UserLimiter) Allow(userId int64) Decision {
+	if l == nil {
+		return Decision{Allowed: true}
+	}
+	now := time.Now()
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.cleanup(now)
+	b, ok := l.buckets[userId]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.buckets[userId] = b
+	}
+	b.lastSeen = now
+	if b.limiter.AllowN(now, 1) {
+		b.throttled = false
+		return Decision{Allowed: true}
+	}
+	reservation := b.limiter.ReserveN(now, 1)
+	retryAfter := reservation.DelayFrom(now)
+	reservation.CancelAt(now)
+	warn := !b.throttled
+	b.throttled = true
+	return Decision{Warn: warn, RetryAfter: retryAfter}
+}
+
+//cleanup корзина, не использовавшаяся дольше времени полного пополнения, не отличается от новой и удаляется
+func (l *UserLimiter) cleanup(now time.Time) {
+	if now.Sub(l.lastCleanup) < cleanupInterval {
+		return
+	}
+	l.lastCleanup = now
+	idle := time.Duration(float64(l.burst) / float64(l.limit) * float64(time.Second))
+	for userId, b := range l.buckets {
+		if now.Sub(b.lastSeen) > idle {
+			delete(l.buckets, userId)
+		}
+	}
+}
+
+//Semaphore ограничение числа одновременных запросов, общее для всех транспортов, созданных из него.
+//nil Semaphore ничего не ограничивает
+type Semaphore chan struct{}
+
+//NewSemaphore size <= 0 - без ограничения
+func NewSemaphore(size int) Semaphore {
+	if size <= 0 {
+		return nil
+	}
+	return make(Semaphore, size)
+}
+
+//Transport запрос ждет свободного места, пока не истечет контекст запроса (включая таймаут http.Client).
+//Место освобождается после получения заголовков ответа. next == nil - http.DefaultTransport
+func (s Semaphore) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if s == nil {
+		return next
+	}
+	return &transport{semaphore: s, next: next}
+}
+
+type transport struct {
+	semaphore Semaphore
+	next      http.RoundTripper
+}
+
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	select {
+	case t.semaphore <- struct{}{}:
+	case <-r.Context().Done():
+		return nil, r.Context().Err()
+	}
+	defer func() { <-t.semaphore }()
+	return t.next.RoundTrip(r)
+}
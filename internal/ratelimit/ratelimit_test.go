@@ -0,0 +1,147 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUserLimiterAllow(t *testing.T) {
+	tests := []struct {
+		name      string
+		perMinute int
+		burst     int
+		requests  int
+		allowed   int
+	}{
+		{"unlimited", 0, 0, 100, 100},
+		{"negative rate is unlimited", -1, 5, 10, 10},
+		{"burst", 1, 3, 5, 3},
+		{"zero burst allows one request", 1, 0, 3, 1},
+		{"within burst", 60, 10, 10, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewUserLimiter(tt.perMinute, tt.burst)
+			allowed, warnings := 0, 0
+			for i := 0; i < tt.requests; i++ {
+				decision := limiter.Allow(1)
+				if decision.Allowed {
+					allowed++
+					continue
+				}
+				if decision.Warn {
+					warnings++
+				}
+				if decision.RetryAfter <= 0 || decision.RetryAfter > time.Minute {
+					t.Errorf("RetryAfter = %v", decision.RetryAfter)
+				}
+			}
+			if allowed != tt.allowed {
+				t.Errorf("allowed %d of %d requests, want %d", allowed, tt.requests, tt.allowed)
+			}
+			//предупреждение только о первом отказе подряд
+			wantWarnings := 0
+			if allowed < tt.requests {
+				wantWarnings = 1
+			}
+			if warnings != wantWarnings {
+				t.Errorf("warnings = %d, want %d", warnings, wantWarnings)
+			}
+		})
+	}
+}
+
+func TestUserLimiterUsers(t *testing.T) {
+	limiter := NewUserLimiter(1, 1)
+	if !limiter.Allow(1).Allowed {
+		t.Fatal("first request of user 1 throttled")
+	}
+	if limiter.Allow(1).Allowed {
+		t.Error("second request of user 1 allowed")
+	}
+	if !limiter.Allow(2).Allowed {
+		t.Error("user 2 throttled by user 1 requests")
+	}
+}
+
+func TestDecisionRetrySeconds(t *testing.T) {
+	tests := []struct {
+		retryAfter time.Duration
+		want       int
+	}{
+		{0, 1},
+		{time.Millisecond, 1},
+		{time.Second, 1},
+		{1500 * time.Millisecond, 2},
+		{time.Minute, 60},
+	}
+	for _, tt := range tests {
+		if got := (Decision{RetryAfter: tt.retryAfter}).RetrySeconds(); got != tt.want {
+			t.Errorf("RetrySeconds(%v) = %d, want %d", tt.retryAfter, got, tt.want)
+		}
+	}
+}
+
+func TestSemaphoreTransport(t *testing.T) {
+	release := make(chan struct{})
+	var running, maxRunning int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		<-release
+		atomic.AddInt32(&running, -1)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewSemaphore(2).Transport(nil)}
+	done := make(chan error)
+	for i := 0; i < 4; i++ {
+		go func() {
+			resp, err := client.Get(server.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			done <- err
+		}()
+	}
+	//запросы сверх размера семафора ждут и не доходят до сервера
+	time.Sleep(100 * time.Millisecond)
+	if got := atomic.LoadInt32(&running); got != 2 {
+		t.Errorf("running requests = %d, want 2", got)
+	}
+	close(release)
+	for i := 0; i < 4; i++ {
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}
+	if max := atomic.LoadInt32(&maxRunning); max > 2 {
+		t.Errorf("max concurrent requests = %d, want 2", max)
+	}
+
+	t.Run("context canceled while waiting", func(t *testing.T) {
+		semaphore := NewSemaphore(1)
+		semaphore <- struct{}{}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		r, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		if _, err := semaphore.Transport(nil).RoundTrip(r); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("RoundTrip() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+	})
+	t.Run("unlimited", func(t *testing.T) {
+		if NewSemaphore(0).Transport(nil) != http.DefaultTransport {
+			t.Error("nil semaphore wraps the transport")
+		}
+	})
+}
@@ -0,0 +1,130 @@
+package simulator
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/japersik/safe-flight-bot/internal/conversation"
+	"github.com/japersik/safe-flight-bot/logger"
+	"github.com/japersik/safe-flight-bot/model"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var tagRegexp = regexp.MustCompile(`<[^>]+>`)
+
+//Console консольный чат с ядром диалога для отладки сценариев без мессенджера.
+//Ввод: текст, команды вида /plans, геолокация /loc 55.75 37.61, номер кнопки
+type Console struct {
+	core    *conversation.Core
+	in      io.Reader
+	out     io.Writer
+	chatId  int64
+	userId  int64
+	buttons map[int]string
+	next    int
+	mutex   *sync.Mutex
+}
+
+//NewConsole личный чат пользователя userId
+func NewConsole(core *conversation.Core, in io.Reader, out io.Writer, userId int64) *Console {
+	c := &Console{
+		core:    core,
+		in:      in,
+		out:     out,
+		chatId:  userId,
+		userId:  userId,
+		buttons: map[int]string{},
+		next:    1,
+		mutex:   &sync.Mutex{},
+	}
+	core.SetTransport(c)
+	return c
+}
+
+//Run чтение ввода до конца потока
+func (c *Console) Run() error {
+	scanner := bufio.NewScanner(c.in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		in, ok := c.parse(line)
+		if !ok {
+			c.print("Неизвестная кнопка или неверный формат геолокации")
+			continue
+		}
+		if err := c.core.Handle(context.Background(), in); err != nil {
+			logger.Error("message processing error", logger.Err(err))
+			c.print("Ошибка: " + err.Error())
+		}
+	}
+	return scanner.Err()
+}
+
+func (c *Console) parse(line string) (conversation.Incoming, bool) {
+	in := conversation.Incoming{ChatId: c.chatId, UserId: c.userId, UserName: "user", Private: true}
+	if n, err := strconv.Atoi(line); err == nil {
+		c.mutex.Lock()
+		action, ok := c.buttons[n]
+		c.mutex.Unlock()
+		in.Action = action
+		return in, ok
+	}
+	if !strings.HasPrefix(line, "/") {
+		in.Text = line
+		return in, true
+	}
+	fields := strings.Fields(line[1:])
+	if len(fields) == 0 {
+		return in, false
+	}
+	if fields[0] != "loc" {
+		in.Command = fields[0]
+		in.Text = line
+		return in, true
+	}
+	if len(fields) != 3 {
+		return in, false
+	}
+	lat, errLat := strconv.ParseFloat(fields[1], 64)
+	lng, errLng := strconv.ParseFloat(fields[2], 64)
+	if errLat != nil || errLng != nil {
+		return in, false
+	}
+	in.Location = &model.Coordinate{Lat: lat, Lng: lng}
+	return in, true
+}
+
+//Send реализация интерфейса conversation.Transport. Вызывается также из таймеров уведомлений
+func (c *Console) Send(out conversation.Outgoing) error {
+	text := html.UnescapeString(tagRegexp.ReplaceAllString(out.Text, ""))
+	var sb strings.Builder
+	sb.WriteString(strings.TrimSpace(text))
+	c.mutex.Lock()
+	for _, row := range out.Buttons {
+		sb.WriteString("\n")
+		for i, button := range row {
+			if i > 0 {
+				sb.WriteString("  ")
+			}
+			c.buttons[c.next] = button.Action
+			sb.WriteString(fmt.Sprintf("[%d] %s", c.next, button.Text))
+			c.next++
+		}
+	}
+	c.mutex.Unlock()
+	c.print(sb.String())
+	return nil
+}
+
+func (c *Console) print(text string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	fmt.Fprintf(c.out, "bot> %s\n\n", strings.ReplaceAll(text, "\n", "\n     "))
+}
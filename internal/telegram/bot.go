@@ -1,14 +1,27 @@
 package telegram
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/japersik/safe-flight-bot/internal/conversation"
 	"github.com/japersik/safe-flight-bot/internal/flyDataClient"
 	"github.com/japersik/safe-flight-bot/internal/flyPlanner"
+	"github.com/japersik/safe-flight-bot/internal/logbook"
+	"github.com/japersik/safe-flight-bot/internal/mapRenderer"
+	"github.com/japersik/safe-flight-bot/internal/metrics"
+	"github.com/japersik/safe-flight-bot/internal/ratelimit"
+	"github.com/japersik/safe-flight-bot/internal/tracing"
+	"github.com/japersik/safe-flight-bot/internal/userStorage"
 	"github.com/japersik/safe-flight-bot/logger"
 	"github.com/japersik/safe-flight-bot/model"
-	"strconv"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+	"html"
+	"strings"
 	"sync"
+	"time"
 )
 
 //Callback структура используется в tgbotapi.NewInlineKeyboardButtonData в сериализованном в json виде
@@ -20,122 +33,285 @@ type Callback struct {
 //CallbackType ...
 type CallbackType int
 
-//flyPlanStage используется для обозначения и хранения стадии создания автоматических уведомлений
-type flyPlanStage int
+//sessionKey ключ сессии планирования: в группах каждый участник планирует независимо
+type sessionKey struct {
+	chatId int64
+	userId int64
+}
 
-const (
-	dateTimeSelect flyPlanStage = iota
-	notifications
-)
+func newSessionKey(chat *tgbotapi.Chat, user *tgbotapi.User) sessionKey {
+	key := sessionKey{chatId: chat.ID}
+	if user != nil {
+		key.userId = user.ID
+	}
+	return key
+}
 
-//plannedFlightInfo используется для хранения информации о процессе создания автоматических уведомлений
-type plannedFlightInfo struct {
-	plan  model.FlyPlan
-	stage flyPlanStage
+//userMention обращение к пользователю в групповом чате
+func userMention(chat *tgbotapi.Chat, user *tgbotapi.User) string {
+	if chat.IsPrivate() || user == nil {
+		return ""
+	}
+	return fmt.Sprintf(`<a href="tg://user?id=%d">%s</a>, `, user.ID, html.EscapeString(user.FirstName))
 }
 
 type Bot struct {
-	bot                 *tgbotapi.BotAPI
-	flyClient           flyDataClient.Client
-	planner             flyPlanner.Planner
-	flightPlanningUsers map[int64]*plannedFlightInfo
-	planMutex           *sync.Mutex
-	markupsToDelete     map[int64]tgbotapi.Message
-	markupsMutex        *sync.Mutex
+	bot              *tgbotapi.BotAPI
+	flyClient        flyDataClient.Client
+	planner          flyPlanner.Planner
+	users            userStorage.Storage
+	logbook          logbook.Logbook
+	core             *conversation.Core
+	placeNamingUsers map[sessionKey]model.FavouritePlace
+	checklistUsers   map[sessionKey]bool
+	logbookUsers     map[sessionKey]uint64
+	fleetUsers       map[sessionKey]bool
+	operatorUsers    map[sessionKey]bool
+	importSessions   map[sessionKey][]importEvent
+	planMutex        *sync.Mutex
+	markupsToDelete  map[sessionKey]tgbotapi.Message
+	markupsMutex     *sync.Mutex
+	mapRenderer      *mapRenderer.Renderer
+	liveSessions     map[sessionKey]*liveSession
+	liveMutex        *sync.Mutex
+	calendarFeedURL  string
+	apiURL           string
+	updatesWG        *sync.WaitGroup
+	admins           map[int64]bool
+	adminsMutex      *sync.Mutex
+	logLevel         *zap.AtomicLevel
+	updateLimiter    *ratelimit.UserLimiter
+	checkLimiter     *ratelimit.UserLimiter
+	startedAt        time.Time
+	//последняя проверка связи с Telegram
+	telegramCheckedAt  time.Time
+	telegramCheckErr   error
+	telegramCheckMutex *sync.Mutex
+	//ошибки getUpdates подряд в режиме polling
+	pollingFailures int
+	pollingErr      error
 }
 
-func NewBot(bot *tgbotapi.BotAPI, client flyDataClient.Client, planner flyPlanner.Planner) *Bot {
+func NewBot(bot *tgbotapi.BotAPI, client flyDataClient.Client, planner flyPlanner.Planner, users userStorage.Storage,
+	flights logbook.Logbook) *Bot {
 	myBot := &Bot{
-		bot:                 bot,
-		flyClient:           client,
-		planner:             planner,
-		flightPlanningUsers: map[int64]*plannedFlightInfo{},
-		planMutex:           &sync.Mutex{},
-		markupsToDelete:     map[int64]tgbotapi.Message{},
-		markupsMutex:        &sync.Mutex{},
+		bot:                bot,
+		flyClient:          client,
+		planner:            planner,
+		users:              users,
+		logbook:            flights,
+		core:               conversation.NewCore(client, planner, users),
+		placeNamingUsers:   map[sessionKey]model.FavouritePlace{},
+		checklistUsers:     map[sessionKey]bool{},
+		logbookUsers:       map[sessionKey]uint64{},
+		fleetUsers:         map[sessionKey]bool{},
+		operatorUsers:      map[sessionKey]bool{},
+		importSessions:     map[sessionKey][]importEvent{},
+		planMutex:          &sync.Mutex{},
+		markupsToDelete:    map[sessionKey]tgbotapi.Message{},
+		markupsMutex:       &sync.Mutex{},
+		liveSessions:       map[sessionKey]*liveSession{},
+		liveMutex:          &sync.Mutex{},
+		updatesWG:          &sync.WaitGroup{},
+		admins:             map[int64]bool{},
+		adminsMutex:        &sync.Mutex{},
+		startedAt:          time.Now(),
+		telegramCheckMutex: &sync.Mutex{},
 	}
+	myBot.core.SetTransport(transport{bot: myBot})
+	myBot.core.SetPlanListener(myBot)
+	planner.SetChangeListener(myBot)
+	myBot.core.SetReporter(myBot)
 	return myBot
 }
 
+//SetMapRenderer включает отправку карты района полёта вместе с отчетом
+func (b *Bot) SetMapRenderer(renderer *mapRenderer.Renderer) {
+	b.mapRenderer = renderer
+}
+
 //Notify реализация интерфейса flyPlanner.Notifier
-func (b *Bot) Notify(flyPlan model.FlyPlan) error {
-	text, err := b.getInfoText(flyPlan.Data.Coordinate, 100)
+func (b *Bot) Notify(ctx context.Context, flyPlan model.FlyPlan, notification time.Duration) error {
+	text, report, zones, err := b.core.Notification(ctx, flyPlan, notification)
 	if err != nil {
 		return err
 	}
-	text = "Автоматическое уведомление №" + strconv.FormatUint(flyPlan.FlyId, 10) + "\n" + text
-	msg := tgbotapi.NewMessage(flyPlan.Data.UserId, text)
+	msg := tgbotapi.NewMessage(flyPlan.Data.NotifyChatId(), text)
 	cancelFlyNotifications, _ := json.Marshal(Callback{
 		CallbackType: cancelFlyCallback,
 		Data:         flyPlan.FlyId,
 	})
-	numericKeyboard := tgbotapi.NewInlineKeyboardMarkup(
+	rows := zonesKeyboardRows(zones)
+	//кнопка заявления, как и при создании плана, только в личном чате
+	if report.Locality != nil && report.Locality.FlyRestriction && !flyPlan.IsEveryDayPlan && flyPlan.Data.ChatId == 0 {
+		rows = append(rows, permissionDocKeyboardRow(flyPlan.FlyId))
+	}
+	numericKeyboard := tgbotapi.NewInlineKeyboardMarkup(append(rows,
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("Отключить уведомление", string(cancelFlyNotifications))),
-	)
+	)...)
 	msg.ReplyMarkup = numericKeyboard
 	msg.ParseMode = "HTML"
 	_, err = b.Send(msg)
-	return err
-}
-
-//Start запуск обработки обновлений
-func (b *Bot) Start() error {
-
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
-	updates := b.bot.GetUpdatesChan(u)
-	for update := range updates {
-		go b.manageUpdate(update)
+	b.sendToSubscribers(ctx, flyPlan, text, append(zonesKeyboardRows(zones), unsubscribeKeyboardRow(flyPlan.FlyId)))
+	if err == nil && !flyPlan.IsEveryDayPlan && len(flyPlan.Checklist) > 0 && notification == checklistNotification {
+		err = b.sendChecklist(flyPlan)
 	}
-	return nil
+	//последнее уведомление по плану, после него план удаляется
+	if err == nil && !flyPlan.IsEveryDayPlan && len(flyPlan.Notifications) == 0 {
+		err = b.askFlightHappened(flyPlan, report)
+	}
+	return err
 }
 
 func (b *Bot) manageUpdate(update tgbotapi.Update) {
+	kind := updateType(update)
+	ctx, span := tracing.Start(updateContext(update), "telegram.update",
+		attribute.String("update.type", kind), attribute.Int("update.id", update.UpdateID))
+	ctx = tracing.WithTraceId(ctx)
+	var err error
 	defer func() {
 		if r := recover(); r != nil {
-			logger.Error("message processing error ", r)
+			logger.FromContext(ctx).Error("update processing panic", logger.Any("panic", r))
+			err = fmt.Errorf("panic: %v", r)
 		}
+		tracing.End(span, err)
 	}()
+	defer metrics.ObserveUpdate(kind, time.Now())
+	if err = b.dispatchUpdate(ctx, update); err != nil {
+		logger.FromContext(ctx).Warn("update processing error", logger.Err(err))
+	}
+}
+
+//updateContext ctx обработки обновления с логгером, в записи которого добавляются correlationId, чат и пользователь
+func updateContext(update tgbotapi.Update) context.Context {
+	fields := []logger.Field{logger.Int("updateId", update.UpdateID)}
+	if chat := update.FromChat(); chat != nil {
+		fields = append(fields, logger.ChatId(chat.ID))
+	}
+	if user := update.SentFrom(); user != nil {
+		fields = append(fields, logger.UserId(user.ID))
+	}
+	return logger.WithCorrelation(context.Background(), fields...)
+}
+
+func (b *Bot) dispatchUpdate(ctx context.Context, update tgbotapi.Update) error {
+	if update.InlineQuery != nil {
+		return b.handleInlineQuery(ctx, update.InlineQuery)
+	}
+	if update.FromChat() == nil {
+		return nil
+	}
+	if update.EditedMessage != nil {
+		b.handleLiveLocation(ctx, update.EditedMessage)
+		return nil
+	}
+	if update.Message != nil && update.Message.IsCommand() && !b.isCommandForMe(update.Message) {
+		return nil
+	}
+	key := newSessionKey(update.FromChat(), update.SentFrom())
 	b.markupsMutex.Lock()
-	msg, ok := b.markupsToDelete[update.FromChat().ID]
+	msg, ok := b.markupsToDelete[key]
+	delete(b.markupsToDelete, key)
 	b.markupsMutex.Unlock()
 	if ok {
-		msg := tgbotapi.NewEditMessageText(update.FromChat().ID, msg.MessageID, msg.Text)
+		msg := tgbotapi.NewEditMessageText(msg.Chat.ID, msg.MessageID, msg.Text)
 		b.Send(msg)
 	}
-	ok, _ = b.checkManageFlyPlanning(update)
-	if ok {
-		return
+	for _, check := range []func(ctx context.Context, update tgbotapi.Update) (bool, error){
+		b.checkManageFlyPlanning,
+		b.checkManagePlaceNaming,
+		b.checkManageChecklistEditing,
+		b.checkManageLogbookInput,
+		b.checkManageFleetInput,
+		b.checkManageOperatorInput,
+	} {
+		if ok, err := check(ctx, update); ok {
+			return err
+		}
 	}
 	if update.CallbackData() != "" {
-		b.handleCallback(update.FromChat(), update.CallbackQuery)
+		return b.handleCallback(ctx, update.FromChat(), update.CallbackQuery)
 	} else if update.Message == nil {
 	} else if update.Message.Location != nil {
-		b.handleGeoLocationMessage(update.Message)
+		return b.handleGeoLocationMessage(ctx, update.Message)
+	} else if update.Message.Document != nil {
+		return b.handleDocumentMessage(ctx, update.Message)
 	} else if update.Message.IsCommand() {
-		b.handleCommand(update.Message)
-	} else {
+		return b.handleCommand(ctx, update.Message)
+	} else if update.Message.Text != "" {
+		return b.handleTextMessage(ctx, update.Message)
+	} else if update.Message.Chat.IsPrivate() {
 		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Команда не поддерживается.")
-		b.bot.Send(msg)
+		_, err := b.bot.Send(msg)
+		return err
 	}
+	return nil
 }
 
-type MyMessageConfig struct {
-	tgbotapi.Chattable
-	needToDeleteMarkup bool
+//updateType метка типа обновления для метрик
+func updateType(update tgbotapi.Update) string {
+	switch {
+	case update.InlineQuery != nil:
+		return "inline_query"
+	case update.EditedMessage != nil:
+		return "edited_message"
+	case update.CallbackQuery != nil:
+		return "callback"
+	case update.Message == nil:
+		return "other"
+	case update.Message.IsCommand():
+		return "command"
+	case update.Message.Location != nil:
+		return "location"
+	case update.Message.Document != nil:
+		return "document"
+	}
+	return "message"
 }
 
-func (b *Bot) SendWithMarkupToDelete(c tgbotapi.Chattable) (tgbotapi.Message, error) {
-	return b.Send(MyMessageConfig{c, true})
+//PlanningSessions число пользователей, планирующих полёт
+func (b *Bot) PlanningSessions() int {
+	return b.core.PlanningSessions()
 }
 
-func (b *Bot) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
-	msg, err := b.bot.Send(c)
-	if message, ok := c.(MyMessageConfig); ok && message.needToDeleteMarkup {
+//telegramCheckPeriod результат проверки связи с Telegram используется повторно в течение этого времени
+const telegramCheckPeriod = 30 * time.Second
+
+//CheckTelegram проверка связи с Bot API для /readyz. Результат кэшируется, чтобы частые проверки
+//не расходовали лимиты API
+func (b *Bot) CheckTelegram() error {
+	b.telegramCheckMutex.Lock()
+	defer b.telegramCheckMutex.Unlock()
+	if b.pollingFailures >= pollingFailuresNotReady {
+		return fmt.Errorf("getUpdates failed %d times in a row: %w", b.pollingFailures, b.pollingErr)
+	}
+	if time.Since(b.telegramCheckedAt) < telegramCheckPeriod {
+		return b.telegramCheckErr
+	}
+	_, b.telegramCheckErr = b.bot.GetMe()
+	b.telegramCheckedAt = time.Now()
+	return b.telegramCheckErr
+}
+
+//isCommandForMe false для команд вида /start@otherbot, адресованных другим ботам группы
+func (b *Bot) isCommandForMe(message *tgbotapi.Message) bool {
+	command := message.CommandWithAt()
+	i := strings.Index(command, "@")
+	return i < 0 || strings.EqualFold(command[i+1:], b.bot.Self.UserName)
+}
+
+//SendWithMarkupToDelete отправка сообщения, клавиатура которого удаляется при следующем сообщении пользователя
+func (b *Bot) SendWithMarkupToDelete(user *tgbotapi.User, c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	msg, err := b.Send(c)
+	if err == nil && msg.Chat != nil {
 		b.markupsMutex.Lock()
-		b.markupsToDelete[msg.Chat.ID] = msg
+		b.markupsToDelete[newSessionKey(msg.Chat, user)] = msg
 		b.markupsMutex.Unlock()
 	}
 	return msg, err
 }
+
+func (b *Bot) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	return b.bot.Send(c)
+}
@@ -0,0 +1,223 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/japersik/safe-flight-bot/internal/health"
+	"github.com/japersik/safe-flight-bot/logger"
+	"github.com/japersik/safe-flight-bot/model"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//broadcastDelay пауза между сообщениями рассылки, чтобы не превысить лимит Telegram в 30 сообщений в секунду
+const broadcastDelay = 50 * time.Millisecond
+
+//healthTimeout время ожидания ответа внешних сервисов в /health
+const healthTimeout = 15 * time.Second
+
+//SetAdmins id пользователей с доступом к командам администрирования. Может вызываться при перезагрузке конфигурации
+func (b *Bot) SetAdmins(ids []int64) {
+	admins := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		admins[id] = true
+	}
+	b.adminsMutex.Lock()
+	b.admins = admins
+	b.adminsMutex.Unlock()
+}
+
+//SetLogLevel уровень логирования, изменяемый командой /loglevel
+func (b *Bot) SetLogLevel(level zap.AtomicLevel) {
+	b.logLevel = &level
+}
+
+func (b *Bot) isAdmin(user *tgbotapi.User) bool {
+	if user == nil {
+		return false
+	}
+	b.adminsMutex.Lock()
+	defer b.adminsMutex.Unlock()
+	return b.admins[user.ID]
+}
+
+//handleAdminCommand команды администратора доступны только в личном чате. Для остальных пользователей
+//они неотличимы от неизвестных команд
+func (b *Bot) handleAdminCommand(ctx context.Context, message *tgbotapi.Message) error {
+	if !message.Chat.IsPrivate() || !b.isAdmin(message.From) {
+		return b.handleUnknownCommand(message)
+	}
+	log := logger.FromContext(ctx).With(logger.String("command", message.Command()))
+	log.Info("admin command")
+	var (
+		text string
+		err  error
+	)
+	switch message.Command() {
+	case "stats":
+		text = b.statsText()
+	case "broadcast":
+		text, err = b.broadcast(ctx, message.CommandArguments())
+	case "plans":
+		text = b.userPlansText(message.CommandArguments())
+	case "health":
+		text = b.healthText(ctx)
+	case "loglevel":
+		text = b.changeLogLevel(ctx, message.CommandArguments())
+	default:
+		text = adminHelpText
+	}
+	if err != nil {
+		log.Error("admin command error", logger.Err(err))
+		text = "Ошибка: " + html.EscapeString(err.Error())
+	}
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ParseMode = "HTML"
+	msg.DisableWebPagePreview = true
+	_, err = b.Send(msg)
+	return err
+}
+
+const adminHelpText = "Команды администратора:\n" +
+	"/stats - пользователи, планы и запланированные уведомления\n" +
+	"/broadcast <i>текст</i> - сообщение о технических работах всем авторам планов\n" +
+	"/plans <i>id</i> - планы пользователя\n" +
+	"/health - доступность внешних сервисов\n" +
+	"/loglevel [<i>debug|info|warn|error</i>] - уровень логирования"
+
+func (b *Bot) statsText() string {
+	plans := b.planner.AllPlans()
+	var daily, subscribers int
+	owners := map[int64]bool{}
+	//пользователи с сохраненными настройками, авторы планов и подписчики без настроек
+	users := map[int64]bool{}
+	profiles := b.users.UserIds()
+	for _, id := range profiles {
+		users[id] = true
+	}
+	for _, plan := range plans {
+		if plan.IsEveryDayPlan {
+			daily++
+		}
+		subscribers += len(plan.Subscribers)
+		owners[plan.Data.UserId] = true
+		users[plan.Data.UserId] = true
+		for _, sub := range plan.Subscribers {
+			users[sub.UserId] = true
+		}
+	}
+	b.liveMutex.Lock()
+	live := len(b.liveSessions)
+	b.liveMutex.Unlock()
+	text := fmt.Sprintf("<b>Статистика</b>\n"+
+		"Пользователи: %d (с сохраненными настройками: %d)\n"+
+		"Активные планы: %d (ежедневных: %d)\n"+
+		"Авторы планов: %d, подписки: %d\n"+
+		"Запланированные уведомления: %d\n"+
+		"Трансляции геопозиции: %d\n"+
+		"Время работы: %s",
+		len(users), len(profiles), len(plans), daily, len(owners), subscribers,
+		b.planner.PendingNotifications(), live, time.Since(b.startedAt).Round(time.Second))
+	if b.logLevel != nil {
+		text += "\nУровень логирования: " + b.logLevel.Level().String()
+	}
+	return text
+}
+
+//broadcast рассылка авторам планов. Сообщение отправляется в личный чат, поэтому авторы, планирующие
+//только в группах и не начавшие диалог с ботом, его не получат
+func (b *Bot) broadcast(ctx context.Context, text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "Укажите текст сообщения: /broadcast <i>текст</i>", nil
+	}
+	owners := make([]int64, 0)
+	seen := map[int64]bool{}
+	for _, plan := range b.planner.AllPlans() {
+		if !seen[plan.Data.UserId] {
+			seen[plan.Data.UserId] = true
+			owners = append(owners, plan.Data.UserId)
+		}
+	}
+	var failed int
+	for i, userId := range owners {
+		if i > 0 {
+			time.Sleep(broadcastDelay)
+		}
+		msg := tgbotapi.NewMessage(userId, "🛠 Сообщение от администратора бота\n\n"+text)
+		if _, err := b.Send(msg); err != nil {
+			logger.FromContext(ctx).Debug("broadcast message error", logger.UserId(userId), logger.Err(err))
+			failed++
+		}
+	}
+	logger.FromContext(ctx).Info("broadcast sent", logger.Int("sent", len(owners)-failed), logger.Int("owners", len(owners)))
+	return fmt.Sprintf("Сообщение отправлено: %d из %d авторов планов", len(owners)-failed, len(owners)), nil
+}
+
+func (b *Bot) userPlansText(args string) string {
+	userId, err := strconv.ParseInt(strings.TrimSpace(args), 10, 64)
+	if err != nil {
+		return "Укажите id пользователя: /plans <i>id</i>"
+	}
+	plans := b.planner.UserPlans(userId)
+	if len(plans) == 0 {
+		return fmt.Sprintf("У пользователя %d нет планов", userId)
+	}
+	lines := []string{fmt.Sprintf("<b>Планы пользователя %d</b>", userId)}
+	for _, plan := range plans {
+		when := plan.FlyDateTime.Format("02.01.2006 15:04 -0700")
+		if plan.IsEveryDayPlan {
+			when = "ежедневно в " + plan.FlyDateTime.Format("15:04 -0700")
+		}
+		radius := plan.Data.Radius
+		if radius <= 0 {
+			radius = model.DefaultRadius()
+		}
+		line := fmt.Sprintf("№%d %s, %.5f, %.5f, R=%dм, уведомлений: %d", plan.FlyId, when,
+			plan.Data.Coordinate.Lat, plan.Data.Coordinate.Lng, radius, len(plan.Notifications))
+		if plan.Data.UserId != userId {
+			line += ", подписка на план " + strconv.FormatInt(plan.Data.UserId, 10)
+		} else if chatId := plan.Data.NotifyChatId(); chatId != userId {
+			line += fmt.Sprintf(", чат %d", chatId)
+		}
+		if len(plan.Subscribers) > 0 {
+			line += fmt.Sprintf(", подписчиков: %d", len(plan.Subscribers))
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (b *Bot) healthText(ctx context.Context) string {
+	lines := []string{"<b>Внешние сервисы</b>"}
+	for _, result := range health.Run(ctx, health.Upstreams(b.flyClient, b.mapRenderer), healthTimeout) {
+		if result.OK() {
+			lines = append(lines, fmt.Sprintf("✅ %s: %s", result.Name, result.Latency.Round(time.Millisecond)))
+		} else {
+			lines = append(lines, fmt.Sprintf("❌ %s: %s", result.Name, html.EscapeString(result.Err.Error())))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (b *Bot) changeLogLevel(ctx context.Context, args string) string {
+	if b.logLevel == nil {
+		return "Изменение уровня логирования не поддерживается"
+	}
+	args = strings.TrimSpace(args)
+	if args == "" {
+		return "Уровень логирования: " + b.logLevel.Level().String()
+	}
+	level, err := zapcore.ParseLevel(args)
+	if err != nil {
+		return "Неизвестный уровень " + html.EscapeString(args) + ". Допустимые: debug, info, warn, error"
+	}
+	b.logLevel.SetLevel(level)
+	logger.FromContext(ctx).Info("log level changed", logger.String("level", level.String()))
+	return "Уровень логирования: " + level.String() + ". До перезапуска или перезагрузки конфигурации"
+}
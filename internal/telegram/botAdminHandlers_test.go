@@ -0,0 +1,44 @@
+package telegram
+
+import (
+	"github.com/japersik/safe-flight-bot/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsTextUsers(t *testing.T) {
+	bot, _ := newTestBot(t)
+	future := time.Now().Add(48 * time.Hour)
+	//настройки есть у автора 1 и у пользователя 4 без планов, автор 2 и подписчик 3 их не сохраняли
+	if err := bot.users.SetChecklist(1, []string{"Заряд батарей"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bot.users.SetChecklist(4, []string{"Пропеллеры"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, plan := range []model.FlyPlan{
+		{FlyDateTime: future, Data: model.FlyData{UserId: 1}},
+		{FlyDateTime: future, Data: model.FlyData{UserId: 2}},
+		{FlyDateTime: future.Add(time.Hour), IsEveryDayPlan: true, Data: model.FlyData{UserId: 2}},
+	} {
+		flyId, err := bot.planner.PlanFly(plan)
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, _ := bot.planner.ShareFly(flyId)
+		if _, err := bot.planner.Subscribe(token, model.Subscriber{UserId: 3}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	text := bot.statsText()
+	for _, want := range []string{
+		"Пользователи: 4 (с сохраненными настройками: 2)",
+		"Активные планы: 3 (ежедневных: 1)",
+		"Авторы планов: 2, подписки: 3",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("stats do not contain %q:\n%s", want, text)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package telegram
+
+import (
+	"encoding/json"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"strings"
+)
+
+//SetAPIURL включает выдачу токенов REST API. url - внешний адрес http сервера API
+func (b *Bot) SetAPIURL(url string) {
+	b.apiURL = strings.TrimSuffix(url, "/")
+}
+
+func (b *Bot) handleAPICommand(message *tgbotapi.Message) error {
+	if message.From == nil {
+		return nil
+	}
+	if b.apiURL == "" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "REST API не включено")
+		_, err := b.Send(msg)
+		return err
+	}
+	if !message.Chat.IsPrivate() {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Токен доступа к API выдается только в личном чате с ботом")
+		_, err := b.Send(msg)
+		return err
+	}
+	token, err := b.users.APIToken(message.From.ID)
+	if err != nil {
+		return err
+	}
+	return b.sendAPIToken(message.Chat, token)
+}
+
+func (b Bot) sendAPIToken(chat *tgbotapi.Chat, token string) error {
+	text := "REST API для наземных станций и других программ: " + b.apiURL + "\n" +
+		"Описание: " + b.apiURL + "/v1/openapi.yaml\n\n" +
+		"Токен доступа, передается в заголовке Authorization: Bearer <токен>\n<code>" + token + "</code>\n\n" +
+		"Токен дает доступ к вашим планам полётов. Если он стал известен другим, создайте новый"
+	msg := tgbotapi.NewMessage(chat.ID, text)
+	msg.ParseMode = "HTML"
+	msg.DisableWebPagePreview = true
+	callbackReset, _ := json.Marshal(Callback{
+		CallbackType: resetAPITokenCallback,
+		Data:         struct{}{},
+	})
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔄 Новый токен", string(callbackReset))),
+	)
+	_, err := b.Send(msg)
+	return err
+}
+
+func (b Bot) handleResetAPITokenCallback(chat *tgbotapi.Chat, user *tgbotapi.User) error {
+	if b.apiURL == "" || !chat.IsPrivate() {
+		return nil
+	}
+	token, err := b.users.ResetAPIToken(user.ID)
+	if err != nil {
+		return err
+	}
+	return b.sendAPIToken(chat, token)
+}
@@ -0,0 +1,84 @@
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/japersik/safe-flight-bot/internal/calendar"
+	"strings"
+	"time"
+)
+
+//SetCalendarFeedURL включает выдачу ссылок на календарь. url - внешний адрес http сервера календарей
+func (b *Bot) SetCalendarFeedURL(url string) {
+	b.calendarFeedURL = strings.TrimSuffix(url, "/")
+}
+
+func (b Bot) calendarFeedLink(token string) string {
+	return b.calendarFeedURL + calendar.FeedPath + token + ".ics"
+}
+
+func (b *Bot) handleCalendarCommand(message *tgbotapi.Message) error {
+	if message.From == nil {
+		return nil
+	}
+	//файл содержит все планы пользователя, а ссылка дает постоянный доступ к ним
+	if !message.Chat.IsPrivate() {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Календарь полётов выдается только в личном чате с ботом")
+		_, err := b.Send(msg)
+		return err
+	}
+	plans := b.planner.UserPlans(message.From.ID)
+	if len(plans) == 0 {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "У вас нет запланированных полётов")
+		if _, err := b.Send(msg); err != nil {
+			return err
+		}
+	} else {
+		buf := &bytes.Buffer{}
+		if err := calendar.Write(buf, "Полёты БВС", plans, time.Now()); err != nil {
+			return err
+		}
+		doc := tgbotapi.NewDocument(message.Chat.ID, tgbotapi.FileBytes{Name: "flights.ics", Bytes: buf.Bytes()})
+		doc.Caption = "Запланированные полёты. Откройте файл, чтобы добавить их в календарь"
+		if _, err := b.Send(doc); err != nil {
+			return err
+		}
+	}
+	if b.calendarFeedURL == "" {
+		return nil
+	}
+	token, err := b.users.CalendarToken(message.From.ID)
+	if err != nil {
+		return err
+	}
+	return b.sendCalendarLink(message.Chat, token)
+}
+
+func (b Bot) sendCalendarLink(chat *tgbotapi.Chat, token string) error {
+	text := "Ссылка для подписки на календарь полётов, он обновляется вместе с планами:\n" + b.calendarFeedLink(token) +
+		"\n\nНе передавайте ссылку посторонним. Если она стала известна другим, создайте новую"
+	msg := tgbotapi.NewMessage(chat.ID, text)
+	msg.DisableWebPagePreview = true
+	callbackReset, _ := json.Marshal(Callback{
+		CallbackType: resetCalendarCallback,
+		Data:         struct{}{},
+	})
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔄 Новая ссылка", string(callbackReset))),
+	)
+	_, err := b.Send(msg)
+	return err
+}
+
+func (b Bot) handleResetCalendarCallback(chat *tgbotapi.Chat, user *tgbotapi.User) error {
+	if b.calendarFeedURL == "" {
+		return nil
+	}
+	token, err := b.users.ResetCalendarToken(user.ID)
+	if err != nil {
+		return err
+	}
+	return b.sendCalendarLink(chat, token)
+}
@@ -1,9 +1,12 @@
 package telegram
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/japersik/safe-flight-bot/internal/conversation"
+	"github.com/japersik/safe-flight-bot/logger"
 	"github.com/japersik/safe-flight-bot/model"
 	"github.com/mitchellh/mapstructure"
 )
@@ -14,13 +17,43 @@ const (
 	cancelFlyCallback
 	repeatRequestCallback
 	cancelPlanFlyCallback
+	zoneInfoCallback
+	savePlaceCallback
+	cancelSavePlaceCallback
+	placeCheckCallback
+	placePlanCallback
+	placeDeleteCallback
+	sharePlanCallback
+	editPlanCallback
+	unsubscribePlanCallback
+	checklistItemCallback
+	editChecklistCallback
+	cancelChecklistCallback
+	resetChecklistCallback
+	logConfirmCallback
+	logDetailsCallback
+	logRejectCallback
+	logExportCallback
+	addDroneCallback
+	cancelAddDroneCallback
+	deleteDroneCallback
+	selectDroneCallback
+	permissionDocCallback
+	editOperatorCallback
+	cancelOperatorCallback
+	resetCalendarCallback
+	importToggleCallback
+	importConfirmCallback
+	importCancelCallback
+	resetAPITokenCallback
+	coreActionCallback
 )
 
 var (
 	WrongCallbackErr = errors.New("wrong Callback")
 )
 
-func (b *Bot) handleCallback(chat *tgbotapi.Chat, query *tgbotapi.CallbackQuery) error {
+func (b *Bot) handleCallback(ctx context.Context, chat *tgbotapi.Chat, query *tgbotapi.CallbackQuery) error {
 	var text string
 	callbackData := query.Data
 
@@ -36,31 +69,136 @@ func (b *Bot) handleCallback(chat *tgbotapi.Chat, query *tgbotapi.CallbackQuery)
 		if err != nil {
 			return WrongCallbackErr
 		}
-		return b.handlePlanFlyCallback(chat, coords)
+		return b.handlePlanFlyCallback(chat, query.From, coords)
 	case planFlyEdNotifications:
 		coords := model.Coordinate{}
 		err := mapstructure.Decode(callback.Data, &coords)
 		if err != nil {
 			return WrongCallbackErr
 		}
-		return b.handlePlanFlyEdNotifications(chat, coords)
+		return b.handlePlanFlyEdNotifications(chat, query.From, coords)
 	case cancelFlyCallback:
-		query.Message.ReplyMarkup = nil
-		edit := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, query.Message.Text)
-		b.Send(edit)
 		var flyId uint64
 		err := mapstructure.Decode(callback.Data, &flyId)
 		if err != nil {
 			return WrongCallbackErr
 		}
-		return b.handleCancelFlyCallback(chat, flyId)
+		return b.handleCancelFlyCallback(ctx, chat, query, flyId)
 	case repeatRequestCallback:
 		coords := model.Coordinate{}
 		err := mapstructure.Decode(callback.Data, &coords)
 		if err != nil {
 			return WrongCallbackErr
 		}
-		return b.handleRepeatRequestCallback(chat, coords)
+		return b.handleRepeatRequestCallback(ctx, chat, query.From, coords)
+	case zoneInfoCallback:
+		var code string
+		err := mapstructure.Decode(callback.Data, &code)
+		if err != nil {
+			return WrongCallbackErr
+		}
+		return b.handleZoneInfoCallback(chat, code)
+	case savePlaceCallback:
+		coords := model.Coordinate{}
+		err := mapstructure.Decode(callback.Data, &coords)
+		if err != nil {
+			return WrongCallbackErr
+		}
+		return b.handleSavePlaceCallback(chat, query.From, coords)
+	case placeCheckCallback, placePlanCallback, placeDeleteCallback:
+		var placeId uint64
+		err := mapstructure.Decode(callback.Data, &placeId)
+		if err != nil {
+			return WrongCallbackErr
+		}
+		switch callback.CallbackType {
+		case placeCheckCallback:
+			return b.handlePlaceCheckCallback(ctx, chat, query.From, placeId)
+		case placePlanCallback:
+			return b.handlePlacePlanCallback(chat, query.From, placeId)
+		default:
+			return b.handlePlaceDeleteCallback(chat, query, placeId)
+		}
+	case sharePlanCallback, editPlanCallback, unsubscribePlanCallback:
+		var flyId uint64
+		err := mapstructure.Decode(callback.Data, &flyId)
+		if err != nil {
+			return WrongCallbackErr
+		}
+		switch callback.CallbackType {
+		case sharePlanCallback:
+			return b.handleSharePlanCallback(ctx, chat, query, flyId)
+		case editPlanCallback:
+			return b.handleEditPlanCallback(ctx, chat, query, flyId)
+		default:
+			return b.handleUnsubscribePlanCallback(chat, query, flyId)
+		}
+	case checklistItemCallback:
+		data := checklistItemData{}
+		err := mapstructure.Decode(callback.Data, &data)
+		if err != nil {
+			return WrongCallbackErr
+		}
+		return b.handleChecklistItemCallback(ctx, chat, query, data)
+	case editChecklistCallback:
+		return b.handleEditChecklistCallback(chat, query.From)
+	case resetChecklistCallback:
+		return b.handleResetChecklistCallback(chat, query.From)
+	case logConfirmCallback, logDetailsCallback, logRejectCallback:
+		var entryId uint64
+		err := mapstructure.Decode(callback.Data, &entryId)
+		if err != nil {
+			return WrongCallbackErr
+		}
+		switch callback.CallbackType {
+		case logConfirmCallback:
+			return b.handleLogConfirmCallback(chat, query, entryId)
+		case logDetailsCallback:
+			return b.handleLogDetailsCallback(chat, query, entryId)
+		default:
+			return b.handleLogRejectCallback(chat, query, entryId)
+		}
+	case logExportCallback:
+		return b.handleLogExportCallback(chat, query.From)
+	case addDroneCallback:
+		return b.handleAddDroneCallback(chat, query.From)
+	case deleteDroneCallback:
+		var droneId uint64
+		err := mapstructure.Decode(callback.Data, &droneId)
+		if err != nil {
+			return WrongCallbackErr
+		}
+		return b.handleDeleteDroneCallback(chat, query, droneId)
+	case permissionDocCallback:
+		var flyId uint64
+		err := mapstructure.Decode(callback.Data, &flyId)
+		if err != nil {
+			return WrongCallbackErr
+		}
+		return b.handlePermissionDocCallback(ctx, chat, query, flyId)
+	case editOperatorCallback:
+		return b.handleEditOperatorCallback(chat, query.From)
+	case resetCalendarCallback:
+		return b.handleResetCalendarCallback(chat, query.From)
+	case importToggleCallback:
+		var index int
+		err := mapstructure.Decode(callback.Data, &index)
+		if err != nil {
+			return WrongCallbackErr
+		}
+		return b.handleImportToggleCallback(chat, query, index)
+	case importConfirmCallback:
+		return b.handleImportConfirmCallback(ctx, chat, query)
+	case importCancelCallback:
+		return b.handleImportCancelCallback(chat, query)
+	case resetAPITokenCallback:
+		return b.handleResetAPITokenCallback(chat, query.From)
+	case coreActionCallback:
+		in := incomingFrom(chat, query.From)
+		if err := mapstructure.Decode(callback.Data, &in.Action); err != nil {
+			return WrongCallbackErr
+		}
+		return b.core.Handle(ctx, in)
 	default:
 		text = "Еще не реализовано:("
 	}
@@ -70,47 +208,51 @@ func (b *Bot) handleCallback(chat *tgbotapi.Chat, query *tgbotapi.CallbackQuery)
 	return err
 }
 
-func (b Bot) handleRepeatRequestCallback(chat *tgbotapi.Chat, coord model.Coordinate) error {
-	text, err := b.getInfoText(coord, 100)
+func (b Bot) handleRepeatRequestCallback(ctx context.Context, chat *tgbotapi.Chat, user *tgbotapi.User, coord model.Coordinate) error {
+	if throttled, err := b.throttleCheck(ctx, chat, user); throttled {
+		return err
+	}
+	text, report, err := b.core.Report(ctx, coord, conversation.NotifyRadius, model.DefaultAltitude())
 	if err != nil {
 		return err
 	}
 	msg := tgbotapi.NewMessage(chat.ID, text)
 	msg.ParseMode = "HTML"
+	if rows := zonesKeyboardRows(report.Zones()); len(rows) > 0 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	}
 
 	if _, err = b.Send(msg); err != nil {
 		return err
 	}
-	return nil
+	return b.sendAreaMap(ctx, chat.ID, report)
 }
-func (b Bot) handlePlanFlyEdNotifications(chat *tgbotapi.Chat, coord model.Coordinate) error {
-	b.planMutex.Lock()
-	defer b.planMutex.Unlock()
-	b.flightPlanningUsers[chat.ID] = &plannedFlightInfo{
-		plan: model.FlyPlan{
-			Data:           model.FlyData{Coordinate: coord, UserId: chat.ID},
-			IsEveryDayPlan: true,
-		},
-		stage: dateTimeSelect,
-	}
-	return b.sendNeedTimeSelect(chat)
+func (b Bot) handlePlanFlyEdNotifications(chat *tgbotapi.Chat, user *tgbotapi.User, coord model.Coordinate) error {
+	return b.newPlanningSession(chat, user, model.FlyPlan{
+		Data:           model.FlyData{Coordinate: coord},
+		IsEveryDayPlan: true,
+	})
 }
 
-func (b Bot) handlePlanFlyCallback(chat *tgbotapi.Chat, coord model.Coordinate) error {
-	b.planMutex.Lock()
-	defer b.planMutex.Unlock()
-	b.flightPlanningUsers[chat.ID] = &plannedFlightInfo{
-		plan: model.FlyPlan{
-			Data:           model.FlyData{Coordinate: coord, UserId: chat.ID},
-			IsEveryDayPlan: false,
-		},
-		stage: dateTimeSelect,
-	}
-	return b.sendNeedDateSelect(chat)
+func (b Bot) handlePlanFlyCallback(chat *tgbotapi.Chat, user *tgbotapi.User, coord model.Coordinate) error {
+	return b.newPlanningSession(chat, user, model.FlyPlan{
+		Data:           model.FlyData{Coordinate: coord},
+		IsEveryDayPlan: false,
+	})
 }
 
-func (b Bot) handleCancelFlyCallback(chat *tgbotapi.Chat, id uint64) error {
-	err := b.planner.CancelFly(id)
+func (b Bot) handleCancelFlyCallback(ctx context.Context, chat *tgbotapi.Chat, query *tgbotapi.CallbackQuery, id uint64) error {
+	plan, err := b.planner.GetFly(id)
+	if err == nil && !b.canManagePlan(ctx, chat, query.From, plan) {
+		_, err := b.bot.Request(tgbotapi.NewCallbackWithAlert(query.ID,
+			"Отключить уведомление может только автор плана или администратор чата"))
+		return err
+	}
+	if query.Message != nil {
+		edit := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, query.Message.Text)
+		b.Send(edit)
+	}
+	err = b.planner.CancelFly(ctx, id)
 	msg := tgbotapi.NewMessage(chat.ID, "Уведомление успешно отключено")
 	if err != nil {
 		msg = tgbotapi.NewMessage(chat.ID, "Это уведомление уже было отключено")
@@ -120,3 +262,21 @@ func (b Bot) handleCancelFlyCallback(chat *tgbotapi.Chat, id uint64) error {
 	return err
 
 }
+
+//canManagePlan управлять планом может автор, а в группе - также администраторы чата
+func (b Bot) canManagePlan(ctx context.Context, chat *tgbotapi.Chat, user *tgbotapi.User, plan model.FlyPlan) bool {
+	if user == nil || plan.Data.UserId == 0 || plan.Data.UserId == user.ID {
+		return true
+	}
+	if chat.IsPrivate() || plan.Data.ChatId != chat.ID {
+		return false
+	}
+	member, err := b.bot.GetChatMember(tgbotapi.GetChatMemberConfig{
+		ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: chat.ID, UserID: user.ID},
+	})
+	if err != nil {
+		logger.FromContext(ctx).Warn("get chat member error", logger.Err(err))
+		return false
+	}
+	return member.IsCreator() || member.IsAdministrator()
+}
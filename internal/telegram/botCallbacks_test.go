@@ -0,0 +1,96 @@
+package telegram
+
+import (
+	"context"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/japersik/safe-flight-bot/model"
+	"testing"
+	"time"
+)
+
+func chatMemberReply(status string) string {
+	return `{"ok": true, "result": {"user": {"id": 2}, "status": "` + status + `"}}`
+}
+
+func TestCanManagePlan(t *testing.T) {
+	group := &tgbotapi.Chat{ID: -100, Type: "group"}
+	private := &tgbotapi.Chat{ID: 2, Type: "private"}
+	groupPlan := model.FlyPlan{Data: model.FlyData{UserId: 1, ChatId: group.ID}}
+	tests := []struct {
+		name   string
+		chat   *tgbotapi.Chat
+		user   *tgbotapi.User
+		plan   model.FlyPlan
+		member string
+		want   bool
+	}{
+		{"author", group, &tgbotapi.User{ID: 1}, groupPlan, "", true},
+		{"plan without author", group, &tgbotapi.User{ID: 2}, model.FlyPlan{}, "", true},
+		{"administrator", group, &tgbotapi.User{ID: 2}, groupPlan, chatMemberReply("administrator"), true},
+		{"creator", group, &tgbotapi.User{ID: 2}, groupPlan, chatMemberReply("creator"), true},
+		{"member", group, &tgbotapi.User{ID: 2}, groupPlan, chatMemberReply("member"), false},
+		{"member lookup failed", group, &tgbotapi.User{ID: 2}, groupPlan,
+			`{"ok": false, "error_code": 400, "description": "Bad Request: user not found"}`, false},
+		{"another user in private chat", private, &tgbotapi.User{ID: 2},
+			model.FlyPlan{Data: model.FlyData{UserId: 1}}, "", false},
+		{"plan of another group", &tgbotapi.Chat{ID: -200, Type: "supergroup"}, &tgbotapi.User{ID: 2},
+			groupPlan, chatMemberReply("administrator"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot, telegram := newTestBot(t)
+			telegram.replies = map[string]string{"getChatMember": tt.member}
+			if got := bot.canManagePlan(context.Background(), tt.chat, tt.user, tt.plan); got != tt.want {
+				t.Errorf("canManagePlan() = %v, want %v", got, tt.want)
+			}
+			//права запрашиваются, только если пользователь не автор и план принадлежит этой группе
+			asked := len(telegram.sent("getChatMember")) > 0
+			if asked != (tt.member != "" && tt.name != "plan of another group") {
+				t.Errorf("getChatMember requested: %v", asked)
+			}
+		})
+	}
+}
+
+func TestHandleCancelFlyCallbackInGroup(t *testing.T) {
+	bot, telegram := newTestBot(t)
+	telegram.replies = map[string]string{"getChatMember": chatMemberReply("member")}
+	group := &tgbotapi.Chat{ID: -100, Type: "group"}
+	flyId, err := bot.planner.PlanFly(model.FlyPlan{FlyDateTime: time.Now().Add(24 * time.Hour),
+		Data: model.FlyData{UserId: 1, ChatId: group.ID, Coordinate: model.Coordinate{Lat: 55.75, Lng: 37.61}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := func(user int64) *tgbotapi.CallbackQuery {
+		return &tgbotapi.CallbackQuery{ID: "cb", From: &tgbotapi.User{ID: user},
+			Message: &tgbotapi.Message{MessageID: 7, Chat: group, Text: "План полёта"}}
+	}
+
+	if err := bot.handleCancelFlyCallback(context.Background(), group, query(2), flyId); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bot.planner.GetFly(flyId); err != nil {
+		t.Fatal("plan canceled by a group member")
+	}
+	if alerts := telegram.sent("answerCallbackQuery"); len(alerts) != 1 || alerts[0].Get("show_alert") != "true" {
+		t.Errorf("member answer = %v", alerts)
+	}
+	if len(telegram.sent("sendMessage")) != 0 || len(telegram.sent("editMessageText")) != 0 {
+		t.Error("group notified about a refused cancel")
+	}
+
+	if err := bot.handleCancelFlyCallback(context.Background(), group, query(1), flyId); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bot.planner.GetFly(flyId); err == nil {
+		t.Fatal("plan not canceled by the author")
+	}
+	//кнопки под сообщением плана убираются
+	if edits := telegram.sent("editMessageText"); len(edits) != 1 || edits[0].Get("reply_markup") != "" {
+		t.Errorf("plan message edits = %v", edits)
+	}
+	sent := telegram.sent("sendMessage")
+	if len(sent) != 1 || sent[0].Get("chat_id") != "-100" || sent[0].Get("text") != "Уведомление успешно отключено" {
+		t.Errorf("author answer = %v", sent)
+	}
+}
@@ -0,0 +1,195 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/japersik/safe-flight-bot/model"
+	"html"
+	"strings"
+	"time"
+)
+
+const (
+	//за сколько до полёта присылается интерактивный чек-лист
+	checklistNotification = -time.Hour
+	maxChecklistItems     = 20
+	maxChecklistItemLen   = 60
+)
+
+//checklistItemData данные кнопки пункта чек-листа, поля сокращены из-за ограничения размера callback_data
+type checklistItemData struct {
+	FlyId uint64 `json:"f" mapstructure:"f"`
+	Item  int    `json:"i" mapstructure:"i"`
+}
+
+func checklistKeyboard(plan model.FlyPlan) tgbotapi.InlineKeyboardMarkup {
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(plan.Checklist))
+	for i, item := range plan.Checklist {
+		callbackItem, _ := json.Marshal(Callback{
+			CallbackType: checklistItemCallback,
+			Data:         checklistItemData{FlyId: plan.FlyId, Item: i},
+		})
+		mark := "⬜ "
+		if item.Done {
+			mark = "✅ "
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(mark+item.Text, string(callbackItem))))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+//sendChecklist интерактивный чек-лист перед полётом
+func (b Bot) sendChecklist(plan model.FlyPlan) error {
+	text := fmt.Sprintf("Предполётный чек-лист для плана №%d. Отмечайте выполненные пункты", plan.FlyId)
+	msg := tgbotapi.NewMessage(plan.Data.NotifyChatId(), text)
+	msg.ReplyMarkup = checklistKeyboard(plan)
+	_, err := b.Send(msg)
+	return err
+}
+
+func (b Bot) handleChecklistItemCallback(ctx context.Context, chat *tgbotapi.Chat, query *tgbotapi.CallbackQuery, data checklistItemData) error {
+	plan, err := b.planner.GetFly(data.FlyId)
+	if err != nil {
+		return b.sendPlanNotExist(chat)
+	}
+	if plan.Data.NotifyChatId() != chat.ID && !b.canManagePlan(ctx, chat, query.From, plan) {
+		_, err := b.bot.Request(tgbotapi.NewCallbackWithAlert(query.ID, "Это чек-лист другого плана"))
+		return err
+	}
+	plan, err = b.planner.ToggleChecklistItem(data.FlyId, data.Item)
+	if err != nil {
+		return WrongCallbackErr
+	}
+	if query.Message == nil {
+		return nil
+	}
+	_, err = b.Send(tgbotapi.NewEditMessageReplyMarkup(chat.ID, query.Message.MessageID, checklistKeyboard(plan)))
+	return err
+}
+
+func (b *Bot) handleChecklistCommand(message *tgbotapi.Message) error {
+	if message.From == nil {
+		return nil
+	}
+	items := b.users.GetChecklist(message.From.ID)
+	text := "<b>Ваш предполётный чек-лист</b>\n"
+	if len(items) == 0 {
+		text += "Чек-лист пуст\n"
+	}
+	for i, item := range items {
+		text += fmt.Sprintf("%d. %s\n", i+1, html.EscapeString(item))
+	}
+	text += "\nЧек-лист добавляется к новым запланированным полётам. За час до полёта бот пришлет его " +
+		"для отметки, а во время взлета напомнит о невыполненных пунктах"
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ParseMode = "HTML"
+	callbackEdit, _ := json.Marshal(Callback{
+		CallbackType: editChecklistCallback,
+		Data:         struct{}{},
+	})
+	callbackReset, _ := json.Marshal(Callback{
+		CallbackType: resetChecklistCallback,
+		Data:         struct{}{},
+	})
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✏️ Изменить", string(callbackEdit)),
+			tgbotapi.NewInlineKeyboardButtonData("↩️ По умолчанию", string(callbackReset))),
+	)
+	_, err := b.Send(msg)
+	return err
+}
+
+func (b Bot) handleEditChecklistCallback(chat *tgbotapi.Chat, user *tgbotapi.User) error {
+	b.planMutex.Lock()
+	b.checklistUsers[newSessionKey(chat, user)] = true
+	b.planMutex.Unlock()
+	text := "Отправьте пункты чек-листа, каждый с новой строки. Отправьте <b>-</b>, чтобы очистить чек-лист"
+	msg := tgbotapi.NewMessage(chat.ID, userMention(chat, user)+text+groupReplyHint(chat))
+	msg.ParseMode = "HTML"
+	callbackCancel, _ := json.Marshal(Callback{
+		CallbackType: cancelChecklistCallback,
+		Data:         struct{}{},
+	})
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Отмена", string(callbackCancel))),
+	)
+	_, err := b.SendWithMarkupToDelete(user, msg)
+	return err
+}
+
+func (b Bot) handleResetChecklistCallback(chat *tgbotapi.Chat, user *tgbotapi.User) error {
+	if err := b.users.SetChecklist(user.ID, model.DefaultChecklist); err != nil {
+		return err
+	}
+	msg := tgbotapi.NewMessage(chat.ID, "Восстановлен чек-лист по умолчанию: /checklist")
+	_, err := b.Send(msg)
+	return err
+}
+
+//checkManageChecklistEditing обработка ввода нового чек-листа
+func (b Bot) checkManageChecklistEditing(ctx context.Context, update tgbotapi.Update) (bool, error) {
+	chat, user := update.FromChat(), update.SentFrom()
+	key := newSessionKey(chat, user)
+	b.planMutex.Lock()
+	_, ok := b.checklistUsers[key]
+	delete(b.checklistUsers, key)
+	b.planMutex.Unlock()
+	if !ok {
+		return false, nil
+	}
+	if update.CallbackQuery != nil {
+		callback := Callback{}
+		if err := json.Unmarshal([]byte(update.CallbackData()), &callback); err == nil &&
+			callback.CallbackType == cancelChecklistCallback {
+			msg := tgbotapi.NewMessage(chat.ID, "Изменение чек-листа отменено")
+			_, err := b.Send(msg)
+			return true, err
+		}
+		return false, nil
+	}
+	if update.Message == nil || update.Message.Text == "" || update.Message.IsCommand() || user == nil {
+		return false, nil
+	}
+	items, err := parseChecklist(update.Message.Text)
+	if err != nil {
+		b.planMutex.Lock()
+		b.checklistUsers[key] = true
+		b.planMutex.Unlock()
+		msg := tgbotapi.NewMessage(chat.ID, err.Error())
+		_, err := b.Send(msg)
+		return true, err
+	}
+	if err := b.users.SetChecklist(user.ID, items); err != nil {
+		return true, err
+	}
+	msg := tgbotapi.NewMessage(chat.ID, "Чек-лист сохранен: /checklist")
+	_, err = b.Send(msg)
+	return true, err
+}
+
+//parseChecklist разбор пунктов чек-листа, по одному в строке
+func parseChecklist(text string) ([]string, error) {
+	items := make([]string, 0)
+	if strings.TrimSpace(text) == "-" {
+		return items, nil
+	}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(line, "-•* "))
+		if line == "" {
+			continue
+		}
+		if len([]rune(line)) > maxChecklistItemLen {
+			return nil, fmt.Errorf("Пункт чек-листа не должен быть длиннее %d символов", maxChecklistItemLen)
+		}
+		items = append(items, line)
+	}
+	if len(items) > maxChecklistItems {
+		return nil, fmt.Errorf("Чек-лист может содержать не более %d пунктов", maxChecklistItems)
+	}
+	return items, nil
+}
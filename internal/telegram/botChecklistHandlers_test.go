@@ -0,0 +1,136 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/japersik/safe-flight-bot/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseChecklist(t *testing.T) {
+	long := strings.Repeat("я", maxChecklistItemLen)
+	tests := []struct {
+		text  string
+		items []string
+		err   bool
+	}{
+		{" - ", []string{}, false},
+		{"Батареи\n\n- Пропеллеры\n• Карта памяти\n* " + long, []string{"Батареи", "Пропеллеры", "Карта памяти", long}, false},
+		{"Батареи\n" + long + "я", nil, true},
+		{strings.Repeat("Пункт\n", maxChecklistItems+1), nil, true},
+	}
+	for _, tt := range tests {
+		items, err := parseChecklist(tt.text)
+		if (err != nil) != tt.err {
+			t.Errorf("%q: error %v", tt.text, err)
+			continue
+		}
+		if strings.Join(items, "|") != strings.Join(tt.items, "|") || (items == nil) != (tt.items == nil) {
+			t.Errorf("%q: items %q, want %q", tt.text, items, tt.items)
+		}
+	}
+}
+
+func TestChecklistItemCallback(t *testing.T) {
+	bot, telegram := newTestBot(t)
+	ctx := context.Background()
+	flyId, err := bot.planner.PlanFly(model.FlyPlan{FlyDateTime: time.Now().Add(time.Hour),
+		Data:      model.FlyData{UserId: 1, Coordinate: model.Coordinate{Lat: 55.75, Lng: 37.61}},
+		Checklist: model.NewChecklist([]string{"Батареи", "Пропеллеры"})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, _ := bot.planner.GetFly(flyId)
+	if err := bot.sendChecklist(plan); err != nil {
+		t.Fatal(err)
+	}
+	sent := telegram.sent("sendMessage")
+	if len(sent) != 1 || sent[0].Get("chat_id") != "1" {
+		t.Fatalf("checklist messages = %v", sent)
+	}
+	if got := buttons(t, sent[0]); strings.Join(got, "|") != "⬜ Батареи|⬜ Пропеллеры" {
+		t.Errorf("checklist buttons = %q", got)
+	}
+
+	private := &tgbotapi.Chat{ID: 1, Type: "private"}
+	query := &tgbotapi.CallbackQuery{ID: "cb", From: &tgbotapi.User{ID: 1}, Message: &tgbotapi.Message{MessageID: 3, Chat: private}}
+	if err := bot.handleChecklistItemCallback(ctx, private, query, checklistItemData{FlyId: flyId, Item: 1}); err != nil {
+		t.Fatal(err)
+	}
+	edits := telegram.sent("editMessageReplyMarkup")
+	if len(edits) != 1 || edits[0].Get("message_id") != "3" {
+		t.Fatalf("keyboard edits = %v", edits)
+	}
+	if got := buttons(t, edits[0]); strings.Join(got, "|") != "⬜ Батареи|✅ Пропеллеры" {
+		t.Errorf("edited buttons = %q", got)
+	}
+	if plan, _ := bot.planner.GetFly(flyId); !plan.Checklist[1].Done || plan.Checklist[0].Done {
+		t.Errorf("stored checklist = %+v", plan.Checklist)
+	}
+
+	if err := bot.handleChecklistItemCallback(ctx, private, query, checklistItemData{FlyId: flyId, Item: 2}); err != WrongCallbackErr {
+		t.Errorf("item out of range: %v", err)
+	}
+
+	//кнопку пересланного в чужой чат чек-листа нажимает не автор
+	other := &tgbotapi.Chat{ID: 2, Type: "private"}
+	query = &tgbotapi.CallbackQuery{ID: "cb2", From: &tgbotapi.User{ID: 2}, Message: &tgbotapi.Message{MessageID: 4, Chat: other}}
+	if err := bot.handleChecklistItemCallback(ctx, other, query, checklistItemData{FlyId: flyId, Item: 0}); err != nil {
+		t.Fatal(err)
+	}
+	if plan, _ := bot.planner.GetFly(flyId); plan.Checklist[0].Done {
+		t.Error("checklist item toggled from another chat")
+	}
+	if alerts := telegram.sent("answerCallbackQuery"); len(alerts) != 1 || alerts[0].Get("text") != "Это чек-лист другого плана" {
+		t.Errorf("foreign chat answer = %v", alerts)
+	}
+}
+
+func TestEditChecklist(t *testing.T) {
+	bot, telegram := newTestBot(t)
+	ctx := context.Background()
+	chat := &tgbotapi.Chat{ID: 1, Type: "private"}
+	user := &tgbotapi.User{ID: 1}
+	message := func(text string) tgbotapi.Update {
+		return tgbotapi.Update{Message: &tgbotapi.Message{From: user, Chat: chat, Text: text}}
+	}
+	if got := bot.users.GetChecklist(user.ID); strings.Join(got, "|") != strings.Join(model.DefaultChecklist, "|") {
+		t.Fatalf("initial checklist = %q", got)
+	}
+
+	if handled, _ := bot.checkManageChecklistEditing(ctx, message("Батареи")); handled {
+		t.Error("message handled without editing session")
+	}
+	if err := bot.handleEditChecklistCallback(chat, user); err != nil {
+		t.Fatal(err)
+	}
+	if handled, err := bot.checkManageChecklistEditing(ctx, message(strings.Repeat("Пункт\n", maxChecklistItems+1))); !handled || err != nil {
+		t.Fatalf("too long checklist: handled %v, %v", handled, err)
+	}
+	if handled, err := bot.checkManageChecklistEditing(ctx, message("-")); !handled || err != nil {
+		t.Fatalf("clear: handled %v, %v", handled, err)
+	}
+	//пустой чек-лист сохраняется, а не заменяется чек-листом по умолчанию
+	if got := bot.users.GetChecklist(user.ID); len(got) != 0 {
+		t.Errorf("cleared checklist = %q", got)
+	}
+	var texts []string
+	for _, params := range telegram.sent("sendMessage") {
+		texts = append(texts, params.Get("text"))
+	}
+	if len(texts) != 3 || !strings.HasPrefix(texts[1], "Чек-лист может содержать") || texts[2] != "Чек-лист сохранен: /checklist" {
+		t.Errorf("answers = %q", texts)
+	}
+
+	data, _ := json.Marshal(Callback{CallbackType: resetChecklistCallback, Data: struct{}{}})
+	if err := bot.handleCallback(ctx, chat, &tgbotapi.CallbackQuery{ID: "cb", From: user, Data: string(data),
+		Message: &tgbotapi.Message{Chat: chat}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := bot.users.GetChecklist(user.ID); strings.Join(got, "|") != strings.Join(model.DefaultChecklist, "|") {
+		t.Errorf("reset checklist = %q", got)
+	}
+}
@@ -0,0 +1,180 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/japersik/safe-flight-bot/internal/conversation"
+	"github.com/japersik/safe-flight-bot/model"
+	"strconv"
+	"strings"
+)
+
+const maxDroneWeight = 150000
+
+//planDroneName название дрона плана для журнала полётов
+func planDroneName(plan model.FlyPlan) string {
+	if plan.Drone == nil {
+		return ""
+	}
+	return plan.Drone.Name
+}
+
+func droneKeyboardRow(drone model.Drone) []tgbotapi.InlineKeyboardButton {
+	callbackDelete, _ := json.Marshal(Callback{
+		CallbackType: deleteDroneCallback,
+		Data:         drone.DroneId,
+	})
+	return tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🗑 Удалить", string(callbackDelete)))
+}
+
+func (b *Bot) handleFleetCommand(message *tgbotapi.Message) error {
+	if message.From == nil {
+		return nil
+	}
+	for _, drone := range b.users.GetFleet(message.From.ID) {
+		msg := tgbotapi.NewMessage(message.Chat.ID, conversation.DroneDescription(drone))
+		msg.ParseMode = "HTML"
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(droneKeyboardRow(drone))
+		if _, err := b.Send(msg); err != nil {
+			return err
+		}
+	}
+	text := "Дроны из парка можно выбрать при планировании полёта: уведомления учтут их ограничения по ветру и температуре"
+	if len(b.users.GetFleet(message.From.ID)) == 0 {
+		text = "В вашем парке нет дронов. " + text
+	}
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	callbackAdd, _ := json.Marshal(Callback{
+		CallbackType: addDroneCallback,
+		Data:         struct{}{},
+	})
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("➕ Добавить дрон", string(callbackAdd))),
+	)
+	_, err := b.Send(msg)
+	return err
+}
+
+func (b Bot) handleAddDroneCallback(chat *tgbotapi.Chat, user *tgbotapi.User) error {
+	b.planMutex.Lock()
+	b.fleetUsers[newSessionKey(chat, user)] = true
+	b.planMutex.Unlock()
+	text := `Отправьте через точку с запятой: название; взлетную массу в граммах; максимальный ветер в м/с; ` +
+		`диапазон рабочих температур; учетный номер. Обязательны только название и масса, например ` +
+		`<b>Mavic 3; 895; 12; -10..40; 0123456</b>`
+	msg := tgbotapi.NewMessage(chat.ID, userMention(chat, user)+text+groupReplyHint(chat))
+	msg.ParseMode = "HTML"
+	callbackCancel, _ := json.Marshal(Callback{
+		CallbackType: cancelAddDroneCallback,
+		Data:         struct{}{},
+	})
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Отмена", string(callbackCancel))),
+	)
+	_, err := b.SendWithMarkupToDelete(user, msg)
+	return err
+}
+
+//checkManageFleetInput обработка ввода описания нового дрона
+func (b Bot) checkManageFleetInput(ctx context.Context, update tgbotapi.Update) (bool, error) {
+	chat, user := update.FromChat(), update.SentFrom()
+	key := newSessionKey(chat, user)
+	b.planMutex.Lock()
+	_, ok := b.fleetUsers[key]
+	delete(b.fleetUsers, key)
+	b.planMutex.Unlock()
+	if !ok {
+		return false, nil
+	}
+	if update.CallbackQuery != nil {
+		callback := Callback{}
+		if err := json.Unmarshal([]byte(update.CallbackData()), &callback); err == nil &&
+			callback.CallbackType == cancelAddDroneCallback {
+			msg := tgbotapi.NewMessage(chat.ID, "Добавление дрона отменено")
+			_, err := b.Send(msg)
+			return true, err
+		}
+		return false, nil
+	}
+	if update.Message == nil || update.Message.Text == "" || update.Message.IsCommand() || user == nil {
+		return false, nil
+	}
+	drone, err := parseDrone(update.Message.Text)
+	if err != nil {
+		b.planMutex.Lock()
+		b.fleetUsers[key] = true
+		b.planMutex.Unlock()
+		msg := tgbotapi.NewMessage(chat.ID, err.Error())
+		_, err := b.Send(msg)
+		return true, err
+	}
+	drone.DroneId, err = b.users.AddDrone(user.ID, drone)
+	if err != nil {
+		return true, err
+	}
+	msg := tgbotapi.NewMessage(chat.ID, "Дрон добавлен в парк: /fleet\n\n"+conversation.DroneDescription(drone))
+	msg.ParseMode = "HTML"
+	_, err = b.Send(msg)
+	return true, err
+}
+
+//parseDrone разбор строки вида "Название; масса; ветер; мин..макс температура; учетный номер"
+func parseDrone(text string) (model.Drone, error) {
+	parts := strings.Split(text, ";")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	drone := model.Drone{Name: parts[0]}
+	if drone.Name == "" || len(parts) < 2 {
+		return drone, fmt.Errorf("Укажите название и взлетную массу дрона в граммах через точку с запятой")
+	}
+	weight, err := strconv.Atoi(parts[1])
+	if err != nil || weight <= 0 || weight > maxDroneWeight {
+		return drone, fmt.Errorf("Масса должна быть целым числом от 1 до %d грамм", maxDroneWeight)
+	}
+	drone.Weight = weight
+	if len(parts) > 2 && parts[2] != "" {
+		wind, err := strconv.ParseFloat(strings.Replace(parts[2], ",", ".", 1), 64)
+		if err != nil || wind <= 0 {
+			return drone, fmt.Errorf("Максимальный ветер должен быть положительным числом в м/с")
+		}
+		drone.MaxWind = wind
+	}
+	if len(parts) > 3 && parts[3] != "" {
+		limits := strings.SplitN(parts[3], "..", 2)
+		if len(limits) != 2 {
+			return drone, fmt.Errorf("Укажите диапазон температур в формате -10..40")
+		}
+		minTemp, err1 := strconv.ParseFloat(strings.TrimSpace(limits[0]), 64)
+		maxTemp, err2 := strconv.ParseFloat(strings.TrimSpace(limits[1]), 64)
+		if err1 != nil || err2 != nil || minTemp >= maxTemp {
+			return drone, fmt.Errorf("Укажите диапазон температур в формате -10..40")
+		}
+		drone.MinTemp, drone.MaxTemp = minTemp, maxTemp
+	}
+	if len(parts) > 4 {
+		drone.RegistrationNumber = parts[4]
+	}
+	return drone, nil
+}
+
+func (b Bot) handleDeleteDroneCallback(chat *tgbotapi.Chat, query *tgbotapi.CallbackQuery, droneId uint64) error {
+	if err := b.users.DeleteDrone(query.From.ID, droneId); err != nil {
+		msg := tgbotapi.NewMessage(chat.ID, "Этот дрон уже удален")
+		_, err := b.Send(msg)
+		return err
+	}
+	//сообщение с кнопкой может быть недоступно, например слишком старое
+	if query.Message == nil {
+		_, err := b.Send(tgbotapi.NewMessage(chat.ID, "Дрон удален"))
+		return err
+	}
+	edit := tgbotapi.NewEditMessageText(chat.ID, query.Message.MessageID, query.Message.Text+"\n\nДрон удален")
+	_, err := b.Send(edit)
+	return err
+}
@@ -0,0 +1,90 @@
+package telegram
+
+import (
+	"context"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/japersik/safe-flight-bot/model"
+	"strings"
+	"testing"
+)
+
+func TestParseDrone(t *testing.T) {
+	valid := map[string]model.Drone{
+		"Mini 2; 249":                          {Name: "Mini 2", Weight: 249},
+		" Mavic 3 ; 895; 12; -10..40; 0123456": {Name: "Mavic 3", Weight: 895, MaxWind: 12, MinTemp: -10, MaxTemp: 40, RegistrationNumber: "0123456"},
+		"Avata; 410; 10,7":                     {Name: "Avata", Weight: 410, MaxWind: 10.7},
+		"Matrice; 6470;; -20 .. 50":            {Name: "Matrice", Weight: 6470, MinTemp: -20, MaxTemp: 50},
+	}
+	for text, want := range valid {
+		drone, err := parseDrone(text)
+		if err != nil || drone != want {
+			t.Errorf("%q: %+v, %v, want %+v", text, drone, err, want)
+		}
+	}
+	invalid := map[string]string{
+		"Mini 2":                 "Укажите название",
+		"; 249":                  "Укажите название",
+		"Mini 2; 0":              "Масса",
+		"Agras; 150001":          "Масса",
+		"Mini 2; 249; штиль":     "Максимальный ветер",
+		"Mini 2; 249; 10; -10":   "Укажите диапазон",
+		"Mini 2; 249; 10; 40..0": "Укажите диапазон",
+	}
+	for text, want := range invalid {
+		if _, err := parseDrone(text); err == nil || !strings.HasPrefix(err.Error(), want) {
+			t.Errorf("%q: error %v, want %q", text, err, want)
+		}
+	}
+}
+
+func TestFleet(t *testing.T) {
+	bot, telegram := newTestBot(t)
+	chat := &tgbotapi.Chat{ID: 1, Type: "private"}
+	user := &tgbotapi.User{ID: 1}
+	command := &tgbotapi.Message{From: user, Chat: chat, Text: "/fleet"}
+	if err := bot.handleFleetCommand(command); err != nil {
+		t.Fatal(err)
+	}
+	sent := telegram.sent("sendMessage")
+	if len(sent) != 1 || !strings.HasPrefix(sent[0].Get("text"), "В вашем парке нет дронов") ||
+		!hasButton(buttons(t, sent[0]), "➕ Добавить дрон") {
+		t.Fatalf("empty fleet answer = %v", sent)
+	}
+
+	if err := bot.handleAddDroneCallback(chat, user); err != nil {
+		t.Fatal(err)
+	}
+	input := tgbotapi.Update{Message: &tgbotapi.Message{From: user, Chat: chat, Text: "Mavic 3; 895; 12"}}
+	if handled, err := bot.checkManageFleetInput(context.Background(), input); !handled || err != nil {
+		t.Fatalf("drone input: handled %v, %v", handled, err)
+	}
+	fleet := bot.users.GetFleet(user.ID)
+	if len(fleet) != 1 || fleet[0].Name != "Mavic 3" || fleet[0].DroneId == 0 {
+		t.Fatalf("fleet = %+v", fleet)
+	}
+
+	before := len(telegram.sent("sendMessage"))
+	if err := bot.handleFleetCommand(command); err != nil {
+		t.Fatal(err)
+	}
+	sent = telegram.sent("sendMessage")[before:]
+	if len(sent) != 2 || !strings.Contains(sent[0].Get("text"), "Mavic 3") || !hasButton(buttons(t, sent[0]), "🗑 Удалить") ||
+		strings.HasPrefix(sent[1].Get("text"), "В вашем парке нет дронов") {
+		t.Errorf("fleet answer = %v", sent)
+	}
+
+	//кнопка удаления старого сообщения приходит без самого сообщения
+	query := &tgbotapi.CallbackQuery{From: user}
+	for _, want := range []string{"Дрон удален", "Этот дрон уже удален"} {
+		if err := bot.handleDeleteDroneCallback(chat, query, fleet[0].DroneId); err != nil {
+			t.Fatal(err)
+		}
+		sent = telegram.sent("sendMessage")
+		if got := sent[len(sent)-1].Get("text"); got != want {
+			t.Errorf("delete answer = %q, want %q", got, want)
+		}
+	}
+	if len(bot.users.GetFleet(user.ID)) != 0 {
+		t.Error("drone not deleted")
+	}
+}
@@ -1,6 +1,7 @@
 package telegram
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -8,20 +9,46 @@ import (
 	"strings"
 )
 
-func (b *Bot) handleCommand(message *tgbotapi.Message) error {
+func (b *Bot) handleCommand(ctx context.Context, message *tgbotapi.Message) error {
 	//fmt.Println(message.Command())
 	switch message.Command() {
 	case "start":
-		return b.handleStartCommand(message)
+		return b.handleStartCommand(ctx, message)
 	case "info":
 		return b.handleInfoCommand(message)
+	case "places":
+		return b.handlePlacesCommand(message)
+	case "checklist":
+		return b.handleChecklistCommand(message)
+	case "logbook":
+		return b.handleLogbookCommand(message)
+	case "fleet":
+		return b.handleFleetCommand(message)
+	case "operator":
+		return b.handleOperatorCommand(message)
+	case "calendar":
+		return b.handleCalendarCommand(message)
+	case "notifications":
+		return b.handleNotificationsCommand(message)
+	case "api":
+		return b.handleAPICommand(message)
+	case "admin", "stats", "broadcast", "plans", "health", "loglevel":
+		return b.handleAdminCommand(ctx, message)
+	case "cancel":
+		in := incomingFrom(message.Chat, message.From)
+		in.Command = message.Command()
+		return b.core.Handle(ctx, in)
 	default:
 		return b.handleUnknownCommand(message)
 	}
 }
 
-func (b *Bot) handleStartCommand(message *tgbotapi.Message) error {
-	msg := tgbotapi.NewMessage(message.Chat.ID, "Привет, отправь мне геолокацию для получения информации о возможности полётов")
+func (b *Bot) handleStartCommand(ctx context.Context, message *tgbotapi.Message) error {
+	if args := message.CommandArguments(); strings.HasPrefix(args, sharePlanPrefix) && message.From != nil {
+		return b.handleSubscribePlan(ctx, message, strings.TrimPrefix(args, sharePlanPrefix))
+	}
+	msg := tgbotapi.NewMessage(message.Chat.ID, "Привет, отправь мне геолокацию, координаты, ссылку на карту "+
+		"или название места для получения информации о возможности полётов")
 	numericKeyboard := tgbotapi.NewReplyKeyboard(
 		tgbotapi.NewKeyboardButtonRow(
 			tgbotapi.NewKeyboardButtonLocation("Проверить мое местоположение"),
@@ -35,7 +62,16 @@ func (b *Bot) handleStartCommand(message *tgbotapi.Message) error {
 func (b *Bot) handleInfoCommand(message *tgbotapi.Message) error {
 	text := fmt.Sprintf("Бот %s (@%s) - <b>не</b>официальный бот для работы с сервисом https://map.avtm.center \n"+
 		"Здесь можно узнать информацию об ограничениях полётов, погоде и "+
-		"запланировать полётную миссию с уведомлением о погоде и ограничениях. ", b.bot.Self.FirstName, b.bot.Self.UserName)
+		"запланировать полётную миссию с уведомлением о погоде и ограничениях. \n\n"+
+		"/places - сохраненные места полётов\n"+
+		"/checklist - предполётный чек-лист\n"+
+		"/logbook - журнал полётов\n"+
+		"/fleet - парк дронов\n"+
+		"/operator - данные эксплуатанта для заявлений\n"+
+		"/calendar - запланированные полёты в календаре\n"+
+		"/notifications - уведомления для новых планов\n"+
+		"/api - доступ к REST API\n\n"+
+		"Отправьте файл календаря .ics, чтобы создать планы полётов из приглашений", b.bot.Self.FirstName, b.bot.Self.UserName)
 
 	msg := tgbotapi.NewMessage(message.Chat.ID, text)
 	msg.ParseMode = "HTML"
@@ -56,13 +92,36 @@ func (b *Bot) handleUnknownCommand(message *tgbotapi.Message) error {
 	return err
 }
 
-func (b *Bot) handleGeoLocationMessage(message *tgbotapi.Message) error {
+func (b *Bot) handleGeoLocationMessage(ctx context.Context, message *tgbotapi.Message) error {
 	coord := model.Coordinate{
 		Lng: message.Location.Longitude,
 		Lat: message.Location.Latitude,
 	}
-	text, err := b.getInfoText(coord, 300)
-	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	if throttled, err := b.throttleCheck(ctx, message.Chat, message.From); throttled {
+		return err
+	}
+	if err := b.sendLocationReport(ctx, message.Chat, coord); err != nil {
+		return err
+	}
+	if message.Location.LivePeriod > 0 {
+		b.handleLiveLocation(ctx, message)
+		return b.sendLiveTrackingStarted(message.Chat)
+	}
+	return nil
+}
+
+//handleTextMessage поиск координат, ссылки на карту или названия места в тексте сообщения
+func (b *Bot) handleTextMessage(ctx context.Context, message *tgbotapi.Message) error {
+	in := incomingFrom(message.Chat, message.From)
+	in.Text = message.Text
+	return b.core.HandleText(ctx, in)
+}
+
+//sendLocationReport отчет о возможности полётов в выбранной точке
+func (b *Bot) sendLocationReport(ctx context.Context, chat *tgbotapi.Chat, coord model.Coordinate) error {
+	coord = coord.Rounded()
+	text, report, err := b.core.Report(ctx, coord, model.DefaultRadius(), model.DefaultAltitude())
+	msg := tgbotapi.NewMessage(chat.ID, text)
 	msg.ParseMode = "HTML"
 
 	callbackPlanFly, _ := json.Marshal(Callback{
@@ -77,7 +136,11 @@ func (b *Bot) handleGeoLocationMessage(message *tgbotapi.Message) error {
 		CallbackType: repeatRequestCallback,
 		Data:         coord,
 	})
-	numericKeyboard := tgbotapi.NewInlineKeyboardMarkup(
+	callbackSavePlace, _ := json.Marshal(Callback{
+		CallbackType: savePlaceCallback,
+		Data:         coord,
+	})
+	numericKeyboard := tgbotapi.NewInlineKeyboardMarkup(append(zonesKeyboardRows(report.Zones()),
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("Запланировать полёт тут ", string(callbackPlanFly))),
 		tgbotapi.NewInlineKeyboardRow(
@@ -85,59 +148,17 @@ func (b *Bot) handleGeoLocationMessage(message *tgbotapi.Message) error {
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("Повторить запрос ", string(callbackRepeatRequest)),
 		),
-	)
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Сохранить место", string(callbackSavePlace)),
+		),
+	)...)
 
 	msg.ReplyMarkup = numericKeyboard
 	//fmt.Println(b.flyClient.GetForecastWeather(coord))
 	_, err = b.Send(msg)
 	//fmt.Println(err)
-	return err
-}
-
-func (b Bot) getInfoText(coord model.Coordinate, radius int) (string, error) {
-	text := fmt.Sprintf("Полученые географические координаты:<b> %f ,%f </b>\n\n", coord.Lng, coord.Lat)
-	locationInfo, err := b.flyClient.LocalityInfoSource.GetLocalityFlyInfo(coord)
 	if err != nil {
-		text += "К сожалению, не удалось получить информацию о населенных пунктах вблизи. \n\n"
-	} else {
-		text += "Точка находится в: " + locationInfo.Name + "\n"
-		if locationInfo.FlyRestriction {
-			text += "Полёты над населёнными пунктами <b>требуют согласования</b> с администрацией\n\n"
-		}
+		return err
 	}
-
-	zoneInfo, err := b.flyClient.CheckConditions(coord, radius)
-	if err == nil {
-		if zoneInfo.NearBoundaryZone {
-			text += "Выбранные координаты находятся в 20-км приграничной зоне. Полёты здесь запрещены\n\n"
-		} else {
-			if len(zoneInfo.ActiveZones) == 0 {
-				text += "В этом месте нет действующих зон ограничений полётов\n\n"
-			} else {
-				text += "В этом месте имеются зоны, <b>ограничивающие полеты</b>: " +
-					strings.Join(zoneInfo.ActiveZones, ", ") + "\n\n"
-			}
-			if len(zoneInfo.InactiveZones) > 0 {
-				text += "Также в данный момент <b>не действуют</b>, но могут стать активными следующие зоны:" +
-					strings.Join(zoneInfo.InactiveZones, ", ") + "\n\n"
-			}
-		}
-	} else {
-		text += "К сожалению, не удалось получить информацию о зонах ограничения полетов от сервера. \n\n"
-	}
-
-	weatherInfo, err := b.flyClient.GetForecastWeather(coord)
-	if err == nil {
-		text += fmt.Sprintf("<b>Информация о погоде:</b> \n")
-		text += fmt.Sprintf("Температура: %v *C\n", weatherInfo.Current.Temperature)
-		text += fmt.Sprintf("Ветер: %v м/c, %v \n", weatherInfo.Current.WindSpeed, weatherInfo.Current.WindDeg)
-		text += fmt.Sprintf("Влажность: %v%% \n", weatherInfo.Current.Humidity)
-		text += fmt.Sprintf("Вероятность выпадения осадков: %v%% \n", weatherInfo.Current.PrecipProb*100)
-		text += fmt.Sprintf("Видимость: %v м\n", weatherInfo.Current.Visibility)
-		text += fmt.Sprintf("Давление: %v мм рт.ст. \n\n", weatherInfo.Current.Pressure)
-	} else {
-		text += "К сожалению, не удалось получить информацию о погоде от сервера. \n\n"
-	}
-
-	return text, nil
+	return b.sendAreaMap(ctx, chat.ID, report)
 }
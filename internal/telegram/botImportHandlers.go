@@ -0,0 +1,273 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/japersik/safe-flight-bot/internal/calendar"
+	"github.com/japersik/safe-flight-bot/internal/conversation"
+	"github.com/japersik/safe-flight-bot/internal/flyPlanner"
+	"github.com/japersik/safe-flight-bot/logger"
+	"github.com/japersik/safe-flight-bot/model"
+	"github.com/zsefvlol/timezonemapper"
+	"html"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	//максимальный размер импортируемого файла календаря
+	maxIcsSize = 1 << 20
+	//максимальное количество событий в одном импорте
+	maxImportEvents = 10
+)
+
+var icsClient = &http.Client{Timeout: 20 * time.Second}
+
+//importEvent событие календаря с определенным местом полёта
+type importEvent struct {
+	event      calendar.Event
+	coordinate model.Coordinate
+	selected   bool
+}
+
+//handleDocumentMessage импорт полётов из приглашений календаря (.ics)
+func (b *Bot) handleDocumentMessage(ctx context.Context, message *tgbotapi.Message) error {
+	doc := message.Document
+	if !strings.HasSuffix(strings.ToLower(doc.FileName), ".ics") && doc.MimeType != "text/calendar" {
+		if !message.Chat.IsPrivate() {
+			return nil
+		}
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Поддерживается импорт полётов только из файлов календаря .ics")
+		_, err := b.Send(msg)
+		return err
+	}
+	if doc.FileSize > maxIcsSize {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Файл календаря слишком большой")
+		_, err := b.Send(msg)
+		return err
+	}
+	events, err := b.downloadCalendar(doc.FileID)
+	if err != nil {
+		logger.FromContext(ctx).Warn("calendar import error", logger.Err(err))
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Не удалось найти события в файле календаря")
+		_, err := b.Send(msg)
+		return err
+	}
+
+	found, unresolved := b.resolveEvents(ctx, events)
+	if len(found) == 0 {
+		text := "В файле нет предстоящих событий"
+		if unresolved > 0 {
+			text = "Не удалось определить место ни для одного предстоящего события. Укажите координаты в поле GEO " +
+				"или адрес в поле LOCATION"
+		}
+		msg := tgbotapi.NewMessage(message.Chat.ID, text)
+		_, err := b.Send(msg)
+		return err
+	}
+	b.planMutex.Lock()
+	b.importSessions[newSessionKey(message.Chat, message.From)] = found
+	b.planMutex.Unlock()
+
+	text := fmt.Sprintf("Найдено предстоящих событий: %d. Выберите, для каких создать планы полётов\n\n", len(found))
+	for i, event := range found {
+		text += fmt.Sprintf("%d. %s\n", i+1, getImportEventText(event))
+	}
+	if unresolved > 0 {
+		text += fmt.Sprintf("\nНе удалось определить место для событий: %d", unresolved)
+	}
+	msg := tgbotapi.NewMessage(message.Chat.ID, userMention(message.Chat, message.From)+text)
+	msg.ParseMode = "HTML"
+	msg.ReplyMarkup = importKeyboard(found)
+	_, err = b.Send(msg)
+	return err
+}
+
+func (b Bot) downloadCalendar(fileId string) ([]calendar.Event, error) {
+	url, err := b.bot.GetFileDirectURL(fileId)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := icsClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("file download status %s", resp.Status)
+	}
+	return calendar.Parse(io.LimitReader(resp.Body, maxIcsSize))
+}
+
+//resolveEvents определение координат предстоящих событий по GEO или геокодированием LOCATION
+func (b Bot) resolveEvents(ctx context.Context, events []calendar.Event) (found []importEvent, unresolved int) {
+	now := time.Now()
+	sort.Slice(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+	for _, event := range events {
+		if len(found) >= maxImportEvents {
+			break
+		}
+		if !event.Daily && event.End.Before(now) {
+			continue
+		}
+		var coordinate model.Coordinate
+		switch {
+		case event.Geo != nil:
+			coordinate = *event.Geo
+		case event.Location != "":
+			places, err := b.flyClient.SearchLocality(ctx, event.Location, 1)
+			if err != nil || len(places) == 0 {
+				unresolved++
+				continue
+			}
+			coordinate = places[0].Coordinate
+		default:
+			unresolved++
+			continue
+		}
+		if event.Floating {
+			loc, _ := time.LoadLocation(timezonemapper.LatLngToTimezoneString(coordinate.Lat, coordinate.Lng))
+			event.InLocation(loc)
+		}
+		found = append(found, importEvent{event: event, coordinate: coordinate.Rounded(), selected: true})
+	}
+	return found, unresolved
+}
+
+func getImportEventText(event importEvent) string {
+	summary := event.event.Summary
+	if summary == "" {
+		summary = "Без названия"
+	}
+	when := event.event.Start.Format("02.01.2006 15:04")
+	if event.event.Daily {
+		when = "ежедневно в " + event.event.Start.Format("15:04")
+	}
+	text := fmt.Sprintf("%s, <b>%s</b>", when, html.EscapeString(summary))
+	if event.event.Location != "" {
+		text += " (" + html.EscapeString(event.event.Location) + ")"
+	}
+	return text + fmt.Sprintf(" - %f, %f", event.coordinate.Lat, event.coordinate.Lng)
+}
+
+func importKeyboard(events []importEvent) tgbotapi.InlineKeyboardMarkup {
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(events)+1)
+	for i, event := range events {
+		callbackToggle, _ := json.Marshal(Callback{
+			CallbackType: importToggleCallback,
+			Data:         i,
+		})
+		mark := "⬜ "
+		if event.selected {
+			mark = "✅ "
+		}
+		title := event.event.Summary
+		if title == "" {
+			title = event.event.Start.Format("02.01.2006 15:04")
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%s%d. %s", mark, i+1, title), string(callbackToggle))))
+	}
+	callbackConfirm, _ := json.Marshal(Callback{
+		CallbackType: importConfirmCallback,
+		Data:         struct{}{},
+	})
+	callbackCancel, _ := json.Marshal(Callback{
+		CallbackType: importCancelCallback,
+		Data:         struct{}{},
+	})
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Создать планы", string(callbackConfirm)),
+		tgbotapi.NewInlineKeyboardButtonData("Отмена", string(callbackCancel))))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+func (b Bot) handleImportToggleCallback(chat *tgbotapi.Chat, query *tgbotapi.CallbackQuery, index int) error {
+	b.planMutex.Lock()
+	events, ok := b.importSessions[newSessionKey(chat, query.From)]
+	if ok && index >= 0 && index < len(events) {
+		events[index].selected = !events[index].selected
+	}
+	b.planMutex.Unlock()
+	if !ok || query.Message == nil {
+		return b.sendImportExpired(chat)
+	}
+	_, err := b.Send(tgbotapi.NewEditMessageReplyMarkup(chat.ID, query.Message.MessageID, importKeyboard(events)))
+	return err
+}
+
+func (b Bot) handleImportConfirmCallback(ctx context.Context, chat *tgbotapi.Chat, query *tgbotapi.CallbackQuery) error {
+	key := newSessionKey(chat, query.From)
+	b.planMutex.Lock()
+	events, ok := b.importSessions[key]
+	delete(b.importSessions, key)
+	b.planMutex.Unlock()
+	if !ok {
+		return b.sendImportExpired(chat)
+	}
+	if query.Message != nil {
+		b.Send(tgbotapi.NewEditMessageReplyMarkup(chat.ID, query.Message.MessageID,
+			tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}))
+	}
+	created := 0
+	for _, event := range events {
+		if !event.selected {
+			continue
+		}
+		plan := model.FlyPlan{
+			Data:           model.FlyData{Coordinate: event.coordinate, UserId: query.From.ID},
+			FlyDateTime:    event.event.Start,
+			IsEveryDayPlan: event.event.Daily,
+			Notifications:  []time.Duration{0},
+		}
+		if !chat.IsPrivate() {
+			plan.Data.ChatId = chat.ID
+		}
+		if !plan.IsEveryDayPlan {
+			plan.Notifications = b.users.GetNotifications(query.From.ID)
+		}
+		_, err := b.core.SavePlan(ctx, &plan)
+		if errors.Is(err, flyPlanner.PlansLimitErr) {
+			_, err = b.Send(tgbotapi.NewMessage(chat.ID, conversation.PlansLimitText))
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if err := b.sendPlanCreated(ctx, chat, plan); err != nil {
+			return err
+		}
+		created++
+	}
+	if created == 0 {
+		msg := tgbotapi.NewMessage(chat.ID, "Не выбрано ни одного события")
+		_, err := b.Send(msg)
+		return err
+	}
+	return nil
+}
+
+func (b Bot) handleImportCancelCallback(chat *tgbotapi.Chat, query *tgbotapi.CallbackQuery) error {
+	b.planMutex.Lock()
+	delete(b.importSessions, newSessionKey(chat, query.From))
+	b.planMutex.Unlock()
+	if query.Message != nil {
+		b.Send(tgbotapi.NewEditMessageReplyMarkup(chat.ID, query.Message.MessageID,
+			tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}))
+	}
+	msg := tgbotapi.NewMessage(chat.ID, "Импорт отменен")
+	_, err := b.Send(msg)
+	return err
+}
+
+func (b Bot) sendImportExpired(chat *tgbotapi.Chat) error {
+	msg := tgbotapi.NewMessage(chat.ID, "Импорт уже завершен. Отправьте файл календаря еще раз")
+	_, err := b.Send(msg)
+	return err
+}
@@ -0,0 +1,88 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/japersik/safe-flight-bot/internal/conversation"
+	"github.com/japersik/safe-flight-bot/internal/coordParser"
+	"github.com/japersik/safe-flight-bot/internal/flyDataClient"
+	"github.com/japersik/safe-flight-bot/internal/metrics"
+	"github.com/japersik/safe-flight-bot/logger"
+	"github.com/japersik/safe-flight-bot/model"
+	"html"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	inlineSearchLimit = 3
+	//время кэширования результатов inline запроса в секундах
+	inlineCacheTime = 60
+)
+
+//handleInlineQuery ответ на inline запрос вида "55.75, 37.61" или "Москва, Парк Горького"
+func (b *Bot) handleInlineQuery(ctx context.Context, query *tgbotapi.InlineQuery) error {
+	text := strings.TrimSpace(query.Query)
+	answer := tgbotapi.InlineConfig{
+		InlineQueryID: query.ID,
+		Results:       []interface{}{},
+		CacheTime:     inlineCacheTime,
+	}
+	if text == "" {
+		answer.SwitchPMText = "Введите координаты или название места"
+		answer.SwitchPMParameter = "inline"
+		_, err := b.bot.Request(answer)
+		return err
+	}
+	if decision := b.checkLimiter.Allow(query.From.ID); !decision.Allowed {
+		metrics.Throttled("checks")
+		answer.SwitchPMText = fmt.Sprintf(inlineThrottledText, decision.RetrySeconds())
+		answer.SwitchPMParameter = "inline"
+		answer.CacheTime = 1
+		answer.IsPersonal = true
+		_, err := b.bot.Request(answer)
+		return err
+	}
+
+	var places []flyDataClient.Place
+	if coord, ok := coordParser.Parse(text); ok {
+		places = []flyDataClient.Place{{Name: text, Coordinate: coord}}
+	} else {
+		var err error
+		places, err = b.flyClient.SearchLocality(ctx, text, inlineSearchLimit)
+		if err != nil {
+			logger.FromContext(ctx).Warn("place search error", logger.Err(err))
+		}
+	}
+
+	reports := make([]flyDataClient.Report, len(places))
+	wg := sync.WaitGroup{}
+	for i, place := range places {
+		wg.Add(1)
+		go func(i int, place flyDataClient.Place) {
+			defer wg.Done()
+			reports[i] = b.flyClient.GetReport(ctx, place.Coordinate, model.DefaultRadius(), model.DefaultAltitude())
+		}(i, place)
+	}
+	wg.Wait()
+
+	for i, report := range reports {
+		verdict := report.Verdict()
+		title := conversation.VerdictIcons[verdict.Level] + " " + verdict.Level.String()
+		article := tgbotapi.NewInlineQueryResultArticleHTML(strconv.Itoa(i), title,
+			fmt.Sprintf("<b>%s</b>\n%s", html.EscapeString(places[i].Name), conversation.ReportText(report)))
+		article.Description = places[i].Name
+		if len(verdict.Reasons) > 0 {
+			article.Description += "\n" + strings.Join(verdict.Reasons, ", ")
+		}
+		answer.Results = append(answer.Results, article)
+	}
+	if len(answer.Results) == 0 {
+		answer.SwitchPMText = "Место не найдено"
+		answer.SwitchPMParameter = "inline"
+	}
+	_, err := b.bot.Request(answer)
+	return err
+}
@@ -0,0 +1,113 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/japersik/safe-flight-bot/internal/flyDataClient"
+	"github.com/japersik/safe-flight-bot/internal/ratelimit"
+	"github.com/japersik/safe-flight-bot/model"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+//searchSource населенные пункты по названию, запоминает запросы поиска
+type searchSource struct {
+	fakeSource
+	places  map[string][]flyDataClient.Place
+	queries []string
+}
+
+func (s *searchSource) SearchLocality(ctx context.Context, query string, limit int) ([]flyDataClient.Place, error) {
+	s.queries = append(s.queries, query)
+	places := s.places[query]
+	if len(places) > limit {
+		places = places[:limit]
+	}
+	return places, nil
+}
+
+func TestHandleInlineQuery(t *testing.T) {
+	moscow := []flyDataClient.Place{
+		{Name: "Москва, Парк Горького", Coordinate: model.Coordinate{Lat: 55.73, Lng: 37.6}},
+		{Name: "Москва, ВДНХ", Coordinate: model.Coordinate{Lat: 55.83, Lng: 37.63}},
+	}
+	tests := []struct {
+		query    string
+		searches int
+		// описания найденных мест, nil - ответ без результатов с кнопкой перехода в бота
+		places   []string
+		switchPM string
+	}{
+		{"  ", 0, nil, "Введите координаты или название места"},
+		{"55.75, 37.61", 0, []string{"55.75, 37.61"}, ""},
+		{"Москва", 1, []string{"Москва, Парк Горького", "Москва, ВДНХ"}, ""},
+		{"Атлантида", 1, nil, "Место не найдено"},
+	}
+	for _, tt := range tests {
+		bot, telegram := newTestBot(t)
+		source := &searchSource{fakeSource: fakeSource{}, places: map[string][]flyDataClient.Place{"Москва": moscow}}
+		bot.flyClient.LocalityInfoSource = source
+		query := &tgbotapi.InlineQuery{ID: "iq", From: &tgbotapi.User{ID: 1}, Query: tt.query}
+		if err := bot.handleInlineQuery(context.Background(), query); err != nil {
+			t.Fatalf("%q: %v", tt.query, err)
+		}
+		if len(source.queries) != tt.searches {
+			t.Errorf("%q: searched %v", tt.query, source.queries)
+		}
+		sent := telegram.sent("answerInlineQuery")
+		if len(sent) != 1 {
+			t.Fatalf("%q: answered %d times", tt.query, len(sent))
+		}
+		if got := sent[0].Get("switch_pm_text"); got != tt.switchPM {
+			t.Errorf("%q: switch_pm_text = %q, want %q", tt.query, got, tt.switchPM)
+		}
+		var results []tgbotapi.InlineQueryResultArticle
+		if err := json.Unmarshal([]byte(sent[0].Get("results")), &results); err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != len(tt.places) {
+			t.Fatalf("%q: %d results, want %d", tt.query, len(results), len(tt.places))
+		}
+		for i, result := range results {
+			if !strings.HasPrefix(result.Description, tt.places[i]) {
+				t.Errorf("%q: result %d description %q", tt.query, i, result.Description)
+			}
+			if result.Title == "" || result.ID != strconv.Itoa(i) {
+				t.Errorf("%q: result %d id %q, title %q", tt.query, i, result.ID, result.Title)
+			}
+		}
+	}
+}
+
+func TestHandleInlineQueryThrottled(t *testing.T) {
+	bot, telegram := newTestBot(t)
+	bot.SetRateLimits(nil, ratelimit.NewUserLimiter(1, 1))
+	query := &tgbotapi.InlineQuery{ID: "iq", From: &tgbotapi.User{ID: 1}, Query: "55.75 37.61"}
+	for i := 0; i < 2; i++ {
+		if err := bot.handleInlineQuery(context.Background(), query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sent := telegram.sent("answerInlineQuery")
+	if len(sent) != 2 {
+		t.Fatalf("answered %d times", len(sent))
+	}
+	if sent[0].Get("results") == "[]" {
+		t.Error("first query was throttled")
+	}
+	throttled := sent[1]
+	if throttled.Get("results") != "[]" || throttled.Get("is_personal") != "true" ||
+		!strings.HasPrefix(throttled.Get("switch_pm_text"), "Слишком много запросов") {
+		t.Errorf("second query answer = %v", throttled)
+	}
+	//пустой запрос не расходует лимит проверок
+	query.Query = ""
+	if err := bot.handleInlineQuery(context.Background(), query); err != nil {
+		t.Fatal(err)
+	}
+	if got := telegram.sent("answerInlineQuery")[2].Get("switch_pm_text"); got != "Введите координаты или название места" {
+		t.Errorf("empty query answer = %q", got)
+	}
+}
@@ -0,0 +1,181 @@
+package telegram
+
+import (
+	"context"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/japersik/safe-flight-bot/internal/conversation"
+	"github.com/japersik/safe-flight-bot/logger"
+	"github.com/japersik/safe-flight-bot/model"
+	"time"
+)
+
+const (
+	//радиус, при пересечении которого с зоной пилот считается приближающимся к ней
+	liveApproachRadius = 1000
+	//радиус, при пересечении которого с зоной пилот считается находящимся в ней
+	liveInsideRadius = 50
+	//расстояние в метрах, после смещения на которое выполняется новая проверка
+	liveCheckDistance = 150
+	//минимальный интервал между проверками одной трансляции
+	liveCheckInterval = 20 * time.Second
+	//ключ приграничной зоны в списке оповещений
+	boundaryZoneKey = "boundary"
+)
+
+//liveAlertLevel степень близости к зоне
+type liveAlertLevel int
+
+const (
+	alertNone liveAlertLevel = iota
+	alertApproach
+	alertInside
+)
+
+//liveSession состояние отслеживания трансляции геопозиции
+type liveSession struct {
+	messageId     int
+	expires       time.Time
+	lastCheck     model.Coordinate
+	lastCheckTime time.Time
+	checking      bool
+	alerts        map[string]liveAlertLevel
+}
+
+//handleLiveLocation обработка начала трансляции геопозиции и ее обновлений (EditedMessage)
+func (b *Bot) handleLiveLocation(ctx context.Context, message *tgbotapi.Message) {
+	if message.Location == nil || message.From == nil {
+		return
+	}
+	coord := model.Coordinate{Lng: message.Location.Longitude, Lat: message.Location.Latitude}
+	key := newSessionKey(message.Chat, message.From)
+
+	b.liveMutex.Lock()
+	session, ok := b.liveSessions[key]
+	if !ok || session.messageId != message.MessageID {
+		expires := message.Time().Add(time.Duration(message.Location.LivePeriod) * time.Second)
+		//обновление уже закончившейся трансляции приходит после удаления ее сессии и не должно начинать новую
+		if message.Location.LivePeriod == 0 || time.Now().After(expires) {
+			b.liveMutex.Unlock()
+			return
+		}
+		session = &liveSession{
+			messageId: message.MessageID,
+			expires:   expires,
+			alerts:    map[string]liveAlertLevel{},
+		}
+		b.liveSessions[key] = session
+		//Telegram не сообщает об окончании трансляции, поэтому сессия удаляется по истечении ее срока
+		time.AfterFunc(time.Until(session.expires), func() { b.removeLiveSession(key, session) })
+	} else if time.Now().After(session.expires) {
+		delete(b.liveSessions, key)
+		b.liveMutex.Unlock()
+		return
+	} else if session.checking || time.Since(session.lastCheckTime) < liveCheckInterval ||
+		model.Distance(session.lastCheck, coord) < liveCheckDistance {
+		b.liveMutex.Unlock()
+		return
+	}
+	session.checking = true
+	session.lastCheck = coord
+	session.lastCheckTime = time.Now()
+	b.liveMutex.Unlock()
+
+	levels, zones, err := b.checkLivePosition(ctx, coord)
+
+	b.liveMutex.Lock()
+	session.checking = false
+	if err != nil {
+		b.liveMutex.Unlock()
+		logger.FromContext(ctx).Warn("live location check error", logger.Err(err))
+		return
+	}
+	text := ""
+	alertZones := make([]model.Zone, 0)
+	for code, level := range levels {
+		if level <= session.alerts[code] {
+			continue
+		}
+		if code == boundaryZoneKey {
+			text += liveAlertText(level, "20-км приграничной зоне")
+			continue
+		}
+		zone := zones[code]
+		alertZones = append(alertZones, zone)
+		text += liveAlertText(level, "зоне "+conversation.ZoneTitle(zone))
+	}
+	session.alerts = levels
+	b.liveMutex.Unlock()
+
+	if text == "" {
+		return
+	}
+	msg := tgbotapi.NewMessage(message.Chat.ID, userMention(message.Chat, message.From)+"<b>Внимание!</b>\n"+text)
+	msg.ParseMode = "HTML"
+	msg.ReplyToMessageID = session.messageId
+	if rows := zonesKeyboardRows(alertZones); len(rows) > 0 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	}
+	if _, err := b.Send(msg); err != nil {
+		logger.FromContext(ctx).Warn("live location alert error", logger.Err(err))
+	}
+}
+
+//removeLiveSession удаляет сессию, если она не заменена новой трансляцией
+func (b *Bot) removeLiveSession(key sessionKey, session *liveSession) {
+	b.liveMutex.Lock()
+	defer b.liveMutex.Unlock()
+	if b.liveSessions[key] == session {
+		delete(b.liveSessions, key)
+	}
+}
+
+//checkLivePosition определяет действующие зоны вблизи пилота. Проверка малым радиусом выполняется
+//только если рядом найдены зоны, чтобы не делать лишних запросов
+func (b *Bot) checkLivePosition(ctx context.Context, coord model.Coordinate) (map[string]liveAlertLevel, map[string]model.Zone, error) {
+	levels := map[string]liveAlertLevel{}
+	zones := map[string]model.Zone{}
+	near, err := b.flyClient.CheckConditions(ctx, coord, liveApproachRadius, model.DefaultAltitude())
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, zone := range near.ActiveZones {
+		levels[zone.Code] = alertApproach
+		zones[zone.Code] = zone
+	}
+	if near.NearBoundaryZone {
+		levels[boundaryZoneKey] = alertApproach
+	}
+	if len(levels) == 0 {
+		return levels, zones, nil
+	}
+	b.core.CacheZones(near.ActiveZones)
+	inside, err := b.flyClient.CheckConditions(ctx, coord, liveInsideRadius, model.DefaultAltitude())
+	if err != nil {
+		return nil, nil, err
+	}
+	b.core.CacheZones(inside.ActiveZones)
+	for _, zone := range inside.ActiveZones {
+		levels[zone.Code] = alertInside
+		if _, ok := zones[zone.Code]; !ok {
+			zones[zone.Code] = zone
+		}
+	}
+	if inside.NearBoundaryZone {
+		levels[boundaryZoneKey] = alertInside
+	}
+	return levels, zones, nil
+}
+
+func liveAlertText(level liveAlertLevel, name string) string {
+	if level == alertInside {
+		return "⛔ Вы находитесь в " + name + "\n"
+	}
+	return "⚠️ Вы приближаетесь к " + name + " (менее 1 км)\n"
+}
+
+func (b *Bot) sendLiveTrackingStarted(chat *tgbotapi.Chat) error {
+	msg := tgbotapi.NewMessage(chat.ID, "Слежу за трансляцией геопозиции: сообщу, если вы приблизитесь "+
+		"к действующей зоне ограничений или приграничной зоне")
+	_, err := b.Send(msg)
+	return err
+}
@@ -0,0 +1,107 @@
+package telegram
+
+import (
+	"context"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/japersik/safe-flight-bot/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+//circleZoneSource одна действующая круглая зона, пересечение определяется по расстоянию до центра
+type circleZoneSource struct {
+	fakeSource
+	zone   model.Zone
+	center model.Coordinate
+	radius int
+	checks []int
+}
+
+func (s *circleZoneSource) CheckConditions(ctx context.Context, coordinate model.Coordinate, radius int, altitude int) (model.Condition, error) {
+	s.checks = append(s.checks, radius)
+	condition := model.Condition{ActiveZones: []model.Zone{}}
+	if model.Distance(coordinate, s.center) < float64(radius+s.radius) {
+		condition.ActiveZones = append(condition.ActiveZones, s.zone)
+	}
+	return condition, nil
+}
+
+func TestHandleLiveLocation(t *testing.T) {
+	bot, telegram := newTestBot(t)
+	source := &circleZoneSource{zone: model.Zone{Code: "UUP123", Name: "Парк"},
+		center: model.Coordinate{Lat: 55.75, Lng: 37.61}, radius: 300}
+	bot.flyClient.ZoneInfoSource = source
+	chat := &tgbotapi.Chat{ID: 1, Type: "private"}
+	user := &tgbotapi.User{ID: 1}
+	live := func(lat float64) *tgbotapi.Message {
+		return &tgbotapi.Message{MessageID: 20, From: user, Chat: chat, Date: int(time.Now().Unix()),
+			Location: &tgbotapi.Location{Latitude: lat, Longitude: 37.61, LivePeriod: 900}}
+	}
+	//проверка по следующему обновлению не ждет интервала между проверками
+	skipInterval := func() {
+		bot.liveMutex.Lock()
+		bot.liveSessions[newSessionKey(chat, user)].lastCheckTime = time.Time{}
+		bot.liveMutex.Unlock()
+	}
+	ctx := context.Background()
+	steps := []struct {
+		name   string
+		lat    float64
+		skip   bool
+		checks []int
+		alert  string
+	}{
+		{"start near the zone", 55.76, false, []int{1000, 50}, "⚠️ Вы приближаетесь к зоне UUP123 «Парк»"},
+		{"update before the interval", 55.7525, false, nil, ""},
+		{"inside the zone", 55.7525, true, []int{1000, 50}, "⛔ Вы находитесь в зоне UUP123 «Парк»"},
+		{"moved less than the check distance", 55.7515, true, nil, ""},
+		{"still inside", 55.7505, true, []int{1000, 50}, ""},
+		{"left the zone", 55.78, true, []int{1000}, ""},
+		{"approached again", 55.759, true, []int{1000, 50}, "⚠️ Вы приближаетесь"},
+	}
+	for _, step := range steps {
+		if step.skip {
+			skipInterval()
+		}
+		source.checks = nil
+		before := len(telegram.sent("sendMessage"))
+		bot.handleLiveLocation(ctx, live(step.lat))
+		if len(source.checks) != len(step.checks) {
+			t.Errorf("%s: checks with radius %v, want %v", step.name, source.checks, step.checks)
+		}
+		sent := telegram.sent("sendMessage")[before:]
+		if step.alert == "" {
+			if len(sent) != 0 {
+				t.Errorf("%s: unexpected alert %q", step.name, sent[0].Get("text"))
+			}
+			continue
+		}
+		if len(sent) != 1 {
+			t.Fatalf("%s: %d alerts", step.name, len(sent))
+		}
+		if !strings.Contains(sent[0].Get("text"), step.alert) || sent[0].Get("reply_to_message_id") != "20" {
+			t.Errorf("%s: alert %v", step.name, sent[0])
+		}
+	}
+}
+
+func TestHandleLiveLocationIgnoresStaticLocation(t *testing.T) {
+	bot, _ := newTestBot(t)
+	source := &circleZoneSource{center: model.Coordinate{Lat: 55.75, Lng: 37.61}, radius: 300}
+	bot.flyClient.ZoneInfoSource = source
+	chat := &tgbotapi.Chat{ID: 1, Type: "private"}
+	bot.handleLiveLocation(context.Background(), &tgbotapi.Message{MessageID: 21, From: &tgbotapi.User{ID: 1}, Chat: chat,
+		Location: &tgbotapi.Location{Latitude: 55.75, Longitude: 37.61}})
+	if len(source.checks) != 0 || len(bot.liveSessions) != 0 {
+		t.Errorf("static location started tracking: checks %v", source.checks)
+	}
+
+	//обновление закончившейся трансляции не начинает отслеживание заново
+	expired := &tgbotapi.Message{MessageID: 22, From: &tgbotapi.User{ID: 1}, Chat: chat,
+		Date: int(time.Now().Add(-time.Hour).Unix()), Location: &tgbotapi.Location{Latitude: 55.75, Longitude: 37.61, LivePeriod: 60}}
+	bot.handleLiveLocation(context.Background(), expired)
+	if len(source.checks) != 0 || len(bot.liveSessions) != 0 {
+		t.Errorf("expired live location checked: %v", source.checks)
+	}
+}
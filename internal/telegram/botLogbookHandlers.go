@@ -0,0 +1,264 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/japersik/safe-flight-bot/internal/conversation"
+	"github.com/japersik/safe-flight-bot/internal/flyDataClient"
+	"github.com/japersik/safe-flight-bot/internal/logbook"
+	"github.com/japersik/safe-flight-bot/logger"
+	"github.com/japersik/safe-flight-bot/model"
+	"github.com/zsefvlol/timezonemapper"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+)
+
+//количество последних полётов, выводимых в /logbook
+const logbookLastFlights = 5
+
+var flightTimeRegexp = regexp.MustCompile(`^(\d{1,2}:\d{2})\s*-\s*(\d{1,2}:\d{2})$`)
+
+func logEntryCallback(callbackType CallbackType, entryId uint64) string {
+	callback, _ := json.Marshal(Callback{
+		CallbackType: callbackType,
+		Data:         entryId,
+	})
+	return string(callback)
+}
+
+//askFlightHappened по окончании окна полёта создает черновик записи журнала и спрашивает, состоялся ли полёт
+func (b Bot) askFlightHappened(plan model.FlyPlan, report flyDataClient.Report) error {
+	from, to := plan.Window(time.Now())
+	entryId, err := b.logbook.AddEntry(model.LogEntry{
+		UserId:     plan.Data.UserId,
+		FlyId:      plan.FlyId,
+		Coordinate: plan.Data.Coordinate,
+		Start:      from,
+		End:        to,
+		Conditions: conversation.FlightConditions(report, from),
+		Drone:      planDroneName(plan),
+	})
+	if err != nil {
+		return err
+	}
+	text := fmt.Sprintf("Полёт по плану №%d состоялся? Ответ будет записан в журнал полётов /logbook", plan.FlyId)
+	msg := tgbotapi.NewMessage(plan.Data.NotifyChatId(), text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Да, как планировалось", logEntryCallback(logConfirmCallback, entryId))),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✏️ Да, указать детали", logEntryCallback(logDetailsCallback, entryId)),
+			tgbotapi.NewInlineKeyboardButtonData("❌ Нет", logEntryCallback(logRejectCallback, entryId))),
+	)
+	_, err = b.Send(msg)
+	return err
+}
+
+//getLogEntry запись журнала, которой может управлять только автор плана
+func (b Bot) getLogEntry(chat *tgbotapi.Chat, query *tgbotapi.CallbackQuery, entryId uint64) (model.LogEntry, bool) {
+	entry, err := b.logbook.GetEntry(entryId)
+	if err != nil {
+		b.Send(tgbotapi.NewMessage(chat.ID, "Запись журнала не найдена"))
+		return entry, false
+	}
+	if query.From != nil && query.From.ID != entry.UserId {
+		b.bot.Request(tgbotapi.NewCallbackWithAlert(query.ID, "Отметить полёт может только автор плана"))
+		return entry, false
+	}
+	return entry, true
+}
+
+func (b Bot) handleLogConfirmCallback(chat *tgbotapi.Chat, query *tgbotapi.CallbackQuery, entryId uint64) error {
+	entry, ok := b.getLogEntry(chat, query, entryId)
+	if !ok {
+		return nil
+	}
+	entry.Confirmed = true
+	if err := b.logbook.UpdateEntry(entry); err != nil {
+		return err
+	}
+	return b.editLogQuestion(chat, query, getLogEntryText(entry))
+}
+
+func (b Bot) handleLogRejectCallback(chat *tgbotapi.Chat, query *tgbotapi.CallbackQuery, entryId uint64) error {
+	if _, ok := b.getLogEntry(chat, query, entryId); !ok {
+		return nil
+	}
+	b.logbook.DeleteEntry(entryId)
+	return b.editLogQuestion(chat, query, "Полёт не записан в журнал")
+}
+
+func (b Bot) handleLogDetailsCallback(chat *tgbotapi.Chat, query *tgbotapi.CallbackQuery, entryId uint64) error {
+	if _, ok := b.getLogEntry(chat, query, entryId); !ok {
+		return nil
+	}
+	b.planMutex.Lock()
+	b.logbookUsers[newSessionKey(chat, query.From)] = entryId
+	b.planMutex.Unlock()
+	text := "Отправьте фактическое местное время начала и окончания полёта, дрон и заметки через точку с запятой, " +
+		"например <b>10:05-10:40; Mavic 3; съемка поля</b>. Дрон и заметки можно не указывать. Для отмены: /cancel"
+	msg := tgbotapi.NewMessage(chat.ID, userMention(chat, query.From)+text+groupReplyHint(chat))
+	msg.ParseMode = "HTML"
+	_, err := b.SendWithMarkupToDelete(query.From, msg)
+	return err
+}
+
+func (b Bot) editLogQuestion(chat *tgbotapi.Chat, query *tgbotapi.CallbackQuery, text string) error {
+	if query.Message == nil {
+		msg := tgbotapi.NewMessage(chat.ID, text)
+		msg.ParseMode = "HTML"
+		_, err := b.Send(msg)
+		return err
+	}
+	edit := tgbotapi.NewEditMessageText(chat.ID, query.Message.MessageID, query.Message.Text+"\n\n"+html.EscapeString(text))
+	_, err := b.Send(edit)
+	return err
+}
+
+//checkManageLogbookInput обработка ввода деталей полёта
+func (b Bot) checkManageLogbookInput(ctx context.Context, update tgbotapi.Update) (bool, error) {
+	chat := update.FromChat()
+	key := newSessionKey(chat, update.SentFrom())
+	b.planMutex.Lock()
+	entryId, ok := b.logbookUsers[key]
+	b.planMutex.Unlock()
+	if !ok || update.Message == nil || update.Message.Text == "" {
+		return false, nil
+	}
+	if update.Message.IsCommand() {
+		if update.Message.Command() != "cancel" {
+			return false, nil
+		}
+		b.planMutex.Lock()
+		delete(b.logbookUsers, key)
+		b.planMutex.Unlock()
+		msg := tgbotapi.NewMessage(chat.ID, "Ввод деталей полёта отменён")
+		_, err := b.Send(msg)
+		return true, err
+	}
+	entry, err := b.logbook.GetEntry(entryId)
+	if err != nil {
+		b.planMutex.Lock()
+		delete(b.logbookUsers, key)
+		b.planMutex.Unlock()
+		return false, nil
+	}
+	if err := parseLogDetails(update.Message.Text, &entry); err != nil {
+		msg := tgbotapi.NewMessage(chat.ID, err.Error())
+		_, err := b.Send(msg)
+		return true, err
+	}
+	entry.Confirmed = true
+	if err := b.logbook.UpdateEntry(entry); err != nil {
+		return true, err
+	}
+	b.planMutex.Lock()
+	delete(b.logbookUsers, key)
+	b.planMutex.Unlock()
+	msg := tgbotapi.NewMessage(chat.ID, getLogEntryText(entry))
+	_, err = b.Send(msg)
+	return true, err
+}
+
+//parseLogDetails разбор строки вида "10:05-10:40; Mavic 3; заметки"
+func parseLogDetails(text string, entry *model.LogEntry) error {
+	parts := strings.SplitN(text, ";", 3)
+	match := flightTimeRegexp.FindStringSubmatch(strings.TrimSpace(parts[0]))
+	if match == nil {
+		return fmt.Errorf("Укажите время в формате 10:05-10:40")
+	}
+	timezone := timezonemapper.LatLngToTimezoneString(entry.Coordinate.Lat, entry.Coordinate.Lng)
+	loc, _ := time.LoadLocation(timezone)
+	day := entry.Start.In(loc)
+	start, err1 := time.ParseInLocation("15:04", match[1], loc)
+	end, err2 := time.ParseInLocation("15:04", match[2], loc)
+	if err1 != nil || err2 != nil {
+		return fmt.Errorf("Укажите время в формате 10:05-10:40")
+	}
+	entry.Start = time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), 0, 0, loc)
+	entry.End = time.Date(day.Year(), day.Month(), day.Day(), end.Hour(), end.Minute(), 0, 0, loc)
+	if entry.End.Before(entry.Start) {
+		entry.End = entry.End.AddDate(0, 0, 1)
+	}
+	if len(parts) > 1 {
+		entry.Drone = strings.TrimSpace(parts[1])
+	}
+	if len(parts) > 2 {
+		entry.Notes = strings.TrimSpace(parts[2])
+	}
+	return nil
+}
+
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	return fmt.Sprintf("%d ч %d мин", int(d.Hours()), int(d.Minutes())%60)
+}
+
+func getLogEntryText(entry model.LogEntry) string {
+	text := fmt.Sprintf("Полёт записан в журнал: %s, %s", entry.Start.Format("02.01.2006 15:04"), formatDuration(entry.Duration()))
+	if entry.Drone != "" {
+		text += ", " + entry.Drone
+	}
+	return text + ". Журнал полётов: /logbook"
+}
+
+func (b *Bot) handleLogbookCommand(message *tgbotapi.Message) error {
+	if message.From == nil {
+		return nil
+	}
+	entries := b.logbook.UserEntries(message.From.ID)
+	if len(entries) == 0 {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Журнал полётов пуст. После запланированного полёта бот "+
+			"спросит, состоялся ли он, и запишет полёт в журнал")
+		_, err := b.Send(msg)
+		return err
+	}
+	var total time.Duration
+	for _, entry := range entries {
+		total += entry.Duration()
+	}
+	text := fmt.Sprintf("<b>Журнал полётов</b>\nВсего полётов: %d, общее время: %s\n\n", len(entries), formatDuration(total))
+	for _, month := range logbook.MonthTotals(entries) {
+		text += fmt.Sprintf("%s: %d, %s\n", month.Month.Format("01.2006"), month.Flights, formatDuration(month.Duration))
+	}
+	text += "\n<b>Последние полёты:</b>\n"
+	for i := len(entries) - 1; i >= 0 && i >= len(entries)-logbookLastFlights; i-- {
+		entry := entries[i]
+		text += fmt.Sprintf("%s, %s", entry.Start.Format("02.01.2006 15:04"), formatDuration(entry.Duration()))
+		if entry.Drone != "" {
+			text += ", " + html.EscapeString(entry.Drone)
+		}
+		if entry.Notes != "" {
+			text += " - " + html.EscapeString(entry.Notes)
+		}
+		text += "\n"
+	}
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ParseMode = "HTML"
+	callbackExport, _ := json.Marshal(Callback{
+		CallbackType: logExportCallback,
+		Data:         struct{}{},
+	})
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📄 Экспорт CSV", string(callbackExport))),
+	)
+	_, err := b.Send(msg)
+	return err
+}
+
+func (b Bot) handleLogExportCallback(chat *tgbotapi.Chat, user *tgbotapi.User) error {
+	buf := &bytes.Buffer{}
+	if err := logbook.WriteCSV(buf, b.logbook.UserEntries(user.ID)); err != nil {
+		logger.Error("logbook export error", logger.Err(err))
+		return err
+	}
+	doc := tgbotapi.NewDocument(chat.ID, tgbotapi.FileBytes{Name: "logbook.csv", Bytes: buf.Bytes()})
+	_, err := b.Send(doc)
+	return err
+}
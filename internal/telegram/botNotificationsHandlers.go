@@ -0,0 +1,59 @@
+package telegram
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/japersik/safe-flight-bot/internal/conversation"
+	"github.com/japersik/safe-flight-bot/model"
+	"strings"
+	"time"
+)
+
+//handleNotificationsCommand просмотр и изменение смещений уведомлений для новых планов:
+///notifications -24h -3h 0 2h, /notifications default
+func (b *Bot) handleNotificationsCommand(message *tgbotapi.Message) error {
+	if message.From == nil {
+		return nil
+	}
+	args := strings.Fields(message.CommandArguments())
+	text := ""
+	switch {
+	case len(args) == 0:
+		text = "Уведомления для новых планов полётов: " + formatOffsets(b.users.GetNotifications(message.From.ID)) +
+			"\n\nЧтобы изменить, отправьте смещения относительно времени полёта, например " +
+			"<b>/notifications -24h -3h -1h 0 2h</b>. Вернуть настройки по умолчанию: <b>/notifications default</b>"
+	case len(args) == 1 && args[0] == "default":
+		if err := b.users.SetNotifications(message.From.ID, nil); err != nil {
+			return err
+		}
+		text = "Восстановлены уведомления по умолчанию: " + formatOffsets(b.users.GetNotifications(message.From.ID))
+	default:
+		offsets, err := model.ParseNotifications(args)
+		if err != nil {
+			text = conversation.NotificationsErrorText(err)
+			break
+		}
+		if err := b.users.SetNotifications(message.From.ID, offsets); err != nil {
+			return err
+		}
+		text = "Уведомления для новых планов сохранены: " + formatOffsets(offsets)
+	}
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ParseMode = "HTML"
+	_, err := b.Send(msg)
+	return err
+}
+
+func formatOffsets(offsets []time.Duration) string {
+	parts := make([]string, 0, len(offsets))
+	for _, offset := range offsets {
+		switch {
+		case offset == 0:
+			parts = append(parts, "в момент начала")
+		case offset < 0:
+			parts = append(parts, "за "+formatDuration(-offset))
+		default:
+			parts = append(parts, "через "+formatDuration(offset))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,194 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/japersik/safe-flight-bot/internal/permissionDoc"
+	"github.com/japersik/safe-flight-bot/logger"
+	"github.com/japersik/safe-flight-bot/model"
+	"html"
+	"strings"
+)
+
+const operatorPrivateText = "Данные эксплуатанта просматриваются и изменяются только в личном чате с ботом"
+
+func permissionDocKeyboardRow(flyId uint64) []tgbotapi.InlineKeyboardButton {
+	callbackDoc, _ := json.Marshal(Callback{
+		CallbackType: permissionDocCallback,
+		Data:         flyId,
+	})
+	return tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("📄 Заявление в администрацию", string(callbackDoc)))
+}
+
+//handlePermissionDocCallback заявление на полёт над населенным пунктом по данным плана и профиля эксплуатанта
+func (b Bot) handlePermissionDocCallback(ctx context.Context, chat *tgbotapi.Chat, query *tgbotapi.CallbackQuery, flyId uint64) error {
+	plan, err := b.planner.GetFly(flyId)
+	if err != nil {
+		return b.sendPlanNotExist(chat)
+	}
+	if !b.canManagePlan(ctx, chat, query.From, plan) {
+		_, err := b.bot.Request(tgbotapi.NewCallbackWithAlert(query.ID,
+			"Заявление может сформировать только автор плана или администратор чата"))
+		return err
+	}
+	//заявление подается от имени автора плана и содержит его персональные данные, поэтому отправляется
+	//только ему в личные сообщения, даже если кнопку нажал администратор группы
+	ownerId := plan.Data.UserId
+	if ownerId == 0 {
+		ownerId = query.From.ID
+	}
+	operator, ok := b.users.GetOperator(ownerId)
+	if !ok {
+		text := "Для заявления нужны данные эксплуатанта. Заполните их в личном чате с ботом командой /operator " +
+			"и нажмите кнопку еще раз"
+		if ownerId != query.From.ID {
+			text = "Автор плана не заполнил данные эксплуатанта (/operator в личном чате с ботом)"
+		}
+		msg := tgbotapi.NewMessage(chat.ID, text)
+		_, err := b.Send(msg)
+		return err
+	}
+	locality := ""
+	if info, err := b.flyClient.GetLocalityFlyInfo(ctx, plan.Data.Coordinate); err != nil {
+		logger.FromContext(ctx).Warn("locality info error", logger.Err(err))
+	} else if info != nil {
+		locality = info.Name
+	}
+	request := permissionDoc.NewRequest(plan, operator, locality)
+	data, err := permissionDoc.Generate(request)
+	if err != nil {
+		return err
+	}
+	doc := tgbotapi.NewDocument(ownerId, tgbotapi.FileBytes{Name: request.FileName(), Bytes: data})
+	doc.Caption = fmt.Sprintf("Заявление на полёт по плану №%d. Проверьте данные, укажите цель полёта, "+
+		"подпишите и направьте в администрацию населенного пункта", plan.FlyId)
+	_, err = b.Send(doc)
+	if chat.ID == ownerId {
+		return err
+	}
+	text := "Заявление отправлено автору плана в личные сообщения"
+	if err != nil {
+		logger.FromContext(ctx).Warn("permission document private send error", logger.Err(err))
+		text = "Не удалось отправить заявление автору плана в личные сообщения. Автору нужно начать чат с ботом " +
+			"и нажать кнопку еще раз"
+	}
+	_, err = b.Send(tgbotapi.NewMessage(chat.ID, text))
+	return err
+}
+
+func (b *Bot) handleOperatorCommand(message *tgbotapi.Message) error {
+	if message.From == nil {
+		return nil
+	}
+	if !message.Chat.IsPrivate() {
+		msg := tgbotapi.NewMessage(message.Chat.ID, operatorPrivateText)
+		_, err := b.Send(msg)
+		return err
+	}
+	text := "Данные эксплуатанта не заполнены. Они используются в заявлениях на полёт над населенными пунктами"
+	if operator, ok := b.users.GetOperator(message.From.ID); ok {
+		text = fmt.Sprintf("<b>Данные эксплуатанта</b>\nФИО: %s\nТелефон: %s\nЭл. почта: %s\nАдрес: %s",
+			html.EscapeString(operator.FullName), html.EscapeString(operator.Phone),
+			html.EscapeString(operator.Email), html.EscapeString(operator.Address))
+	}
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ParseMode = "HTML"
+	callbackEdit, _ := json.Marshal(Callback{
+		CallbackType: editOperatorCallback,
+		Data:         struct{}{},
+	})
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✏️ Изменить", string(callbackEdit))),
+	)
+	_, err := b.Send(msg)
+	return err
+}
+
+func (b Bot) handleEditOperatorCallback(chat *tgbotapi.Chat, user *tgbotapi.User) error {
+	if !chat.IsPrivate() {
+		_, err := b.Send(tgbotapi.NewMessage(chat.ID, operatorPrivateText))
+		return err
+	}
+	b.planMutex.Lock()
+	b.operatorUsers[newSessionKey(chat, user)] = true
+	b.planMutex.Unlock()
+	text := "Отправьте через точку с запятой ФИО, телефон, эл. почту и адрес, например " +
+		"<b>Иванов Иван Иванович; +7 900 000-00-00; ivanov@example.com; г. Москва, ул. Ленина, д. 1</b>. " +
+		"Эл. почту и адрес можно не указывать"
+	msg := tgbotapi.NewMessage(chat.ID, userMention(chat, user)+text+groupReplyHint(chat))
+	msg.ParseMode = "HTML"
+	callbackCancel, _ := json.Marshal(Callback{
+		CallbackType: cancelOperatorCallback,
+		Data:         struct{}{},
+	})
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Отмена", string(callbackCancel))),
+	)
+	_, err := b.SendWithMarkupToDelete(user, msg)
+	return err
+}
+
+//checkManageOperatorInput обработка ввода данных эксплуатанта
+func (b Bot) checkManageOperatorInput(ctx context.Context, update tgbotapi.Update) (bool, error) {
+	chat, user := update.FromChat(), update.SentFrom()
+	key := newSessionKey(chat, user)
+	b.planMutex.Lock()
+	_, ok := b.operatorUsers[key]
+	delete(b.operatorUsers, key)
+	b.planMutex.Unlock()
+	if !ok {
+		return false, nil
+	}
+	if update.CallbackQuery != nil {
+		callback := Callback{}
+		if err := json.Unmarshal([]byte(update.CallbackData()), &callback); err == nil &&
+			callback.CallbackType == cancelOperatorCallback {
+			msg := tgbotapi.NewMessage(chat.ID, "Изменение данных эксплуатанта отменено")
+			_, err := b.Send(msg)
+			return true, err
+		}
+		return false, nil
+	}
+	if update.Message == nil || update.Message.Text == "" || update.Message.IsCommand() || user == nil {
+		return false, nil
+	}
+	operator, err := parseOperator(update.Message.Text)
+	if err != nil {
+		b.planMutex.Lock()
+		b.operatorUsers[key] = true
+		b.planMutex.Unlock()
+		msg := tgbotapi.NewMessage(chat.ID, err.Error())
+		_, err := b.Send(msg)
+		return true, err
+	}
+	if err := b.users.SetOperator(user.ID, operator); err != nil {
+		return true, err
+	}
+	msg := tgbotapi.NewMessage(chat.ID, "Данные эксплуатанта сохранены: /operator")
+	_, err = b.Send(msg)
+	return true, err
+}
+
+//parseOperator разбор строки вида "ФИО; телефон; эл. почта; адрес"
+func parseOperator(text string) (model.Operator, error) {
+	parts := strings.SplitN(text, ";", 4)
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return model.Operator{}, fmt.Errorf("Укажите ФИО и телефон через точку с запятой")
+	}
+	operator := model.Operator{FullName: parts[0], Phone: parts[1]}
+	if len(parts) > 2 {
+		operator.Email = parts[2]
+	}
+	if len(parts) > 3 {
+		operator.Address = parts[3]
+	}
+	return operator, nil
+}
@@ -0,0 +1,199 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/japersik/safe-flight-bot/model"
+	"html"
+	"strconv"
+	"strings"
+)
+
+func (b Bot) handleSavePlaceCallback(chat *tgbotapi.Chat, user *tgbotapi.User, coord model.Coordinate) error {
+	b.planMutex.Lock()
+	b.placeNamingUsers[newSessionKey(chat, user)] = model.FavouritePlace{
+		Coordinate: coord,
+		Radius:     model.DefaultRadius(),
+		Altitude:   model.DefaultAltitude(),
+	}
+	b.planMutex.Unlock()
+	text := fmt.Sprintf(`Напишите название места. Через точку с запятой можно указать радиус и высоту полётов в метрах, `+
+		`например <b>Поле у реки; 500; 120</b>. По умолчанию радиус %d м, высота %d м`, model.DefaultRadius(), model.DefaultAltitude())
+	msg := tgbotapi.NewMessage(chat.ID, userMention(chat, user)+text+groupReplyHint(chat))
+	msg.ParseMode = "HTML"
+	callbackCancel, _ := json.Marshal(Callback{
+		CallbackType: cancelSavePlaceCallback,
+		Data:         struct{}{},
+	})
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Отмена", string(callbackCancel))),
+	)
+	_, err := b.SendWithMarkupToDelete(user, msg)
+	return err
+}
+
+//checkManagePlaceNaming обработка ввода названия сохраняемого места
+func (b Bot) checkManagePlaceNaming(ctx context.Context, update tgbotapi.Update) (bool, error) {
+	chat := update.FromChat()
+	key := newSessionKey(chat, update.SentFrom())
+	b.planMutex.Lock()
+	place, ok := b.placeNamingUsers[key]
+	if ok {
+		delete(b.placeNamingUsers, key)
+	}
+	b.planMutex.Unlock()
+	if !ok {
+		return false, nil
+	}
+	if update.CallbackQuery != nil {
+		callback := Callback{}
+		if err := json.Unmarshal([]byte(update.CallbackData()), &callback); err == nil &&
+			callback.CallbackType == cancelSavePlaceCallback {
+			msg := tgbotapi.NewMessage(chat.ID, "Сохранение места отменено")
+			_, err := b.Send(msg)
+			return true, err
+		}
+		return false, nil
+	}
+	if update.Message == nil || update.Message.Text == "" || update.Message.IsCommand() {
+		return false, nil
+	}
+	if err := parsePlaceDescription(update.Message.Text, &place); err != nil {
+		b.planMutex.Lock()
+		b.placeNamingUsers[key] = place
+		b.planMutex.Unlock()
+		msg := tgbotapi.NewMessage(chat.ID, err.Error())
+		_, err := b.Send(msg)
+		return true, err
+	}
+	placeId, err := b.users.AddPlace(key.userId, place)
+	if err != nil {
+		return true, err
+	}
+	place.PlaceId = placeId
+	text := fmt.Sprintf("Место <b>%s</b> сохранено. Список сохраненных мест: /places", html.EscapeString(place.Name))
+	msg := tgbotapi.NewMessage(chat.ID, text)
+	msg.ParseMode = "HTML"
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(placeKeyboardRow(place))
+	_, err = b.Send(msg)
+	return true, err
+}
+
+//parsePlaceDescription разбор строки вида "Название; радиус; высота"
+func parsePlaceDescription(text string, place *model.FavouritePlace) error {
+	parts := strings.Split(text, ";")
+	name := strings.TrimSpace(parts[0])
+	if name == "" {
+		return fmt.Errorf("Название места не может быть пустым")
+	}
+	if len(parts) > 1 && strings.TrimSpace(parts[1]) != "" {
+		radius, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || radius <= 0 || radius > model.MaxRadius {
+			return fmt.Errorf("Радиус должен быть целым числом от 1 до %d метров", model.MaxRadius)
+		}
+		place.Radius = radius
+	}
+	if len(parts) > 2 && strings.TrimSpace(parts[2]) != "" {
+		altitude, err := strconv.Atoi(strings.TrimSpace(parts[2]))
+		if err != nil || altitude <= 0 || altitude > model.MaxAltitude {
+			return fmt.Errorf("Высота должна быть целым числом от 1 до %d метров", model.MaxAltitude)
+		}
+		place.Altitude = altitude
+	}
+	place.Name = name
+	return nil
+}
+
+func placeKeyboardRow(place model.FavouritePlace) []tgbotapi.InlineKeyboardButton {
+	callbackCheck, _ := json.Marshal(Callback{
+		CallbackType: placeCheckCallback,
+		Data:         place.PlaceId,
+	})
+	callbackPlan, _ := json.Marshal(Callback{
+		CallbackType: placePlanCallback,
+		Data:         place.PlaceId,
+	})
+	callbackDelete, _ := json.Marshal(Callback{
+		CallbackType: placeDeleteCallback,
+		Data:         place.PlaceId,
+	})
+	return tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔍 Проверить", string(callbackCheck)),
+		tgbotapi.NewInlineKeyboardButtonData("🗓 Запланировать", string(callbackPlan)),
+		tgbotapi.NewInlineKeyboardButtonData("🗑 Удалить", string(callbackDelete)),
+	)
+}
+
+func (b *Bot) handlePlacesCommand(message *tgbotapi.Message) error {
+	places := b.users.GetPlaces(message.From.ID)
+	if len(places) == 0 {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "У вас нет сохраненных мест. "+
+			"Отправьте геолокацию и нажмите кнопку \"Сохранить место\" под отчетом")
+		_, err := b.Send(msg)
+		return err
+	}
+	for _, place := range places {
+		text := fmt.Sprintf("<b>%s</b>\n%f, %f\nРадиус %d м, высота %d м", html.EscapeString(place.Name),
+			place.Coordinate.Lat, place.Coordinate.Lng, place.Radius, place.Altitude)
+		msg := tgbotapi.NewMessage(message.Chat.ID, text)
+		msg.ParseMode = "HTML"
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(placeKeyboardRow(place))
+		if _, err := b.Send(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Bot) handlePlaceCheckCallback(ctx context.Context, chat *tgbotapi.Chat, user *tgbotapi.User, placeId uint64) error {
+	place, err := b.users.GetPlace(user.ID, placeId)
+	if err != nil {
+		return b.sendPlaceNotFound(chat)
+	}
+	if throttled, err := b.throttleCheck(ctx, chat, user); throttled {
+		return err
+	}
+	text, report, err := b.core.Report(ctx, place.Coordinate, place.Radius, place.Altitude)
+	text = fmt.Sprintf("Место <b>%s</b>\n", html.EscapeString(place.Name)) + text
+	msg := tgbotapi.NewMessage(chat.ID, text)
+	msg.ParseMode = "HTML"
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(append(zonesKeyboardRows(report.Zones()), placeKeyboardRow(place))...)
+	if _, err = b.Send(msg); err != nil {
+		return err
+	}
+	return b.sendAreaMap(ctx, chat.ID, report)
+}
+
+func (b Bot) handlePlacePlanCallback(chat *tgbotapi.Chat, user *tgbotapi.User, placeId uint64) error {
+	place, err := b.users.GetPlace(user.ID, placeId)
+	if err != nil {
+		return b.sendPlaceNotFound(chat)
+	}
+	return b.newPlanningSession(chat, user, model.FlyPlan{
+		Data: model.FlyData{
+			Coordinate: place.Coordinate,
+			Radius:     place.Radius,
+			Altitude:   place.Altitude,
+		},
+		IsEveryDayPlan: false,
+		PlaceId:        place.PlaceId,
+	})
+}
+
+func (b Bot) handlePlaceDeleteCallback(chat *tgbotapi.Chat, query *tgbotapi.CallbackQuery, placeId uint64) error {
+	if err := b.users.DeletePlace(query.From.ID, placeId); err != nil {
+		return b.sendPlaceNotFound(chat)
+	}
+	edit := tgbotapi.NewEditMessageText(chat.ID, query.Message.MessageID, query.Message.Text+"\n\nМесто удалено")
+	_, err := b.Send(edit)
+	return err
+}
+
+func (b Bot) sendPlaceNotFound(chat *tgbotapi.Chat) error {
+	msg := tgbotapi.NewMessage(chat.ID, "Это место уже удалено")
+	_, err := b.Send(msg)
+	return err
+}
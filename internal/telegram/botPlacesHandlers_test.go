@@ -0,0 +1,138 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/japersik/safe-flight-bot/model"
+	"strings"
+	"testing"
+)
+
+func TestParsePlaceDescription(t *testing.T) {
+	tests := []struct {
+		text     string
+		name     string
+		radius   int
+		altitude int
+		err      string
+	}{
+		{"Поле у реки", "Поле у реки", 100, 50, ""},
+		{" Поле у реки ; 500; 120 ", "Поле у реки", 500, 120, ""},
+		{"Поле;;120", "Поле", 100, 120, ""},
+		{"Поле; 500", "Поле", 500, 50, ""},
+		{" ; 500", "", 0, 0, "Название"},
+		{"Поле; -1", "", 0, 0, "Радиус"},
+		{"Поле; 50001", "", 0, 0, "Радиус"},
+		{"Поле; 500; высоко", "", 0, 0, "Высота"},
+		{"Поле; 500; 5001", "", 0, 0, "Высота"},
+	}
+	for _, tt := range tests {
+		place := model.FavouritePlace{Radius: 100, Altitude: 50}
+		err := parsePlaceDescription(tt.text, &place)
+		if tt.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+				t.Errorf("%q: error %v, want %q", tt.text, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.text, err)
+			continue
+		}
+		if place.Name != tt.name || place.Radius != tt.radius || place.Altitude != tt.altitude {
+			t.Errorf("%q: got %q %d %d", tt.text, place.Name, place.Radius, place.Altitude)
+		}
+	}
+}
+
+func TestSavePlace(t *testing.T) {
+	bot, telegram := newTestBot(t)
+	ctx := context.Background()
+	chat := &tgbotapi.Chat{ID: -100, Type: "group"}
+	owner := &tgbotapi.User{ID: 1, FirstName: "Иван"}
+	message := func(from *tgbotapi.User, text string) tgbotapi.Update {
+		return tgbotapi.Update{Message: &tgbotapi.Message{MessageID: 10, From: from, Chat: chat, Text: text}}
+	}
+	coord := model.Coordinate{Lat: 55.75, Lng: 37.61}
+	if err := bot.handleSavePlaceCallback(chat, owner, coord); err != nil {
+		t.Fatal(err)
+	}
+	prompt := telegram.sent("sendMessage")[0].Get("text")
+	if !strings.Contains(prompt, "tg://user?id=1") || !strings.Contains(prompt, "Ответьте на это сообщение") {
+		t.Errorf("group prompt = %q", prompt)
+	}
+
+	//в группе название ждут только от того, кто нажал кнопку
+	if handled, _ := bot.checkManagePlaceNaming(ctx, message(&tgbotapi.User{ID: 2}, "Чужое поле")); handled {
+		t.Error("message of another group member taken as the place name")
+	}
+	//ошибка в описании не сбрасывает ввод
+	if handled, err := bot.checkManagePlaceNaming(ctx, message(owner, "Поле; сто")); !handled || err != nil {
+		t.Fatalf("invalid description: handled %v, %v", handled, err)
+	}
+	if handled, err := bot.checkManagePlaceNaming(ctx, message(owner, "Поле; 300")); !handled || err != nil {
+		t.Fatalf("description: handled %v, %v", handled, err)
+	}
+	places := bot.users.GetPlaces(owner.ID)
+	if len(places) != 1 || places[0].Name != "Поле" || places[0].Radius != 300 || places[0].Coordinate != coord ||
+		places[0].Altitude != model.DefaultAltitude() {
+		t.Fatalf("saved places = %+v", places)
+	}
+	if handled, _ := bot.checkManagePlaceNaming(ctx, message(owner, "Второе поле")); handled {
+		t.Error("naming session not finished after the place was saved")
+	}
+
+	sent := telegram.sent("sendMessage")
+	if got := sent[len(sent)-2].Get("text"); !strings.HasPrefix(got, "Радиус должен быть") {
+		t.Errorf("validation answer = %q", got)
+	}
+	saved := sent[len(sent)-1]
+	if !strings.Contains(saved.Get("text"), "<b>Поле</b> сохранено") || !hasButton(buttons(t, saved), "🗑 Удалить") {
+		t.Errorf("saved answer = %v", saved)
+	}
+
+	query := &tgbotapi.CallbackQuery{From: owner, Message: &tgbotapi.Message{MessageID: 11, Chat: chat, Text: "Поле"}}
+	if err := bot.handlePlaceDeleteCallback(chat, query, places[0].PlaceId); err != nil {
+		t.Fatal(err)
+	}
+	if len(bot.users.GetPlaces(owner.ID)) != 0 {
+		t.Error("place not deleted")
+	}
+	if edits := telegram.sent("editMessageText"); len(edits) != 1 || !strings.HasSuffix(edits[0].Get("text"), "Место удалено") {
+		t.Errorf("delete answer = %v", edits)
+	}
+	if err := bot.handlePlaceDeleteCallback(chat, query, places[0].PlaceId); err != nil {
+		t.Fatal(err)
+	}
+	sent = telegram.sent("sendMessage")
+	if got := sent[len(sent)-1].Get("text"); got != "Это место уже удалено" {
+		t.Errorf("repeated delete answer = %q", got)
+	}
+}
+
+func TestCancelSavePlace(t *testing.T) {
+	bot, telegram := newTestBot(t)
+	chat := &tgbotapi.Chat{ID: 1, Type: "private"}
+	user := &tgbotapi.User{ID: 1}
+	if err := bot.handleSavePlaceCallback(chat, user, model.Coordinate{Lat: 55.75, Lng: 37.61}); err != nil {
+		t.Fatal(err)
+	}
+	cancel := buttons(t, telegram.sent("sendMessage")[0])
+	if len(cancel) != 1 || cancel[0] != "Отмена" {
+		t.Fatalf("prompt buttons = %v", cancel)
+	}
+	data, _ := json.Marshal(Callback{CallbackType: cancelSavePlaceCallback, Data: struct{}{}})
+	update := tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{From: user, Data: string(data),
+		Message: &tgbotapi.Message{Chat: chat}}}
+	if handled, err := bot.checkManagePlaceNaming(context.Background(), update); !handled || err != nil {
+		t.Fatalf("cancel: handled %v, %v", handled, err)
+	}
+	if len(bot.users.GetPlaces(user.ID)) != 0 {
+		t.Error("place saved after cancel")
+	}
+	sent := telegram.sent("sendMessage")
+	if got := sent[len(sent)-1].Get("text"); got != "Сохранение места отменено" {
+		t.Errorf("cancel answer = %q", got)
+	}
+}
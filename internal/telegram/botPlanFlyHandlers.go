@@ -1,203 +1,184 @@
 package telegram
 
 import (
+	"context"
 	"encoding/json"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/japersik/safe-flight-bot/internal/conversation"
+	"github.com/japersik/safe-flight-bot/logger"
 	"github.com/japersik/safe-flight-bot/model"
-	"github.com/zsefvlol/timezonemapper"
-	"time"
+	"github.com/mitchellh/mapstructure"
+	"strconv"
 )
 
-func (b Bot) checkManageFlyPlanning(update tgbotapi.Update) (bool, error) {
-	//fmt.Println("HERE")
-	//fmt.Println(update)
-	//var id int64
-	//if update.FromChat() != nil {
-	//	id = update.FromChat().ID
-	//} else {
-	//	id = update.Message.Chat.ID
-	//}
-	b.planMutex.Lock()
-	pFlightInfo, ok := b.flightPlanningUsers[update.FromChat().ID]
-	b.planMutex.Unlock()
-	if !ok {
+//checkManageFlyPlanning передает сообщения пользователя в режиме планирования ядру диалога
+func (b Bot) checkManageFlyPlanning(ctx context.Context, update tgbotapi.Update) (bool, error) {
+	chat, user := update.FromChat(), update.SentFrom()
+	in := incomingFrom(chat, user)
+	if !b.core.IsPlanning(in) {
 		return false, nil
 	}
-	if update.CallbackData() != "" {
-		b.handlePlanFlyCallbacks(update.FromChat(), update.CallbackQuery)
-	} else if pFlightInfo.plan.IsEveryDayPlan {
-		switch pFlightInfo.stage {
-		case dateTimeSelect:
-			if t, err := parseTime(update.Message.Text, pFlightInfo.plan.Data.Coordinate); err != nil {
-				return true, b.sendFlyPlaningStatus(update.FromChat(), *pFlightInfo)
-			} else {
-				pFlightInfo.plan.FlyDateTime = t
-				pFlightInfo.plan.Notifications = []time.Duration{0}
-				flyId, _ := b.planner.PlanFly(pFlightInfo.plan)
-				//fmt.Println(flyId, err)
-				delete(b.flightPlanningUsers, update.FromChat().ID)
-				return true, b.sendPlanCreated(update.FromChat(), flyId)
-			}
-		}
-	} else {
-		switch pFlightInfo.stage {
-		case dateTimeSelect:
-			if t, err := parseDateTime(update.Message.Text, pFlightInfo.plan.Data.Coordinate); err != nil {
-				return true, err
-			} else {
-				pFlightInfo.plan.FlyDateTime = t
-				pFlightInfo.plan.Notifications = []time.Duration{-48 * time.Hour, -24 * time.Hour, -12 * time.Hour, -3 * time.Hour,
-					-2 * time.Hour, -1 * time.Hour, 0, 1 * time.Hour, 2 * time.Hour}
-				flyId, _ := b.planner.PlanFly(pFlightInfo.plan)
-				delete(b.flightPlanningUsers, update.FromChat().ID)
-				return true, b.sendPlanCreated(update.FromChat(), flyId)
-				//return true, b.sendSelectNotificationsTime(update.FromChat())
-			}
-			//case notifications:
-			//	fmt.Println(pFlightInfo)
-			//	fmt.Println(update.Message)
+	switch {
+	case update.CallbackQuery != nil:
+		in.Action = callbackAction(update.CallbackQuery.Data)
+	case update.Message != nil:
+		in.Text = update.Message.Text
+		if update.Message.IsCommand() {
+			in.Command = update.Message.Command()
 		}
+	default:
+		return false, nil
+	}
+	return b.core.HandlePlanning(ctx, in)
+}
+
+//newPlanningSession начало планирования полёта в ядре диалога
+func (b Bot) newPlanningSession(chat *tgbotapi.Chat, user *tgbotapi.User, plan model.FlyPlan) error {
+	return b.core.StartPlanning(incomingFrom(chat, user), plan)
+}
+
+//incomingFrom сообщение ядру от пользователя в чате. user может отсутствовать, например в каналах
+func incomingFrom(chat *tgbotapi.Chat, user *tgbotapi.User) conversation.Incoming {
+	in := conversation.Incoming{ChatId: chat.ID, Private: chat.IsPrivate()}
+	if user != nil {
+		in.UserId = user.ID
+		in.UserName = user.FirstName
 	}
-	return true, nil
+	return in
 }
 
-//var selectTime = map[string]time.Duration{
-//	"За 2 дня до запланированного времени":        -48 * time.Hour,
-//	"За 1 день до запланированного времени":       -24 * time.Hour,
-//	"За 12 часов до запланированного времени":     -12 * time.Hour,
-//	"За 3 часа до запланированного времени":       -3 * time.Hour,
-//	"За 2 часа до запланированного времени":       -2 * time.Hour,
-//	"За 1 час до запланированного времени":        -time.Hour,
-//	"В запланированное время":                     0,
-//	"Через 1 час после запланированного времени":  time.Hour,
-//	"Через 2 часа после запланированного времени": 2 * time.Hour,
-//	"Через 3 часа после запланированного времени": 3 * time.Hour,
-//}
-//
-//func (b *Bot) sendSelectNotificationsTime(chat *tgbotapi.Chat) error {
-//	options := make([]string, 0, len(selectTime))
-//	for i, _ := range selectTime {
-//		options = append(options, i)
-//	}
-//	msg := tgbotapi.NewPoll(chat.ID, "В какое время стоит уведомить об обстановке?", options...)
-//	msg.AllowsMultipleAnswers = true
-//	_, err := b.bot.Send(msg)
-//	return err
-//}
-func (b *Bot) handlePlanFlyCallbacks(chat *tgbotapi.Chat, query *tgbotapi.CallbackQuery) error {
-	callbackData := query.Data
+//callbackAction действие ядра по данным кнопки. Кнопки планирования, отправленные до выделения ядра,
+//переводятся в действия ядра, прочие кнопки повторяют текущий шаг планирования
+func callbackAction(data string) string {
 	callback := Callback{}
-	err := json.Unmarshal([]byte(callbackData), &callback)
-	if err != nil {
-		return WrongCallbackErr
+	if err := json.Unmarshal([]byte(data), &callback); err != nil {
+		return data
 	}
 	switch callback.CallbackType {
+	case coreActionCallback:
+		var action string
+		if err := mapstructure.Decode(callback.Data, &action); err == nil && action != "" {
+			return action
+		}
 	case cancelPlanFlyCallback:
-		b.planMutex.Lock()
-		delete(b.flightPlanningUsers, chat.ID)
-		b.planMutex.Unlock()
-		return b.sendFlightPlanningCanceled(chat)
-	default:
-		status := b.flightPlanningUsers[chat.ID]
-		return b.sendFlyPlaningStatus(chat, *status)
+		return conversation.ActionCancelPlanning
+	case selectDroneCallback:
+		var droneId uint64
+		if err := mapstructure.Decode(callback.Data, &droneId); err == nil {
+			return conversation.ActionDrone + ":" + strconv.FormatUint(droneId, 10)
+		}
 	}
+	return data
 }
 
-func parseTime(str string, coordinate model.Coordinate) (time.Time, error) {
-	layout := "15:04"
-	timezone := timezonemapper.LatLngToTimezoneString(coordinate.Lat, coordinate.Lng)
-	loc, _ := time.LoadLocation(timezone)
-	return time.ParseInLocation(layout, str, loc)
+//transport доставка сообщений ядра диалога в Telegram
+type transport struct {
+	bot *Bot
 }
 
-func parseDateTime(str string, coordinate model.Coordinate) (time.Time, error) {
-	layout := "02.01.2006 15:04"
-	timezone := timezonemapper.LatLngToTimezoneString(coordinate.Lat, coordinate.Lng)
-	loc, _ := time.LoadLocation(timezone)
-	return time.ParseInLocation(layout, str, loc)
+//Send реализация интерфейса conversation.Transport
+func (t transport) Send(out conversation.Outgoing) error {
+	text := out.Text
+	//в личном чате id чата совпадает с id пользователя
+	if out.MentionUserId != 0 && out.MentionUserId != out.ChatId {
+		chat := &tgbotapi.Chat{ID: out.ChatId, Type: "group"}
+		text = userMention(chat, &tgbotapi.User{ID: out.MentionUserId, FirstName: out.MentionUserName}) + text
+		if out.AwaitReply {
+			text += groupReplyHint(chat)
+		}
+	}
+	msg := tgbotapi.NewMessage(out.ChatId, text)
+	msg.ParseMode = "HTML"
+	if rows := coreKeyboardRows(out.Buttons); len(rows) > 0 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	}
+	var err error
+	if out.MentionUserId != 0 {
+		_, err = t.bot.SendWithMarkupToDelete(&tgbotapi.User{ID: out.MentionUserId}, msg)
+	} else {
+		_, err = t.bot.Send(msg)
+	}
+	return err
 }
 
-// Planner Handlers
-func (b Bot) sendFlyPlaningStatus(chat *tgbotapi.Chat, status plannedFlightInfo) error {
-	switch status.stage {
-	case dateTimeSelect:
-		if status.plan.IsEveryDayPlan {
-			return b.sendNeedTimeSelect(chat)
-		} else {
-			return b.sendNeedDateSelect(chat)
+//coreKeyboardRows кнопки ядра. Кнопки с данными длиннее ограничения Telegram в 64 байта пропускаются
+func coreKeyboardRows(buttons [][]conversation.Button) [][]tgbotapi.InlineKeyboardButton {
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(buttons))
+	for _, line := range buttons {
+		row := make([]tgbotapi.InlineKeyboardButton, 0, len(line))
+		for _, button := range line {
+			data, _ := json.Marshal(Callback{CallbackType: coreActionCallback, Data: button.Action})
+			if len(data) > 64 {
+				logger.Warn("callback data is too long", logger.String("data", string(data)))
+				continue
+			}
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(button.Text, string(data)))
+		}
+		if len(row) > 0 {
+			rows = append(rows, row)
 		}
-	default:
-		return b.sendNotInPlanningMode(chat)
 	}
+	return rows
 }
-func (b Bot) sendNotInPlanningMode(chat *tgbotapi.Chat) error {
-	text :=
-		`Вы не находитесь в режиме планирования`
-	msg := tgbotapi.NewMessage(chat.ID, text)
-	msg.ParseMode = "HTML"
-	_, err := b.Send(msg)
-	return err
+
+//PlanSaved реализация интерфейса conversation.PlanListener
+func (b *Bot) PlanSaved(ctx context.Context, in conversation.Incoming, plan model.FlyPlan, created bool) error {
+	return b.sendPlanCreated(ctx, chatFrom(in), plan)
 }
-func (b Bot) sendNeedDateSelect(chat *tgbotapi.Chat) error {
-	text :=
-		`Вы находитесь в режиме планирования.
- Напишите дату и местное время в формате <b>01.12.2022 23:59</b> или нажмите кнопку "Отмена" для отмены планирования полета`
-	msg := tgbotapi.NewMessage(chat.ID, text)
-	msg.ParseMode = "HTML"
-	callbackPlanEveryDayNotifications, _ := json.Marshal(Callback{
-		CallbackType: cancelPlanFlyCallback,
-		Data:         struct{}{},
-	})
-	numericKeyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("Отмена", string(callbackPlanEveryDayNotifications))),
-	)
-	msg.ReplyMarkup = numericKeyboard
-	_, err := b.SendWithMarkupToDelete(msg)
-	return err
+
+//AllowCheck реализация интерфейса conversation.Reporter
+func (b *Bot) AllowCheck(ctx context.Context, in conversation.Incoming) (bool, error) {
+	throttled, err := b.throttleCheck(ctx, chatFrom(in), userFrom(in))
+	return !throttled, err
 }
-func (b Bot) sendNeedTimeSelect(chat *tgbotapi.Chat) error {
-	text :=
-		`Вы находитесь в режиме планирования.
- Напишите местное время в формате <b>23:59</b> или нажмите кнопку "Отмена" для отмены планирования полета`
-	msg := tgbotapi.NewMessage(chat.ID, text)
-	msg.ParseMode = "HTML"
-	callbackPlanEveryDayNotifications, _ := json.Marshal(Callback{
-		CallbackType: cancelPlanFlyCallback,
-		Data:         struct{}{},
-	})
-	numericKeyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("Отмена", string(callbackPlanEveryDayNotifications))),
-	)
-	msg.ReplyMarkup = numericKeyboard
-	_, err := b.SendWithMarkupToDelete(msg)
-	return err
+
+//SendReport реализация интерфейса conversation.Reporter: отчет с кнопками Telegram и картой района
+func (b *Bot) SendReport(ctx context.Context, in conversation.Incoming, coord model.Coordinate) error {
+	return b.sendLocationReport(ctx, chatFrom(in), coord)
+}
+
+//chatFrom чат сообщения ядра. Для отправки достаточно id и признака личного чата
+func chatFrom(in conversation.Incoming) *tgbotapi.Chat {
+	chatType := "group"
+	if in.Private {
+		chatType = "private"
+	}
+	return &tgbotapi.Chat{ID: in.ChatId, Type: chatType}
+}
+
+func userFrom(in conversation.Incoming) *tgbotapi.User {
+	if in.UserId == 0 {
+		return nil
+	}
+	return &tgbotapi.User{ID: in.UserId, FirstName: in.UserName}
 }
-func (b Bot) sendPlanCreated(chat *tgbotapi.Chat, flyId uint64) error {
-	text :=
-		`Уведомление успешно запланировано.
-Нажмите кнопку ниже для отмены`
+
+//sendPlanCreated заявление в администрацию содержит данные эксплуатанта, поэтому кнопка есть только в личном чате
+func (b Bot) sendPlanCreated(ctx context.Context, chat *tgbotapi.Chat, plan model.FlyPlan) error {
+	text, zones := b.core.PlanCreatedText(ctx, plan)
 	msg := tgbotapi.NewMessage(chat.ID, text)
 	msg.ParseMode = "HTML"
 	cancelFlyNotifications, _ := json.Marshal(Callback{
 		CallbackType: cancelFlyCallback,
-		Data:         flyId,
+		Data:         plan.FlyId,
 	})
-	numericKeyboard := tgbotapi.NewInlineKeyboardMarkup(
+	rows := append(zonesKeyboardRows(zones), planManageKeyboardRow(plan.FlyId))
+	if chat.IsPrivate() {
+		rows = append(rows, permissionDocKeyboardRow(plan.FlyId))
+	}
+	numericKeyboard := tgbotapi.NewInlineKeyboardMarkup(append(rows,
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("Отменить уведомление", string(cancelFlyNotifications))),
-	)
+	)...)
 	msg.ReplyMarkup = numericKeyboard
 	_, err := b.Send(msg)
 	return err
 }
 
-func (b Bot) sendFlightPlanningCanceled(chat *tgbotapi.Chat) error {
-	text :=
-		`Планирование полета/ежедневного уведомления отменено`
-	msg := tgbotapi.NewMessage(chat.ID, text)
-	msg.ParseMode = "HTML"
-	_, err := b.Send(msg)
-	return err
+//groupReplyHint в группах бот без прав администратора получает только ответы на свои сообщения
+func groupReplyHint(chat *tgbotapi.Chat) string {
+	if chat.IsPrivate() {
+		return ""
+	}
+	return "\n<i>Ответьте на это сообщение</i>"
 }
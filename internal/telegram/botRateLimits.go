@@ -0,0 +1,97 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/japersik/safe-flight-bot/internal/metrics"
+	"github.com/japersik/safe-flight-bot/internal/ratelimit"
+	"github.com/japersik/safe-flight-bot/logger"
+)
+
+const (
+	updatesThrottledText = "Слишком много сообщений подряд. Подождите %d с, сообщения до этого времени не обрабатываются"
+	checksThrottledText  = "Слишком много проверок подряд. Следующая будет доступна через %d с"
+	//текст кнопки над пустыми результатами inline запроса
+	inlineThrottledText = "Слишком много запросов, повторите через %d с"
+)
+
+//SetRateLimits лимиты на пользователя: updates - все сообщения, нажатия кнопок и inline запросы,
+//checks - проверки места, каждая из которых запрашивает внешние сервисы. nil - без ограничения
+func (b *Bot) SetRateLimits(updates, checks *ratelimit.UserLimiter) {
+	b.updateLimiter = updates
+	b.checkLimiter = checks
+}
+
+//updateUser автор обновления для лимита. Правки сообщений (трансляция геопозиции) и служебные обновления
+//не ограничиваются: их частоту определяет Telegram, а проверки по трансляции выполняются с собственным интервалом
+func updateUser(update tgbotapi.Update) *tgbotapi.User {
+	switch {
+	case update.Message != nil:
+		return update.Message.From
+	case update.CallbackQuery != nil:
+		return update.CallbackQuery.From
+	case update.InlineQuery != nil:
+		return update.InlineQuery.From
+	}
+	return nil
+}
+
+//allowUpdate проверка лимита до запуска обработки, чтобы поток сообщений одного пользователя не порождал горутины
+func (b *Bot) allowUpdate(update tgbotapi.Update) ratelimit.Decision {
+	user := updateUser(update)
+	if user == nil {
+		return ratelimit.Decision{Allowed: true}
+	}
+	decision := b.updateLimiter.Allow(user.ID)
+	if !decision.Allowed {
+		metrics.Throttled("updates")
+		logger.Debug("update throttled", logger.UserId(user.ID), logger.Int("updateId", update.UpdateID))
+	}
+	return decision
+}
+
+//sendUpdatesThrottled единственное предупреждение о превышении лимита, следующие сообщения отбрасываются молча.
+//На каждое нажатие кнопки и inline запрос нужен ответ, иначе Telegram показывает загрузку
+func (b *Bot) sendUpdatesThrottled(update tgbotapi.Update, decision ratelimit.Decision) {
+	text := fmt.Sprintf(updatesThrottledText, decision.RetrySeconds())
+	var err error
+	switch {
+	case update.CallbackQuery != nil && decision.Warn:
+		_, err = b.bot.Request(tgbotapi.NewCallbackWithAlert(update.CallbackQuery.ID, text))
+	case update.CallbackQuery != nil:
+		_, err = b.bot.Request(tgbotapi.NewCallback(update.CallbackQuery.ID, ""))
+	case update.InlineQuery != nil:
+		//короткое время кэширования, чтобы после паузы запрос выполнился заново
+		_, err = b.bot.Request(tgbotapi.InlineConfig{
+			InlineQueryID:     update.InlineQuery.ID,
+			Results:           []interface{}{},
+			CacheTime:         1,
+			IsPersonal:        true,
+			SwitchPMText:      fmt.Sprintf(inlineThrottledText, decision.RetrySeconds()),
+			SwitchPMParameter: "inline",
+		})
+	case update.Message != nil && decision.Warn:
+		_, err = b.Send(tgbotapi.NewMessage(update.Message.Chat.ID, text))
+	}
+	if err != nil {
+		logger.Warn("throttling reply error", logger.Err(err))
+	}
+}
+
+//throttleCheck лимит проверок места. При превышении отправляет ответ и возвращает true
+func (b *Bot) throttleCheck(ctx context.Context, chat *tgbotapi.Chat, user *tgbotapi.User) (bool, error) {
+	if user == nil {
+		return false, nil
+	}
+	decision := b.checkLimiter.Allow(user.ID)
+	if decision.Allowed {
+		return false, nil
+	}
+	metrics.Throttled("checks")
+	logger.FromContext(ctx).Debug("check throttled")
+	msg := tgbotapi.NewMessage(chat.ID, userMention(chat, user)+fmt.Sprintf(checksThrottledText, decision.RetrySeconds()))
+	msg.ParseMode = "HTML"
+	_, err := b.Send(msg)
+	return true, err
+}
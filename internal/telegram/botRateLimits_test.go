@@ -0,0 +1,83 @@
+package telegram
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/japersik/safe-flight-bot/internal/ratelimit"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSendUpdatesThrottled(t *testing.T) {
+	user := &tgbotapi.User{ID: 1}
+	message := tgbotapi.Update{Message: &tgbotapi.Message{From: user, Chat: &tgbotapi.Chat{ID: 1, Type: "private"}, Text: "55.75 37.61"}}
+	callback := tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{ID: "cb", From: user, Data: "{}"}}
+	inline := tgbotapi.Update{InlineQuery: &tgbotapi.InlineQuery{ID: "iq", From: user, Query: "Москва"}}
+	tests := []struct {
+		name   string
+		update tgbotapi.Update
+		warn   bool
+		method string
+		check  map[string]string
+	}{
+		{"first message", message, true, "sendMessage", map[string]string{"chat_id": "1"}},
+		{"next message", message, false, "", nil},
+		{"first callback", callback, true, "answerCallbackQuery", map[string]string{"show_alert": "true"}},
+		{"next callback", callback, false, "answerCallbackQuery", map[string]string{"show_alert": ""}},
+		{"first inline query", inline, true, "answerInlineQuery",
+			map[string]string{"inline_query_id": "iq", "results": "[]", "cache_time": "1", "is_personal": "true",
+				"switch_pm_text": "Слишком много запросов, повторите через 3 с"}},
+		//на inline запрос отвечают всегда: без ответа Telegram показывает загрузку
+		{"next inline query", inline, false, "answerInlineQuery", map[string]string{"cache_time": "1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot, telegram := newTestBot(t)
+			bot.sendUpdatesThrottled(tt.update, ratelimit.Decision{Warn: tt.warn, RetryAfter: 3 * time.Second})
+			var answered []string
+			for _, method := range []string{"sendMessage", "answerCallbackQuery", "answerInlineQuery"} {
+				if len(telegram.sent(method)) > 0 {
+					answered = append(answered, method)
+				}
+			}
+			if tt.method == "" {
+				if len(answered) > 0 {
+					t.Errorf("answered with %v, want silence", answered)
+				}
+				return
+			}
+			if len(answered) != 1 || answered[0] != tt.method {
+				t.Fatalf("answered with %v, want %s", answered, tt.method)
+			}
+			params := telegram.sent(tt.method)[0]
+			for name, want := range tt.check {
+				if got := params.Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessUpdateThrottledInlineQueries(t *testing.T) {
+	bot, telegram := newTestBot(t)
+	bot.SetRateLimits(ratelimit.NewUserLimiter(1, 1), nil)
+	for i := 0; i < 3; i++ {
+		bot.processUpdate(tgbotapi.Update{UpdateID: i, InlineQuery: &tgbotapi.InlineQuery{ID: strconv.Itoa(i),
+			From: &tgbotapi.User{ID: 1}}})
+	}
+	bot.updatesWG.Wait()
+	answers := telegram.sent("answerInlineQuery")
+	if len(answers) != 3 {
+		t.Fatalf("answered %d of 3 inline queries", len(answers))
+	}
+	throttled := 0
+	for _, answer := range answers {
+		if answer.Get("cache_time") == "1" && answer.Get("results") == "[]" {
+			throttled++
+		}
+	}
+	if throttled != 2 {
+		t.Errorf("throttled answers = %d, want 2", throttled)
+	}
+}
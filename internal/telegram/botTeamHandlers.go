@@ -0,0 +1,161 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/japersik/safe-flight-bot/internal/conversation"
+	"github.com/japersik/safe-flight-bot/internal/flyPlanner"
+	"github.com/japersik/safe-flight-bot/logger"
+	"github.com/japersik/safe-flight-bot/model"
+	"html"
+	"strings"
+)
+
+//префикс параметра deep-link ссылки /start plan_<token>
+const sharePlanPrefix = "plan_"
+
+func planManageKeyboardRow(flyId uint64) []tgbotapi.InlineKeyboardButton {
+	callbackShare, _ := json.Marshal(Callback{
+		CallbackType: sharePlanCallback,
+		Data:         flyId,
+	})
+	callbackEdit, _ := json.Marshal(Callback{
+		CallbackType: editPlanCallback,
+		Data:         flyId,
+	})
+	return tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("👥 Поделиться", string(callbackShare)),
+		tgbotapi.NewInlineKeyboardButtonData("🕓 Изменить время", string(callbackEdit)),
+	)
+}
+
+func unsubscribeKeyboardRow(flyId uint64) []tgbotapi.InlineKeyboardButton {
+	callbackUnsubscribe, _ := json.Marshal(Callback{
+		CallbackType: unsubscribePlanCallback,
+		Data:         flyId,
+	})
+	return tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Отписаться", string(callbackUnsubscribe)))
+}
+
+//PlanCanceled реализация интерфейса flyPlanner.ChangeListener: план отменён в чате, через API или sfcheck
+func (b *Bot) PlanCanceled(ctx context.Context, plan model.FlyPlan) {
+	b.sendToSubscribers(ctx, plan, conversation.PlanCanceledText(plan), nil)
+}
+
+//PlanUpdated реализация интерфейса flyPlanner.ChangeListener
+func (b *Bot) PlanUpdated(ctx context.Context, plan model.FlyPlan) {
+	text, zones := b.core.PlanUpdatedText(ctx, plan)
+	b.sendToSubscribers(ctx, plan, text, append(zonesKeyboardRows(zones), unsubscribeKeyboardRow(plan.FlyId)))
+}
+
+//sendToSubscribers рассылка сообщения участникам команды, подписанным на план
+func (b Bot) sendToSubscribers(ctx context.Context, plan model.FlyPlan, text string, rows [][]tgbotapi.InlineKeyboardButton) {
+	for _, sub := range plan.Subscribers {
+		if sub.UserId == plan.Data.NotifyChatId() {
+			continue
+		}
+		msg := tgbotapi.NewMessage(sub.UserId, text)
+		msg.ParseMode = "HTML"
+		if len(rows) > 0 {
+			msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+		}
+		if _, err := b.Send(msg); err != nil {
+			logger.FromContext(ctx).Warn("subscriber notification error", logger.FlyId(plan.FlyId), logger.UserId(sub.UserId), logger.Err(err))
+		}
+	}
+}
+
+func (b Bot) handleSharePlanCallback(ctx context.Context, chat *tgbotapi.Chat, query *tgbotapi.CallbackQuery, flyId uint64) error {
+	plan, err := b.planner.GetFly(flyId)
+	if err != nil {
+		return b.sendPlanNotExist(chat)
+	}
+	if !b.canManagePlan(ctx, chat, query.From, plan) {
+		_, err := b.bot.Request(tgbotapi.NewCallbackWithAlert(query.ID,
+			"Поделиться планом может только автор плана или администратор чата"))
+		return err
+	}
+	token, err := b.planner.ShareFly(flyId)
+	if err != nil {
+		return b.sendPlanNotExist(chat)
+	}
+	link := fmt.Sprintf("https://t.me/%s?start=%s%s", b.bot.Self.UserName, sharePlanPrefix, token)
+	text := fmt.Sprintf("Ссылка для подписки на уведомления по плану №%d:\n%s\n\n", flyId, link)
+	if len(plan.Subscribers) == 0 {
+		text += "Подписчиков пока нет"
+	} else {
+		names := make([]string, 0, len(plan.Subscribers))
+		for _, sub := range plan.Subscribers {
+			names = append(names, fmt.Sprintf(`<a href="tg://user?id=%d">%s</a>`, sub.UserId, html.EscapeString(sub.Name)))
+		}
+		text += "Подписчики: " + strings.Join(names, ", ")
+	}
+	msg := tgbotapi.NewMessage(chat.ID, text)
+	msg.ParseMode = "HTML"
+	msg.DisableWebPagePreview = true
+	_, err = b.Send(msg)
+	return err
+}
+
+func (b Bot) handleEditPlanCallback(ctx context.Context, chat *tgbotapi.Chat, query *tgbotapi.CallbackQuery, flyId uint64) error {
+	plan, err := b.planner.GetFly(flyId)
+	if err != nil {
+		return b.sendPlanNotExist(chat)
+	}
+	if !b.canManagePlan(ctx, chat, query.From, plan) {
+		_, err := b.bot.Request(tgbotapi.NewCallbackWithAlert(query.ID,
+			"Изменить план может только автор плана или администратор чата"))
+		return err
+	}
+	return b.newPlanningSession(chat, query.From, plan)
+}
+
+func (b Bot) handleUnsubscribePlanCallback(chat *tgbotapi.Chat, query *tgbotapi.CallbackQuery, flyId uint64) error {
+	text := fmt.Sprintf("Вы отписались от уведомлений по плану №%d", flyId)
+	if err := b.planner.Unsubscribe(flyId, query.From.ID); err != nil {
+		text = "Вы уже не подписаны на этот план"
+	}
+	msg := tgbotapi.NewMessage(chat.ID, text)
+	_, err := b.Send(msg)
+	return err
+}
+
+//handleSubscribePlan подписка по ссылке-приглашению /start plan_<token>
+func (b *Bot) handleSubscribePlan(ctx context.Context, message *tgbotapi.Message, token string) error {
+	subscriber := model.Subscriber{UserId: message.From.ID, Name: message.From.FirstName}
+	plan, err := b.planner.Subscribe(token, subscriber)
+	switch err {
+	case nil:
+	case flyPlanner.OwnPlanErr:
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Это ваш план, уведомления по нему уже приходят вам")
+		_, err := b.Send(msg)
+		return err
+	case flyPlanner.PlansLimitErr:
+		_, err := b.Send(tgbotapi.NewMessage(message.Chat.ID, conversation.PlansLimitText))
+		return err
+	default:
+		return b.sendPlanNotExist(message.Chat)
+	}
+	windowText, zones := b.core.FlightWindowText(ctx, plan)
+	text := fmt.Sprintf("Вы подписались на уведомления по плану №%d\n\n", plan.FlyId) + windowText
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ParseMode = "HTML"
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(append(zonesKeyboardRows(zones), unsubscribeKeyboardRow(plan.FlyId))...)
+	if _, err = b.Send(msg); err != nil {
+		return err
+	}
+	ownerMsg := tgbotapi.NewMessage(plan.Data.NotifyChatId(), fmt.Sprintf(`<a href="tg://user?id=%d">%s</a> теперь получает уведомления по плану №%d`,
+		subscriber.UserId, html.EscapeString(subscriber.Name), plan.FlyId))
+	ownerMsg.ParseMode = "HTML"
+	_, err = b.Send(ownerMsg)
+	return err
+}
+
+func (b Bot) sendPlanNotExist(chat *tgbotapi.Chat) error {
+	msg := tgbotapi.NewMessage(chat.ID, "План не найден: он был отменён или полёт уже завершён")
+	_, err := b.Send(msg)
+	return err
+}
@@ -0,0 +1,72 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/japersik/safe-flight-bot/internal/conversation"
+	"github.com/japersik/safe-flight-bot/internal/flyDataClient"
+	"github.com/japersik/safe-flight-bot/internal/mapRenderer"
+	"github.com/japersik/safe-flight-bot/logger"
+	"github.com/japersik/safe-flight-bot/model"
+)
+
+//максимальный размер callback_data, допустимый Telegram
+const maxCallbackDataLen = 64
+
+//zonesKeyboardRows кнопки для просмотра подробной информации о зонах, по две в ряд
+func zonesKeyboardRows(zones []model.Zone) [][]tgbotapi.InlineKeyboardButton {
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(zones)/2+1)
+	row := make([]tgbotapi.InlineKeyboardButton, 0, 2)
+	for _, zone := range zones {
+		callbackZoneInfo, _ := json.Marshal(Callback{
+			CallbackType: zoneInfoCallback,
+			Data:         zone.Code,
+		})
+		if len(callbackZoneInfo) > maxCallbackDataLen {
+			continue
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("ℹ "+zone.Code, string(callbackZoneInfo)))
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = make([]tgbotapi.InlineKeyboardButton, 0, 2)
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func (b Bot) handleZoneInfoCallback(chat *tgbotapi.Chat, code string) error {
+	zone, ok := b.core.Zone(code)
+	text := "Информация о зоне устарела, повторите запрос"
+	if ok {
+		text = conversation.ZoneText(zone)
+	}
+	msg := tgbotapi.NewMessage(chat.ID, text)
+	msg.ParseMode = "HTML"
+	_, err := b.Send(msg)
+	return err
+}
+
+//sendAreaMap отправка карты района полёта с зонами ограничений
+func (b Bot) sendAreaMap(ctx context.Context, chatId int64, report flyDataClient.Report) error {
+	if b.mapRenderer == nil {
+		return nil
+	}
+	var active, inactive []model.Zone
+	if report.Condition != nil {
+		active, inactive = report.Condition.ActiveZones, report.Condition.InactiveZones
+	}
+	image, err := b.mapRenderer.Render(ctx, report.Coordinate, report.Radius, active, inactive)
+	if err != nil {
+		logger.FromContext(ctx).Warn("map rendering error", logger.Err(err))
+		return err
+	}
+	photo := tgbotapi.NewPhoto(chatId, tgbotapi.FileBytes{Name: "map.png", Bytes: image})
+	photo.Caption = "Синим отмечен район полёта, красным - действующие зоны ограничений, оранжевым - неактивные зоны\n" +
+		mapRenderer.Attribution
+	_, err = b.Send(photo)
+	return err
+}
@@ -0,0 +1,181 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/japersik/safe-flight-bot/internal/flyDataClient"
+	"github.com/japersik/safe-flight-bot/internal/flyPlanner"
+	"github.com/japersik/safe-flight-bot/internal/logbook"
+	"github.com/japersik/safe-flight-bot/internal/userStorage"
+	"github.com/japersik/safe-flight-bot/logger"
+	"github.com/japersik/safe-flight-bot/model"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	logger.NewInstance(logger.NewZapLogger(zap.NewNop()))
+	os.Exit(m.Run())
+}
+
+//apiCall запрос бота к Bot API
+type apiCall struct {
+	method string
+	params url.Values
+}
+
+//fakeTelegram Bot API, сохраняющий запросы. На любой метод отвечает объектом, который подходит и как
+//пользователь для getMe, и как отправленное сообщение
+type fakeTelegram struct {
+	mutex sync.Mutex
+	calls []apiCall
+	// ответы на отдельные методы вместо ответа по умолчанию
+	replies map[string]string
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		r.ParseForm()
+	}
+	method := path.Base(r.URL.Path)
+	f.mutex.Lock()
+	f.calls = append(f.calls, apiCall{method: method, params: r.Form})
+	reply, ok := f.replies[method]
+	f.mutex.Unlock()
+	if ok {
+		w.Write([]byte(reply))
+		return
+	}
+	chatId := r.Form.Get("chat_id")
+	if chatId == "" {
+		chatId = "0"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"ok": true, "result": {"id": 1, "is_bot": true, "username": "safe_flight_bot", ` +
+		`"message_id": 1, "chat": {"id": ` + chatId + `}}}`))
+}
+
+//sent параметры запросов метода method в порядке отправки
+func (f *fakeTelegram) sent(method string) []url.Values {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	result := make([]url.Values, 0)
+	for _, call := range f.calls {
+		if call.method == method {
+			result = append(result, call.params)
+		}
+	}
+	return result
+}
+
+//fakeSource внешние сервисы без зон, погоды и населенных пунктов
+type fakeSource struct{}
+
+func (fakeSource) GetForecastWeather(context.Context, model.Coordinate) (*model.WeatherForecast, error) {
+	return nil, nil
+}
+
+func (fakeSource) GetCurrentWeather(context.Context, model.Coordinate) (*model.CurrentWeatherData, error) {
+	return nil, nil
+}
+
+func (fakeSource) CheckConditions(ctx context.Context, coordinate model.Coordinate, radius int, altitude int) (model.Condition, error) {
+	return model.Condition{}, nil
+}
+
+func (fakeSource) CheckConditionsForPeriod(ctx context.Context, coordinate model.Coordinate, radius int, altitude int, from time.Time, duration time.Duration) (model.Condition, error) {
+	return model.Condition{}, nil
+}
+
+func (fakeSource) GetLocalityFlyInfo(context.Context, model.Coordinate) (*flyDataClient.LocalityInfo, error) {
+	return nil, nil
+}
+
+func (fakeSource) SearchLocality(ctx context.Context, query string, limit int) ([]flyDataClient.Place, error) {
+	return nil, nil
+}
+
+//newTestBot бот с данными во временном каталоге и поддельным Bot API
+func newTestBot(t *testing.T) (*Bot, *fakeTelegram) {
+	t.Helper()
+	telegram := &fakeTelegram{}
+	server := httptest.NewServer(telegram)
+	t.Cleanup(server.Close)
+	api, err := tgbotapi.NewBotAPIWithClient("token", server.URL+"/bot%s/%s", server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	planner := flyPlanner.NewPlaner(filepath.Join(dir, "plans.json"))
+	users := userStorage.NewFileStorage(filepath.Join(dir, "users.json"))
+	if err := users.Init(); err != nil {
+		t.Fatal(err)
+	}
+	source := fakeSource{}
+	client := flyDataClient.Client{WeatherInfoSource: source, ZoneInfoSource: source, LocalityInfoSource: source}
+	return NewBot(api, client, planner, users, logbook.NewFileLogbook(filepath.Join(dir, "logbook.json"))), telegram
+}
+
+//buttons тексты кнопок клавиатуры сообщения
+func buttons(t *testing.T, params url.Values) []string {
+	t.Helper()
+	markup := tgbotapi.InlineKeyboardMarkup{}
+	if err := json.Unmarshal([]byte(params.Get("reply_markup")), &markup); err != nil {
+		t.Fatalf("reply_markup %q: %v", params.Get("reply_markup"), err)
+	}
+	result := make([]string, 0)
+	for _, row := range markup.InlineKeyboard {
+		for _, button := range row {
+			result = append(result, button.Text)
+		}
+	}
+	return result
+}
+
+func hasButton(buttons []string, prefix string) bool {
+	for _, text := range buttons {
+		if strings.HasPrefix(text, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSendPlanCreatedPermissionDoc(t *testing.T) {
+	tests := []struct {
+		name string
+		chat *tgbotapi.Chat
+		want bool
+	}{
+		{"private chat", &tgbotapi.Chat{ID: 1, Type: "private"}, true},
+		{"group", &tgbotapi.Chat{ID: -100, Type: "group"}, false},
+		{"supergroup", &tgbotapi.Chat{ID: -1001, Type: "supergroup"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot, telegram := newTestBot(t)
+			plan := model.FlyPlan{FlyId: 5, FlyDateTime: time.Now().Add(24 * time.Hour),
+				Data: model.FlyData{UserId: 1, Coordinate: model.Coordinate{Lat: 55.75, Lng: 37.61}}}
+			if err := bot.sendPlanCreated(context.Background(), tt.chat, plan); err != nil {
+				t.Fatal(err)
+			}
+			sent := telegram.sent("sendMessage")
+			if len(sent) != 1 {
+				t.Fatalf("sent %d messages", len(sent))
+			}
+			if got := hasButton(buttons(t, sent[0]), "📄 Заявление"); got != tt.want {
+				t.Errorf("permission document button = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
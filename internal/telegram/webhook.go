@@ -0,0 +1,287 @@
+package telegram
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/japersik/safe-flight-bot/logger"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+	maxUpdateSize     = 1 << 20
+	shutdownTimeout   = 15 * time.Second
+	//пауза после ошибки getUpdates
+	pollingRetryDelay = 3 * time.Second
+	//после стольких ошибок getUpdates подряд бот не готов (/readyz), пока прием не восстановится
+	pollingFailuresNotReady = 3
+)
+
+//допустимые символы secret_token по документации Bot API
+var secretTokenRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+//WebhookConfig параметры приема обновлений через webhook
+type WebhookConfig struct {
+	// адрес http сервера, например :8443
+	ListenAddr string
+	// внешний адрес, по которому Telegram отправляет обновления, например https://bot.example.com
+	URL string
+	// секретная часть пути webhook. Пустая - генерируется при запуске
+	PathSecret string
+	// значение заголовка X-Telegram-Bot-Api-Secret-Token. Пустое - генерируется при запуске
+	SecretToken string
+	// TLS сертификат и ключ. Не указаны - сервер работает по http за обратным прокси
+	CertFile string
+	KeyFile  string
+	// отправить сертификат в Telegram (для самоподписанных сертификатов)
+	UploadCert bool
+}
+
+func (c *WebhookConfig) prepare() error {
+	if c.ListenAddr == "" || c.URL == "" {
+		return errors.New("webhook listen address and url are required")
+	}
+	if !strings.HasPrefix(c.URL, "https://") {
+		return errors.New("webhook url must use https")
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return errors.New("both webhook certificate and key files are required for tls")
+	}
+	if c.UploadCert && c.CertFile == "" {
+		return errors.New("webhook certificate file is required to upload it")
+	}
+	var err error
+	if c.PathSecret == "" {
+		if c.PathSecret, err = randomSecret(); err != nil {
+			return err
+		}
+	}
+	if c.SecretToken == "" {
+		if c.SecretToken, err = randomSecret(); err != nil {
+			return err
+		}
+	}
+	if !secretTokenRegexp.MatchString(c.SecretToken) {
+		return errors.New("webhook secret token may contain only A-Z, a-z, 0-9, _ and -")
+	}
+	return nil
+}
+
+func (c WebhookConfig) path() string {
+	return "/telegram/" + c.PathSecret
+}
+
+func randomSecret() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+//Start запуск обработки обновлений через long polling. Завершается после отмены ctx и обработки полученных обновлений
+//или с ошибкой getUpdates, которую повтор не исправит
+func (b *Bot) Start(ctx context.Context) error {
+	//getUpdates не работает, пока установлен webhook
+	if _, err := b.bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		return fmt.Errorf("webhook removing error: %w", err)
+	}
+	updates := make(chan tgbotapi.Update, b.bot.Buffer)
+	pollErr := make(chan error, 1)
+	go func() { pollErr <- b.poll(ctx, updates) }()
+	for {
+		select {
+		case <-ctx.Done():
+			b.updatesWG.Wait()
+			return nil
+		case err := <-pollErr:
+			b.updatesWG.Wait()
+			return err
+		case update := <-updates:
+			b.processUpdate(update)
+		}
+	}
+}
+
+//poll получение обновлений через getUpdates до отмены ctx. Временные ошибки повторяются с паузой, а серия ошибок
+//видна в CheckTelegram. Ошибки, которые повтор не исправит, завершают прием
+func (b *Bot) poll(ctx context.Context, updates chan<- tgbotapi.Update) error {
+	config := tgbotapi.NewUpdate(0)
+	config.Timeout = 60
+	for ctx.Err() == nil {
+		received, err := b.bot.GetUpdates(config)
+		failures := b.setPollingResult(err)
+		if err != nil {
+			if permanentPollingErr(err) {
+				return fmt.Errorf("getUpdates error: %w", err)
+			}
+			if failures >= pollingFailuresNotReady {
+				logger.Error("getUpdates error", logger.Int("failures", failures), logger.Err(err))
+			} else {
+				logger.Warn("getUpdates error", logger.Int("failures", failures), logger.Err(err))
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(pollingRetryDelay):
+			}
+			continue
+		}
+		for _, update := range received {
+			if update.UpdateID < config.Offset {
+				continue
+			}
+			config.Offset = update.UpdateID + 1
+			select {
+			case updates <- update:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+//setPollingResult учет ошибок getUpdates подряд для CheckTelegram. Возвращает их число
+func (b *Bot) setPollingResult(err error) int {
+	b.telegramCheckMutex.Lock()
+	defer b.telegramCheckMutex.Unlock()
+	if err == nil {
+		b.pollingFailures = 0
+	} else {
+		b.pollingFailures++
+	}
+	b.pollingErr = err
+	return b.pollingFailures
+}
+
+//permanentPollingErr неверный токен или обновления получает другой экземпляр бота либо webhook
+func permanentPollingErr(err error) bool {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.Code {
+	case http.StatusUnauthorized, http.StatusNotFound, http.StatusConflict:
+		return true
+	}
+	return false
+}
+
+//StartWebhook запуск приема обновлений через webhook. Завершается после отмены ctx, остановки http сервера
+//и обработки полученных обновлений
+func (b *Bot) StartWebhook(ctx context.Context, config WebhookConfig) error {
+	if err := config.prepare(); err != nil {
+		return err
+	}
+	if err := b.setWebhook(config); err != nil {
+		return fmt.Errorf("webhook setting error: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle(config.path(), b.webhookHandler(config.SecretToken))
+	server := &http.Server{
+		Addr:         config.ListenAddr,
+		Handler:      mux,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		logger.Info("telegram webhook listening", logger.String("addr", config.ListenAddr))
+		if config.CertFile != "" {
+			errCh <- server.ListenAndServeTLS(config.CertFile, config.KeyFile)
+		} else {
+			errCh <- server.ListenAndServe()
+		}
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	//webhook не удаляется: обновления дождутся следующего запуска или будут приняты другим экземпляром
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err := server.Shutdown(shutdownCtx)
+	b.updatesWG.Wait()
+	return err
+}
+
+func (b *Bot) setWebhook(config WebhookConfig) error {
+	params := tgbotapi.Params{
+		"url":          strings.TrimSuffix(config.URL, "/") + config.path(),
+		"secret_token": config.SecretToken,
+	}
+	allowed, _ := json.Marshal([]string{"message", "edited_message", "callback_query", "inline_query"})
+	params["allowed_updates"] = string(allowed)
+	var (
+		resp *tgbotapi.APIResponse
+		err  error
+	)
+	if config.UploadCert {
+		resp, err = b.bot.UploadFiles("setWebhook", params, []tgbotapi.RequestFile{{
+			Name: "certificate",
+			Data: tgbotapi.FilePath(config.CertFile),
+		}})
+	} else {
+		resp, err = b.bot.MakeRequest("setWebhook", params)
+	}
+	if err != nil {
+		return err
+	}
+	if !resp.Ok {
+		return errors.New(resp.Description)
+	}
+	return nil
+}
+
+//webhookHandler прием обновлений от Telegram. Ответ отправляется сразу, обработка идет асинхронно
+func (b *Bot) webhookHandler(secretToken string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(secretTokenHeader)), []byte(secretToken)) != 1 {
+			logger.Warn("webhook request with wrong secret token", logger.String("remoteAddr", r.RemoteAddr))
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		var update tgbotapi.Update
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxUpdateSize)).Decode(&update); err != nil {
+			logger.Warn("webhook update decoding error", logger.Err(err))
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		b.processUpdate(update)
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+//processUpdate асинхронная обработка обновления с учетом для корректного завершения. Обновления сверх лимита
+//пользователя не обрабатываются: на первое из них отправляется предупреждение, на нажатия кнопок и inline
+//запросы - пустой ответ
+func (b *Bot) processUpdate(update tgbotapi.Update) {
+	decision := b.allowUpdate(update)
+	if !decision.Allowed && !decision.Warn && update.CallbackQuery == nil && update.InlineQuery == nil {
+		return
+	}
+	b.updatesWG.Add(1)
+	go func() {
+		defer b.updatesWG.Done()
+		if decision.Allowed {
+			b.manageUpdate(update)
+		} else {
+			b.sendUpdatesThrottled(update, decision)
+		}
+	}()
+}
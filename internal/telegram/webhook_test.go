@@ -0,0 +1,170 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPermanentPollingErr(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&tgbotapi.Error{Code: 401, Message: "Unauthorized"}, true},
+		{&tgbotapi.Error{Code: 404, Message: "Not Found"}, true},
+		{&tgbotapi.Error{Code: 409, Message: "Conflict: terminated by other getUpdates request"}, true},
+		{&tgbotapi.Error{Code: 429, Message: "Too Many Requests"}, false},
+		{&tgbotapi.Error{Code: 502, Message: "Bad Gateway"}, false},
+		{errors.New("dial tcp: i/o timeout"), false},
+	}
+	for _, tt := range tests {
+		if got := permanentPollingErr(tt.err); got != tt.want {
+			t.Errorf("permanentPollingErr(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestStartStopsOnConflict(t *testing.T) {
+	bot, telegram := newTestBot(t)
+	telegram.replies = map[string]string{
+		"getUpdates": `{"ok": false, "error_code": 409, "description": "Conflict: terminated by other getUpdates request"}`,
+	}
+	done := make(chan error, 1)
+	go func() { done <- bot.Start(context.Background()) }()
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "Conflict") {
+			t.Errorf("Start() error = %v, want the getUpdates conflict", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start() keeps polling after a conflict")
+	}
+}
+
+func TestStartProcessesUpdates(t *testing.T) {
+	bot, telegram := newTestBot(t)
+	telegram.replies = map[string]string{
+		"getUpdates": `{"ok": true, "result": [{"update_id": 7, "message": {"message_id": 1, "text": "/help",
+			"entities": [{"type": "bot_command", "offset": 0, "length": 5}],
+			"chat": {"id": 5, "type": "private"}, "from": {"id": 5, "first_name": "Pilot"}}}]}`,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- bot.Start(ctx) }()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(telegram.sent("sendMessage")) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	//Telegram возвращает обновление повторно, пока offset его не подтвердит
+	if sent := telegram.sent("sendMessage"); len(sent) != 1 {
+		t.Errorf("update 7 processed %d times", len(sent))
+	}
+	for _, params := range telegram.sent("getUpdates")[1:] {
+		if params.Get("offset") != "8" {
+			t.Errorf("getUpdates offset = %q, want 8", params.Get("offset"))
+		}
+	}
+}
+
+func TestCheckTelegramPollingFailures(t *testing.T) {
+	bot, _ := newTestBot(t)
+	timeout := errors.New("dial tcp: i/o timeout")
+	for i := 1; i < pollingFailuresNotReady; i++ {
+		bot.setPollingResult(timeout)
+		if err := bot.CheckTelegram(); err != nil {
+			t.Fatalf("not ready after %d failures: %v", i, err)
+		}
+	}
+	bot.setPollingResult(timeout)
+	if err := bot.CheckTelegram(); !errors.Is(err, timeout) {
+		t.Errorf("CheckTelegram() = %v, want the getUpdates error", err)
+	}
+	bot.setPollingResult(nil)
+	if err := bot.CheckTelegram(); err != nil {
+		t.Errorf("CheckTelegram() after recovery = %v", err)
+	}
+}
+
+func TestWebhookHandler(t *testing.T) {
+	update := `{"update_id": 3, "message": {"message_id": 1, "text": "/help",
+		"entities": [{"type": "bot_command", "offset": 0, "length": 5}],
+		"chat": {"id": 5, "type": "private"}, "from": {"id": 5, "first_name": "Pilot"}}}`
+	tests := []struct {
+		name    string
+		method  string
+		secret  string
+		body    string
+		status  int
+		answers int
+	}{
+		{"update", http.MethodPost, "s3cret", update, http.StatusOK, 1},
+		{"without secret token", http.MethodPost, "", update, http.StatusForbidden, 0},
+		{"wrong secret token", http.MethodPost, "s3cre", update, http.StatusForbidden, 0},
+		{"get", http.MethodGet, "s3cret", "", http.StatusMethodNotAllowed, 0},
+		{"broken json", http.MethodPost, "s3cret", `{"update_id": `, http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot, telegram := newTestBot(t)
+			r := httptest.NewRequest(tt.method, "/telegram/path", strings.NewReader(tt.body))
+			if tt.secret != "" {
+				r.Header.Set(secretTokenHeader, tt.secret)
+			}
+			w := httptest.NewRecorder()
+			bot.webhookHandler("s3cret").ServeHTTP(w, r)
+			bot.updatesWG.Wait()
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if got := len(telegram.sent("sendMessage")); got != tt.answers {
+				t.Errorf("answered %d times, want %d", got, tt.answers)
+			}
+		})
+	}
+}
+
+func TestWebhookConfigPrepare(t *testing.T) {
+	valid := WebhookConfig{ListenAddr: ":8443", URL: "https://bot.example.com"}
+	config := valid
+	if err := config.prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if config.PathSecret == "" || !secretTokenRegexp.MatchString(config.SecretToken) {
+		t.Errorf("generated secrets %q, %q", config.PathSecret, config.SecretToken)
+	}
+	another := valid
+	another.prepare()
+	if another.PathSecret == config.PathSecret || another.SecretToken == config.SecretToken {
+		t.Error("generated secrets repeat")
+	}
+	config = valid
+	config.PathSecret, config.SecretToken = "path", "token_1-A"
+	if err := config.prepare(); err != nil || config.path() != "/telegram/path" || config.SecretToken != "token_1-A" {
+		t.Errorf("configured secrets changed: %+v, %v", config, err)
+	}
+
+	invalid := []func(c *WebhookConfig){
+		func(c *WebhookConfig) { c.ListenAddr = "" },
+		func(c *WebhookConfig) { c.URL = "http://bot.example.com" },
+		func(c *WebhookConfig) { c.CertFile = "cert.pem" },
+		func(c *WebhookConfig) { c.UploadCert = true },
+		func(c *WebhookConfig) { c.SecretToken = "token with spaces" },
+	}
+	for i, change := range invalid {
+		config := valid
+		change(&config)
+		if err := config.prepare(); err == nil {
+			t.Errorf("invalid config %d accepted: %+v", i, config)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"github.com/japersik/safe-flight-bot/logger"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+const (
+	serviceName     = "safe-flight-bot"
+	instrumentation = "github.com/japersik/safe-flight-bot"
+)
+
+//Setup регистрирует глобальный провайдер трассировок. До вызова Setup и при exporter none спаны не записываются.
+//Возвращаемая функция отправляет накопленные спаны и должна вызываться при остановке
+func Setup(ctx context.Context, exporter, endpoint string, sampleRatio float64) (func(context.Context) error, error) {
+	var (
+		spanExporter sdktrace.SpanExporter
+		err          error
+	)
+	switch exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		var options []otlptracehttp.Option
+		options, err = otlpOptions(endpoint)
+		if err == nil {
+			spanExporter, err = otlptracehttp.New(ctx, options...)
+		}
+	default:
+		err = fmt.Errorf("unknown exporter %q", exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tracing exporter: %w", err)
+	}
+	res, err := resource.Merge(resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, fmt.Errorf("tracing resource: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return provider.Shutdown, nil
+}
+
+//otlpOptions endpoint - адрес коллектора OTLP/HTTP вида http://host:4318. Пустой endpoint -
+//адрес из OTEL_EXPORTER_OTLP_ENDPOINT
+func otlpOptions(endpoint string) ([]otlptracehttp.Option, error) {
+	if endpoint == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %q", endpoint)
+	}
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(u.Host)}
+	if u.Scheme == "http" {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+	if u.Path != "" && u.Path != "/" {
+		options = append(options, otlptracehttp.WithURLPath(u.Path))
+	}
+	return options, nil
+}
+
+//Start новый спан, дочерний к спану из ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentation).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+//End завершение спана. Ошибка сохраняется в спане и меняет его статус
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+//WithTraceId добавляет traceId записываемого спана из ctx в поля логгера, чтобы по записи лога найти трассу
+func WithTraceId(ctx context.Context) context.Context {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsSampled() {
+		return ctx
+	}
+	return logger.NewContext(ctx, logger.FromContext(ctx).With(logger.String("traceId", spanContext.TraceID().String())))
+}
+
+//Transport спан на каждый запрос к внешнему сервису. Спан дочерний к спану из контекста запроса, заголовок
+//traceparent передается сервису. endpoint - имя спана с ограниченным числом значений, как метка в метриках.
+//next == nil - http.DefaultTransport
+func Transport(service string, endpoint func(r *http.Request) string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return otelhttp.NewTransport(next,
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return service + " " + r.Method + " " + endpoint(r)
+		}),
+		otelhttp.WithSpanOptions(trace.WithAttributes(attribute.String("peer.service", service))),
+	)
+}
+
+//OpaqueTransport спан на каждый запрос без адреса запроса в атрибутах и без передачи traceparent сервису.
+//Используется для Telegram Bot API: адрес запроса содержит токен бота. next == nil - http.DefaultTransport
+func OpaqueTransport(service string, endpoint func(r *http.Request) string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &opaqueTransport{service: service, endpoint: endpoint, next: next}
+}
+
+type opaqueTransport struct {
+	service  string
+	endpoint func(r *http.Request) string
+	next     http.RoundTripper
+}
+
+func (t *opaqueTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ctx, span := otel.Tracer(instrumentation).Start(r.Context(), t.service+" "+r.Method+" "+t.endpoint(r),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("peer.service", t.service), attribute.String("http.method", r.Method)))
+	resp, err := t.next.RoundTrip(r.WithContext(ctx))
+	if err == nil {
+		span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+		if resp.StatusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, resp.Status)
+		}
+	}
+	End(span, err)
+	return resp, err
+}
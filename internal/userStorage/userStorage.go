@@ -0,0 +1,381 @@
+package userStorage
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/japersik/safe-flight-bot/logger"
+	"github.com/japersik/safe-flight-bot/model"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	PlaceNotExistErr = errors.New("place not exist")
+	DroneNotExistErr = errors.New("drone not exist")
+)
+
+type Storage interface {
+	AddPlace(userId int64, place model.FavouritePlace) (placeId uint64, err error)
+	GetPlaces(userId int64) []model.FavouritePlace
+	GetPlace(userId int64, placeId uint64) (model.FavouritePlace, error)
+	DeletePlace(userId int64, placeId uint64) error
+	GetChecklist(userId int64) []string
+	SetChecklist(userId int64, items []string) error
+	AddDrone(userId int64, drone model.Drone) (droneId uint64, err error)
+	GetFleet(userId int64) []model.Drone
+	GetDrone(userId int64, droneId uint64) (model.Drone, error)
+	DeleteDrone(userId int64, droneId uint64) error
+	GetOperator(userId int64) (operator model.Operator, ok bool)
+	SetOperator(userId int64, operator model.Operator) error
+	//CalendarToken returns secret token of user calendar feed, creating it if needed
+	CalendarToken(userId int64) (string, error)
+	ResetCalendarToken(userId int64) (string, error)
+	UserByCalendarToken(token string) (userId int64, ok bool)
+	//APIToken returns REST API access token of the user, creating it if needed
+	APIToken(userId int64) (string, error)
+	ResetAPIToken(userId int64) (string, error)
+	UserByAPIToken(token string) (userId int64, ok bool)
+	GetNotifications(userId int64) []time.Duration
+	SetNotifications(userId int64, offsets []time.Duration) error
+	//UserIds returns ids of users with stored data
+	UserIds() []int64
+}
+
+type usersData struct {
+	MaxPlaceId uint64                       `json:"maxPlaceId"`
+	MaxDroneId uint64                       `json:"maxDroneId"`
+	Users      map[int64]*model.UserProfile `json:"users,omitempty"`
+	usersMutex *sync.Mutex
+}
+
+//FileStorage хранение данных пользователей в json файле
+type FileStorage struct {
+	usersData *usersData
+	filepath  string
+}
+
+//NewFileStorage ...
+func NewFileStorage(filepath string) *FileStorage {
+	return &FileStorage{
+		usersData: &usersData{
+			MaxPlaceId: 0,
+			Users:      map[int64]*model.UserProfile{},
+			usersMutex: &sync.Mutex{},
+		},
+		filepath: filepath,
+	}
+}
+
+//profile returns user profile, creating new one if needed. usersMutex must be locked
+func (s *FileStorage) profile(userId int64) *model.UserProfile {
+	profile, ok := s.usersData.Users[userId]
+	if !ok {
+		profile = &model.UserProfile{UserId: userId}
+		s.usersData.Users[userId] = profile
+	}
+	return profile
+}
+
+//AddPlace ...
+func (s *FileStorage) AddPlace(userId int64, place model.FavouritePlace) (uint64, error) {
+	s.usersData.usersMutex.Lock()
+	defer s.usersData.usersMutex.Unlock()
+	s.usersData.MaxPlaceId++
+	place.PlaceId = s.usersData.MaxPlaceId
+	profile := s.profile(userId)
+	profile.Places = append(profile.Places, place)
+	logger.Info("place saved", logger.Any("placeId", place.PlaceId), logger.UserId(userId), logger.Any("coordinate", place.Coordinate))
+	return place.PlaceId, nil
+}
+
+//GetPlaces ...
+func (s *FileStorage) GetPlaces(userId int64) []model.FavouritePlace {
+	s.usersData.usersMutex.Lock()
+	defer s.usersData.usersMutex.Unlock()
+	profile, ok := s.usersData.Users[userId]
+	if !ok {
+		return nil
+	}
+	return append([]model.FavouritePlace{}, profile.Places...)
+}
+
+//GetPlace ...
+func (s *FileStorage) GetPlace(userId int64, placeId uint64) (model.FavouritePlace, error) {
+	for _, place := range s.GetPlaces(userId) {
+		if place.PlaceId == placeId {
+			return place, nil
+		}
+	}
+	return model.FavouritePlace{}, PlaceNotExistErr
+}
+
+//DeletePlace ...
+func (s *FileStorage) DeletePlace(userId int64, placeId uint64) error {
+	s.usersData.usersMutex.Lock()
+	defer s.usersData.usersMutex.Unlock()
+	profile, ok := s.usersData.Users[userId]
+	if !ok {
+		return PlaceNotExistErr
+	}
+	for i, place := range profile.Places {
+		if place.PlaceId == placeId {
+			profile.Places = append(profile.Places[:i], profile.Places[i+1:]...)
+			logger.Info("place deleted", logger.Any("placeId", placeId), logger.UserId(userId))
+			return nil
+		}
+	}
+	return PlaceNotExistErr
+}
+
+//GetChecklist returns user checklist or model.DefaultChecklist if user has not set it
+func (s *FileStorage) GetChecklist(userId int64) []string {
+	s.usersData.usersMutex.Lock()
+	defer s.usersData.usersMutex.Unlock()
+	profile, ok := s.usersData.Users[userId]
+	if !ok || profile.Checklist == nil {
+		return append([]string{}, model.DefaultChecklist...)
+	}
+	return append([]string{}, profile.Checklist...)
+}
+
+//SetChecklist ...
+func (s *FileStorage) SetChecklist(userId int64, items []string) error {
+	s.usersData.usersMutex.Lock()
+	defer s.usersData.usersMutex.Unlock()
+	s.profile(userId).Checklist = append([]string{}, items...)
+	return nil
+}
+
+//AddDrone ...
+func (s *FileStorage) AddDrone(userId int64, drone model.Drone) (uint64, error) {
+	s.usersData.usersMutex.Lock()
+	defer s.usersData.usersMutex.Unlock()
+	s.usersData.MaxDroneId++
+	drone.DroneId = s.usersData.MaxDroneId
+	profile := s.profile(userId)
+	profile.Fleet = append(profile.Fleet, drone)
+	logger.Info("drone added", logger.Any("droneId", drone.DroneId), logger.UserId(userId))
+	return drone.DroneId, nil
+}
+
+//GetFleet ...
+func (s *FileStorage) GetFleet(userId int64) []model.Drone {
+	s.usersData.usersMutex.Lock()
+	defer s.usersData.usersMutex.Unlock()
+	profile, ok := s.usersData.Users[userId]
+	if !ok {
+		return nil
+	}
+	return append([]model.Drone{}, profile.Fleet...)
+}
+
+//GetDrone ...
+func (s *FileStorage) GetDrone(userId int64, droneId uint64) (model.Drone, error) {
+	for _, drone := range s.GetFleet(userId) {
+		if drone.DroneId == droneId {
+			return drone, nil
+		}
+	}
+	return model.Drone{}, DroneNotExistErr
+}
+
+//DeleteDrone ...
+func (s *FileStorage) DeleteDrone(userId int64, droneId uint64) error {
+	s.usersData.usersMutex.Lock()
+	defer s.usersData.usersMutex.Unlock()
+	profile, ok := s.usersData.Users[userId]
+	if !ok {
+		return DroneNotExistErr
+	}
+	for i, drone := range profile.Fleet {
+		if drone.DroneId == droneId {
+			profile.Fleet = append(profile.Fleet[:i], profile.Fleet[i+1:]...)
+			logger.Info("drone deleted", logger.Any("droneId", droneId), logger.UserId(userId))
+			return nil
+		}
+	}
+	return DroneNotExistErr
+}
+
+//GetOperator ...
+func (s *FileStorage) GetOperator(userId int64) (model.Operator, bool) {
+	s.usersData.usersMutex.Lock()
+	defer s.usersData.usersMutex.Unlock()
+	profile, ok := s.usersData.Users[userId]
+	if !ok || profile.Operator == nil {
+		return model.Operator{}, false
+	}
+	return *profile.Operator, true
+}
+
+//SetOperator ...
+func (s *FileStorage) SetOperator(userId int64, operator model.Operator) error {
+	s.usersData.usersMutex.Lock()
+	defer s.usersData.usersMutex.Unlock()
+	s.profile(userId).Operator = &operator
+	return nil
+}
+
+//CalendarToken ...
+func (s *FileStorage) CalendarToken(userId int64) (string, error) {
+	s.usersData.usersMutex.Lock()
+	defer s.usersData.usersMutex.Unlock()
+	profile := s.profile(userId)
+	if profile.CalendarToken == "" {
+		token, err := newToken()
+		if err != nil {
+			return "", err
+		}
+		profile.CalendarToken = token
+	}
+	return profile.CalendarToken, nil
+}
+
+//ResetCalendarToken делает старую ссылку на календарь недействительной
+func (s *FileStorage) ResetCalendarToken(userId int64) (string, error) {
+	s.usersData.usersMutex.Lock()
+	defer s.usersData.usersMutex.Unlock()
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	s.profile(userId).CalendarToken = token
+	return token, nil
+}
+
+//UserByCalendarToken ...
+func (s *FileStorage) UserByCalendarToken(token string) (int64, bool) {
+	return s.userByToken(token, func(profile *model.UserProfile) string { return profile.CalendarToken })
+}
+
+//APIToken ...
+func (s *FileStorage) APIToken(userId int64) (string, error) {
+	s.usersData.usersMutex.Lock()
+	defer s.usersData.usersMutex.Unlock()
+	profile := s.profile(userId)
+	if profile.APIToken == "" {
+		token, err := newToken()
+		if err != nil {
+			return "", err
+		}
+		profile.APIToken = token
+	}
+	return profile.APIToken, nil
+}
+
+//ResetAPIToken отзыв старого токена доступа к API
+func (s *FileStorage) ResetAPIToken(userId int64) (string, error) {
+	s.usersData.usersMutex.Lock()
+	defer s.usersData.usersMutex.Unlock()
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	s.profile(userId).APIToken = token
+	return token, nil
+}
+
+//UserByAPIToken ...
+func (s *FileStorage) UserByAPIToken(token string) (int64, bool) {
+	return s.userByToken(token, func(profile *model.UserProfile) string { return profile.APIToken })
+}
+
+//userByToken поиск пользователя по секретному токену со сравнением за постоянное время
+func (s *FileStorage) userByToken(token string, field func(profile *model.UserProfile) string) (int64, bool) {
+	s.usersData.usersMutex.Lock()
+	defer s.usersData.usersMutex.Unlock()
+	if token == "" {
+		return 0, false
+	}
+	for userId, profile := range s.usersData.Users {
+		if subtle.ConstantTimeCompare([]byte(field(profile)), []byte(token)) == 1 {
+			return userId, true
+		}
+	}
+	return 0, false
+}
+
+func newToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+//GetNotifications returns user notification offsets or model.DefaultNotifications() if user has not set them
+func (s *FileStorage) GetNotifications(userId int64) []time.Duration {
+	s.usersData.usersMutex.Lock()
+	defer s.usersData.usersMutex.Unlock()
+	profile, ok := s.usersData.Users[userId]
+	if !ok || len(profile.Notifications) == 0 {
+		return model.DefaultNotifications()
+	}
+	return append([]time.Duration{}, profile.Notifications...)
+}
+
+//SetNotifications ...
+func (s *FileStorage) SetNotifications(userId int64, offsets []time.Duration) error {
+	s.usersData.usersMutex.Lock()
+	defer s.usersData.usersMutex.Unlock()
+	s.profile(userId).Notifications = append([]time.Duration{}, offsets...)
+	return nil
+}
+
+//UserIds ...
+func (s *FileStorage) UserIds() []int64 {
+	s.usersData.usersMutex.Lock()
+	defer s.usersData.usersMutex.Unlock()
+	ids := make([]int64, 0, len(s.usersData.Users))
+	for id := range s.usersData.Users {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+//Init ...
+func (s *FileStorage) Init() error {
+	usersData := &usersData{
+		MaxPlaceId: 0,
+		Users:      map[int64]*model.UserProfile{},
+		usersMutex: &sync.Mutex{},
+	}
+	file, err := os.OpenFile(s.filepath, os.O_RDONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	err = decoder.Decode(usersData)
+	if err != nil {
+		if err == io.EOF {
+			logger.Info("creating new users data file", logger.String("file", s.filepath))
+			return nil
+		}
+		return err
+	}
+	if usersData.Users == nil {
+		usersData.Users = map[int64]*model.UserProfile{}
+	}
+	s.usersData = usersData
+	logger.Info("users data file loaded", logger.String("file", s.filepath), logger.Int("users", len(usersData.Users)))
+	return nil
+}
+
+//Save ...
+func (s *FileStorage) Save() error {
+	s.usersData.usersMutex.Lock()
+	defer s.usersData.usersMutex.Unlock()
+	file, err := os.OpenFile(s.filepath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	encoder := json.NewEncoder(file)
+	return encoder.Encode(s.usersData)
+}
@@ -0,0 +1,81 @@
+package userStorage
+
+import (
+	"github.com/japersik/safe-flight-bot/logger"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	logger.NewInstance(logger.NewZapLogger(zap.NewNop()))
+	os.Exit(m.Run())
+}
+
+func newTestStorage(t *testing.T, path string) *FileStorage {
+	t.Helper()
+	storage := NewFileStorage(path)
+	if err := storage.Init(); err != nil {
+		t.Fatal(err)
+	}
+	return storage
+}
+
+func TestTokens(t *testing.T) {
+	kinds := []struct {
+		name   string
+		get    func(s *FileStorage, userId int64) (string, error)
+		reset  func(s *FileStorage, userId int64) (string, error)
+		lookup func(s *FileStorage, token string) (int64, bool)
+	}{
+		{"calendar", (*FileStorage).CalendarToken, (*FileStorage).ResetCalendarToken, (*FileStorage).UserByCalendarToken},
+		{"api", (*FileStorage).APIToken, (*FileStorage).ResetAPIToken, (*FileStorage).UserByAPIToken},
+	}
+	for i, kind := range kinds {
+		t.Run(kind.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "users.json")
+			storage := newTestStorage(t, path)
+			if _, ok := kind.lookup(storage, ""); ok {
+				t.Error("empty token matched a user without token")
+			}
+			token, err := kind.get(storage, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			other, _ := kind.get(storage, 2)
+			if len(token) != 32 || token == other {
+				t.Fatalf("tokens %q and %q", token, other)
+			}
+			if again, _ := kind.get(storage, 1); again != token {
+				t.Error("token changed on the second request")
+			}
+			if userId, ok := kind.lookup(storage, token); !ok || userId != 1 {
+				t.Errorf("lookup = %d, %v", userId, ok)
+			}
+			//токен другого вида не подходит
+			another := kinds[(i+1)%len(kinds)]
+			if _, ok := another.lookup(storage, token); ok {
+				t.Errorf("%s token accepted as %s token", kind.name, another.name)
+			}
+
+			reset, err := kind.reset(storage, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := kind.lookup(storage, token); ok || reset == token {
+				t.Error("old token works after reset")
+			}
+			if err := storage.Save(); err != nil {
+				t.Fatal(err)
+			}
+			loaded := newTestStorage(t, path)
+			if userId, ok := kind.lookup(loaded, reset); !ok || userId != 1 {
+				t.Errorf("token lost after reload: %d, %v", userId, ok)
+			}
+			if userId, ok := kind.lookup(loaded, other); !ok || userId != 2 {
+				t.Errorf("token of another user lost after reload: %d, %v", userId, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+type contextKey struct{}
+
+//NewContext ctx с логгером l
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+//FromContext логгер из ctx. Если его нет, логгер без полей
+func FromContext(ctx context.Context) *Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+			return l
+		}
+	}
+	return root
+}
+
+//WithCorrelation ctx с логгером, дополненным новым correlationId и fields
+func WithCorrelation(ctx context.Context, fields ...Field) context.Context {
+	return NewContext(ctx, FromContext(ctx).With(append([]Field{CorrelationId(NewCorrelationId())}, fields...)...))
+}
+
+//NewCorrelationId случайный идентификатор из 16 шестнадцатеричных символов
+func NewCorrelationId() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,43 @@
+package logger
+
+import (
+	"context"
+	"regexp"
+	"testing"
+)
+
+func TestWithCorrelation(t *testing.T) {
+	if FromContext(context.Background()) != root || FromContext(nil) != root {
+		t.Error("context without logger does not give the root logger")
+	}
+	observed.TakeAll()
+	first := WithCorrelation(context.Background(), Int64("userId", 5))
+	second := WithCorrelation(context.Background())
+	FromContext(first).Info("update", String("type", "message"))
+	FromContext(first).Warn("send error")
+	FromContext(second).Info("update")
+
+	entries := observed.TakeAll()
+	if len(entries) != 3 {
+		t.Fatalf("entries = %v", entries)
+	}
+	ids := make([]string, len(entries))
+	for i, entry := range entries {
+		fields := entry.ContextMap()
+		id, _ := fields["correlationId"].(string)
+		if !regexp.MustCompile(`^[0-9a-f]{16}$`).MatchString(id) {
+			t.Errorf("entry %d correlationId = %q", i, fields["correlationId"])
+		}
+		ids[i] = id
+	}
+	//записи одного обновления связаны общим идентификатором, разных - различаются
+	if ids[0] != ids[1] || ids[0] == ids[2] {
+		t.Errorf("correlation ids = %v", ids)
+	}
+	if fields := entries[0].ContextMap(); fields["userId"] != int64(5) || fields["type"] != "message" {
+		t.Errorf("first entry fields = %v", fields)
+	}
+	if _, ok := entries[2].ContextMap()["userId"]; ok {
+		t.Error("fields of one context leaked into another")
+	}
+}
@@ -0,0 +1,69 @@
+package logger
+
+import "time"
+
+//Field типизированное поле записи
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+//String ...
+func String(key, value string) Field {
+	return Field{Key: key, Value: value}
+}
+
+//Int ...
+func Int(key string, value int) Field {
+	return Field{Key: key, Value: value}
+}
+
+//Int64 ...
+func Int64(key string, value int64) Field {
+	return Field{Key: key, Value: value}
+}
+
+//Bool ...
+func Bool(key string, value bool) Field {
+	return Field{Key: key, Value: value}
+}
+
+//Any значение произвольного типа, сериализуется реализацией логгера
+func Any(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+//Err ошибка в поле error. nil ошибка не выводится
+func Err(err error) Field {
+	return Field{Key: "error", Value: err}
+}
+
+//UserId id пользователя Telegram
+func UserId(id int64) Field {
+	return Field{Key: "userId", Value: id}
+}
+
+//ChatId ...
+func ChatId(id int64) Field {
+	return Field{Key: "chatId", Value: id}
+}
+
+//FlyId номер плана полёта
+func FlyId(id uint64) Field {
+	return Field{Key: "flyId", Value: id}
+}
+
+//Endpoint адрес внешнего сервиса или http обработчика
+func Endpoint(endpoint string) Field {
+	return Field{Key: "endpoint", Value: endpoint}
+}
+
+//Duration ...
+func Duration(d time.Duration) Field {
+	return Field{Key: "duration", Value: d}
+}
+
+//CorrelationId общий идентификатор записей, относящихся к одному обновлению, запросу или уведомлению
+func CorrelationId(id string) Field {
+	return Field{Key: "correlationId", Value: id}
+}
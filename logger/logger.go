@@ -2,7 +2,7 @@ package logger
 
 import (
 	"errors"
-	"fmt"
+	"os"
 	"sync"
 )
 
@@ -11,82 +11,132 @@ var (
 	loggerAlreadyDefinedErr = errors.New("logger already defined")
 )
 
-var once sync.Once
+//Level уровень записи
+type Level int8
 
-type logger struct {
-	loggerI
-}
+const (
+	DebugLevel Level = iota - 1
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+	//FatalLevel после записи программа завершается
+	FatalLevel
+)
+
+var once sync.Once
 
-var loggerInstance *logger
+var loggerInstance loggerI
 
-//func GetInstance() *logger {
-//	if loggerInstance == nil {
-//		panic("logger not defined")
-//		return nil
-//	}
-//	return loggerInstance
-//}
+//exit завершение программы после Fatal
+var exit = os.Exit
 
+//NewInstance установка реализации логгера. Вызывается один раз при запуске
 func NewInstance(i loggerI) error {
 	if loggerInstance == nil {
 		once.Do(func() {
-			loggerInstance = &logger{i}
+			loggerInstance = i
 		})
 		return nil
 	}
-	loggerInstance.Error(loggerAlreadyDefinedErr.Error())
+	loggerInstance.Log(ErrorLevel, loggerAlreadyDefinedErr.Error(), nil)
 	return loggerAlreadyDefinedErr
-
 }
 
 func checkLoggerSetup() {
 	if loggerInstance == nil {
-		panic("logger not defined")
+		panic(loggerNotDefinedErr)
 	}
 }
-func Debug(a ...any) {
-	checkLoggerSetup()
-	loggerInstance.loggerI.Debug(fmt.Sprint(a...))
+
+//Logger логгер с полями контекста, которые добавляются к каждой записи
+type Logger struct {
+	fields []Field
 }
 
-func Info(a ...any) {
-	checkLoggerSetup()
-	loggerInstance.loggerI.Info(fmt.Sprint(a...))
+//root логгер без полей, используется функциями пакета
+var root = &Logger{}
+
+//With логгер с полями fields
+func With(fields ...Field) *Logger {
+	return root.With(fields...)
 }
 
-func Error(a ...any) {
-	checkLoggerSetup()
-	loggerInstance.loggerI.Error(fmt.Sprint(a...))
+//With логгер с полями l и fields
+func (l *Logger) With(fields ...Field) *Logger {
+	joined := make([]Field, 0, len(l.fields)+len(fields))
+	joined = append(joined, l.fields...)
+	return &Logger{fields: append(joined, fields...)}
 }
 
-func Fatal(a ...any) {
+func (l *Logger) log(level Level, msg string, fields []Field) {
 	checkLoggerSetup()
-	loggerInstance.loggerI.Debug(fmt.Sprint(a...))
+	if len(l.fields) > 0 {
+		fields = append(append(make([]Field, 0, len(l.fields)+len(fields)), l.fields...), fields...)
+	}
+	loggerInstance.Log(level, msg, fields)
+	if level == FatalLevel {
+		loggerInstance.Sync()
+		exit(1)
+	}
 }
 
-func DebugF(format string, a ...any) {
-	checkLoggerSetup()
-	loggerInstance.loggerI.Debug(fmt.Sprintf(format, a...))
+//Debug ...
+func (l *Logger) Debug(msg string, fields ...Field) {
+	l.log(DebugLevel, msg, fields)
 }
 
-func InfoF(format string, a ...any) {
-	checkLoggerSetup()
-	loggerInstance.loggerI.Info(fmt.Sprintf(format, a...))
+//Info ...
+func (l *Logger) Info(msg string, fields ...Field) {
+	l.log(InfoLevel, msg, fields)
 }
 
-func ErrorF(format string, a ...any) {
-	checkLoggerSetup()
-	loggerInstance.loggerI.Error(fmt.Sprintf(format, a...))
+//Warn ...
+func (l *Logger) Warn(msg string, fields ...Field) {
+	l.log(WarnLevel, msg, fields)
+}
+
+//Error ...
+func (l *Logger) Error(msg string, fields ...Field) {
+	l.log(ErrorLevel, msg, fields)
+}
+
+//Fatal запись и завершение программы с кодом 1
+func (l *Logger) Fatal(msg string, fields ...Field) {
+	l.log(FatalLevel, msg, fields)
+}
+
+//Debug ...
+func Debug(msg string, fields ...Field) {
+	root.log(DebugLevel, msg, fields)
+}
+
+//Info ...
+func Info(msg string, fields ...Field) {
+	root.log(InfoLevel, msg, fields)
+}
+
+//Warn ...
+func Warn(msg string, fields ...Field) {
+	root.log(WarnLevel, msg, fields)
+}
+
+//Error ...
+func Error(msg string, fields ...Field) {
+	root.log(ErrorLevel, msg, fields)
+}
+
+//Fatal запись и завершение программы с кодом 1
+func Fatal(msg string, fields ...Field) {
+	root.log(FatalLevel, msg, fields)
 }
 
-func FatalF(format string, a ...any) {
+//Sync запись буферизованных сообщений, вызывается перед завершением программы
+func Sync() error {
 	checkLoggerSetup()
-	loggerInstance.loggerI.Fatal(fmt.Sprintf(format, a...))
+	return loggerInstance.Sync()
 }
 
 type loggerI interface {
-	Debug(str string)
-	Info(str string)
-	Error(str string)
-	Fatal(str string)
+	Log(level Level, msg string, fields []Field)
+	Sync() error
 }
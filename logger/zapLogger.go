@@ -1,6 +1,10 @@
 package logger
 
-import "go.uber.org/zap"
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"time"
+)
 
 type zapLogger struct {
 	logger *zap.Logger
@@ -10,18 +14,58 @@ func NewZapLogger(log *zap.Logger) *zapLogger {
 	return &zapLogger{logger: log}
 
 }
-func (z zapLogger) Debug(str string) {
-	z.logger.Debug(str)
+
+var zapLevels = map[Level]zapcore.Level{
+	DebugLevel: zapcore.DebugLevel,
+	InfoLevel:  zapcore.InfoLevel,
+	WarnLevel:  zapcore.WarnLevel,
+	ErrorLevel: zapcore.ErrorLevel,
+	FatalLevel: zapcore.FatalLevel,
+}
+
+func (z zapLogger) Log(level Level, msg string, fields []Field) {
+	entry := z.check(level, msg)
+	if entry == nil {
+		return
+	}
+	zapFields := make([]zap.Field, 0, len(fields))
+	for _, field := range fields {
+		zapFields = append(zapFields, zapField(field))
+	}
+	entry.Write(zapFields...)
 }
 
-func (z zapLogger) Info(str string) {
-	z.logger.Info(str)
+//check запись FatalLevel проверяется ядром zap в обход zap.Logger: он сам завершил бы программу после записи,
+//а завершение выполняет logger.log через exit. zap v1.21 не позволяет отключить это опцией OnFatal
+func (z zapLogger) check(level Level, msg string) *zapcore.CheckedEntry {
+	if level != FatalLevel {
+		return z.logger.Check(zapLevels[level], msg)
+	}
+	return z.logger.Core().Check(zapcore.Entry{Level: zapcore.FatalLevel, Time: time.Now(), Message: msg}, nil)
 }
 
-func (z zapLogger) Error(str string) {
-	z.logger.Error(str)
+func (z zapLogger) Sync() error {
+	return z.logger.Sync()
 }
 
-func (z zapLogger) Fatal(str string) {
-	z.logger.Fatal(str)
+func zapField(field Field) zap.Field {
+	switch value := field.Value.(type) {
+	case string:
+		return zap.String(field.Key, value)
+	case int:
+		return zap.Int(field.Key, value)
+	case int64:
+		return zap.Int64(field.Key, value)
+	case uint64:
+		return zap.Uint64(field.Key, value)
+	case bool:
+		return zap.Bool(field.Key, value)
+	case time.Duration:
+		return zap.Duration(field.Key, value)
+	case error:
+		return zap.NamedError(field.Key, value)
+	case nil:
+		return zap.Skip()
+	}
+	return zap.Any(field.Key, field.Value)
 }
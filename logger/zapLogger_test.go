@@ -0,0 +1,66 @@
+package logger
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"os"
+	"testing"
+)
+
+//observed записи логгера пакета, установленного в TestMain
+var observed *observer.ObservedLogs
+
+func TestMain(m *testing.M) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	observed = logs
+	NewInstance(NewZapLogger(zap.New(core)))
+	os.Exit(m.Run())
+}
+
+//TestFatal zap.Logger по умолчанию завершает программу на FatalLevel. Если бы запись шла через него,
+//тестовый процесс завершился бы вместо проверки кода выхода
+func TestFatal(t *testing.T) {
+	codes := []int{}
+	exit = func(code int) { codes = append(codes, code) }
+	defer func() { exit = os.Exit }()
+	observed.TakeAll()
+
+	With(String("service", "avtm")).Fatal("config error", Int("attempt", 2))
+
+	if len(codes) != 1 || codes[0] != 1 {
+		t.Errorf("exit codes = %v, want [1]", codes)
+	}
+	entries := observed.TakeAll()
+	if len(entries) != 1 {
+		t.Fatalf("entries = %v", entries)
+	}
+	entry := entries[0]
+	if entry.Level != zapcore.FatalLevel || entry.Message != "config error" || entry.Time.IsZero() {
+		t.Errorf("entry = %+v", entry.Entry)
+	}
+	if fields := entry.ContextMap(); fields["service"] != "avtm" || fields["attempt"] != int64(2) {
+		t.Errorf("fields = %v", fields)
+	}
+}
+
+func TestZapLoggerLevels(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	log := NewZapLogger(zap.New(core))
+	for _, level := range []Level{DebugLevel, InfoLevel, WarnLevel, ErrorLevel, FatalLevel} {
+		log.Log(level, "message", nil)
+	}
+	levels := []zapcore.Level{}
+	for _, entry := range logs.All() {
+		levels = append(levels, entry.Level)
+	}
+	want := []zapcore.Level{zapcore.WarnLevel, zapcore.ErrorLevel, zapcore.FatalLevel}
+	if len(levels) != len(want) {
+		t.Fatalf("levels = %v, want %v", levels, want)
+	}
+	for i := range want {
+		if levels[i] != want[i] {
+			t.Errorf("levels = %v, want %v", levels, want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"sync"
+	"time"
+)
+
+//Ограничения района проверки в метрах, общие для бота, REST API, утилиты и конфигурации
+const (
+	MaxRadius   = 50000
+	MaxAltitude = 5000
+)
+
+//Defaults параметры проверки и уведомлений для пользователей, не указавших свои. Задаются конфигурацией
+//и могут меняться при ее перезагрузке
+type Defaults struct {
+	// радиус проверки в метрах для отчета по геолокации
+	Radius int
+	// высота полёта в метрах, используемая при проверке зон
+	Altitude int
+	// смещения уведомлений относительно времени полёта
+	Notifications []time.Duration
+}
+
+var (
+	defaults = Defaults{
+		Radius:   300,
+		Altitude: 150,
+		Notifications: []time.Duration{-48 * time.Hour, -24 * time.Hour, -12 * time.Hour, -3 * time.Hour,
+			-2 * time.Hour, -1 * time.Hour, 0, 1 * time.Hour, 2 * time.Hour},
+	}
+	defaultsMutex = &sync.RWMutex{}
+)
+
+//SetDefaults ...
+func SetDefaults(d Defaults) {
+	d.Notifications = append([]time.Duration{}, d.Notifications...)
+	defaultsMutex.Lock()
+	defaults = d
+	defaultsMutex.Unlock()
+}
+
+//GetDefaults ...
+func GetDefaults() Defaults {
+	defaultsMutex.RLock()
+	defer defaultsMutex.RUnlock()
+	d := defaults
+	d.Notifications = append([]time.Duration{}, d.Notifications...)
+	return d
+}
+
+//DefaultRadius радиус проверки в метрах для отчета по геолокации
+func DefaultRadius() int {
+	defaultsMutex.RLock()
+	defer defaultsMutex.RUnlock()
+	return defaults.Radius
+}
+
+//DefaultAltitude высота полёта в метрах, используемая при проверке зон, если пользователь ее не указал
+func DefaultAltitude() int {
+	defaultsMutex.RLock()
+	defer defaultsMutex.RUnlock()
+	return defaults.Altitude
+}
+
+//DefaultNotifications смещения уведомлений относительно времени полёта для пользователей, не настроивших свои
+func DefaultNotifications() []time.Duration {
+	return GetDefaults().Notifications
+}
@@ -8,11 +8,93 @@ type FlyPlan struct {
 	FlyDateTime    time.Time       `json:"flyDateTime"`
 	Notifications  []time.Duration `json:"notifications"`
 	IsEveryDayPlan bool            `json:"isEveryDayPlan"`
+	// сохраненное место, из которого создан план
+	PlaceId uint64 `json:"placeId,omitempty"`
+	// токен для ссылки-приглашения /start plan_<token>
+	ShareToken  string       `json:"shareToken,omitempty"`
+	Subscribers []Subscriber `json:"subscribers,omitempty"`
+	// дрон из парка автора, выбранный при планировании
+	Drone *Drone `json:"drone,omitempty"`
+	// условия на момент последней проверки по плану
+	LastCheck *FlightConditions `json:"lastCheck,omitempty"`
+	// предполётный чек-лист, копируется из профиля автора при создании плана
+	Checklist []ChecklistItem `json:"checklist,omitempty"`
 }
+
+//ChecklistItem пункт предполётного чек-листа
+type ChecklistItem struct {
+	Text string `json:"text"`
+	Done bool   `json:"done"`
+}
+
+//NewChecklist ...
+func NewChecklist(items []string) []ChecklistItem {
+	checklist := make([]ChecklistItem, 0, len(items))
+	for _, item := range items {
+		checklist = append(checklist, ChecklistItem{Text: item})
+	}
+	return checklist
+}
+
+//UncheckedItems returns checklist items not marked as done
+func (p FlyPlan) UncheckedItems() []string {
+	items := make([]string, 0)
+	for _, item := range p.Checklist {
+		if !item.Done {
+			items = append(items, item.Text)
+		}
+	}
+	return items
+}
+
+//Subscriber участник команды, получающий уведомления по чужому плану
+type Subscriber struct {
+	UserId int64  `json:"userId"`
+	Name   string `json:"name"`
+}
+
+//IsSubscribed ...
+func (p FlyPlan) IsSubscribed(userId int64) bool {
+	for _, sub := range p.Subscribers {
+		if sub.UserId == userId {
+			return true
+		}
+	}
+	return false
+}
+
+//DefaultFlyDuration продолжительность запланированного полёта
+const DefaultFlyDuration = time.Hour
+
+//Window returns time window of the nearest flight. For every day plans it's the next occurrence of FlyDateTime time
+func (p FlyPlan) Window(now time.Time) (from, to time.Time) {
+	from = p.FlyDateTime
+	if p.IsEveryDayPlan {
+		local := now.In(from.Location())
+		from = time.Date(local.Year(), local.Month(), local.Day(), from.Hour(), from.Minute(), 0, 0, from.Location())
+		if from.Add(DefaultFlyDuration).Before(now) {
+			from = from.AddDate(0, 0, 1)
+		}
+	}
+	return from, from.Add(DefaultFlyDuration)
+}
+
 type FlyData struct {
 	Coordinate Coordinate `json:"coordinate"`
 	Radius     int        `json:"radius"`
-	UserId     int64      `json:"userId"`
+	Altitude   int        `json:"altitude,omitempty"`
+	// автор плана
+	UserId int64 `json:"userId"`
+	// чат для уведомлений, например группа. 0 - личные сообщения автору
+	ChatId int64 `json:"chatId,omitempty"`
+}
+
+//NotifyChatId returns chat for plan notifications
+func (d FlyData) NotifyChatId() int64 {
+	if d.ChatId != 0 {
+		return d.ChatId
+	}
+	return d.UserId
 }
 
 type Coordinate struct {
@@ -47,15 +129,68 @@ type CurrentWeatherData struct {
 }
 
 type Condition struct {
-	DaylightHours       bool     `json:"daylightHours"`
-	HasIntersections    bool     `json:"hasIntersections"`
-	IntoCountryBoundary bool     `json:"intoCountryBoundary"`
-	NearBoundaryZone    bool     `json:"nearBoundaryZone"`
-	Permanent           bool     `json:"permanent"`
-	PolarDayOrNight     bool     `json:"polarDayOrNight"`
-	LocalTimeInLocation string   `json:"localTimeInLocation"`
-	Sunrise             string   `json:"sunrise"`
-	Sunset              string   `json:"sunset"`
-	ActiveZones         []string `json:"activeZones"`
-	InactiveZones       []string `json:"InactiveZones"`
+	DaylightHours       bool   `json:"daylightHours"`
+	HasIntersections    bool   `json:"hasIntersections"`
+	IntoCountryBoundary bool   `json:"intoCountryBoundary"`
+	NearBoundaryZone    bool   `json:"nearBoundaryZone"`
+	Permanent           bool   `json:"permanent"`
+	PolarDayOrNight     bool   `json:"polarDayOrNight"`
+	LocalTimeInLocation string `json:"localTimeInLocation"`
+	Sunrise             string `json:"sunrise"`
+	Sunset              string `json:"sunset"`
+	ActiveZones         []Zone `json:"activeZones"`
+	InactiveZones       []Zone `json:"InactiveZones"`
+}
+
+//ZoneType тип зоны ограничения полётов
+type ZoneType string
+
+const (
+	ZoneUnknown    ZoneType = ""
+	ZoneProhibited ZoneType = "prohibited"
+	ZoneRestricted ZoneType = "restricted"
+	ZoneDangerous  ZoneType = "dangerous"
+	ZoneCTR        ZoneType = "ctr"
+	ZoneTemporary  ZoneType = "temporary"
+)
+
+//Zone подробная информация о зоне ограничения полётов
+type Zone struct {
+	Code string   `json:"code"`
+	Name string   `json:"name"`
+	Type ZoneType `json:"type"`
+	// высоты в метрах, nil - ограничение не указано
+	LowerLimit *int `json:"lowerLimit,omitempty"`
+	UpperLimit *int `json:"upperLimit,omitempty"`
+	// текстовое описание режима работы зоны
+	Schedule      string       `json:"schedule,omitempty"`
+	ActivePeriods []TimePeriod `json:"activePeriods,omitempty"`
+	// орган, выдающий разрешение на использование воздушного пространства
+	Contact string `json:"contact,omitempty"`
+	// внешние границы полигонов зоны
+	Geometry [][]Coordinate `json:"geometry,omitempty"`
+}
+
+type TimePeriod struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+//Overlaps reports whether the period intersects [from, to)
+func (p TimePeriod) Overlaps(from, to time.Time) bool {
+	return p.From.Before(to) && from.Before(p.To)
+}
+
+//ActiveDuring reports whether the zone is active during [from, to) according to its schedule.
+//known is false if the zone has no schedule data
+func (z Zone) ActiveDuring(from, to time.Time) (active bool, known bool) {
+	if len(z.ActivePeriods) == 0 {
+		return false, false
+	}
+	for _, period := range z.ActivePeriods {
+		if period.Overlaps(from, to) {
+			return true, true
+		}
+	}
+	return false, true
 }
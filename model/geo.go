@@ -0,0 +1,43 @@
+package model
+
+import "math"
+
+//earthRadius радиус Земли в метрах (WGS 84)
+const earthRadius = 6378137
+
+//CircleCoordinate returns the point at the given distance (meters) and angle (degrees, counterclockwise from east) from the coordinate
+func CircleCoordinate(coordinate Coordinate, radius int, angleDeg int) Coordinate {
+	angle := float64(angleDeg) * math.Pi * 2 / 360
+	dx := float64(radius) * math.Cos(angle)
+	dy := float64(radius) * math.Sin(angle)
+	return Coordinate{
+		Lat: coordinate.Lat + (180/math.Pi)*(dy/earthRadius),
+		Lng: coordinate.Lng + (180/math.Pi)*(dx/earthRadius)/math.Cos(coordinate.Lat*math.Pi/180),
+	}
+}
+
+//AreaPolygon approximates the circle area with n-gon
+func AreaPolygon(center Coordinate, radius int, n int) []Coordinate {
+	polygon := make([]Coordinate, 0, n)
+	for i := 0; i < n; i++ {
+		polygon = append(polygon, CircleCoordinate(center, radius, 360/n*i))
+	}
+	return polygon
+}
+
+//Rounded rounds the coordinate to 6 decimal places (about 0.1 m), it keeps callback data short
+func (c Coordinate) Rounded() Coordinate {
+	return Coordinate{
+		Lng: math.Round(c.Lng*1e6) / 1e6,
+		Lat: math.Round(c.Lat*1e6) / 1e6,
+	}
+}
+
+//Distance returns great-circle distance between coordinates in meters (haversine formula)
+func Distance(a, b Coordinate) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLat := lat2 - lat1
+	dLng := (b.Lng - a.Lng) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
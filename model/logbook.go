@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+//LogEntry запись в журнале полётов
+type LogEntry struct {
+	EntryId    uint64     `json:"entryId"`
+	UserId     int64      `json:"userId"`
+	FlyId      uint64     `json:"flyId"`
+	Coordinate Coordinate `json:"coordinate"`
+	Start      time.Time  `json:"start"`
+	End        time.Time  `json:"end"`
+	Drone      string     `json:"drone,omitempty"`
+	Notes      string     `json:"notes,omitempty"`
+	// false - пилот еще не подтвердил, что полёт состоялся
+	Confirmed  bool              `json:"confirmed"`
+	Conditions *FlightConditions `json:"conditions,omitempty"`
+}
+
+//Duration ...
+func (e LogEntry) Duration() time.Duration {
+	if e.End.Before(e.Start) {
+		return 0
+	}
+	return e.End.Sub(e.Start)
+}
+
+//FlightConditions снимок погоды и обстановки по зонам на момент последней проверки перед полётом
+type FlightConditions struct {
+	CheckedAt        time.Time    `json:"checkedAt"`
+	Weather          *WeatherData `json:"weather,omitempty"`
+	ActiveZones      []string     `json:"activeZones,omitempty"`
+	NearBoundaryZone bool         `json:"nearBoundaryZone,omitempty"`
+}
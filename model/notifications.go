@@ -0,0 +1,64 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+//Ограничения смещений уведомлений, общие для бота, REST API, утилиты и конфигурации
+const (
+	MaxNotifications      = 10
+	MaxNotificationOffset = 7 * 24 * time.Hour
+)
+
+var (
+	TooManyNotificationsErr     = errors.New("too many notifications")
+	NotificationOffsetErr       = errors.New("invalid notification offset")
+	NotificationOffsetRangeErr  = errors.New("notification offset is out of range")
+	NoNotificationAfterStartErr = errors.New("no notification at or after the flight start")
+)
+
+//ParseNotifications разбор смещений уведомлений вида -24h, -90m, 0, 2h с проверкой NormalizeNotifications
+func ParseNotifications(values []string) ([]time.Duration, error) {
+	if len(values) > MaxNotifications {
+		return nil, TooManyNotificationsErr
+	}
+	offsets := make([]time.Duration, 0, len(values))
+	for _, value := range values {
+		offset, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%w %q", NotificationOffsetErr, value)
+		}
+		offsets = append(offsets, offset)
+	}
+	return NormalizeNotifications(offsets)
+}
+
+//NormalizeNotifications проверка смещений уведомлений. Смещения округляются до минуты, повторы удаляются,
+//результат упорядочен. После последнего уведомления разового плана план удаляется и автору приходит вопрос
+//о состоявшемся полёте, поэтому хотя бы одно уведомление должно приходить в момент начала полёта или позже
+func NormalizeNotifications(offsets []time.Duration) ([]time.Duration, error) {
+	if len(offsets) > MaxNotifications {
+		return nil, TooManyNotificationsErr
+	}
+	seen := map[time.Duration]bool{}
+	result := make([]time.Duration, 0, len(offsets))
+	for _, offset := range offsets {
+		if offset > MaxNotificationOffset || offset < -MaxNotificationOffset {
+			return nil, fmt.Errorf("%w: %s", NotificationOffsetRangeErr, offset)
+		}
+		offset = offset.Truncate(time.Minute)
+		if !seen[offset] {
+			seen[offset] = true
+			result = append(result, offset)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	if len(result) == 0 || result[len(result)-1] < 0 {
+		return nil, NoNotificationAfterStartErr
+	}
+	return result, nil
+}
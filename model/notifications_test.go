@@ -0,0 +1,54 @@
+package model
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseNotifications(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   []time.Duration
+		err    error
+	}{
+		{"single start", []string{"0"}, []time.Duration{0}, nil},
+		{"sorted and deduplicated", []string{" 2h", "-24h", "0s", "-90m", "-1h30m"},
+			[]time.Duration{-24 * time.Hour, -90 * time.Minute, 0, 2 * time.Hour}, nil},
+		{"truncated to minutes", []string{"-30m45s", "90s"}, []time.Duration{-30 * time.Minute, time.Minute}, nil},
+		{"week limits", []string{"-168h", "168h"}, []time.Duration{-MaxNotificationOffset, MaxNotificationOffset}, nil},
+		{"empty", []string{}, nil, NoNotificationAfterStartErr},
+		{"only before start", []string{"-1h", "-5m"}, nil, NoNotificationAfterStartErr},
+		{"seconds before start round to start", []string{"-30s"}, []time.Duration{0}, nil},
+		{"invalid value", []string{"0", "час"}, nil, NotificationOffsetErr},
+		{"number without unit", []string{"15"}, nil, NotificationOffsetErr},
+		{"too early", []string{"-169h", "0"}, nil, NotificationOffsetRangeErr},
+		{"too late", []string{"8d"}, nil, NotificationOffsetErr},
+		{"too late in hours", []string{"200h"}, nil, NotificationOffsetRangeErr},
+		{"too many", []string{"0", "1m", "2m", "3m", "4m", "5m", "6m", "7m", "8m", "9m", "10m"}, nil,
+			TooManyNotificationsErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseNotifications(tt.values)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ParseNotifications(%q) error = %v, want %v", tt.values, err, tt.err)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseNotifications(%q) = %v, want %v", tt.values, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeNotificationsKeepsInput(t *testing.T) {
+	offsets := []time.Duration{time.Hour, -time.Hour}
+	if _, err := NormalizeNotifications(offsets); err != nil {
+		t.Fatal(err)
+	}
+	if offsets[0] != time.Hour || offsets[1] != -time.Hour {
+		t.Errorf("NormalizeNotifications() changed its argument: %v", offsets)
+	}
+}
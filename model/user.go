@@ -0,0 +1,85 @@
+package model
+
+import "time"
+
+//DefaultChecklist предполётный чек-лист для пользователей, не настроивших свой
+var DefaultChecklist = []string{
+	"Аккумуляторы заряжены",
+	"Пропеллеры осмотрены",
+	"Прошивка обновлена",
+	"Регистрация БВС",
+	"Разрешения на полёт",
+	"Проверка воздушного пространства",
+}
+
+//UserProfile данные пользователя
+type UserProfile struct {
+	UserId int64            `json:"userId"`
+	Places []FavouritePlace `json:"places,omitempty"`
+	// nil - используется DefaultChecklist
+	Checklist []string  `json:"checklist"`
+	Fleet     []Drone   `json:"fleet,omitempty"`
+	Operator  *Operator `json:"operator,omitempty"`
+	// смещения уведомлений по планам полётов. nil - используется DefaultNotifications()
+	Notifications []time.Duration `json:"notifications,omitempty"`
+	// секретный токен ссылки на календарь полётов
+	CalendarToken string `json:"calendarToken,omitempty"`
+	// секретный токен доступа к REST API
+	APIToken string `json:"apiToken,omitempty"`
+}
+
+//Operator данные эксплуатанта (внешнего пилота) для документов
+type Operator struct {
+	FullName string `json:"fullName"`
+	Phone    string `json:"phone"`
+	Email    string `json:"email,omitempty"`
+	Address  string `json:"address,omitempty"`
+}
+
+//FavouritePlace сохраненное пользователем место полётов
+type FavouritePlace struct {
+	PlaceId    uint64     `json:"placeId"`
+	Name       string     `json:"name"`
+	Coordinate Coordinate `json:"coordinate"`
+	Radius     int        `json:"radius"`
+	Altitude   int        `json:"altitude"`
+}
+
+//RegistrationWeight масса БВС в граммах, свыше которой требуется постановка на учет
+const RegistrationWeight = 150
+
+//Drone беспилотное воздушное судно из парка пользователя
+type Drone struct {
+	DroneId uint64 `json:"droneId"`
+	Name    string `json:"name"`
+	// взлетная масса в граммах
+	Weight int `json:"weight"`
+	// максимальная скорость ветра, м/с. 0 - не ограничена
+	MaxWind float64 `json:"maxWind,omitempty"`
+	// диапазон рабочих температур, °C. MinTemp == MaxTemp - не ограничен
+	MinTemp            float64 `json:"minTemp,omitempty"`
+	MaxTemp            float64 `json:"maxTemp,omitempty"`
+	RegistrationNumber string  `json:"registrationNumber,omitempty"`
+}
+
+//NeedsRegistration ...
+func (d Drone) NeedsRegistration() bool {
+	return d.Weight > RegistrationWeight
+}
+
+//WeightClass категория по взлетной массе
+func (d Drone) WeightClass() string {
+	switch {
+	case d.Weight <= RegistrationWeight:
+		return "до 150 г"
+	case d.Weight <= 30000:
+		return "от 150 г до 30 кг"
+	default:
+		return "более 30 кг"
+	}
+}
+
+//HasTempLimits ...
+func (d Drone) HasTempLimits() bool {
+	return d.MinTemp != d.MaxTemp
+}